@@ -74,6 +74,11 @@ func NewDaemonRESTServer(daemon ports.DaemonIF) *DaemonRESTServer {
 
 	}
 
+	//entry point to display the scheduled commands with their last and next run times
+	ep = fmt.Sprintf("/%s/schedule", s.monitoredDaemon.GetName())
+	s.router.HandleFunc(ep, s.scheduleLink)
+	s.endpoints = append(s.endpoints, ep)
+
 	//set up the Kubernetes entry points - note: not adding these to the entrypoint list because they would not be called by a user
 	s.router.HandleFunc("/home", s.homeLink)
 	s.router.HandleFunc("/readyz", s.readyzLink)
@@ -136,6 +141,23 @@ func (s *DaemonRESTServer) controlLink(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (s *DaemonRESTServer) scheduleLink(w http.ResponseWriter, r *http.Request) {
+	_ = r
+	var resp = struct {
+		DaemonName string
+		Schedule   []ports.DaemonScheduledJob
+	}{
+		DaemonName: s.monitoredDaemon.GetName(),
+		Schedule:   s.monitoredDaemon.GetSchedule(),
+	}
+	respBytes, err := json.MarshalIndent(&resp, "", "   ")
+	if err == nil {
+		_, _ = fmt.Fprintln(w, string(respBytes))
+	} else {
+		_, _ = fmt.Fprintf(w, "%s\n", err)
+	}
+}
+
 func (s *DaemonRESTServer) controlCmdLink(w http.ResponseWriter, r *http.Request) {
 	rqstPath := r.URL.Path
 	var cmdName string
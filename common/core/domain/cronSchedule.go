@@ -0,0 +1,210 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CronSchedule is a recurring schedule described either by a standard five field cron expression
+// (minute hour day-of-month month day-of-week) or by a fixed interval ("@every 15m")
+type CronSchedule struct {
+	spec     string
+	interval time.Duration
+
+	minutes     uint64
+	hours       uint64
+	daysOfMonth uint64
+	months      uint64
+	daysOfWeek  uint64
+
+	// restricted day fields follow cron semantics - when both are restricted either may match
+	domRestricted bool
+	dowRestricted bool
+}
+
+type cronField struct {
+	min   int
+	max   int
+	names map[string]int
+}
+
+var cronMinuteField = cronField{min: 0, max: 59}
+var cronHourField = cronField{min: 0, max: 23}
+var cronDayOfMonthField = cronField{min: 1, max: 31}
+var cronMonthField = cronField{min: 1, max: 12, names: map[string]int{
+	"JAN": 1, "FEB": 2, "MAR": 3, "APR": 4, "MAY": 5, "JUN": 6,
+	"JUL": 7, "AUG": 8, "SEP": 9, "OCT": 10, "NOV": 11, "DEC": 12,
+}}
+var cronDayOfWeekField = cronField{min: 0, max: 7, names: map[string]int{
+	"SUN": 0, "MON": 1, "TUE": 2, "WED": 3, "THU": 4, "FRI": 5, "SAT": 6,
+}}
+
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// cronSearchLimit bounds the search for the next activation so that impossible expressions (e.g. 30 FEB) terminate
+const cronSearchLimit = 5 * 366 * 24 * time.Hour
+
+// ParseCronSchedule parses a cron expression, a descriptor such as "@daily" or an interval of the form "@every <duration>"
+func ParseCronSchedule(spec string) (*CronSchedule, error) {
+	trimmed := strings.TrimSpace(spec)
+	if trimmed == "" {
+		return nil, errors.New("schedule specification is empty")
+	}
+	sched := CronSchedule{spec: trimmed}
+	if strings.HasPrefix(trimmed, "@every") {
+		dur, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(trimmed, "@every")))
+		if err != nil {
+			return nil, fmt.Errorf("invalid interval in schedule '%s': %s", spec, err)
+		}
+		if dur <= 0 {
+			return nil, fmt.Errorf("interval in schedule '%s' must be positive", spec)
+		}
+		sched.interval = dur
+		return &sched, nil
+	}
+	if expanded, isDescriptor := cronDescriptors[strings.ToLower(trimmed)]; isDescriptor {
+		trimmed = expanded
+	}
+	fields := strings.Fields(trimmed)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression '%s' must have 5 fields; got %d", spec, len(fields))
+	}
+	var err error
+	if sched.minutes, err = cronMinuteField.parse(fields[0]); err != nil {
+		return nil, fmt.Errorf("invalid minute field in '%s': %s", spec, err)
+	}
+	if sched.hours, err = cronHourField.parse(fields[1]); err != nil {
+		return nil, fmt.Errorf("invalid hour field in '%s': %s", spec, err)
+	}
+	if sched.daysOfMonth, err = cronDayOfMonthField.parse(fields[2]); err != nil {
+		return nil, fmt.Errorf("invalid day of month field in '%s': %s", spec, err)
+	}
+	if sched.months, err = cronMonthField.parse(fields[3]); err != nil {
+		return nil, fmt.Errorf("invalid month field in '%s': %s", spec, err)
+	}
+	if sched.daysOfWeek, err = cronDayOfWeekField.parse(fields[4]); err != nil {
+		return nil, fmt.Errorf("invalid day of week field in '%s': %s", spec, err)
+	}
+	// 7 is accepted as an alias for Sunday
+	if sched.daysOfWeek&(1<<7) != 0 {
+		sched.daysOfWeek = (sched.daysOfWeek &^ (1 << 7)) | 1
+	}
+	sched.domRestricted = fields[2] != "*" && fields[2] != "?"
+	sched.dowRestricted = fields[4] != "*" && fields[4] != "?"
+	return &sched, nil
+}
+
+func (f cronField) parse(field string) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if ndx := strings.Index(part, "/"); ndx >= 0 {
+			var err error
+			step, err = strconv.Atoi(part[ndx+1:])
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("bad step in '%s'", part)
+			}
+			part = part[:ndx]
+		}
+		low, high := f.min, f.max
+		if part != "*" && part != "?" {
+			var err error
+			if ndx := strings.Index(part, "-"); ndx >= 0 {
+				if low, err = f.value(part[:ndx]); err != nil {
+					return 0, err
+				}
+				if high, err = f.value(part[ndx+1:]); err != nil {
+					return 0, err
+				}
+			} else {
+				if low, err = f.value(part); err != nil {
+					return 0, err
+				}
+				if step == 1 {
+					high = low
+				}
+			}
+		}
+		if low > high {
+			return 0, fmt.Errorf("range '%s' is reversed", part)
+		}
+		for v := low; v <= high; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func (f cronField) value(token string) (int, error) {
+	if v, isName := f.names[strings.ToUpper(token)]; isName {
+		return v, nil
+	}
+	v, err := strconv.Atoi(token)
+	if err != nil {
+		return 0, fmt.Errorf("'%s' is not a number", token)
+	}
+	if v < f.min || v > f.max {
+		return 0, fmt.Errorf("%d is outside the range %d-%d", v, f.min, f.max)
+	}
+	return v, nil
+}
+
+// String returns the specification the schedule was parsed from
+func (c *CronSchedule) String() string {
+	return c.spec
+}
+
+// IsInterval reports whether the schedule is a fixed interval rather than a calendar based cron expression
+func (c *CronSchedule) IsInterval() bool {
+	return c.interval > 0
+}
+
+// Next returns the first activation time strictly after the given time, or the zero time if there is none
+func (c *CronSchedule) Next(after time.Time) time.Time {
+	if c.interval > 0 {
+		return after.Add(c.interval)
+	}
+	loc := after.Location()
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(cronSearchLimit)
+	for t.Before(limit) {
+		if c.months&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !c.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if c.hours&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+		if c.minutes&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (c *CronSchedule) dayMatches(t time.Time) bool {
+	domMatch := c.daysOfMonth&(1<<uint(t.Day())) != 0
+	dowMatch := c.daysOfWeek&(1<<uint(t.Weekday())) != 0
+	if c.domRestricted && c.dowRestricted {
+		return domMatch || dowMatch
+	}
+	return domMatch && dowMatch
+}
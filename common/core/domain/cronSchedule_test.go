@@ -0,0 +1,81 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_ParseCronSchedule(t *testing.T) {
+	valid := []string{
+		"* * * * *",
+		"0 2 * * *",
+		"*/15 8-17 * * MON-FRI",
+		"0 0 1 JAN,JUL *",
+		"30 6 * * 7",
+		"@daily",
+		"@hourly",
+		"@every 90s",
+	}
+	for _, spec := range valid {
+		if _, err := ParseCronSchedule(spec); err != nil {
+			t.Errorf("Expect '%s' to parse; got %s", spec, err)
+		}
+	}
+
+	invalid := []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"@every",
+		"@every -5m",
+		"@sometimes",
+	}
+	for _, spec := range invalid {
+		if _, err := ParseCronSchedule(spec); err == nil {
+			t.Errorf("Expect '%s' to fail parsing", spec)
+		}
+	}
+}
+
+func Test_CronScheduleNext(t *testing.T) {
+	base := time.Date(2021, 8, 13, 10, 17, 42, 0, time.UTC) // a Friday
+
+	var tests = []struct {
+		spec     string
+		expected time.Time
+	}{
+		{"* * * * *", time.Date(2021, 8, 13, 10, 18, 0, 0, time.UTC)},
+		{"0 2 * * *", time.Date(2021, 8, 14, 2, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2021, 8, 13, 10, 30, 0, 0, time.UTC)},
+		{"0 9 * * MON", time.Date(2021, 8, 16, 9, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2021, 9, 1, 0, 0, 0, 0, time.UTC)},
+		{"30 6 * * 7", time.Date(2021, 8, 15, 6, 30, 0, 0, time.UTC)},
+		{"0 12 1 * SAT", time.Date(2021, 8, 14, 12, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2021, 8, 13, 11, 0, 0, 0, time.UTC)},
+		{"@every 1h30m", time.Date(2021, 8, 13, 11, 47, 42, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		sched, err := ParseCronSchedule(test.spec)
+		if err != nil {
+			t.Fatalf("Expect '%s' to parse; got %s", test.spec, err)
+		}
+		if next := sched.Next(base); !next.Equal(test.expected) {
+			t.Errorf("Expect next run of '%s' to be %s; got %s", test.spec, test.expected, next)
+		}
+	}
+}
+
+func Test_CronScheduleNextImpossible(t *testing.T) {
+	sched, err := ParseCronSchedule("0 0 30 2 *")
+	if err != nil {
+		t.Fatalf("Expect '0 0 30 2 *' to parse; got %s", err)
+	}
+	if next := sched.Next(time.Now()); !next.IsZero() {
+		t.Errorf("Expect no next run for 30 February; got %s", next)
+	}
+}
@@ -13,15 +13,48 @@ type IdTypenameRef struct {
 	TypeName string `json:"__typename" graphql:"__typename"`
 }
 
+type PropertyType string
+
+const (
+	PropertyTypeBound      PropertyType = "BOUND"
+	PropertyTypeCalculated PropertyType = "CALCULATED"
+	PropertyTypeReferenced PropertyType = "REFERENCED"
+)
+
 type Property struct {
 	Id             string            `json:"id"`
 	Name           string            `json:"name"`
+	Type           PropertyType      `json:"type"`
 	Value          string            `json:"value"`
+	Expression     string            `json:"expression"`
+	Address        string            `json:"address"`
+	StoreHistory   bool              `json:"storeHistory"`
+	UnitOfMeasure  UnitOfMeasureRef  `json:"unitOfMeasure"`
 	DataType       string            `json:"dataType"`
 	Equipment      IdNameTypenameRef `json:"equipment"`
 	EquipmentClass IdNameTypenameRef `json:"equipmentClass"`
 }
 
+// EquipmentPropertyOverride replaces attributes of a (usually class defined) property for one equipment - nil
+// attributes are not overridden
+type EquipmentPropertyOverride struct {
+	Id           string            `json:"id"`
+	IsActive     *bool             `json:"isActive"`
+	Property     IdNameTypenameRef `json:"property"`
+	Address      *string           `json:"address"`
+	Expression   *string           `json:"expression"`
+	Value        *string           `json:"value"`
+	StoreHistory *bool             `json:"storeHistory"`
+	Ignore       bool              `json:"ignore"`
+}
+
+type EquipmentPropertiesAndOverrides struct {
+	Id                string                      `json:"id"`
+	Properties        []Property                  `json:"properties"`
+	EquipmentClass    IdNameTypenameRef           `json:"equipmentClass"`
+	PropertyOverrides []EquipmentPropertyOverride `json:"propertyOverrides"`
+}
+
 type EquipmentElementLevel string
 
 type EquipmentStub struct {
@@ -0,0 +1,185 @@
+package domain
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// EquipmentHierarchyNode is one equipment in the ISA-95 tree - managed equipment and the ancestors they roll up to
+type EquipmentHierarchyNode struct {
+	Id             string
+	Name           string
+	EquipmentLevel EquipmentElementLevel
+	ParentId       string
+}
+
+// EquipmentHierarchy is a tree of equipment built from the parent references
+type EquipmentHierarchy struct {
+	nodes    map[string]EquipmentHierarchyNode
+	children map[string][]string
+}
+
+func NewEquipmentHierarchy() *EquipmentHierarchy {
+	return &EquipmentHierarchy{
+		nodes:    map[string]EquipmentHierarchyNode{},
+		children: map[string][]string{},
+	}
+}
+
+// Add inserts (or replaces) a node
+func (h *EquipmentHierarchy) Add(node EquipmentHierarchyNode) {
+	prev, exists := h.nodes[node.Id]
+	if !exists || prev.ParentId != node.ParentId {
+		if exists {
+			siblings := h.children[prev.ParentId]
+			for ndx, id := range siblings {
+				if id == node.Id {
+					h.children[prev.ParentId] = append(siblings[:ndx:ndx], siblings[ndx+1:]...)
+					break
+				}
+			}
+		}
+		h.children[node.ParentId] = append(h.children[node.ParentId], node.Id)
+	}
+	h.nodes[node.Id] = node
+}
+
+// Node returns the node with the given id
+func (h *EquipmentHierarchy) Node(id string) (EquipmentHierarchyNode, bool) {
+	node, exists := h.nodes[id]
+	return node, exists
+}
+
+// MissingParents returns the parent ids referenced by nodes that are not themselves in the hierarchy
+func (h *EquipmentHierarchy) MissingParents() []string {
+	ret := make([]string, 0)
+	for parentId := range h.children {
+		if _, exists := h.nodes[parentId]; !exists && parentId != "" {
+			ret = append(ret, parentId)
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// Parent returns the parent of the node, if it is known
+func (h *EquipmentHierarchy) Parent(id string) (EquipmentHierarchyNode, bool) {
+	node, exists := h.nodes[id]
+	if !exists || node.ParentId == "" {
+		return EquipmentHierarchyNode{}, false
+	}
+	return h.Node(node.ParentId)
+}
+
+// Children returns the direct children of the node, ordered by name
+func (h *EquipmentHierarchy) Children(id string) []EquipmentHierarchyNode {
+	ret := make([]EquipmentHierarchyNode, 0, len(h.children[id]))
+	for _, childId := range h.children[id] {
+		ret = append(ret, h.nodes[childId])
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Name < ret[j].Name
+	})
+	return ret
+}
+
+// Ancestors returns the known ancestors of the node, nearest first
+func (h *EquipmentHierarchy) Ancestors(id string) []EquipmentHierarchyNode {
+	ret := make([]EquipmentHierarchyNode, 0)
+	visited := map[string]bool{id: true}
+	for parent, ok := h.Parent(id); ok && !visited[parent.Id]; parent, ok = h.Parent(parent.Id) {
+		visited[parent.Id] = true
+		ret = append(ret, parent)
+	}
+	return ret
+}
+
+// Descendants returns all nodes below the node, depth first
+func (h *EquipmentHierarchy) Descendants(id string) []EquipmentHierarchyNode {
+	ret := make([]EquipmentHierarchyNode, 0)
+	visited := map[string]bool{id: true}
+	var walk func(parentId string)
+	walk = func(parentId string) {
+		for _, child := range h.Children(parentId) {
+			if !visited[child.Id] {
+				visited[child.Id] = true
+				ret = append(ret, child)
+				walk(child.Id)
+			}
+		}
+	}
+	walk(id)
+	return ret
+}
+
+// Path returns the names from the top of the known hierarchy down to the node, for example "Enterprise/Site/Area/Line"
+func (h *EquipmentHierarchy) Path(id string) string {
+	node, exists := h.nodes[id]
+	if !exists {
+		return ""
+	}
+	ancestors := h.Ancestors(id)
+	names := make([]string, len(ancestors)+1)
+	for ndx, ancestor := range ancestors {
+		names[len(ancestors)-1-ndx] = ancestor.Name
+	}
+	names[len(ancestors)] = node.Name
+	return strings.Join(names, "/")
+}
+
+// FindByPath returns the node at the given path, starting from a node with no known parent
+func (h *EquipmentHierarchy) FindByPath(path string) (EquipmentHierarchyNode, bool) {
+	names := strings.Split(strings.Trim(path, "/"), "/")
+	candidates := make([]EquipmentHierarchyNode, 0)
+	for _, node := range h.nodes {
+		if _, hasParent := h.Parent(node.Id); !hasParent && node.Name == names[0] {
+			candidates = append(candidates, node)
+		}
+	}
+	for _, name := range names[1:] {
+		next := make([]EquipmentHierarchyNode, 0)
+		for _, candidate := range candidates {
+			for _, child := range h.Children(candidate.Id) {
+				if child.Name == name {
+					next = append(next, child)
+				}
+			}
+		}
+		candidates = next
+	}
+	if len(candidates) != 1 {
+		return EquipmentHierarchyNode{}, false
+	}
+	return candidates[0], true
+}
+
+// AggregateValues rolls up a list of values with SUM, AVG, MIN, MAX or COUNT
+func AggregateValues(values []float64, aggregate string) (float64, error) {
+	switch strings.ToUpper(aggregate) {
+	case "COUNT":
+		return float64(len(values)), nil
+	case "SUM", "AVG", "MIN", "MAX":
+	default:
+		return 0, fmt.Errorf("unknown aggregate '%s'", aggregate)
+	}
+	if len(values) == 0 {
+		return 0, fmt.Errorf("no values to aggregate")
+	}
+	sum, min, max := 0.0, math.Inf(1), math.Inf(-1)
+	for _, v := range values {
+		sum += v
+		min = math.Min(min, v)
+		max = math.Max(max, v)
+	}
+	switch strings.ToUpper(aggregate) {
+	case "AVG":
+		return sum / float64(len(values)), nil
+	case "MIN":
+		return min, nil
+	case "MAX":
+		return max, nil
+	}
+	return sum, nil
+}
@@ -0,0 +1,95 @@
+package domain
+
+import (
+	"testing"
+)
+
+func buildTestHierarchy() *EquipmentHierarchy {
+	h := NewEquipmentHierarchy()
+	for _, node := range []EquipmentHierarchyNode{
+		{Id: "cell2", Name: "Cell2", EquipmentLevel: "WorkCell", ParentId: "line1"},
+		{Id: "cell1", Name: "Cell1", EquipmentLevel: "WorkCell", ParentId: "line1"},
+		{Id: "line1", Name: "Line1", EquipmentLevel: "Line", ParentId: "area1"},
+		{Id: "area1", Name: "Packing", EquipmentLevel: "Area", ParentId: "site1"},
+		{Id: "site1", Name: "Sydney", EquipmentLevel: "Site", ParentId: "ent1"},
+		{Id: "ent1", Name: "Acme", EquipmentLevel: "Enterprise"},
+		{Id: "cell3", Name: "Cell1", EquipmentLevel: "WorkCell", ParentId: "line2"},
+		{Id: "line2", Name: "Line2", EquipmentLevel: "Line", ParentId: "area1"},
+	} {
+		h.Add(node)
+	}
+	return h
+}
+
+func nodeIds(nodes []EquipmentHierarchyNode) []string {
+	ret := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		ret = append(ret, node.Id)
+	}
+	return ret
+}
+
+func TestEquipmentHierarchyNavigation(t *testing.T) {
+	h := buildTestHierarchy()
+
+	if parent, ok := h.Parent("cell1"); !ok || parent.Id != "line1" {
+		t.Errorf("Parent(cell1) = %+v, %t; want line1", parent, ok)
+	}
+	if _, ok := h.Parent("ent1"); ok {
+		t.Errorf("Expect no parent for the enterprise")
+	}
+	if ids := nodeIds(h.Children("line1")); len(ids) != 2 || ids[0] != "cell1" || ids[1] != "cell2" {
+		t.Errorf("Children(line1) = %v; want [cell1 cell2]", ids)
+	}
+	if ids := nodeIds(h.Ancestors("cell2")); len(ids) != 4 || ids[0] != "line1" || ids[3] != "ent1" {
+		t.Errorf("Ancestors(cell2) = %v; want [line1 area1 site1 ent1]", ids)
+	}
+	if ids := nodeIds(h.Descendants("area1")); len(ids) != 5 {
+		t.Errorf("Descendants(area1) = %v; want 5 nodes", ids)
+	}
+	if path := h.Path("cell2"); path != "Acme/Sydney/Packing/Line1/Cell2" {
+		t.Errorf("Path(cell2) = %s", path)
+	}
+	if node, ok := h.FindByPath("Acme/Sydney/Packing/Line2/Cell1"); !ok || node.Id != "cell3" {
+		t.Errorf("FindByPath = %+v, %t; want cell3", node, ok)
+	}
+	if _, ok := h.FindByPath("Acme/Sydney/Packing/Line3"); ok {
+		t.Errorf("Expect no node for an unknown path")
+	}
+
+	//re-parenting moves the node
+	h.Add(EquipmentHierarchyNode{Id: "cell2", Name: "Cell2", EquipmentLevel: "WorkCell", ParentId: "line2"})
+	if ids := nodeIds(h.Children("line1")); len(ids) != 1 || ids[0] != "cell1" {
+		t.Errorf("Children(line1) after move = %v; want [cell1]", ids)
+	}
+}
+
+func TestEquipmentHierarchyMissingParents(t *testing.T) {
+	h := NewEquipmentHierarchy()
+	h.Add(EquipmentHierarchyNode{Id: "cell1", Name: "Cell1", ParentId: "line1"})
+	h.Add(EquipmentHierarchyNode{Id: "cell2", Name: "Cell2", ParentId: "line1"})
+	if missing := h.MissingParents(); len(missing) != 1 || missing[0] != "line1" {
+		t.Errorf("MissingParents() = %v; want [line1]", missing)
+	}
+}
+
+func TestAggregateValues(t *testing.T) {
+	values := []float64{4, 1, 7}
+	var tests = []struct {
+		aggregate string
+		expected  float64
+	}{
+		{"SUM", 12}, {"avg", 4}, {"MIN", 1}, {"MAX", 7}, {"COUNT", 3},
+	}
+	for _, test := range tests {
+		if got, err := AggregateValues(values, test.aggregate); err != nil || got != test.expected {
+			t.Errorf("AggregateValues(%s) = %v, %v; want %v", test.aggregate, got, err, test.expected)
+		}
+	}
+	if _, err := AggregateValues(values, "MEDIAN"); err == nil {
+		t.Errorf("Expect an error for an unknown aggregate")
+	}
+	if _, err := AggregateValues(nil, "SUM"); err == nil {
+		t.Errorf("Expect an error when there is nothing to sum")
+	}
+}
@@ -0,0 +1,120 @@
+package domain
+
+import (
+	"fmt"
+	"sort"
+)
+
+// EquipmentModel is a complete equipment model, as loaded from a file when there is no data store
+type EquipmentModel struct {
+	EquipmentClasses []EquipmentClassPropertiesAndParent `json:"equipmentClasses"`
+	Equipment        []EquipmentModelEntry               `json:"equipment"`
+}
+
+// EquipmentModelEntry is one equipment of the model; equipment is active unless isActive is false
+type EquipmentModelEntry struct {
+	Equipment
+	IsActive          *bool                       `json:"isActive"`
+	PropertyOverrides []EquipmentPropertyOverride `json:"propertyOverrides"`
+}
+
+// Active is true unless the entry is explicitly inactive
+func (e EquipmentModelEntry) Active() bool {
+	return e.IsActive == nil || *e.IsActive
+}
+
+// Validate checks that ids and names are unique and that classes and parents refer to entries of the model
+func (m *EquipmentModel) Validate() error {
+	classes := map[string]bool{}
+	for _, cls := range m.EquipmentClasses {
+		if cls.Id == "" {
+			return fmt.Errorf("equipment class with no id")
+		}
+		if classes[cls.Id] {
+			return fmt.Errorf("duplicate equipment class id '%s'", cls.Id)
+		}
+		classes[cls.Id] = true
+	}
+	for _, cls := range m.EquipmentClasses {
+		if cls.Parent.Id != "" && !classes[cls.Parent.Id] {
+			return fmt.Errorf("equipment class '%s' has unknown parent class '%s'", cls.Id, cls.Parent.Id)
+		}
+	}
+	ids := map[string]bool{}
+	names := map[string]bool{}
+	for _, eq := range m.Equipment {
+		if eq.Id == "" || eq.Name == "" {
+			return fmt.Errorf("equipment needs an id and a name (id='%s' name='%s')", eq.Id, eq.Name)
+		}
+		if ids[eq.Id] {
+			return fmt.Errorf("duplicate equipment id '%s'", eq.Id)
+		}
+		if names[eq.Name] {
+			return fmt.Errorf("duplicate equipment name '%s'", eq.Name)
+		}
+		ids[eq.Id] = true
+		names[eq.Name] = true
+	}
+	for _, eq := range m.Equipment {
+		if eq.EquipmentClass.Id != "" && !classes[eq.EquipmentClass.Id] {
+			return fmt.Errorf("equipment '%s' has unknown class '%s'", eq.Name, eq.EquipmentClass.Id)
+		}
+		if eq.Parent.Id != "" && !ids[eq.Parent.Id] {
+			return fmt.Errorf("equipment '%s' has unknown parent '%s'", eq.Name, eq.Parent.Id)
+		}
+	}
+	return nil
+}
+
+// ActiveEquipment returns the active equipment, ordered by name
+func (m *EquipmentModel) ActiveEquipment() []Equipment {
+	ret := make([]Equipment, 0, len(m.Equipment))
+	for _, eq := range m.Equipment {
+		if eq.Active() {
+			ret = append(ret, eq.Equipment)
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Name < ret[j].Name
+	})
+	return ret
+}
+
+// EquipmentById returns the equipment with the given id, active or not
+func (m *EquipmentModel) EquipmentById(eqId string) (Equipment, bool) {
+	for _, eq := range m.Equipment {
+		if eq.Id == eqId {
+			return eq.Equipment, true
+		}
+	}
+	return Equipment{}, false
+}
+
+// ResolvedProperties applies the class chain and overrides of the equipment, as the data store queries do
+func (m *EquipmentModel) ResolvedProperties(eqId string) (map[string]ResolvedProperty, error) {
+	var entry *EquipmentModelEntry
+	for ndx := range m.Equipment {
+		if m.Equipment[ndx].Id == eqId {
+			entry = &m.Equipment[ndx]
+			break
+		}
+	}
+	if entry == nil {
+		return nil, fmt.Errorf("no equipment with id '%s' in the equipment model", eqId)
+	}
+	classById := map[string]EquipmentClassPropertiesAndParent{}
+	for _, cls := range m.EquipmentClasses {
+		classById[cls.Id] = cls
+	}
+	classChain := make([]EquipmentClassPropertiesAndParent, 0)
+	visited := map[string]bool{}
+	for clsId := entry.EquipmentClass.Id; clsId != "" && !visited[clsId]; clsId = classById[clsId].Parent.Id {
+		visited[clsId] = true
+		cls, exists := classById[clsId]
+		if !exists {
+			return nil, fmt.Errorf("equipment '%s' refers to unknown class '%s'", entry.Name, clsId)
+		}
+		classChain = append(classChain, cls)
+	}
+	return ResolveEquipmentProperties(entry.Properties, classChain, entry.PropertyOverrides), nil
+}
@@ -0,0 +1,80 @@
+package domain
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+const testEquipmentModel = `{
+	"equipmentClasses": [
+		{"id": "machine", "properties": [{"id": "m-state", "name": "state", "dataType": "STRING"}]},
+		{"id": "filler", "parent": {"id": "machine"}, "properties": [{"id": "f-count", "name": "count", "dataType": "INT32", "value": "0"}]}
+	],
+	"equipment": [
+		{"id": "line1", "name": "Line1", "equipmentLevel": "Line"},
+		{"id": "filler1", "name": "Filler1", "equipmentLevel": "WorkCell", "equipmentClass": {"id": "filler"}, "parent": {"id": "line1"},
+			"properties": [{"id": "e-speed", "name": "speed", "dataType": "FLOAT64"}],
+			"propertyOverrides": [{"id": "o1", "property": {"id": "f-count"}, "address": "Line1.Filler1.Count"}]},
+		{"id": "filler2", "name": "Filler2", "isActive": false, "equipmentClass": {"id": "filler"}, "parent": {"id": "line1"}}
+	]
+}`
+
+func loadTestEquipmentModel(t *testing.T) *EquipmentModel {
+	var model EquipmentModel
+	if err := json.Unmarshal([]byte(testEquipmentModel), &model); err != nil {
+		t.Fatalf("Failed to read test model: %s", err)
+	}
+	return &model
+}
+
+func TestEquipmentModel(t *testing.T) {
+	model := loadTestEquipmentModel(t)
+	if err := model.Validate(); err != nil {
+		t.Fatalf("Expect the test model to be valid; got %s", err)
+	}
+	active := model.ActiveEquipment()
+	if len(active) != 2 || active[0].Name != "Filler1" || active[1].Name != "Line1" {
+		t.Errorf("ActiveEquipment() = %+v; want Filler1 and Line1", active)
+	}
+	if eq, ok := model.EquipmentById("filler2"); !ok || eq.Name != "Filler2" {
+		t.Errorf("Expect inactive equipment to be found by id")
+	}
+
+	props, err := model.ResolvedProperties("filler1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(props) != 3 {
+		t.Errorf("Expect speed, count and state; got %+v", props)
+	}
+	if p := props["count"]; p.Address != "Line1.Filler1.Count" || p.Sources.Address != PropertySourceOverride {
+		t.Errorf("Expect the override to apply to count; got %+v", p)
+	}
+	if p := props["state"]; p.Sources.ClassId != "machine" {
+		t.Errorf("Expect state to be inherited from machine; got %+v", p)
+	}
+	if _, err := model.ResolvedProperties("nope"); err == nil {
+		t.Errorf("Expect an error for unknown equipment")
+	}
+}
+
+func TestEquipmentModelValidate(t *testing.T) {
+	var tests = []struct {
+		name   string
+		change func(m *EquipmentModel)
+	}{
+		{"duplicate id", func(m *EquipmentModel) { m.Equipment[1].Id = "line1" }},
+		{"duplicate name", func(m *EquipmentModel) { m.Equipment[1].Name = "Line1" }},
+		{"unknown parent", func(m *EquipmentModel) { m.Equipment[1].Parent.Id = "line9" }},
+		{"unknown class", func(m *EquipmentModel) { m.Equipment[1].EquipmentClass.Id = "mixer" }},
+		{"unknown parent class", func(m *EquipmentModel) { m.EquipmentClasses[1].Parent.Id = "mixer" }},
+		{"missing name", func(m *EquipmentModel) { m.Equipment[0].Name = "" }},
+	}
+	for _, test := range tests {
+		model := loadTestEquipmentModel(t)
+		test.change(model)
+		if err := model.Validate(); err == nil {
+			t.Errorf("Expect an error for %s", test.name)
+		}
+	}
+}
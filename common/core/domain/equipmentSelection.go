@@ -0,0 +1,240 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// SelectableEquipment is the equipment record with the fields selection rules can match on
+type SelectableEquipment struct {
+	Equipment
+	IsActive     bool   `json:"isActive"`
+	DataProvider string `json:"dataProvider"` // a DataProvider enum value of the schema, such as MQTT
+}
+
+// EquipmentClassAndParent is enough of an equipment class to walk up to its parent classes
+type EquipmentClassAndParent struct {
+	Id     string            `json:"id"`
+	Name   string            `json:"name"`
+	Parent IdNameTypenameRef `json:"parent"`
+}
+
+// EquipmentSelectionCandidate is one equipment offered to the selection rules
+type EquipmentSelectionCandidate struct {
+	Equipment    Equipment
+	Path         string
+	Classes      []IdNameTypenameRef // the class of the equipment, then its parent classes, nearest first
+	DataProvider string
+	Properties   map[string]string // resolved property values, only filled when a rule tests properties
+}
+
+// EquipmentSelectionRule selects equipment.  The conditions set on one rule must all match; And, Or and Not
+// combine rules, for example
+//
+//	{"or": [{"subtree": "PlantA/Area2"}, {"class": "Filler", "property": "line", "value": "L3"}]}
+type EquipmentSelectionRule struct {
+	And []EquipmentSelectionRule `json:"and,omitempty"`
+	Or  []EquipmentSelectionRule `json:"or,omitempty"`
+	Not *EquipmentSelectionRule  `json:"not,omitempty"`
+
+	Level        string  `json:"level,omitempty"`        // equipment level, for example "Line"
+	Class        string  `json:"class,omitempty"`        // class name or id, including subclasses
+	Subtree      string  `json:"subtree,omitempty"`      // path of an equipment; matches it and everything below
+	Property     string  `json:"property,omitempty"`     // the equipment has the property...
+	Value        *string `json:"value,omitempty"`        // ...with this value
+	DataProvider string  `json:"dataProvider,omitempty"` // the data provider, for example "MQTT"
+	Name         string  `json:"name,omitempty"`         // exact name or a glob such as "Filler*"
+	NameRegex    string  `json:"nameRegex,omitempty"`
+}
+
+// EquipmentSelector is a parsed and checked selection rule
+type EquipmentSelector struct {
+	rule      EquipmentSelectionRule
+	nameRegex *regexp.Regexp
+	children  []*EquipmentSelector
+	not       *EquipmentSelector
+}
+
+// ParseEquipmentSelectionRule reads a rule from its JSON form and compiles it
+func ParseEquipmentSelectionRule(text string) (*EquipmentSelector, error) {
+	var rule EquipmentSelectionRule
+	if err := json.Unmarshal([]byte(text), &rule); err != nil {
+		return nil, fmt.Errorf("bad equipment selection rule: %s", err)
+	}
+	return CompileEquipmentSelectionRule(rule)
+}
+
+// CompileEquipmentSelectionRule checks the rule and its patterns
+func CompileEquipmentSelectionRule(rule EquipmentSelectionRule) (*EquipmentSelector, error) {
+	sel := &EquipmentSelector{rule: rule}
+	if len(rule.And) > 0 && len(rule.Or) > 0 {
+		return nil, fmt.Errorf("an equipment selection rule can't have both 'and' and 'or'")
+	}
+	if rule.Value != nil && rule.Property == "" {
+		return nil, fmt.Errorf("an equipment selection rule with a 'value' needs a 'property'")
+	}
+	if rule.Name != "" {
+		if _, err := path.Match(rule.Name, ""); err != nil {
+			return nil, fmt.Errorf("bad equipment name pattern '%s': %s", rule.Name, err)
+		}
+	}
+	if rule.NameRegex != "" {
+		var err error
+		if sel.nameRegex, err = regexp.Compile(rule.NameRegex); err != nil {
+			return nil, fmt.Errorf("bad equipment name regex '%s': %s", rule.NameRegex, err)
+		}
+	}
+	for _, child := range append(append([]EquipmentSelectionRule{}, rule.And...), rule.Or...) {
+		childSel, err := CompileEquipmentSelectionRule(child)
+		if err != nil {
+			return nil, err
+		}
+		sel.children = append(sel.children, childSel)
+	}
+	if rule.Not != nil {
+		var err error
+		if sel.not, err = CompileEquipmentSelectionRule(*rule.Not); err != nil {
+			return nil, err
+		}
+	}
+	return sel, nil
+}
+
+// Matches is true when the candidate satisfies the rule
+func (s *EquipmentSelector) Matches(candidate EquipmentSelectionCandidate) bool {
+	if !s.matchesConditions(candidate) {
+		return false
+	}
+	if s.not != nil && s.not.Matches(candidate) {
+		return false
+	}
+	if len(s.rule.Or) > 0 {
+		for _, child := range s.children {
+			if child.Matches(candidate) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, child := range s.children {
+		if !child.Matches(candidate) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *EquipmentSelector) matchesConditions(c EquipmentSelectionCandidate) bool {
+	r := s.rule
+	if r.Level != "" && !strings.EqualFold(r.Level, string(c.Equipment.EquipmentLevel)) {
+		return false
+	}
+	if r.Class != "" && !c.isOfClass(r.Class) {
+		return false
+	}
+	if r.Subtree != "" && !isInSubtree(c.Path, r.Subtree) {
+		return false
+	}
+	if r.Property != "" {
+		val, exists := c.Properties[r.Property]
+		if !exists || (r.Value != nil && val != *r.Value) {
+			return false
+		}
+	}
+	if r.DataProvider != "" && r.DataProvider != c.DataProvider {
+		return false
+	}
+	if r.Name != "" {
+		if matched, _ := path.Match(r.Name, c.Equipment.Name); !matched {
+			return false
+		}
+	}
+	if s.nameRegex != nil && !s.nameRegex.MatchString(c.Equipment.Name) {
+		return false
+	}
+	return true
+}
+
+func (c EquipmentSelectionCandidate) isOfClass(class string) bool {
+	for _, cls := range c.Classes {
+		if cls.Name == class || cls.Id == class {
+			return true
+		}
+	}
+	return false
+}
+
+func isInSubtree(eqPath string, subtree string) bool {
+	subtree = strings.Trim(subtree, "/")
+	return eqPath == subtree || strings.HasPrefix(eqPath, subtree+"/")
+}
+
+// NeedsProperties is true when the rule tests property values, which are expensive to fetch
+func (s *EquipmentSelector) NeedsProperties() bool {
+	if s.rule.Property != "" || (s.not != nil && s.not.NeedsProperties()) {
+		return true
+	}
+	for _, child := range s.children {
+		if child.NeedsProperties() {
+			return true
+		}
+	}
+	return false
+}
+
+// Unresolved lists the names, classes, subtrees and data providers in the rule that match none of the candidates,
+// which usually means a typo in the configuration
+func (s *EquipmentSelector) Unresolved(candidates []EquipmentSelectionCandidate) []string {
+	found := map[string]bool{}
+	for _, c := range candidates {
+		found["name '"+c.Equipment.Name+"'"] = true
+		found["data provider '"+c.DataProvider+"'"] = true
+		for _, cls := range c.Classes {
+			found["class '"+cls.Name+"'"] = true
+			found["class '"+cls.Id+"'"] = true
+		}
+		pathNames := strings.Split(c.Path, "/")
+		for n := 1; n <= len(pathNames); n++ {
+			found["subtree '"+strings.Join(pathNames[:n], "/")+"'"] = true
+		}
+	}
+	missing := map[string]bool{}
+	s.collectReferences(func(ref string) {
+		if !found[ref] {
+			missing[ref] = true
+		}
+	})
+	ret := make([]string, 0, len(missing))
+	for ref := range missing {
+		ret = append(ret, ref)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func (s *EquipmentSelector) collectReferences(visit func(ref string)) {
+	r := s.rule
+	//a glob that matches nothing is not reported - it may be waiting for equipment to be added
+	if r.Name != "" && !strings.ContainsAny(r.Name, "*?[") {
+		visit("name '" + r.Name + "'")
+	}
+	if r.Class != "" {
+		visit("class '" + r.Class + "'")
+	}
+	if r.Subtree != "" {
+		visit("subtree '" + strings.Trim(r.Subtree, "/") + "'")
+	}
+	if r.DataProvider != "" {
+		visit("data provider '" + r.DataProvider + "'")
+	}
+	for _, child := range s.children {
+		child.collectReferences(visit)
+	}
+	if s.not != nil {
+		s.not.collectReferences(visit)
+	}
+}
@@ -0,0 +1,92 @@
+package domain
+
+import (
+	"reflect"
+	"testing"
+)
+
+func selectionCandidates() []EquipmentSelectionCandidate {
+	filler := IdNameTypenameRef{Id: "c-filler", Name: "Filler"}
+	rotaryFiller := IdNameTypenameRef{Id: "c-rotary", Name: "RotaryFiller"}
+	capper := IdNameTypenameRef{Id: "c-capper", Name: "Capper"}
+	return []EquipmentSelectionCandidate{
+		{Equipment: Equipment{Id: "1", Name: "Filler1", EquipmentLevel: "WorkCell"}, Path: "Acme/PlantA/Area2/Line1/Filler1",
+			Classes:    []IdNameTypenameRef{filler},
+			Properties: map[string]string{"line": "L1"}},
+		{Equipment: Equipment{Id: "2", Name: "Filler2", EquipmentLevel: "WorkCell"}, Path: "Acme/PlantA/Area3/Line7/Filler2",
+			Classes: []IdNameTypenameRef{rotaryFiller, filler}, DataProvider: "MQTT",
+			Properties: map[string]string{"line": "L7"}},
+		{Equipment: Equipment{Id: "3", Name: "Capper1", EquipmentLevel: "WorkCell"}, Path: "Acme/PlantA/Area2/Line1/Capper1",
+			Classes: []IdNameTypenameRef{capper}},
+		{Equipment: Equipment{Id: "4", Name: "Line1", EquipmentLevel: "Line"}, Path: "Acme/PlantA/Area2/Line1"},
+	}
+}
+
+func selectedIds(t *testing.T, rule string) []string {
+	sel, err := ParseEquipmentSelectionRule(rule)
+	if err != nil {
+		t.Fatalf("Failed to parse rule %s: %s", rule, err)
+	}
+	ret := make([]string, 0)
+	for _, c := range selectionCandidates() {
+		if sel.Matches(c) {
+			ret = append(ret, c.Equipment.Id)
+		}
+	}
+	return ret
+}
+
+func TestEquipmentSelectionRules(t *testing.T) {
+	var tests = []struct {
+		rule     string
+		expected []string
+	}{
+		{`{"class": "Filler"}`, []string{"1", "2"}},
+		{`{"class": "c-rotary"}`, []string{"2"}},
+		{`{"subtree": "Acme/PlantA/Area2"}`, []string{"1", "3", "4"}},
+		{`{"subtree": "Acme/PlantA/Area2", "level": "workcell"}`, []string{"1", "3"}},
+		{`{"property": "line"}`, []string{"1", "2"}},
+		{`{"property": "line", "value": "L7"}`, []string{"2"}},
+		{`{"dataProvider": "MQTT"}`, []string{"2"}},
+		{`{"name": "*1"}`, []string{"1", "3", "4"}},
+		{`{"nameRegex": "^(Filler|Capper)\\d$"}`, []string{"1", "2", "3"}},
+		{`{"or": [{"name": "Line1"}, {"class": "Capper"}]}`, []string{"3", "4"}},
+		{`{"and": [{"class": "Filler"}, {"not": {"dataProvider": "MQTT"}}]}`, []string{"1"}},
+		{`{"subtree": "Acme/PlantA", "not": {"level": "Line"}}`, []string{"1", "2", "3"}},
+	}
+	for _, test := range tests {
+		if got := selectedIds(t, test.rule); !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("Rule %s selected %v; want %v", test.rule, got, test.expected)
+		}
+	}
+}
+
+func TestEquipmentSelectionRuleErrors(t *testing.T) {
+	for _, rule := range []string{
+		`{"nameRegex": "("}`,
+		`{"name": "[a"}`,
+		`{"value": "L1"}`,
+		`{"and": [{"name": "a"}], "or": [{"name": "b"}]}`,
+		`{"or": [{"nameRegex": "("}]}`,
+		`not json`,
+	} {
+		if _, err := ParseEquipmentSelectionRule(rule); err == nil {
+			t.Errorf("Expect an error for rule %s", rule)
+		}
+	}
+}
+
+func TestEquipmentSelectionUnresolved(t *testing.T) {
+	sel, err := ParseEquipmentSelectionRule(`{"or": [{"name": "Filler1"}, {"name": "Filler9"}, {"name": "Mixer*"},
+		{"class": "Mixer"}, {"subtree": "Acme/PlantA/Area2/"}, {"subtree": "Acme/PlantB"}, {"dataProvider": "MQTT"}, {"dataProvider": "OPCUA"}]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"class 'Mixer'", "data provider 'OPCUA'", "name 'Filler9'", "subtree 'Acme/PlantB'"}
+	if got := sel.Unresolved(selectionCandidates()); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Unresolved() = %v; want %v", got, expected)
+	}
+	if sel.NeedsProperties() {
+		t.Errorf("Expect no properties needed")
+	}
+}
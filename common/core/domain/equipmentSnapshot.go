@@ -0,0 +1,58 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+// EquipmentSnapshot is the persisted state of a managed equipment, used to warm start it after a restart
+type EquipmentSnapshot struct {
+	EquipmentId   string                     `json:"equipmentId"`
+	EquipmentName string                     `json:"equipmentName"`
+	Taken         time.Time                  `json:"taken"`
+	Properties    []PropertySnapshot         `json:"properties"`
+	Events        []EquipmentEventDescriptor `json:"events"`
+	OpenEvents    []EventLog                 `json:"openEvents,omitempty"`
+}
+
+// PropertySnapshot holds a property value in its string form, so it is restored with the same conversion used
+// for values read from the data store
+type PropertySnapshot struct {
+	Name       string      `json:"name"`
+	DataType   string      `json:"dataType"`
+	Value      *string     `json:"value"`
+	Quality    *TagQuality `json:"quality,omitempty"`
+	LastUpdate time.Time   `json:"lastUpdate"`
+}
+
+// NewPropertySnapshot captures the value, quality and update time of a property
+func NewPropertySnapshot(prop EquipmentPropertyDescriptor) PropertySnapshot {
+	quality := prop.Quality
+	snap := PropertySnapshot{
+		Name:       prop.Name,
+		DataType:   prop.DataType,
+		Quality:    &quality,
+		LastUpdate: prop.LastUpdate,
+	}
+	if prop.Value != nil {
+		str := fmt.Sprintf("%v", prop.Value)
+		snap.Value = &str
+	}
+	return snap
+}
+
+// TypedValue converts the saved value back to the type of the property (nil if the property had no value)
+func (p PropertySnapshot) TypedValue() (interface{}, error) {
+	if p.Value == nil {
+		return nil, nil
+	}
+	return ConvertPropertyValueStringToTypedValue(p.DataType, *p.Value)
+}
+
+// TagQuality is the saved quality of the value; snapshots taken before qualities were kept count as Good
+func (p PropertySnapshot) TagQuality() TagQuality {
+	if p.Quality == nil {
+		return Good
+	}
+	return *p.Quality
+}
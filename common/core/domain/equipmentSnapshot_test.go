@@ -0,0 +1,55 @@
+package domain
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestPropertySnapshotRoundTrip(t *testing.T) {
+	updated := time.Date(2021, 8, 13, 10, 17, 42, 0, time.UTC)
+	var tests = []struct {
+		prop     EquipmentPropertyDescriptor
+		expected interface{}
+	}{
+		{EquipmentPropertyDescriptor{Name: "speed", DataType: "FLOAT64", Value: 12.5, Quality: Good, LastUpdate: updated}, 12.5},
+		{EquipmentPropertyDescriptor{Name: "level", DataType: "FLOAT64", Value: 0.5, Quality: Uncertain, LastUpdate: updated}, 0.5},
+		{EquipmentPropertyDescriptor{Name: "running", DataType: "BOOL", Value: true, LastUpdate: updated}, true},
+		{EquipmentPropertyDescriptor{Name: "product", DataType: DataTypeString, Value: "ABC-1", LastUpdate: updated}, "ABC-1"},
+		{EquipmentPropertyDescriptor{Name: "count", DataType: "INT32", Value: int64(42), LastUpdate: updated}, int64(42)},
+		{EquipmentPropertyDescriptor{Name: "unset", DataType: "FLOAT64", Value: nil}, nil},
+	}
+	for _, test := range tests {
+		raw, err := json.Marshal(NewPropertySnapshot(test.prop))
+		if err != nil {
+			t.Fatalf("Failed to marshal snapshot of %s: %s", test.prop.Name, err)
+		}
+		var snap PropertySnapshot
+		if err = json.Unmarshal(raw, &snap); err != nil {
+			t.Fatalf("Failed to unmarshal snapshot of %s: %s", test.prop.Name, err)
+		}
+		val, err := snap.TypedValue()
+		if err != nil {
+			t.Errorf("Failed to restore value of %s: %s", test.prop.Name, err)
+		}
+		if val != test.expected {
+			t.Errorf("Restored %s = %v (%T); want %v (%T)", test.prop.Name, val, val, test.expected, test.expected)
+		}
+		if snap.TagQuality() != test.prop.Quality {
+			t.Errorf("Restored %s quality = %s; want %s", test.prop.Name, snap.TagQuality(), test.prop.Quality)
+		}
+		if !snap.LastUpdate.Equal(test.prop.LastUpdate) {
+			t.Errorf("Restored %s LastUpdate = %s; want %s", test.prop.Name, snap.LastUpdate, test.prop.LastUpdate)
+		}
+	}
+}
+
+func TestPropertySnapshotWithoutQuality(t *testing.T) {
+	var snap PropertySnapshot
+	if err := json.Unmarshal([]byte(`{"name":"speed","dataType":"FLOAT64","value":"12.5"}`), &snap); err != nil {
+		t.Fatalf("Failed to unmarshal snapshot: %s", err)
+	}
+	if snap.TagQuality() != Good {
+		t.Errorf("Expect a snapshot without a quality to restore as Good; got %s", snap.TagQuality())
+	}
+}
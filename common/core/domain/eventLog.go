@@ -0,0 +1,115 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+// EventLog is an extended equipment event.  It opens when the trigger of its EventLog event definition fires and
+// closes when the end condition is met - the end expression configured for the definition, or the trigger no longer
+// being true.
+type EventLog struct {
+	LocalId           string                 `json:"localId"` // assigned when the event opens, before the data store has given it an id
+	Id                string                 `json:"id,omitempty"`
+	EventDefinitionId string                 `json:"eventDefinitionId"`
+	Name              string                 `json:"name"`
+	EquipmentId       string                 `json:"equipmentId"`
+	EndExpression     string                 `json:"endExpression,omitempty"`
+	StartDateTime     time.Time              `json:"startDateTime"`
+	EndDateTime       *time.Time             `json:"endDateTime,omitempty"`
+	Duration          float64                `json:"duration"` // seconds, set when the event closes
+	ReasonCode        string                 `json:"reasonCode,omitempty"`
+	ReasonText        string                 `json:"reasonText,omitempty"`
+	ReasonCategory    string                 `json:"reasonCategoryCode,omitempty"`
+	ReasonValue       *float64               `json:"reasonValue,omitempty"`
+	ReasonUoM         string                 `json:"reasonUoM,omitempty"` // unit of measure code of the reason value
+	Comments          string                 `json:"comments,omitempty"`
+	JobResponseId     string                 `json:"jobResponseId,omitempty"`
+	JobLocalId        string                 `json:"jobLocalId,omitempty"` // the running job, until it has a data store id
+	Params            map[string]interface{} `json:"params,omitempty"`
+}
+
+// Payload fields with these names fill in the matching fields of the EventLog
+const (
+	EVENTLOG_REASON_CODE  = "reasonCode"
+	EVENTLOG_REASON_TEXT  = "reasonText"
+	EVENTLOG_REASON_CAT   = "reasonCategoryCode"
+	EVENTLOG_REASON_VALUE = "reasonValue"
+	EVENTLOG_REASON_UOM   = "reasonUoM"
+	EVENTLOG_COMMENTS     = "comments"
+	EVENTLOG_JOB_RESPONSE = "jobResponse"
+)
+
+// NewEventLog opens an event for the definition at the given time, taking the reason and job response from the
+// computed payload.  An empty end expression ends the event when its trigger is no longer true.
+func NewEventLog(localId string, def *EventDefinition, endExpression string, equipmentId string, start time.Time, payload map[string]interface{}) EventLog {
+	log := EventLog{
+		LocalId:           localId,
+		EventDefinitionId: def.Id,
+		Name:              def.Name,
+		EquipmentId:       equipmentId,
+		EndExpression:     endExpression,
+		StartDateTime:     start,
+		Params:            payload,
+	}
+	log.ApplyPayload(payload)
+	return log
+}
+
+// ApplyPayload sets the reason, comments and job response from payload fields of the same names
+func (e *EventLog) ApplyPayload(payload map[string]interface{}) {
+	text := func(key string) string {
+		if val, exists := payload[key]; exists && val != nil {
+			return fmt.Sprintf("%v", val)
+		}
+		return ""
+	}
+	if code := text(EVENTLOG_REASON_CODE); code != "" {
+		e.ReasonCode = code
+	}
+	if reason := text(EVENTLOG_REASON_TEXT); reason != "" {
+		e.ReasonText = reason
+	}
+	if category := text(EVENTLOG_REASON_CAT); category != "" {
+		e.ReasonCategory = category
+	}
+	if num, ok := ValueAsFloat64(payload[EVENTLOG_REASON_VALUE]); ok {
+		e.ReasonValue = &num
+	}
+	if uom := text(EVENTLOG_REASON_UOM); uom != "" {
+		e.ReasonUoM = uom
+	}
+	if comments := text(EVENTLOG_COMMENTS); comments != "" {
+		e.Comments = comments
+	}
+	if job := text(EVENTLOG_JOB_RESPONSE); job != "" {
+		e.JobResponseId = job
+	}
+}
+
+// ApplyReason replaces the raw code of the event with the resolved reason.  The standard value and its unit are
+// only used when the payload gave no reason value.
+func (e *EventLog) ApplyReason(reason ResolvedReason) {
+	e.ReasonCode = reason.Reason.Label
+	e.ReasonText = reason.Reason.Text
+	e.ReasonCategory = reason.CategoryCode
+	if e.ReasonValue == nil && reason.StandardValue != nil {
+		value := *reason.StandardValue
+		e.ReasonValue = &value
+		e.ReasonUoM = reason.Reason.ValueUoM.Code
+	}
+}
+
+// IsOpen is true until the event is closed
+func (e EventLog) IsOpen() bool {
+	return e.EndDateTime == nil
+}
+
+// Close ends the event and computes its duration; an end before the start gives a zero duration
+func (e *EventLog) Close(end time.Time) {
+	e.EndDateTime = &end
+	e.Duration = end.Sub(e.StartDateTime).Seconds()
+	if e.Duration < 0 {
+		e.Duration = 0
+	}
+}
@@ -0,0 +1,33 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEventLogLifecycle(t *testing.T) {
+	def := &EventDefinition{Id: "def1", Name: "Stopped", MessageClass: "EventLog"}
+	start := time.Date(2022, 5, 1, 10, 0, 0, 0, time.UTC)
+	payload := map[string]interface{}{
+		EVENTLOG_REASON_CODE:  "rc-jam",
+		EVENTLOG_REASON_VALUE: "12.5",
+		EVENTLOG_JOB_RESPONSE: "job7",
+		"speed":               0,
+	}
+	log := NewEventLog("local1", def, "state == \"Running\"", "eq1", start, payload)
+	if !log.IsOpen() || log.Name != "Stopped" || log.EndExpression != "state == \"Running\"" {
+		t.Errorf("Unexpected new event log: %+v", log)
+	}
+	if log.ReasonCode != "rc-jam" || log.ReasonValue == nil || *log.ReasonValue != 12.5 || log.JobResponseId != "job7" {
+		t.Errorf("Expect the reason and job response from the payload; got %+v", log)
+	}
+
+	log.Close(start.Add(90 * time.Second))
+	if log.IsOpen() || log.Duration != 90 {
+		t.Errorf("Expect a closed event of 90s; got %+v", log)
+	}
+	log.Close(start.Add(-time.Second))
+	if log.Duration != 0 {
+		t.Errorf("Expect no negative durations; got %v", log.Duration)
+	}
+}
@@ -0,0 +1,27 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+// HistorianPoint is one point written to the historian
+type HistorianPoint struct {
+	Measurement string
+	Tags        map[string]string
+	Fields      map[string]interface{}
+	Timestamp   time.Time
+}
+
+// HistorianFieldValue is the value converted to the data type of its property, so the historian keeps numbers and
+// booleans as such rather than as text.  A value that cannot be converted is kept as text; nil has no field value.
+func HistorianFieldValue(dataType string, value interface{}) (interface{}, bool) {
+	if value == nil {
+		return nil, false
+	}
+	typed, err := ConvertPropertyValueStringToTypedValue(dataType, value)
+	if err != nil || typed == nil {
+		return fmt.Sprintf("%v", value), true
+	}
+	return typed, true
+}
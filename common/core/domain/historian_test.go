@@ -0,0 +1,27 @@
+package domain
+
+import "testing"
+
+func TestHistorianFieldValue(t *testing.T) {
+	for _, tc := range []struct {
+		dataType string
+		value    interface{}
+		expected interface{}
+	}{
+		{"FLOAT64", "12.5", 12.5},
+		{"INT32", "42", int64(42)},
+		{"BOOL", "true", true},
+		{DataTypeString, "ABC-1", "ABC-1"},
+		{"FLOAT64", 7.25, 7.25},
+		{"FLOAT64", "not a number", "not a number"},
+		{"DATETIME", "2021-08-13T10:17:42Z", "2021-08-13T10:17:42Z"},
+	} {
+		val, ok := HistorianFieldValue(tc.dataType, tc.value)
+		if !ok || val != tc.expected {
+			t.Errorf("Expect %v as %s to be %v (%T); got %v (%T) %v", tc.value, tc.dataType, tc.expected, tc.expected, val, val, ok)
+		}
+	}
+	if _, ok := HistorianFieldValue("FLOAT64", nil); ok {
+		t.Errorf("Expect no field value for nil")
+	}
+}
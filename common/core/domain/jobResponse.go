@@ -0,0 +1,56 @@
+package domain
+
+import (
+	"strings"
+	"time"
+)
+
+// JobResponse is a job, usually a production order, run on an equipment.  EventLog and QuantityLog records are
+// linked to the job running when they happened.
+type JobResponse struct {
+	LocalId        string     `json:"localId"` // assigned when the job starts, before the data store has given it an id
+	Id             string     `json:"id,omitempty"`
+	EquipmentId    string     `json:"equipmentId"`
+	JobOrder       string     `json:"jobOrder,omitempty"` // name of the job order, if the start event gave one
+	StartDateTime  time.Time  `json:"startDateTime"`
+	EndDateTime    *time.Time `json:"endDateTime,omitempty"`
+	ActualDuration float64    `json:"actualDuration"` // seconds, set when the job ends
+}
+
+// Payload fields of order events.  An event of the JobResponse message class starts a job unless its JOB_STATE
+// field is JOB_STATE_END, which ends the running job.
+const (
+	JOB_ORDER     = "jobOrder"
+	JOB_STATE     = "jobState"
+	JOB_STATE_END = "End"
+)
+
+// NewJobResponse starts a job on the equipment
+func NewJobResponse(localId string, equipmentId string, jobOrder string, start time.Time) JobResponse {
+	return JobResponse{
+		LocalId:       localId,
+		EquipmentId:   equipmentId,
+		JobOrder:      jobOrder,
+		StartDateTime: start,
+	}
+}
+
+// IsOpen is true until the job ends
+func (j JobResponse) IsOpen() bool {
+	return j.EndDateTime == nil
+}
+
+// End ends the job and computes its duration; an end before the start gives a zero duration
+func (j *JobResponse) End(end time.Time) {
+	j.EndDateTime = &end
+	j.ActualDuration = end.Sub(j.StartDateTime).Seconds()
+	if j.ActualDuration < 0 {
+		j.ActualDuration = 0
+	}
+}
+
+// IsJobEndPayload is true when the payload of a JobResponse event asks for the running job to end
+func IsJobEndPayload(payload map[string]interface{}) bool {
+	state, isString := payload[JOB_STATE].(string)
+	return isString && strings.EqualFold(strings.TrimSpace(state), JOB_STATE_END)
+}
@@ -0,0 +1,31 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestJobResponseLifecycle(t *testing.T) {
+	start := time.Date(2022, 5, 1, 6, 0, 0, 0, time.UTC)
+	job := NewJobResponse("local1", "eq1", "WO-1001", start)
+	if !job.IsOpen() || job.JobOrder != "WO-1001" {
+		t.Errorf("Unexpected new job: %+v", job)
+	}
+	job.End(start.Add(2 * time.Hour))
+	if job.IsOpen() || job.ActualDuration != 7200 {
+		t.Errorf("Expect a closed job of 7200s; got %+v", job)
+	}
+	job.End(start.Add(-time.Minute))
+	if job.ActualDuration != 0 {
+		t.Errorf("Expect no negative durations; got %v", job.ActualDuration)
+	}
+}
+
+func TestIsJobEndPayload(t *testing.T) {
+	if !IsJobEndPayload(map[string]interface{}{JOB_STATE: " end"}) {
+		t.Errorf("Expect a job end")
+	}
+	if IsJobEndPayload(map[string]interface{}{JOB_STATE: "Start", JOB_ORDER: "WO-1"}) || IsJobEndPayload(nil) {
+		t.Errorf("Expect only the End state to end a job")
+	}
+}
@@ -0,0 +1,249 @@
+package domain
+
+import (
+	"time"
+)
+
+// OEEPeriodKind is the kind of period an OEE result covers
+type OEEPeriodKind string
+
+const (
+	OEEPeriodHour    OEEPeriodKind = "Hour"
+	OEEPeriodShift   OEEPeriodKind = "Shift"
+	OEEPeriodRolling OEEPeriodKind = "Rolling"
+)
+
+// OEEPeriodKinds lists the periods an OEETracker keeps
+var OEEPeriodKinds = []OEEPeriodKind{OEEPeriodHour, OEEPeriodShift, OEEPeriodRolling}
+
+// OEEMeasures are the times and counts OEE is computed from.  Only planned busy time is measured, so RunTime is the
+// part of PlannedTime the equipment was running, and the counts are what it made during planned time.  IdealTime
+// is the ideal cycle time of each counted unit added up, so a change of the ideal cycle time only affects what is
+// counted after it.
+type OEEMeasures struct {
+	PlannedTime    time.Duration
+	RunTime        time.Duration
+	IdealTime      time.Duration
+	TotalCount     float64
+	DefectiveCount float64
+}
+
+// Add adds other measures to these
+func (m *OEEMeasures) Add(other OEEMeasures) {
+	m.PlannedTime += other.PlannedTime
+	m.RunTime += other.RunTime
+	m.IdealTime += other.IdealTime
+	m.TotalCount += other.TotalCount
+	m.DefectiveCount += other.DefectiveCount
+}
+
+// GoodCount is the total count less the defective count
+func (m OEEMeasures) GoodCount() float64 {
+	if good := m.TotalCount - m.DefectiveCount; good > 0 {
+		return good
+	}
+	return 0
+}
+
+// OEEResult holds the OEE factors, each between 0 and 1
+type OEEResult struct {
+	Availability float64 `json:"availability"`
+	Performance  float64 `json:"performance"`
+	Quality      float64 `json:"quality"`
+	OEE          float64 `json:"oee"`
+}
+
+// Result computes the OEE factors.  A factor without anything to measure (no planned time, no run time or nothing
+// counted) is 0, and each factor is limited to 1 so a too short ideal cycle time or counts arriving late cannot
+// report more than 100%.
+func (m OEEMeasures) Result() OEEResult {
+	r := OEEResult{
+		Availability: oeeRatio(m.RunTime.Seconds(), m.PlannedTime.Seconds()),
+		Performance:  oeeRatio(m.IdealTime.Seconds(), m.RunTime.Seconds()),
+		Quality:      oeeRatio(m.GoodCount(), m.TotalCount),
+	}
+	r.OEE = r.Availability * r.Performance * r.Quality
+	return r
+}
+
+func oeeRatio(part float64, whole float64) float64 {
+	if whole <= 0 || part <= 0 {
+		return 0
+	}
+	if part >= whole {
+		return 1
+	}
+	return part / whole
+}
+
+// OEEPeriod is the measures of one hour, shift or rolling window; Name is the shift name for a shift
+type OEEPeriod struct {
+	Kind     OEEPeriodKind
+	Name     string
+	Start    time.Time
+	End      time.Time
+	Measures OEEMeasures
+}
+
+// oeeSlice is the granularity of the rolling window
+const oeeSlice = time.Minute
+
+// OEETracker measures the OEE of one equipment for the current hour, the current shift and a rolling window.  It
+// is told when the equipment starts and stops running, when planned busy time starts and ends, and what it counted;
+// time is measured up to the latest time it was given.  Hours and shifts that end are kept until taken with
+// TakeEnded.  The equipment is taken to be in planned busy time, without a shift, until SetPlanned says otherwise.
+// It is not safe for concurrent use.
+type OEETracker struct {
+	rollingWindow  time.Duration
+	last           time.Time
+	running        bool
+	planned        bool
+	idealCycleTime time.Duration
+
+	hour   OEEPeriod
+	shift  *OEEPeriod
+	slices []OEEPeriod
+	ended  []OEEPeriod
+}
+
+// NewOEETracker creates a tracker that starts measuring at the given time
+func NewOEETracker(rollingWindow time.Duration, start time.Time) *OEETracker {
+	t := OEETracker{
+		rollingWindow: rollingWindow,
+		last:          start,
+		planned:       true,
+	}
+	t.hour = newOEEHour(start)
+	return &t
+}
+
+func newOEEHour(at time.Time) OEEPeriod {
+	start := at.Truncate(time.Hour)
+	return OEEPeriod{Kind: OEEPeriodHour, Name: start.Format("2006-01-02T15"), Start: start, End: start.Add(time.Hour)}
+}
+
+// SetRunning records that the equipment started or stopped running
+func (t *OEETracker) SetRunning(running bool, at time.Time) {
+	t.Advance(at)
+	t.running = running
+}
+
+// SetPlanned records whether the equipment is in planned busy time and the name of its shift.  A shift ends when
+// planned busy time ends or the shift name changes; without a shift name no shift is measured.
+func (t *OEETracker) SetPlanned(planned bool, shift string, at time.Time) {
+	t.Advance(at)
+	t.planned = planned
+	if !planned {
+		shift = ""
+	}
+	if t.shift != nil && t.shift.Name == shift {
+		return
+	}
+	if t.shift != nil {
+		t.shift.End = t.last
+		t.ended = append(t.ended, *t.shift)
+		t.shift = nil
+	}
+	if shift != "" {
+		t.shift = &OEEPeriod{Kind: OEEPeriodShift, Name: shift, Start: t.last}
+	}
+}
+
+// SetIdealCycleTime sets the ideal time to make one unit, used for what is counted from now on
+func (t *OEETracker) SetIdealCycleTime(idealCycleTime time.Duration) {
+	t.idealCycleTime = idealCycleTime
+}
+
+// AddCount records units made at the given time, of which defective were defective; counts outside planned busy
+// time are not measured
+func (t *OEETracker) AddCount(total float64, defective float64, at time.Time) {
+	t.Advance(at)
+	if !t.planned || (total <= 0 && defective <= 0) {
+		return
+	}
+	t.measure(OEEMeasures{
+		TotalCount:     total,
+		DefectiveCount: defective,
+		IdealTime:      time.Duration(total * float64(t.idealCycleTime)),
+	})
+}
+
+// Advance measures the time up to the given time; earlier times are ignored
+func (t *OEETracker) Advance(at time.Time) {
+	for t.last.Before(at) {
+		next := t.last.Truncate(oeeSlice).Add(oeeSlice)
+		if next.After(at) {
+			next = at
+		}
+		if t.planned {
+			elapsed := next.Sub(t.last)
+			step := OEEMeasures{PlannedTime: elapsed}
+			if t.running {
+				step.RunTime = elapsed
+			}
+			t.measure(step)
+		}
+		t.last = next
+		if !t.last.Before(t.hour.End) {
+			t.ended = append(t.ended, t.hour)
+			t.hour = newOEEHour(t.last)
+		}
+	}
+}
+
+// measure adds to the current hour, shift and rolling slice
+func (t *OEETracker) measure(m OEEMeasures) {
+	t.hour.Measures.Add(m)
+	if t.shift != nil {
+		t.shift.Measures.Add(m)
+	}
+	start := t.last.Truncate(oeeSlice)
+	if n := len(t.slices); n == 0 || !t.slices[n-1].Start.Equal(start) {
+		t.slices = append(t.slices, OEEPeriod{Kind: OEEPeriodRolling, Start: start, End: start.Add(oeeSlice)})
+		t.pruneSlices()
+	}
+	t.slices[len(t.slices)-1].Measures.Add(m)
+}
+
+func (t *OEETracker) pruneSlices() {
+	from := t.last.Add(-t.rollingWindow)
+	keep := 0
+	for keep < len(t.slices) && !t.slices[keep].End.After(from) {
+		keep++
+	}
+	t.slices = t.slices[keep:]
+}
+
+// Current returns the measures of the current period of the kind so far; there is no current shift outside planned
+// busy time or without a shift name.  The rolling window ends at the latest time measured.
+func (t *OEETracker) Current(kind OEEPeriodKind) (OEEPeriod, bool) {
+	switch kind {
+	case OEEPeriodHour:
+		period := t.hour
+		period.End = t.last
+		return period, true
+	case OEEPeriodShift:
+		if t.shift == nil {
+			return OEEPeriod{}, false
+		}
+		period := *t.shift
+		period.End = t.last
+		return period, true
+	case OEEPeriodRolling:
+		period := OEEPeriod{Kind: OEEPeriodRolling, Start: t.last.Add(-t.rollingWindow), End: t.last}
+		for _, slice := range t.slices {
+			if slice.End.After(period.Start) {
+				period.Measures.Add(slice.Measures)
+			}
+		}
+		return period, true
+	}
+	return OEEPeriod{}, false
+}
+
+// TakeEnded returns the hours and shifts that ended since it was last called, in the order they ended
+func (t *OEETracker) TakeEnded() []OEEPeriod {
+	ended := t.ended
+	t.ended = nil
+	return ended
+}
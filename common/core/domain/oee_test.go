@@ -0,0 +1,78 @@
+package domain
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestOEEResult(t *testing.T) {
+	m := OEEMeasures{
+		PlannedTime:    100 * time.Minute,
+		RunTime:        80 * time.Minute,
+		IdealTime:      60 * time.Minute,
+		TotalCount:     100,
+		DefectiveCount: 10,
+	}
+	r := m.Result()
+	if r.Availability != 0.8 || r.Performance != 0.75 || r.Quality != 0.9 || math.Abs(r.OEE-0.54) > 1e-9 {
+		t.Errorf("Unexpected result %+v", r)
+	}
+	if r = (OEEMeasures{}).Result(); r != (OEEResult{}) {
+		t.Errorf("Expect an empty result without measures; got %+v", r)
+	}
+	m.IdealTime = 90 * time.Minute
+	if r = m.Result(); r.Performance != 1 {
+		t.Errorf("Expect performance to be limited to 1; got %v", r.Performance)
+	}
+}
+
+func TestOEETracker(t *testing.T) {
+	start := time.Date(2021, 6, 1, 8, 0, 0, 0, time.UTC)
+	tracker := NewOEETracker(30*time.Minute, start)
+	tracker.SetIdealCycleTime(30 * time.Second)
+	tracker.SetPlanned(true, "Day", start)
+	tracker.SetRunning(true, start)
+	tracker.AddCount(60, 0, start.Add(40*time.Minute))
+	tracker.AddCount(0, 6, start.Add(40*time.Minute))
+	tracker.SetRunning(false, start.Add(40*time.Minute))
+	tracker.Advance(start.Add(50 * time.Minute))
+
+	hour, _ := tracker.Current(OEEPeriodHour)
+	if hour.Measures.PlannedTime != 50*time.Minute || hour.Measures.RunTime != 40*time.Minute || hour.Measures.TotalCount != 60 {
+		t.Errorf("Unexpected hour measures %+v", hour.Measures)
+	}
+	r := hour.Measures.Result()
+	if r.Availability != 0.8 || r.Performance != 0.75 || r.Quality != 0.9 {
+		t.Errorf("Unexpected hour result %+v", r)
+	}
+	rolling, _ := tracker.Current(OEEPeriodRolling)
+	if rolling.Measures.PlannedTime != 30*time.Minute || rolling.Measures.RunTime != 20*time.Minute {
+		t.Errorf("Unexpected rolling measures %+v", rolling.Measures)
+	}
+	if ended := tracker.TakeEnded(); len(ended) != 0 {
+		t.Errorf("Expect nothing to have ended; got %+v", ended)
+	}
+
+	//the hour ends, and then the shift when planned busy time ends
+	tracker.SetPlanned(false, "", start.Add(70*time.Minute))
+	if _, open := tracker.Current(OEEPeriodShift); open {
+		t.Errorf("Expect no shift outside planned busy time")
+	}
+	tracker.AddCount(10, 0, start.Add(75*time.Minute))
+	tracker.Advance(start.Add(80 * time.Minute))
+	ended := tracker.TakeEnded()
+	if len(ended) != 2 || ended[0].Kind != OEEPeriodHour || ended[1].Kind != OEEPeriodShift {
+		t.Fatalf("Expect the hour and then the shift to end; got %+v", ended)
+	}
+	if ended[0].Measures.PlannedTime != time.Hour || !ended[0].End.Equal(start.Add(time.Hour)) {
+		t.Errorf("Unexpected ended hour %+v", ended[0])
+	}
+	if ended[1].Name != "Day" || ended[1].Measures.PlannedTime != 70*time.Minute || !ended[1].End.Equal(start.Add(70*time.Minute)) {
+		t.Errorf("Unexpected ended shift %+v", ended[1])
+	}
+	hour, _ = tracker.Current(OEEPeriodHour)
+	if hour.Measures.PlannedTime != 10*time.Minute || hour.Measures.TotalCount != 0 {
+		t.Errorf("Expect only planned time to be measured; got %+v", hour.Measures)
+	}
+}
@@ -0,0 +1,236 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MachineState is a PackML (ISA-TR88.00.02) machine state, the MachineState enum of the schema
+type MachineState string
+
+const (
+	MachineStateClearing     MachineState = "Clearing"
+	MachineStateStopped      MachineState = "Stopped"
+	MachineStateStarting     MachineState = "Starting"
+	MachineStateIdle         MachineState = "Idle"
+	MachineStateSuspended    MachineState = "Suspended"
+	MachineStateExecute      MachineState = "Execute"
+	MachineStateStopping     MachineState = "Stopping"
+	MachineStateAborting     MachineState = "Aborting"
+	MachineStateAborted      MachineState = "Aborted"
+	MachineStateHolding      MachineState = "Holding"
+	MachineStateHeld         MachineState = "Held"
+	MachineStateUnholding    MachineState = "Unholding"
+	MachineStateSuspending   MachineState = "Suspending"
+	MachineStateUnsuspending MachineState = "Unsuspending"
+	MachineStateResetting    MachineState = "Resetting"
+	MachineStateCompleting   MachineState = "Completing"
+	MachineStateComplete     MachineState = "Complete"
+)
+
+// packMLStateNumbers are the state numbers of PackML StateCurrent tags; 0 is undefined
+var packMLStateNumbers = []MachineState{"", MachineStateClearing, MachineStateStopped, MachineStateStarting,
+	MachineStateIdle, MachineStateSuspended, MachineStateExecute, MachineStateStopping, MachineStateAborting,
+	MachineStateAborted, MachineStateHolding, MachineStateHeld, MachineStateUnholding, MachineStateSuspending,
+	MachineStateUnsuspending, MachineStateResetting, MachineStateCompleting, MachineStateComplete}
+
+// ParseMachineState reads a state from its PackML number or its name, ignoring case
+func ParseMachineState(value interface{}) (MachineState, error) {
+	if num, ok := ValueAsFloat64(value); ok {
+		if ndx := int(num); float64(ndx) == num && ndx > 0 && ndx < len(packMLStateNumbers) {
+			return packMLStateNumbers[ndx], nil
+		}
+		return "", fmt.Errorf("undefined PackML state number %v", value)
+	}
+	text := strings.TrimSpace(fmt.Sprintf("%v", value))
+	for _, state := range packMLStateNumbers[1:] {
+		if strings.EqualFold(text, string(state)) {
+			return state, nil
+		}
+	}
+	return "", fmt.Errorf("unknown PackML state '%s'", text)
+}
+
+// Number is the PackML state number, 0 for an unknown state
+func (m MachineState) Number() int {
+	for ndx, state := range packMLStateNumbers {
+		if state == m && ndx > 0 {
+			return ndx
+		}
+	}
+	return 0
+}
+
+// IsActing is true for the transient states that end by themselves when their action is done
+func (m MachineState) IsActing() bool {
+	return strings.HasSuffix(string(m), "ing") && m != MachineStateExecute
+}
+
+// packMLTransitions are the state changes of the PackML state model besides Stop and Abort, which are allowed from
+// most states (see IsValidPackMLTransition)
+var packMLTransitions = map[MachineState][]MachineState{
+	MachineStateStopped:      {MachineStateResetting},
+	MachineStateResetting:    {MachineStateIdle},
+	MachineStateIdle:         {MachineStateStarting},
+	MachineStateStarting:     {MachineStateExecute},
+	MachineStateExecute:      {MachineStateCompleting, MachineStateHolding, MachineStateSuspending},
+	MachineStateCompleting:   {MachineStateComplete},
+	MachineStateComplete:     {MachineStateResetting},
+	MachineStateHolding:      {MachineStateHeld},
+	MachineStateHeld:         {MachineStateUnholding},
+	MachineStateUnholding:    {MachineStateExecute},
+	MachineStateSuspending:   {MachineStateSuspended},
+	MachineStateSuspended:    {MachineStateUnsuspending},
+	MachineStateUnsuspending: {MachineStateExecute},
+	MachineStateStopping:     {MachineStateStopped},
+	MachineStateAborting:     {MachineStateAborted},
+	MachineStateAborted:      {MachineStateClearing},
+	MachineStateClearing:     {MachineStateStopped},
+}
+
+// IsValidPackMLTransition is true when the state model allows the machine to go directly from one state to the other
+func IsValidPackMLTransition(from MachineState, to MachineState) bool {
+	for _, next := range packMLTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	switch to {
+	case MachineStateAborting:
+		return from != MachineStateAborting && from != MachineStateAborted
+	case MachineStateStopping:
+		return from != MachineStateStopping && from != MachineStateStopped && from != MachineStateAborting &&
+			from != MachineStateAborted && from != MachineStateClearing
+	}
+	return false
+}
+
+// PackMLStateInterval is the time the machine spent in one state
+type PackMLStateInterval struct {
+	State    MachineState `json:"state"`
+	Start    time.Time    `json:"start"`
+	End      time.Time    `json:"end"`
+	Duration float64      `json:"duration"` // seconds
+}
+
+// PackMLStateChange describes a change of the machine state
+type PackMLStateChange struct {
+	From     MachineState         `json:"from"`
+	To       MachineState         `json:"to"`
+	At       time.Time            `json:"at"`
+	Valid    bool                 `json:"valid"`              // the state model allows the change
+	Interval *PackMLStateInterval `json:"interval,omitempty"` // the time spent in the previous state, if it was known
+}
+
+// PackMLStateTracker follows the state of a machine and the time it spent in each state
+type PackMLStateTracker struct {
+	state       MachineState
+	since       time.Time
+	timeInState map[MachineState]time.Duration
+}
+
+func NewPackMLStateTracker() *PackMLStateTracker {
+	return &PackMLStateTracker{timeInState: map[MachineState]time.Duration{}}
+}
+
+// Seed sets the state without reporting a change, for example from the state saved before a restart
+func (t *PackMLStateTracker) Seed(state MachineState, since time.Time) {
+	t.state = state
+	t.since = since
+}
+
+// Current returns the state and when the machine entered it; the state is "" until the first update
+func (t *PackMLStateTracker) Current() (MachineState, time.Time) {
+	return t.state, t.since
+}
+
+// Update takes a state reading.  It returns false for a reading of the current state or one older than the last
+// change; otherwise the change, which is flagged invalid when the state model does not allow it.
+func (t *PackMLStateTracker) Update(state MachineState, at time.Time) (PackMLStateChange, bool) {
+	if state == t.state || (t.state != "" && at.Before(t.since)) {
+		return PackMLStateChange{}, false
+	}
+	change := PackMLStateChange{From: t.state, To: state, At: at, Valid: true}
+	if t.state != "" {
+		change.Valid = IsValidPackMLTransition(t.state, state)
+		spent := at.Sub(t.since)
+		t.timeInState[t.state] += spent
+		change.Interval = &PackMLStateInterval{State: t.state, Start: t.since, End: at, Duration: spent.Seconds()}
+	}
+	t.state = state
+	t.since = at
+	return change, true
+}
+
+// TimeInState is the total time spent in the state since tracking began, including the current stay up to now
+func (t *PackMLStateTracker) TimeInState(state MachineState, now time.Time) time.Duration {
+	total := t.timeInState[state]
+	if state == t.state && now.After(t.since) {
+		total += now.Sub(t.since)
+	}
+	return total
+}
+
+// PackMLCountKind is one of the PackML Admin product count arrays
+type PackMLCountKind string
+
+const (
+	PackMLCountConsumed  PackMLCountKind = "Consumed"  // Admin.ProdConsumedCount
+	PackMLCountProcessed PackMLCountKind = "Processed" // Admin.ProdProcessedCount
+	PackMLCountDefective PackMLCountKind = "Defective" // Admin.ProdDefectiveCount
+)
+
+// PackMLJobCounts are the running totals of PackML Admin counts per product for the job running on a machine.  The
+// totals start again from zero when the job changes.
+type PackMLJobCounts struct {
+	job    string
+	totals map[PackMLCountKind]map[string]float64
+}
+
+func NewPackMLJobCounts() *PackMLJobCounts {
+	return &PackMLJobCounts{totals: map[PackMLCountKind]map[string]float64{}}
+}
+
+// Add counts a quantity of the product for the job and returns the job's new total for the product
+func (c *PackMLJobCounts) Add(job string, kind PackMLCountKind, product string, quantity float64) float64 {
+	if job != c.job {
+		c.job = job
+		c.totals = map[PackMLCountKind]map[string]float64{}
+	}
+	if c.totals[kind] == nil {
+		c.totals[kind] = map[string]float64{}
+	}
+	c.totals[kind][product] += quantity
+	return c.totals[kind][product]
+}
+
+// Seed starts the job's totals of the kind from counts restored after a restart.  The total of the kind may include
+// products without a total of their own, which are kept under an empty product name so the total carries on.
+func (c *PackMLJobCounts) Seed(job string, kind PackMLCountKind, kindTotal float64, productTotals map[string]float64) {
+	if job != c.job {
+		c.job = job
+		c.totals = map[PackMLCountKind]map[string]float64{}
+	}
+	c.totals[kind] = map[string]float64{}
+	for product, quantity := range productTotals {
+		c.totals[kind][product] = quantity
+	}
+	if rest := kindTotal - c.Total(kind); rest > 0 {
+		c.totals[kind][""] += rest
+	}
+}
+
+// Total is the job's total of the kind across all products
+func (c *PackMLJobCounts) Total(kind PackMLCountKind) float64 {
+	total := 0.0
+	for _, quantity := range c.totals[kind] {
+		total += quantity
+	}
+	return total
+}
+
+// Job is the job the totals are for
+func (c *PackMLJobCounts) Job() string {
+	return c.job
+}
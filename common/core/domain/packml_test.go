@@ -0,0 +1,115 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseMachineState(t *testing.T) {
+	for _, tc := range []struct {
+		value interface{}
+		state MachineState
+	}{
+		{6, MachineStateExecute},
+		{"11", MachineStateHeld},
+		{"aborted", MachineStateAborted},
+		{17.0, MachineStateComplete},
+	} {
+		if state, err := ParseMachineState(tc.value); err != nil || state != tc.state {
+			t.Errorf("Expect %v to be %s; got %s %v", tc.value, tc.state, state, err)
+		}
+	}
+	for _, bad := range []interface{}{0, 18, 2.5, "Running"} {
+		if state, err := ParseMachineState(bad); err == nil {
+			t.Errorf("Expect an error for %v; got %s", bad, state)
+		}
+	}
+	if MachineStateExecute.Number() != 6 || MachineStateExecute.IsActing() || !MachineStateStarting.IsActing() || MachineStateHeld.IsActing() {
+		t.Errorf("Unexpected state number or acting flag")
+	}
+}
+
+func TestPackMLTransitions(t *testing.T) {
+	valid := [][2]MachineState{
+		{MachineStateStopped, MachineStateResetting},
+		{MachineStateExecute, MachineStateHolding},
+		{MachineStateHeld, MachineStateStopping},
+		{MachineStateIdle, MachineStateAborting},
+		{MachineStateAborted, MachineStateClearing},
+		{MachineStateClearing, MachineStateStopped},
+	}
+	for _, tr := range valid {
+		if !IsValidPackMLTransition(tr[0], tr[1]) {
+			t.Errorf("Expect %s -> %s to be valid", tr[0], tr[1])
+		}
+	}
+	invalid := [][2]MachineState{
+		{MachineStateIdle, MachineStateExecute},
+		{MachineStateStopped, MachineStateStopping},
+		{MachineStateAborted, MachineStateAborting},
+		{MachineStateClearing, MachineStateStopping},
+		{MachineStateHeld, MachineStateExecute},
+	}
+	for _, tr := range invalid {
+		if IsValidPackMLTransition(tr[0], tr[1]) {
+			t.Errorf("Expect %s -> %s to be invalid", tr[0], tr[1])
+		}
+	}
+}
+
+func TestPackMLStateTracker(t *testing.T) {
+	start := time.Date(2022, 5, 1, 6, 0, 0, 0, time.UTC)
+	tracker := NewPackMLStateTracker()
+	change, changed := tracker.Update(MachineStateIdle, start)
+	if !changed || change.Interval != nil || !change.Valid {
+		t.Errorf("Expect the first state without an interval; got %+v", change)
+	}
+	if _, changed = tracker.Update(MachineStateIdle, start.Add(time.Second)); changed {
+		t.Errorf("Expect no change for the same state")
+	}
+	change, _ = tracker.Update(MachineStateExecute, start.Add(10*time.Second))
+	if change.Valid || change.Interval == nil || change.Interval.State != MachineStateIdle || change.Interval.Duration != 10 {
+		t.Errorf("Expect an invalid change after 10s idle; got %+v %+v", change, change.Interval)
+	}
+	if _, changed = tracker.Update(MachineStateHeld, start.Add(5*time.Second)); changed {
+		t.Errorf("Expect a reading older than the last change to be ignored")
+	}
+	tracker.Update(MachineStateHolding, start.Add(70*time.Second))
+	tracker.Update(MachineStateHeld, start.Add(75*time.Second))
+	tracker.Update(MachineStateUnholding, start.Add(100*time.Second))
+	tracker.Update(MachineStateExecute, start.Add(105*time.Second))
+	if spent := tracker.TimeInState(MachineStateExecute, start.Add(125*time.Second)); spent != 80*time.Second {
+		t.Errorf("Expect 80s in Execute including the current stay; got %s", spent)
+	}
+	if state, since := tracker.Current(); state != MachineStateExecute || !since.Equal(start.Add(105*time.Second)) {
+		t.Errorf("Unexpected current state %s since %s", state, since)
+	}
+}
+
+func TestPackMLJobCounts(t *testing.T) {
+	counts := NewPackMLJobCounts()
+	counts.Add("job1", PackMLCountProcessed, "P1", 10)
+	counts.Add("job1", PackMLCountProcessed, "P2", 5)
+	if total := counts.Add("job1", PackMLCountProcessed, "P1", 2); total != 12 {
+		t.Errorf("Expect a running total of 12 for P1; got %v", total)
+	}
+	counts.Add("job1", PackMLCountDefective, "P1", 1)
+	if counts.Total(PackMLCountProcessed) != 17 || counts.Total(PackMLCountDefective) != 1 || counts.Total(PackMLCountConsumed) != 0 {
+		t.Errorf("Unexpected job totals %+v", counts)
+	}
+	if total := counts.Add("job2", PackMLCountProcessed, "P1", 3); total != 3 || counts.Total(PackMLCountProcessed) != 3 || counts.Job() != "job2" {
+		t.Errorf("Expect the totals to start again for a new job; got %v", total)
+	}
+}
+
+func TestPackMLJobCountsSeed(t *testing.T) {
+	counts := NewPackMLJobCounts()
+	counts.Seed("job1", PackMLCountProcessed, 40, map[string]float64{"P1": 25})
+	if total := counts.Add("job1", PackMLCountProcessed, "P1", 5); total != 30 || counts.Total(PackMLCountProcessed) != 45 {
+		t.Errorf("Expect the totals to carry on from the seeded counts; got %v of %v", total, counts.Total(PackMLCountProcessed))
+	}
+	counts.Seed("job1", PackMLCountDefective, 2, nil)
+	if counts.Total(PackMLCountDefective) != 2 || counts.Total(PackMLCountProcessed) != 45 {
+		t.Errorf("Expect seeding one kind to leave the others; got %+v", counts)
+	}
+}
@@ -0,0 +1,44 @@
+package domain
+
+import "sort"
+
+// PropertyDefinitionDiff lists the properties of an equipment whose definitions changed
+type PropertyDefinitionDiff struct {
+	Added           []string
+	Removed         []string
+	DataTypeChanged []string
+	// Redefined properties kept their data type, but their address, expression, type, unit or history setting changed
+	Redefined []string
+}
+
+// IsEmpty is true when the definitions are unchanged
+func (d PropertyDefinitionDiff) IsEmpty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.DataTypeChanged) == 0 && len(d.Redefined) == 0
+}
+
+// DiffPropertyDefinitions compares the properties a managed equipment is running with against the newly resolved ones
+func DiffPropertyDefinitions(current map[string]EquipmentPropertyDescriptor, resolved map[string]ResolvedProperty) PropertyDefinitionDiff {
+	var diff PropertyDefinitionDiff
+	for name, prop := range resolved {
+		curr, exists := current[name]
+		switch {
+		case !exists:
+			diff.Added = append(diff.Added, name)
+		case curr.DataType != prop.DataType:
+			diff.DataTypeChanged = append(diff.DataTypeChanged, name)
+		case curr.Address != prop.Address || curr.Expression != prop.Expression || curr.Type != prop.Type ||
+			curr.StoreHistory != prop.StoreHistory || curr.UnitOfMeasure != prop.UnitOfMeasure.Code:
+			diff.Redefined = append(diff.Redefined, name)
+		}
+	}
+	for name := range current {
+		if _, exists := resolved[name]; !exists {
+			diff.Removed = append(diff.Removed, name)
+		}
+	}
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.DataTypeChanged)
+	sort.Strings(diff.Redefined)
+	return diff
+}
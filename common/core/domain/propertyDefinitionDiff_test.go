@@ -0,0 +1,40 @@
+package domain
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffPropertyDefinitions(t *testing.T) {
+	current := map[string]EquipmentPropertyDescriptor{
+		"count": {Name: "count", DataType: "INT32", Address: "Filler.Count"},
+		"speed": {Name: "speed", DataType: "INT32"},
+		"state": {Name: "state", DataType: DataTypeString},
+		"spare": {Name: "spare", DataType: "FLOAT64"},
+	}
+	resolved := map[string]ResolvedProperty{
+		"count": {Property: Property{Name: "count", DataType: "INT32", Address: "Line3.Filler.Count"}},
+		"speed": {Property: Property{Name: "speed", DataType: "FLOAT64"}},
+		"state": {Property: Property{Name: "state", DataType: DataTypeString}},
+		"temp":  {Property: Property{Name: "temp", DataType: "FLOAT64"}},
+		"rate":  {Property: Property{Name: "rate", DataType: "FLOAT64"}},
+	}
+	diff := DiffPropertyDefinitions(current, resolved)
+	expected := PropertyDefinitionDiff{
+		Added:           []string{"rate", "temp"},
+		Removed:         []string{"spare"},
+		DataTypeChanged: []string{"speed"},
+		Redefined:       []string{"count"},
+	}
+	if !reflect.DeepEqual(diff, expected) {
+		t.Errorf("DiffPropertyDefinitions() = %+v; want %+v", diff, expected)
+	}
+	if diff := DiffPropertyDefinitions(current, map[string]ResolvedProperty{
+		"count": {Property: Property{Name: "count", DataType: "INT32", Address: "Filler.Count"}},
+		"speed": {Property: Property{Name: "speed", DataType: "INT32"}},
+		"state": {Property: Property{Name: "state", DataType: DataTypeString}},
+		"spare": {Property: Property{Name: "spare", DataType: "FLOAT64"}},
+	}); !diff.IsEmpty() {
+		t.Errorf("Expect no differences; got %+v", diff)
+	}
+}
@@ -0,0 +1,122 @@
+package domain
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// PropertyDependencyGraph orders calculated properties so that each is computed after the properties it uses
+type PropertyDependencyGraph struct {
+	dependsOn  map[string][]string
+	dependents map[string][]string
+	order      []string
+	position   map[string]int
+}
+
+// NewPropertyDependencyGraph builds the graph from the inputs of each calculated property.  If the calculated
+// properties form a cycle an error naming the cycle is returned along with a graph of the properties that are not
+// part of (or dependent on) a cycle.
+func NewPropertyDependencyGraph(dependencies map[string][]string) (*PropertyDependencyGraph, error) {
+	g := &PropertyDependencyGraph{
+		dependsOn:  map[string][]string{},
+		dependents: map[string][]string{},
+		order:      []string{},
+		position:   map[string]int{},
+	}
+	names := make([]string, 0, len(dependencies))
+	for name := range dependencies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	const (
+		unvisited = iota
+		visiting
+		done
+		broken
+	)
+	state := map[string]int{}
+	var cycles []string
+	var visit func(name string, path []string) bool
+	visit = func(name string, path []string) bool {
+		switch state[name] {
+		case done:
+			return true
+		case broken:
+			return false
+		case visiting:
+			start := 0
+			for ndx, p := range path {
+				if p == name {
+					start = ndx
+				}
+			}
+			cycles = append(cycles, strings.Join(append(path[start:], name), " -> "))
+			return false
+		}
+		state[name] = visiting
+		ok := true
+		for _, input := range dependencies[name] {
+			if _, calculated := dependencies[input]; calculated {
+				if !visit(input, append(path, name)) {
+					ok = false
+				}
+			}
+		}
+		if !ok {
+			state[name] = broken
+			return false
+		}
+		state[name] = done
+		g.position[name] = len(g.order)
+		g.order = append(g.order, name)
+		return true
+	}
+	for _, name := range names {
+		visit(name, []string{})
+	}
+
+	for _, name := range g.order {
+		g.dependsOn[name] = dependencies[name]
+		for _, input := range dependencies[name] {
+			g.dependents[input] = append(g.dependents[input], name)
+		}
+	}
+	if len(cycles) > 0 {
+		return g, fmt.Errorf("calculated properties form a cycle: %s", strings.Join(cycles, "; "))
+	}
+	return g, nil
+}
+
+// Order returns the calculated properties in evaluation order
+func (g *PropertyDependencyGraph) Order() []string {
+	return append([]string{}, g.order...)
+}
+
+// Inputs returns the properties used by a calculated property
+func (g *PropertyDependencyGraph) Inputs(name string) []string {
+	return append([]string{}, g.dependsOn[name]...)
+}
+
+// Affected returns the calculated properties that (directly or indirectly) use the changed property, in evaluation order
+func (g *PropertyDependencyGraph) Affected(changed string) []string {
+	seen := map[string]bool{}
+	pending := append([]string{}, g.dependents[changed]...)
+	for len(pending) > 0 {
+		name := pending[0]
+		pending = pending[1:]
+		if !seen[name] {
+			seen[name] = true
+			pending = append(pending, g.dependents[name]...)
+		}
+	}
+	ret := make([]string, 0, len(seen))
+	for name := range seen {
+		ret = append(ret, name)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return g.position[ret[i]] < g.position[ret[j]]
+	})
+	return ret
+}
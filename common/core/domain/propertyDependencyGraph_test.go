@@ -0,0 +1,57 @@
+package domain
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPropertyDependencyGraphOrder(t *testing.T) {
+	g, err := NewPropertyDependencyGraph(map[string][]string{
+		"rate":       {"count", "runTime"},
+		"efficiency": {"rate", "idealRate"},
+		"display":    {"efficiency", "product"},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	expected := []string{"rate", "efficiency", "display"}
+	if order := g.Order(); !reflect.DeepEqual(order, expected) {
+		t.Errorf("Order() = %v; want %v", order, expected)
+	}
+
+	var tests = []struct {
+		changed  string
+		expected []string
+	}{
+		{"count", []string{"rate", "efficiency", "display"}},
+		{"idealRate", []string{"efficiency", "display"}},
+		{"product", []string{"display"}},
+		{"rate", []string{"efficiency", "display"}},
+		{"unrelated", []string{}},
+	}
+	for _, test := range tests {
+		if affected := g.Affected(test.changed); !reflect.DeepEqual(affected, test.expected) {
+			t.Errorf("Affected(%s) = %v; want %v", test.changed, affected, test.expected)
+		}
+	}
+}
+
+func TestPropertyDependencyGraphCycle(t *testing.T) {
+	g, err := NewPropertyDependencyGraph(map[string][]string{
+		"a":      {"b"},
+		"b":      {"c"},
+		"c":      {"a"},
+		"usesA":  {"a"},
+		"simple": {"input"},
+	})
+	if err == nil {
+		t.Fatalf("Expect an error for a cycle")
+	}
+	if !strings.Contains(err.Error(), "a -> b -> c -> a") {
+		t.Errorf("Expect the cycle to be named in the error; got %s", err)
+	}
+	if order := g.Order(); !reflect.DeepEqual(order, []string{"simple"}) {
+		t.Errorf("Expect only the properties outside the cycle to be ordered; got %v", order)
+	}
+}
@@ -0,0 +1,103 @@
+package domain
+
+// PropertySource identifies where an attribute of a resolved property came from
+type PropertySource string
+
+const (
+	PropertySourceNone      PropertySource = ""
+	PropertySourceEquipment PropertySource = "EQUIPMENT"
+	PropertySourceClass     PropertySource = "EQUIPMENT_CLASS"
+	PropertySourceOverride  PropertySource = "OVERRIDE"
+)
+
+// PropertyAttributeSources records, per attribute, which level of the model supplied the resolved value
+type PropertyAttributeSources struct {
+	Definition   PropertySource
+	ClassId      string
+	Value        PropertySource
+	Address      PropertySource
+	Expression   PropertySource
+	StoreHistory PropertySource
+	OverrideId   string
+}
+
+// ResolvedProperty is a property of an equipment after class inheritance and equipment overrides are applied
+type ResolvedProperty struct {
+	Property
+	Sources PropertyAttributeSources
+}
+
+// ResolveEquipmentProperties merges the properties of an equipment with those of its class hierarchy (nearest class
+// first) and applies the equipment's property overrides.  A property defined on the equipment hides a property of
+// the same name on any class, and a nearer class hides a more distant one.  Overrides match the property by id (or
+// by name if the id is not given); inactive overrides are skipped and an override with ignore set removes the
+// property from the equipment.
+func ResolveEquipmentProperties(equipmentProps []Property, classChain []EquipmentClassPropertiesAndParent, overrides []EquipmentPropertyOverride) map[string]ResolvedProperty {
+	resolved := map[string]ResolvedProperty{}
+	define := func(prop Property, source PropertySource, classId string) {
+		if _, exists := resolved[prop.Name]; exists {
+			return
+		}
+		sources := PropertyAttributeSources{Definition: source, ClassId: classId}
+		if prop.Value != "" {
+			sources.Value = source
+		}
+		if prop.Address != "" {
+			sources.Address = source
+		}
+		if prop.Expression != "" {
+			sources.Expression = source
+		}
+		sources.StoreHistory = source
+		resolved[prop.Name] = ResolvedProperty{Property: prop, Sources: sources}
+	}
+	for _, prop := range equipmentProps {
+		define(prop, PropertySourceEquipment, "")
+	}
+	for _, class := range classChain {
+		for _, prop := range class.Properties {
+			define(prop, PropertySourceClass, class.Id)
+		}
+	}
+
+	for _, override := range overrides {
+		if override.IsActive != nil && !*override.IsActive {
+			continue
+		}
+		name := ""
+		for propName, prop := range resolved {
+			if (override.Property.Id != "" && prop.Id == override.Property.Id) ||
+				(override.Property.Id == "" && propName == override.Property.Name) {
+				name = propName
+				break
+			}
+		}
+		if name == "" {
+			continue
+		}
+		if override.Ignore {
+			delete(resolved, name)
+			continue
+		}
+		prop := resolved[name]
+		prop.Sources.OverrideId = override.Id
+		if override.Address != nil {
+			prop.Address = *override.Address
+			prop.Sources.Address = PropertySourceOverride
+		}
+		if override.Expression != nil {
+			prop.Expression = *override.Expression
+			prop.Sources.Expression = PropertySourceOverride
+		}
+		if override.Value != nil {
+			prop.Value = *override.Value
+			prop.Sources.Value = PropertySourceOverride
+		}
+		if override.StoreHistory != nil {
+			prop.StoreHistory = *override.StoreHistory
+			prop.Sources.StoreHistory = PropertySourceOverride
+		}
+		resolved[name] = prop
+	}
+	return resolved
+}
@@ -0,0 +1,73 @@
+package domain
+
+import "testing"
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+func TestResolveEquipmentPropertiesPrecedence(t *testing.T) {
+	equipmentProps := []Property{
+		{Id: "eq-speed", Name: "speed", Value: "10", DataType: "FLOAT64"},
+	}
+	classChain := []EquipmentClassPropertiesAndParent{
+		{Id: "filler", Properties: []Property{
+			{Id: "filler-speed", Name: "speed", Value: "20", DataType: "FLOAT64"},
+			{Id: "filler-count", Name: "count", Address: "ns=2;s=Filler.Count", DataType: "INT32"},
+		}},
+		{Id: "machine", Properties: []Property{
+			{Id: "machine-count", Name: "count", Address: "ns=2;s=Machine.Count", DataType: "INT32"},
+			{Id: "machine-state", Name: "state", DataType: DataTypeString},
+		}},
+	}
+	resolved := ResolveEquipmentProperties(equipmentProps, classChain, nil)
+
+	if len(resolved) != 3 {
+		t.Fatalf("Expect 3 resolved properties; got %d", len(resolved))
+	}
+	if p := resolved["speed"]; p.Id != "eq-speed" || p.Value != "10" || p.Sources.Definition != PropertySourceEquipment {
+		t.Errorf("Expect the equipment property to hide the class property; got %+v", p)
+	}
+	if p := resolved["count"]; p.Id != "filler-count" || p.Sources.ClassId != "filler" || p.Sources.Address != PropertySourceClass {
+		t.Errorf("Expect the nearest class property to hide the parent class property; got %+v", p)
+	}
+	if p := resolved["state"]; p.Sources.Definition != PropertySourceClass || p.Sources.ClassId != "machine" || p.Sources.Value != PropertySourceNone {
+		t.Errorf("Unexpected resolution of inherited property: %+v", p)
+	}
+}
+
+func TestResolveEquipmentPropertiesOverrides(t *testing.T) {
+	classChain := []EquipmentClassPropertiesAndParent{
+		{Id: "filler", Properties: []Property{
+			{Id: "p-count", Name: "count", Address: "Filler.Count", Value: "0", DataType: "INT32"},
+			{Id: "p-rate", Name: "rate", Expression: "count / 60", DataType: "FLOAT64"},
+			{Id: "p-temp", Name: "temp", Address: "Filler.Temp", DataType: "FLOAT64", StoreHistory: true},
+			{Id: "p-spare", Name: "spare", DataType: "FLOAT64"},
+		}},
+	}
+	overrides := []EquipmentPropertyOverride{
+		{Id: "o1", Property: IdNameTypenameRef{Id: "p-count"}, Address: strPtr("Line3.Filler.Count")},
+		{Id: "o2", Property: IdNameTypenameRef{Name: "rate"}, Expression: strPtr("count / 30"), Value: strPtr("1.5")},
+		{Id: "o3", Property: IdNameTypenameRef{Id: "p-temp"}, StoreHistory: boolPtr(false)},
+		{Id: "o4", Property: IdNameTypenameRef{Id: "p-spare"}, Ignore: true},
+		{Id: "o5", IsActive: boolPtr(false), Property: IdNameTypenameRef{Id: "p-count"}, Address: strPtr("inactive")},
+	}
+	resolved := ResolveEquipmentProperties(nil, classChain, overrides)
+
+	if _, exists := resolved["spare"]; exists {
+		t.Errorf("Expect ignored property to be removed")
+	}
+	if p := resolved["count"]; p.Address != "Line3.Filler.Count" || p.Sources.Address != PropertySourceOverride || p.Sources.Value != PropertySourceClass || p.Sources.OverrideId != "o1" {
+		t.Errorf("Unexpected override of count: %+v", p)
+	}
+	if p := resolved["rate"]; p.Expression != "count / 30" || p.Value != "1.5" || p.Sources.Expression != PropertySourceOverride || p.Sources.Value != PropertySourceOverride {
+		t.Errorf("Unexpected override of rate by name: %+v", p)
+	}
+	if p := resolved["temp"]; p.StoreHistory || p.Sources.StoreHistory != PropertySourceOverride || p.Address != "Filler.Temp" {
+		t.Errorf("Unexpected override of temp: %+v", p)
+	}
+}
@@ -0,0 +1,126 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MaterialUse is how a quantity of material was used, the MaterialUse enum of the schema
+type MaterialUse string
+
+const (
+	MaterialUseInitial     MaterialUse = "Initial"
+	MaterialUseProduced    MaterialUse = "Produced"
+	MaterialUseByProduct   MaterialUse = "ByProduct"
+	MaterialUseCoProduct   MaterialUse = "CoProduct"
+	MaterialUseConsumable  MaterialUse = "Consumable"
+	MaterialUseConsumed    MaterialUse = "Consumed"
+	MaterialUseTransferred MaterialUse = "Transferred"
+	MaterialUseScrap       MaterialUse = "Scrap"
+	MaterialUseRework      MaterialUse = "Rework"
+	MaterialUseReceived    MaterialUse = "Received"
+	MaterialUsePacked      MaterialUse = "Packed"
+	MaterialUseShipped     MaterialUse = "Shipped"
+)
+
+var materialUses = []MaterialUse{MaterialUseInitial, MaterialUseProduced, MaterialUseByProduct, MaterialUseCoProduct,
+	MaterialUseConsumable, MaterialUseConsumed, MaterialUseTransferred, MaterialUseScrap, MaterialUseRework,
+	MaterialUseReceived, MaterialUsePacked, MaterialUseShipped}
+
+// ParseMaterialUse reads a material use, ignoring case
+func ParseMaterialUse(text string) (MaterialUse, error) {
+	for _, use := range materialUses {
+		if strings.EqualFold(strings.TrimSpace(text), string(use)) {
+			return use, nil
+		}
+	}
+	return "", fmt.Errorf("unknown material use '%s'", text)
+}
+
+// QuantityLog is a quantity of material produced or used by equipment, usually the increase of a production counter
+type QuantityLog struct {
+	EquipmentId   string      `json:"equipmentId"`
+	JobResponseId string      `json:"jobResponseId,omitempty"`
+	JobLocalId    string      `json:"jobLocalId,omitempty"` // the running job, until it has a data store id
+	Type          MaterialUse `json:"type"`
+	Quantity      float64     `json:"quantity"`
+	ReasonCode    string      `json:"reasonCode,omitempty"`
+	ReasonText    string      `json:"reasonText,omitempty"`
+	Timestamp     time.Time   `json:"timestamp"`
+	Property      string      `json:"property"` // the counter the quantity came from
+}
+
+// ApplyReason replaces the configured code of the quantity with the resolved reason
+func (q *QuantityLog) ApplyReason(reason ResolvedReason) {
+	q.ReasonCode = reason.Reason.Label
+	q.ReasonText = reason.Reason.Text
+}
+
+// CounterChange is what a CounterTracker made of a counter reading
+type CounterChange int
+
+const (
+	CounterBaseline   CounterChange = iota // the first reading, or one after a reset with nothing counted yet
+	CounterIncreased                       // the counter went up
+	CounterRolledOver                      // the counter passed its rollover value and started again from zero
+	CounterReset                           // the counter went down - a PLC reboot or an operator reset
+	CounterIgnored                         // a duplicate, unchanged or out of order reading
+)
+
+// CounterTracker turns the readings of a cumulative counter into the quantities counted between readings
+type CounterTracker struct {
+	// Rollover is the value at which the counter starts again from zero (65536 for an unsigned 16 bit counter);
+	// zero means the counter never rolls over, so any decrease is a reset
+	Rollover float64
+
+	hasReading bool
+	lastValue  float64
+	lastTime   time.Time
+}
+
+// NewCounterTracker starts a tracker with no readings
+func NewCounterTracker(rollover float64) *CounterTracker {
+	return &CounterTracker{Rollover: rollover}
+}
+
+// Seed sets the last reading without counting anything, for example from a value saved before a restart
+func (t *CounterTracker) Seed(value float64, at time.Time) {
+	t.hasReading = true
+	t.lastValue = value
+	t.lastTime = at
+}
+
+// Last returns the last reading, if there is one
+func (t *CounterTracker) Last() (float64, time.Time, bool) {
+	return t.lastValue, t.lastTime, t.hasReading
+}
+
+// Update takes a new reading and returns the quantity counted since the last one.  A decrease is taken as a rollover
+// when the counter has a rollover value and was in its upper half; otherwise it is a reset and the new value is
+// what was counted since the reset.  Readings older than the last one are ignored, so a message delivered twice or
+// late is never counted twice.
+func (t *CounterTracker) Update(value float64, at time.Time) (float64, CounterChange) {
+	if !t.hasReading {
+		t.Seed(value, at)
+		return 0, CounterBaseline
+	}
+	if at.Before(t.lastTime) || value == t.lastValue {
+		if !at.Before(t.lastTime) {
+			t.lastTime = at
+		}
+		return 0, CounterIgnored
+	}
+	last := t.lastValue
+	t.lastValue = value
+	t.lastTime = at
+	switch {
+	case value > last:
+		return value - last, CounterIncreased
+	case t.Rollover > 0 && last >= t.Rollover/2 && value < t.Rollover/2:
+		return t.Rollover - last + value, CounterRolledOver
+	case value > 0:
+		return value, CounterReset
+	}
+	return 0, CounterBaseline
+}
@@ -0,0 +1,61 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCounterTracker(t *testing.T) {
+	start := time.Date(2022, 5, 1, 10, 0, 0, 0, time.UTC)
+	at := func(sec int) time.Time {
+		return start.Add(time.Duration(sec) * time.Second)
+	}
+	tracker := NewCounterTracker(65536)
+	steps := []struct {
+		value    float64
+		sec      int
+		quantity float64
+		change   CounterChange
+	}{
+		{100, 0, 0, CounterBaseline},
+		{110, 1, 10, CounterIncreased},
+		{110, 2, 0, CounterIgnored}, // duplicate message
+		{105, 1, 0, CounterIgnored}, // out of order
+		{65530, 3, 65420, CounterIncreased},
+		{4, 4, 10, CounterRolledOver},
+		{7, 5, 3, CounterIncreased},
+		{2, 6, 2, CounterReset}, // PLC reboot
+		{0, 7, 0, CounterBaseline},
+		{5, 8, 5, CounterIncreased},
+	}
+	for ndx, step := range steps {
+		quantity, change := tracker.Update(step.value, at(step.sec))
+		if quantity != step.quantity || change != step.change {
+			t.Errorf("Step %d: expect %v (%d); got %v (%d)", ndx, step.quantity, step.change, quantity, change)
+		}
+	}
+}
+
+func TestCounterTrackerWithoutRollover(t *testing.T) {
+	tracker := NewCounterTracker(0)
+	now := time.Now()
+	tracker.Seed(60000, now)
+	if quantity, change := tracker.Update(3, now.Add(time.Second)); quantity != 3 || change != CounterReset {
+		t.Errorf("Expect a decrease to be a reset without a rollover value; got %v (%d)", quantity, change)
+	}
+	if value, _, ok := tracker.Last(); !ok || value != 3 {
+		t.Errorf("Expect the last value to be 3; got %v", value)
+	}
+}
+
+func TestParseMaterialUse(t *testing.T) {
+	if use, err := ParseMaterialUse(" produced"); err != nil || use != MaterialUseProduced {
+		t.Errorf("Expect Produced; got %v %v", use, err)
+	}
+	if use, err := ParseMaterialUse("SCRAP"); err != nil || use != MaterialUseScrap {
+		t.Errorf("Expect Scrap; got %v %v", use, err)
+	}
+	if _, err := ParseMaterialUse("Wasted"); err == nil {
+		t.Errorf("Expect an error for an unknown material use")
+	}
+}
@@ -0,0 +1,169 @@
+package domain
+
+// ReasonClass is what a reason explains - lost time or scrapped material
+type ReasonClass string
+
+const (
+	ReasonClassTime  ReasonClass = "Time"
+	ReasonClassScrap ReasonClass = "Scrap"
+)
+
+// ReasonCategory classifies reasons; the time category says how a stop counts against availability
+type ReasonCategory struct {
+	Code         string `json:"code"`
+	TimeCategory string `json:"timeCategory"`
+}
+
+type UnitOfMeasureCodeRef struct {
+	Code string `json:"code"`
+}
+
+// Reason is a stop or scrap reason.  Reasons form a hierarchy; a reason without a category, equipment or equipment
+// class takes them from its parent.
+type Reason struct {
+	Id             string               `json:"id"`
+	IsActive       bool                 `json:"isActive"`
+	Class          ReasonClass          `json:"class"`
+	Label          string               `json:"label"`
+	Text           string               `json:"text"`
+	ErpCode        string               `json:"erpCode"`
+	StandardValue  *float64             `json:"standardValue"`
+	ValueUoM       UnitOfMeasureCodeRef `json:"valueUoM" graphql:"valueUoM"`
+	Category       ReasonCategory       `json:"category"`
+	Parent         IdTypenameRef        `json:"parent"`
+	EquipmentClass IdTypenameRef        `json:"equipmentClass"`
+	Equipment      IdTypenameRef        `json:"equipment"`
+}
+
+// EquipmentReasonOverride changes how a reason applies to one equipment
+type EquipmentReasonOverride struct {
+	Equipment     IdTypenameRef `json:"equipment"`
+	Reason        IdTypenameRef `json:"reason"`
+	Ignore        bool          `json:"ignore"`
+	StandardValue *float64      `json:"standardValue"`
+}
+
+// ResolvedReason is the reason for a code on an equipment, with its classification and the equipment's overrides
+type ResolvedReason struct {
+	Reason        Reason
+	CategoryCode  string
+	TimeCategory  string
+	StandardValue *float64 // the override's standard value, else the reason's
+	Ignore        bool     // the equipment ignores this reason
+}
+
+// ReasonResolver maps PLC fault, stop and scrap codes to the reasons in scope for one equipment
+type ReasonResolver struct {
+	equipmentId string
+	classChain  []string // the class of the equipment, then its parent classes, nearest first
+	byId        map[string]Reason
+	overrides   map[string]EquipmentReasonOverride // of this equipment, by reason id
+}
+
+// NewReasonResolver takes all reasons and overrides; only the ones for the equipment and its classes are used
+func NewReasonResolver(reasons []Reason, overrides []EquipmentReasonOverride, equipmentId string, classChain []string) *ReasonResolver {
+	r := ReasonResolver{
+		equipmentId: equipmentId,
+		classChain:  classChain,
+		byId:        make(map[string]Reason, len(reasons)),
+		overrides:   map[string]EquipmentReasonOverride{},
+	}
+	for _, reason := range reasons {
+		r.byId[reason.Id] = reason
+	}
+	for _, override := range overrides {
+		if override.Equipment.Id == equipmentId {
+			r.overrides[override.Reason.Id] = override
+		}
+	}
+	return &r
+}
+
+// Resolve finds the active reason whose label or ERP code is the code, optionally only of one class.  Reasons of the
+// equipment come before those of its class, then of its parent classes, then reasons with no scope; at the same
+// scope a label match comes before an ERP code match.
+func (r *ReasonResolver) Resolve(code string, class ReasonClass) (ResolvedReason, bool) {
+	var best *Reason
+	bestRank := -1
+	for id := range r.byId {
+		reason := r.byId[id]
+		if !reason.IsActive || (class != "" && reason.Class != class) {
+			continue
+		}
+		var rank int
+		switch code {
+		case reason.Label:
+			rank = 0
+		case reason.ErpCode:
+			rank = 1
+		default:
+			continue
+		}
+		scope, inScope := r.scopeRank(reason)
+		if !inScope {
+			continue
+		}
+		rank += 2 * scope
+		if best == nil || rank < bestRank || (rank == bestRank && reason.Id < best.Id) {
+			best = &reason
+			bestRank = rank
+		}
+	}
+	if best == nil {
+		return ResolvedReason{}, false
+	}
+	resolved := ResolvedReason{Reason: *best, StandardValue: best.StandardValue}
+	r.walkUp(*best, func(reason Reason) bool {
+		if reason.Category.Code != "" {
+			resolved.CategoryCode = reason.Category.Code
+			resolved.TimeCategory = reason.Category.TimeCategory
+			return false
+		}
+		return true
+	})
+	if override, exists := r.overrides[best.Id]; exists {
+		resolved.Ignore = override.Ignore
+		if override.StandardValue != nil {
+			resolved.StandardValue = override.StandardValue
+		}
+	}
+	return resolved, true
+}
+
+// scopeRank is 0 for a reason of the equipment, 1 for its class, 2 for the parent class and so on, and after the
+// classes for a reason with no scope.  A reason of another equipment or class is not in scope.
+func (r *ReasonResolver) scopeRank(reason Reason) (int, bool) {
+	rank, inScope := len(r.classChain)+1, true
+	r.walkUp(reason, func(ancestor Reason) bool {
+		if ancestor.Equipment.Id == "" && ancestor.EquipmentClass.Id == "" {
+			return true
+		}
+		inScope = false
+		if ancestor.Equipment.Id == r.equipmentId {
+			rank, inScope = 0, true
+		}
+		for ndx, classId := range r.classChain {
+			if ancestor.EquipmentClass.Id == classId && (!inScope || ndx+1 < rank) {
+				rank, inScope = ndx+1, true
+			}
+		}
+		return false
+	})
+	return rank, inScope
+}
+
+// walkUp visits the reason and then its ancestors until visit returns false
+func (r *ReasonResolver) walkUp(reason Reason, visit func(reason Reason) bool) {
+	visited := map[string]bool{}
+	for {
+		if visited[reason.Id] || !visit(reason) {
+			return
+		}
+		visited[reason.Id] = true
+		parent, exists := r.byId[reason.Parent.Id]
+		if !exists {
+			return
+		}
+		reason = parent
+	}
+}
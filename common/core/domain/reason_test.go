@@ -0,0 +1,68 @@
+package domain
+
+import "testing"
+
+func TestReasonResolver(t *testing.T) {
+	ten, five := 10.0, 5.0
+	ref := func(id string) IdTypenameRef {
+		return IdTypenameRef{Id: id}
+	}
+	reasons := []Reason{
+		{Id: "r1", IsActive: true, Class: ReasonClassTime, Label: "MECH", Text: "Mechanical", ErpCode: "100",
+			Category: ReasonCategory{Code: "UNPLANNED", TimeCategory: "ADOT"}},
+		{Id: "r2", IsActive: true, Class: ReasonClassTime, Label: "JAM", Text: "Jam", ErpCode: "101", Parent: ref("r1"),
+			EquipmentClass: ref("filler"), StandardValue: &ten},
+		{Id: "r3", IsActive: true, Class: ReasonClassTime, Label: "JAM", Text: "Infeed jam", ErpCode: "102", Equipment: ref("eq1")},
+		{Id: "r4", IsActive: true, Class: ReasonClassTime, Label: "E7", Text: "Guard open", ErpCode: "103", Parent: ref("r2")},
+		{Id: "r5", IsActive: true, Class: ReasonClassTime, Label: "OTHER", Text: "Other line", ErpCode: "104", Equipment: ref("eq2")},
+		{Id: "r6", IsActive: false, Class: ReasonClassTime, Label: "OLD", Text: "Retired", ErpCode: "105"},
+		{Id: "r7", IsActive: true, Class: ReasonClassScrap, Label: "E7", Text: "Scrapped at guard", ErpCode: "106"},
+	}
+	overrides := []EquipmentReasonOverride{
+		{Equipment: ref("eq1"), Reason: ref("r4"), StandardValue: &five},
+		{Equipment: ref("eq1"), Reason: ref("r1"), Ignore: true},
+		{Equipment: ref("eq2"), Reason: ref("r2"), Ignore: true},
+	}
+	resolver := NewReasonResolver(reasons, overrides, "eq1", []string{"filler", "machine"})
+
+	if reason, found := resolver.Resolve("JAM", ReasonClassTime); !found || reason.Reason.Id != "r3" {
+		t.Errorf("Expect the equipment's own reason before its class's; got %+v", reason)
+	}
+	reason, found := resolver.Resolve("E7", ReasonClassTime)
+	if !found || reason.Reason.Id != "r4" {
+		t.Fatalf("Expect the class reason inherited from the parent; got %+v", reason)
+	}
+	if reason.CategoryCode != "UNPLANNED" || reason.TimeCategory != "ADOT" {
+		t.Errorf("Expect the category of the nearest categorised ancestor; got %+v", reason)
+	}
+	if reason.StandardValue == nil || *reason.StandardValue != 5 || reason.Ignore {
+		t.Errorf("Expect the override's standard value; got %+v", reason)
+	}
+	if reason, found := resolver.Resolve("E7", ReasonClassScrap); !found || reason.Reason.Id != "r7" {
+		t.Errorf("Expect the scrap reason; got %+v", reason)
+	}
+	if reason, found := resolver.Resolve("100", ""); !found || reason.Reason.Id != "r1" || !reason.Ignore {
+		t.Errorf("Expect the ERP code to match and the override to ignore it; got %+v", reason)
+	}
+	for _, code := range []string{"OTHER", "OLD", "NOPE"} {
+		if reason, found := resolver.Resolve(code, ""); found {
+			t.Errorf("Expect no reason for %s; got %+v", code, reason)
+		}
+	}
+}
+
+func TestEventLogApplyReason(t *testing.T) {
+	ten := 10.0
+	log := EventLog{ReasonCode: "E7"}
+	log.ApplyReason(ResolvedReason{
+		Reason:        Reason{Label: "GUARD", Text: "Guard open", ValueUoM: UnitOfMeasureCodeRef{Code: "min"}},
+		CategoryCode:  "UNPLANNED",
+		StandardValue: &ten,
+	})
+	if log.ReasonCode != "GUARD" || log.ReasonText != "Guard open" || log.ReasonCategory != "UNPLANNED" {
+		t.Errorf("Expect the resolved reason; got %+v", log)
+	}
+	if log.ReasonValue == nil || *log.ReasonValue != 10 || log.ReasonUoM != "min" {
+		t.Errorf("Expect the standard value when the payload had none; got %+v", log)
+	}
+}
@@ -52,6 +52,7 @@ type StdMessageStruct struct {
 	ItemValue         interface{}          `json:"ItemValue"`
 	ItemOldValue      interface{}          `json:"ItemOldValue"`
 	ItemDataType      string               `json:"ItemDataType"`
+	ItemUoM           string               `json:"ItemUoM,omitempty"`
 	TagQuality        int                  `json:"TagQuality"`
 	Err               *string              `json:"Err"`
 	ChangedTimestamp  time.Time            `json:"ChangedTimestamp"`
@@ -89,10 +90,17 @@ func ConvertTypes(messageStruct StdMessageStruct) StdMessageStruct {
 type EquipmentPropertyDescriptor struct {
 	Name             string
 	DataType         string
+	Type             PropertyType
+	Expression       string
+	Address          string
+	StoreHistory     bool
+	UnitOfMeasure    string
 	Value            interface{}
+	Quality          TagQuality
 	ClassPropertyId  string
 	EquipmentClassId string
 	LastUpdate       time.Time
+	Sources          PropertyAttributeSources
 }
 
 type EquipmentEventDescriptor struct {
@@ -113,6 +121,8 @@ const (
 	SVCRQST_SHUTDOWN_ACK = "SHUTDOWNACK"
 	SVCRQST_TAGDATA      = "TAGDATA"
 	SVCRQST_TAGDATA_ACK  = "TAGDATAACK"
+	// SVCRQST_ERROR_ACK acknowledges a request that failed, with the failure in the message
+	SVCRQST_ERROR_ACK = "ERRORACK"
 )
 
 ///////////////////////////////////////////////////
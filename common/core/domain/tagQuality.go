@@ -0,0 +1,91 @@
+package domain
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// tagQualityMask selects the quality bits of an OPC DA style quality; the lower bits only give the sub status
+const tagQualityMask TagQuality = 0xC0
+
+// IsGood is true for a Good quality of any sub status
+func (q TagQuality) IsGood() bool {
+	return q&tagQualityMask == Good
+}
+
+// IsUncertain is true for an Uncertain quality of any sub status
+func (q TagQuality) IsUncertain() bool {
+	return q&tagQualityMask == Uncertain&tagQualityMask
+}
+
+// IsBad is true for every quality that is neither Good nor Uncertain
+func (q TagQuality) IsBad() bool {
+	return !q.IsGood() && !q.IsUncertain()
+}
+
+// String is the name of the quality, without its sub status
+func (q TagQuality) String() string {
+	switch {
+	case q.IsGood():
+		return "Good"
+	case q.IsUncertain():
+		return "Uncertain"
+	default:
+		return "Bad"
+	}
+}
+
+// rank orders the qualities from Bad to Good
+func (q TagQuality) rank() int {
+	switch {
+	case q.IsGood():
+		return 2
+	case q.IsUncertain():
+		return 1
+	default:
+		return 0
+	}
+}
+
+// WorstTagQuality is the worst of the qualities, e.g. the quality of a value computed from them; it is Good when
+// there are none
+func WorstTagQuality(qualities ...TagQuality) TagQuality {
+	worst := Good
+	for _, q := range qualities {
+		if q.rank() < worst.rank() {
+			worst = q
+		}
+	}
+	return worst
+}
+
+// ParseTagQuality reads a quality name (Good, Uncertain or Bad, in any case) or a number from 0 to 255
+func ParseTagQuality(text string) (TagQuality, error) {
+	text = strings.TrimSpace(text)
+	switch strings.ToUpper(text) {
+	case "GOOD":
+		return Good, nil
+	case "UNCERTAIN":
+		return Uncertain, nil
+	case "BAD":
+		return Bad, nil
+	}
+	number, err := strconv.Atoi(text)
+	if err != nil || number < 0 || number > 255 {
+		return Bad, fmt.Errorf("bad tag quality '%s'", text)
+	}
+	return TagQuality(number), nil
+}
+
+// TagQualityFromOPCUAStatus maps the severity of an OPC UA status code to a quality
+func TagQualityFromOPCUAStatus(status uint32) TagQuality {
+	switch status >> 30 {
+	case 0:
+		return Good
+	case 1:
+		return Uncertain
+	default:
+		return Bad
+	}
+}
@@ -0,0 +1,58 @@
+package domain
+
+import "testing"
+
+func TestTagQualityCategories(t *testing.T) {
+	for _, tc := range []struct {
+		quality TagQuality
+		name    string
+	}{
+		{Good, "Good"},
+		{Good + 24, "Good"},
+		{Uncertain, "Uncertain"},
+		{64, "Uncertain"},
+		{Bad, "Bad"},
+		{1, "Bad"},
+		{128, "Bad"},
+	} {
+		if tc.quality.String() != tc.name {
+			t.Errorf("Expect quality %d to be %s; got %s", int(tc.quality), tc.name, tc.quality)
+		}
+		if tc.quality.IsGood() != (tc.name == "Good") || tc.quality.IsUncertain() != (tc.name == "Uncertain") || tc.quality.IsBad() != (tc.name == "Bad") {
+			t.Errorf("Expect exactly one category for quality %d", int(tc.quality))
+		}
+	}
+}
+
+func TestWorstTagQuality(t *testing.T) {
+	if q := WorstTagQuality(); q != Good {
+		t.Errorf("Expect no qualities to be Good; got %s", q)
+	}
+	if q := WorstTagQuality(Good, Uncertain, Good); q != Uncertain {
+		t.Errorf("Expect Uncertain; got %s", q)
+	}
+	if q := WorstTagQuality(Uncertain, Bad, Good); q != Bad {
+		t.Errorf("Expect Bad; got %s", q)
+	}
+}
+
+func TestParseTagQuality(t *testing.T) {
+	for text, expected := range map[string]TagQuality{"good": Good, " Uncertain ": Uncertain, "BAD": Bad, "192": Good, "68": 68} {
+		if q, err := ParseTagQuality(text); err != nil || q != expected {
+			t.Errorf("Expect '%s' to be %d; got %d %v", text, int(expected), int(q), err)
+		}
+	}
+	for _, bad := range []string{"", "OK", "-1", "256"} {
+		if q, err := ParseTagQuality(bad); err == nil {
+			t.Errorf("Expect an error for '%s'; got %d", bad, int(q))
+		}
+	}
+}
+
+func TestTagQualityFromOPCUAStatus(t *testing.T) {
+	for status, expected := range map[uint32]TagQuality{0x00000000: Good, 0x40920000: Uncertain, 0x80340000: Bad, 0x80000000: Bad} {
+		if q := TagQualityFromOPCUAStatus(status); q != expected {
+			t.Errorf("Expect status 0x%08X to be %s; got %s", status, expected, q)
+		}
+	}
+}
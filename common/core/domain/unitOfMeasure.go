@@ -0,0 +1,154 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+)
+
+type UnitOfMeasureRef struct {
+	Id   string `json:"id"`
+	Code string `json:"code"`
+}
+
+// MaterialDefinitionRef refers to a material definition, which is keyed by its code
+type MaterialDefinitionRef struct {
+	Id   string `json:"id"`
+	Code string `json:"code"`
+}
+
+type UnitOfMeasureConversion struct {
+	Id          string                `json:"id"`
+	IsActive    *bool                 `json:"isActive"`
+	FromUoM     UnitOfMeasureRef      `json:"fromUoM"`
+	ToUoM       UnitOfMeasureRef      `json:"toUoM"`
+	Numerator   float64               `json:"numerator"`
+	Denominator float64               `json:"denominator"`
+	Material    MaterialDefinitionRef `json:"material"`
+}
+
+// UnitConversion converts a value with value * Numerator / Denominator + Offset
+type UnitConversion struct {
+	From        string
+	To          string
+	Numerator   float64
+	Denominator float64
+	Offset      float64
+}
+
+// Apply converts the value from the From unit to the To unit
+func (c UnitConversion) Apply(value float64) float64 {
+	return value*c.Numerator/c.Denominator + c.Offset
+}
+
+// Inverse returns the conversion from the To unit back to the From unit
+func (c UnitConversion) Inverse() UnitConversion {
+	return UnitConversion{
+		From:        c.To,
+		To:          c.From,
+		Numerator:   c.Denominator,
+		Denominator: c.Numerator,
+		Offset:      -c.Offset * c.Denominator / c.Numerator,
+	}
+}
+
+// unitAliases maps the spellings PLCs and models commonly use to one code; a bare "c" or "f" is too ambiguous to alias
+var unitAliases = map[string]string{
+	"°c": "degC", "degc": "degC", "cel": "degC", "celsius": "degC",
+	"°f": "degF", "degf": "degF", "fah": "degF", "fahrenheit": "degF",
+	"k": "K", "kel": "K", "kelvin": "K",
+	"sec": "s", "secs": "s", "second": "s", "seconds": "s",
+	"mins": "min", "minute": "min", "minutes": "min",
+	"hr": "h", "hrs": "h", "hour": "h", "hours": "h",
+}
+
+// builtinUnitFactors are the sizes of linear units relative to a base unit of the same dimension
+var builtinUnitFactors = map[string]struct {
+	dimension string
+	factor    float64
+}{
+	"mm": {"length", 0.001}, "cm": {"length", 0.01}, "m": {"length", 1}, "km": {"length", 1000},
+	"in": {"length", 0.0254}, "ft": {"length", 0.3048},
+	"mg": {"mass", 0.000001}, "g": {"mass", 0.001}, "kg": {"mass", 1}, "t": {"mass", 1000}, "lb": {"mass", 0.45359237},
+	"ml": {"volume", 0.001}, "l": {"volume", 1}, "m3": {"volume", 1000},
+	"ms": {"time", 0.001}, "s": {"time", 1}, "min": {"time", 60}, "h": {"time", 3600},
+	"pa": {"pressure", 1}, "kpa": {"pressure", 1000}, "bar": {"pressure", 100000}, "psi": {"pressure", 6894.757293168},
+}
+
+// NormaliseUnitCode maps common spellings of a unit to one code, so "°F", "degF" and "FAH" compare equal
+func NormaliseUnitCode(code string) string {
+	trimmed := strings.TrimSpace(code)
+	if alias, ok := unitAliases[strings.ToLower(trimmed)]; ok {
+		return alias
+	}
+	return trimmed
+}
+
+// linearFactor returns the dimension and size of a built in unit, including rates such as "mm/min"
+func linearFactor(code string) (string, float64, bool) {
+	parts := strings.Split(code, "/")
+	if len(parts) > 2 {
+		return "", 0, false
+	}
+	lookup := func(unit string) (string, float64, bool) {
+		f, ok := builtinUnitFactors[strings.ToLower(NormaliseUnitCode(unit))]
+		return f.dimension, f.factor, ok
+	}
+	dim, factor, ok := lookup(parts[0])
+	if !ok || len(parts) == 1 {
+		return dim, factor, ok
+	}
+	perDim, perFactor, ok := lookup(parts[1])
+	if !ok {
+		return "", 0, false
+	}
+	return dim + "/" + perDim, factor / perFactor, true
+}
+
+// builtinConversion covers temperature and the linear units known to builtinUnitFactors
+func builtinConversion(from string, to string) (UnitConversion, bool) {
+	temperatures := map[string]UnitConversion{
+		"degC>degF": {Numerator: 9, Denominator: 5, Offset: 32},
+		"degC>K":    {Numerator: 1, Denominator: 1, Offset: 273.15},
+		"degF>K":    {Numerator: 5, Denominator: 9, Offset: 273.15 - 32*5.0/9.0},
+	}
+	if conv, ok := temperatures[from+">"+to]; ok {
+		conv.From, conv.To = from, to
+		return conv, true
+	}
+	if conv, ok := temperatures[to+">"+from]; ok {
+		conv.From, conv.To = to, from
+		return conv.Inverse(), true
+	}
+	fromDim, fromFactor, fromOk := linearFactor(from)
+	toDim, toFactor, toOk := linearFactor(to)
+	if fromOk && toOk && fromDim == toDim {
+		return UnitConversion{From: from, To: to, Numerator: fromFactor, Denominator: toFactor}, true
+	}
+	return UnitConversion{}, false
+}
+
+// FindUnitConversion looks for a conversion between two units - first a configured conversion (in either direction),
+// then the built in temperature and linear conversions
+func FindUnitConversion(from string, to string, conversions []UnitConversion) (UnitConversion, error) {
+	from = NormaliseUnitCode(from)
+	to = NormaliseUnitCode(to)
+	if from == to {
+		return UnitConversion{From: from, To: to, Numerator: 1, Denominator: 1}, nil
+	}
+	for _, conv := range conversions {
+		if conv.Numerator == 0 || conv.Denominator == 0 {
+			continue
+		}
+		convFrom, convTo := NormaliseUnitCode(conv.From), NormaliseUnitCode(conv.To)
+		if convFrom == from && convTo == to {
+			return conv, nil
+		}
+		if convFrom == to && convTo == from {
+			return conv.Inverse(), nil
+		}
+	}
+	if conv, ok := builtinConversion(from, to); ok {
+		return conv, nil
+	}
+	return UnitConversion{}, fmt.Errorf("no conversion from '%s' to '%s'", from, to)
+}
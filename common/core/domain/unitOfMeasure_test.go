@@ -0,0 +1,46 @@
+package domain
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFindUnitConversion(t *testing.T) {
+	configured := []UnitConversion{
+		{From: "case", To: "ea", Numerator: 24, Denominator: 1},
+	}
+	var tests = []struct {
+		from     string
+		to       string
+		value    float64
+		expected float64
+	}{
+		{"°F", "°C", 212, 100},
+		{"degC", "FAH", 100, 212},
+		{"Cel", "K", 0, 273.15},
+		{"K", "°F", 273.15, 32},
+		{"mm/min", "m/min", 1500, 1.5},
+		{"m/min", "m/s", 60, 1},
+		{"kg", "lb", 1, 2.2046226218},
+		{"bar", "kPa", 1.5, 150},
+		{"case", "ea", 2, 48},
+		{"ea", "case", 48, 2},
+		{"m", "m", 7, 7},
+	}
+	for _, test := range tests {
+		conv, err := FindUnitConversion(test.from, test.to, configured)
+		if err != nil {
+			t.Errorf("Expect a conversion from %s to %s; got %s", test.from, test.to, err)
+			continue
+		}
+		if got := conv.Apply(test.value); math.Abs(got-test.expected) > 1e-6 {
+			t.Errorf("Converting %v %s to %s = %v; want %v", test.value, test.from, test.to, got, test.expected)
+		}
+	}
+
+	for _, pair := range [][2]string{{"m", "kg"}, {"degC", "m"}, {"widgets", "ea"}, {"mm/min", "mm"}, {"C", "K"}, {"f", "degC"}} {
+		if _, err := FindUnitConversion(pair[0], pair[1], configured); err == nil {
+			t.Errorf("Expect no conversion from %s to %s", pair[0], pair[1])
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package domain
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Deadband is how far a numeric value must move away from the last value passed on before it is passed on again.
+// It is either an absolute amount, or a percentage of the span between SpanLow and SpanHigh.
+type Deadband struct {
+	Amount   float64
+	Percent  bool
+	SpanLow  float64
+	SpanHigh float64
+}
+
+// ParseDeadband reads "amount" for an absolute deadband, or "percent%,low,high" for a percentage of the span from
+// low to high, e.g. "0.5" or "2%,0,150"
+func ParseDeadband(text string) (Deadband, error) {
+	parts := strings.Split(text, ",")
+	amountText := strings.TrimSpace(parts[0])
+	d := Deadband{Percent: strings.HasSuffix(amountText, "%")}
+	var err error
+	if d.Amount, err = strconv.ParseFloat(strings.TrimSuffix(amountText, "%"), 64); err != nil || d.Amount < 0 || math.IsNaN(d.Amount) {
+		return d, fmt.Errorf("bad deadband '%s'", text)
+	}
+	if !d.Percent {
+		if len(parts) > 1 {
+			return d, fmt.Errorf("bad deadband '%s', only a percentage deadband has a span", text)
+		}
+		return d, nil
+	}
+	if len(parts) != 3 {
+		return d, fmt.Errorf("bad deadband '%s', a percentage deadband needs the low and high of the span", text)
+	}
+	if d.SpanLow, err = strconv.ParseFloat(strings.TrimSpace(parts[1]), 64); err == nil {
+		d.SpanHigh, err = strconv.ParseFloat(strings.TrimSpace(parts[2]), 64)
+	}
+	if err != nil || d.SpanHigh <= d.SpanLow {
+		return d, fmt.Errorf("bad span in deadband '%s'", text)
+	}
+	return d, nil
+}
+
+// Threshold is the absolute amount of the deadband
+func (d Deadband) Threshold() float64 {
+	if d.Percent {
+		return d.Amount / 100 * (d.SpanHigh - d.SpanLow)
+	}
+	return d.Amount
+}
+
+// Passes is true when the value should be passed on, given the last value passed on.  Numbers pass when they are
+// more than the threshold away from the last value, or when they become or stop being NaN.  Booleans, strings that
+// are not numbers and other types pass whenever they are different from the last value, as does a change between
+// a number and another type.
+func (d Deadband) Passes(last interface{}, value interface{}) bool {
+	lastNumber, lastIsNumber := deadbandNumber(last)
+	number, isNumber := deadbandNumber(value)
+	if !lastIsNumber || !isNumber {
+		return lastIsNumber != isNumber || !ValuesEqual(last, value)
+	}
+	if math.IsNaN(lastNumber) || math.IsNaN(number) {
+		return math.IsNaN(lastNumber) != math.IsNaN(number)
+	}
+	return math.Abs(number-lastNumber) > d.Threshold()
+}
+
+// deadbandNumber is the value as a number, if it is one; booleans are not numbers here
+func deadbandNumber(value interface{}) (float64, bool) {
+	if _, isBool := value.(bool); isBool {
+		return 0, false
+	}
+	return ValueAsFloat64(value)
+}
+
+// RateLimit holds back the changes of a value that arrive within MinInterval of the last change passed on.  Only
+// the latest change held back is kept, and it is due once the interval has passed.
+type RateLimit struct {
+	MinInterval time.Duration
+	lastPass    time.Time
+	held        *StdMessageStruct
+}
+
+// Offer is true when the change can be passed on now.  Otherwise the change is held back, replacing any change
+// already held, until the returned time.
+func (r *RateLimit) Offer(change StdMessageStruct, at time.Time) (bool, time.Time) {
+	if !r.lastPass.IsZero() && at.Sub(r.lastPass) < r.MinInterval {
+		r.held = &change
+		return false, r.lastPass.Add(r.MinInterval)
+	}
+	r.lastPass = at
+	r.held = nil
+	return true, time.Time{}
+}
+
+// TakeDue returns the change held back once its interval has passed, counting it as passed on at the given time
+func (r *RateLimit) TakeDue(at time.Time) (StdMessageStruct, bool) {
+	if r.held == nil || at.Before(r.lastPass.Add(r.MinInterval)) {
+		return StdMessageStruct{}, false
+	}
+	change := *r.held
+	r.held = nil
+	r.lastPass = at
+	return change, true
+}
+
+// HEARTBEAT_SUBCATEGORY marks a tag change that repeats an unchanged value
+const HEARTBEAT_SUBCATEGORY = "HEARTBEAT"
+
+// Heartbeat repeats the last change of a value when nothing was passed on for MaxSilence, so a value that did not
+// change can be told from a source that stopped sending
+type Heartbeat struct {
+	MaxSilence time.Duration
+	last       StdMessageStruct
+	lastAt     time.Time
+}
+
+// Seen records a change passed on at the given time
+func (h *Heartbeat) Seen(change StdMessageStruct, at time.Time) {
+	h.last = change
+	h.lastAt = at
+}
+
+// Due returns the heartbeat once MaxSilence has passed since the last change: the last change again, stamped with
+// the given time and marked with the HEARTBEAT_SUBCATEGORY.  The heartbeat counts as passed on.
+func (h *Heartbeat) Due(at time.Time) (StdMessageStruct, bool) {
+	if h.lastAt.IsZero() || at.Sub(h.lastAt) < h.MaxSilence {
+		return StdMessageStruct{}, false
+	}
+	beat := h.last
+	beat.ItemOldValue = beat.ItemValue
+	beat.PreviousTimestamp = beat.ChangedTimestamp
+	beat.ChangedTimestamp = at
+	beat.SubCategory = HEARTBEAT_SUBCATEGORY
+	h.Seen(beat, at)
+	return beat, true
+}
+
+// NextDue is when the next heartbeat is due, if a change has been seen
+func (h *Heartbeat) NextDue() (time.Time, bool) {
+	return h.lastAt.Add(h.MaxSilence), !h.lastAt.IsZero()
+}
@@ -0,0 +1,116 @@
+package domain
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestParseDeadband(t *testing.T) {
+	for _, tc := range []struct {
+		text      string
+		threshold float64
+	}{
+		{"0.5", 0.5},
+		{" 2 ", 2},
+		{"2%,0,150", 3},
+		{"10%, -50, 50", 10},
+		{"0", 0},
+	} {
+		d, err := ParseDeadband(tc.text)
+		if err != nil || d.Threshold() != tc.threshold {
+			t.Errorf("Expect '%s' to have a threshold of %v; got %v %v", tc.text, tc.threshold, d.Threshold(), err)
+		}
+	}
+	for _, bad := range []string{"", "abc", "-1", "2%", "2%,10", "2%,10,5", "0.5,0,10", "NaN"} {
+		if d, err := ParseDeadband(bad); err == nil {
+			t.Errorf("Expect an error for '%s'; got %+v", bad, d)
+		}
+	}
+}
+
+func TestDeadbandPasses(t *testing.T) {
+	d := Deadband{Amount: 1}
+	for _, tc := range []struct {
+		last   interface{}
+		value  interface{}
+		passes bool
+	}{
+		{10.0, 10.5, false},
+		{10.0, 11.0, false},
+		{10.0, 11.01, true},
+		{10, 8.5, true},
+		{"10", 10.8, false},
+		{10.0, math.NaN(), true},
+		{math.NaN(), math.NaN(), false},
+		{math.NaN(), 10.0, true},
+		{true, true, false},
+		{true, false, true},
+		{"Running", "Running", false},
+		{"Running", "Stopped", true},
+		{10.0, "Bad", true},
+		{"Bad", 10.0, true},
+	} {
+		if passes := d.Passes(tc.last, tc.value); passes != tc.passes {
+			t.Errorf("Expect %v after %v to pass %v; got %v", tc.value, tc.last, tc.passes, passes)
+		}
+	}
+	if !(Deadband{}).Passes(1.0, 1.001) || (Deadband{}).Passes(1.0, 1.0) {
+		t.Errorf("Expect a zero deadband to pass every change and nothing else")
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	start := time.Date(2021, 6, 1, 8, 0, 0, 0, time.UTC)
+	r := RateLimit{MinInterval: time.Second}
+	change := func(v int) StdMessageStruct { return StdMessageStruct{ItemName: "Speed", ItemValue: v} }
+	if pass, _ := r.Offer(change(1), start); !pass {
+		t.Errorf("Expect the first change to pass")
+	}
+	if pass, due := r.Offer(change(2), start.Add(300*time.Millisecond)); pass || !due.Equal(start.Add(time.Second)) {
+		t.Errorf("Expect the second change to be held until %s; got %v %s", start.Add(time.Second), pass, due)
+	}
+	r.Offer(change(3), start.Add(600*time.Millisecond))
+	if _, due := r.TakeDue(start.Add(900 * time.Millisecond)); due {
+		t.Errorf("Expect nothing due before the interval has passed")
+	}
+	held, due := r.TakeDue(start.Add(time.Second))
+	if !due || held.ItemValue != 3 {
+		t.Errorf("Expect the last change held to be due; got %v %+v", due, held)
+	}
+	if _, due = r.TakeDue(start.Add(3 * time.Second)); due {
+		t.Errorf("Expect nothing held after it was taken")
+	}
+	if pass, _ := r.Offer(change(4), start.Add(1500*time.Millisecond)); pass {
+		t.Errorf("Expect the interval to run from when the held change was passed on")
+	}
+	if pass, _ := r.Offer(change(5), start.Add(2*time.Second)); !pass {
+		t.Errorf("Expect a change after the interval to pass")
+	}
+	if _, due = r.TakeDue(start.Add(5 * time.Second)); due {
+		t.Errorf("Expect a passed change to replace the held one")
+	}
+}
+
+func TestHeartbeat(t *testing.T) {
+	start := time.Date(2021, 6, 1, 8, 0, 0, 0, time.UTC)
+	h := Heartbeat{MaxSilence: 10 * time.Second}
+	if _, due := h.Due(start); due {
+		t.Errorf("Expect no heartbeat before a change was seen")
+	}
+	h.Seen(StdMessageStruct{ItemName: "Temp", ItemValue: 21.5, ChangedTimestamp: start}, start)
+	if next, _ := h.NextDue(); !next.Equal(start.Add(10 * time.Second)) {
+		t.Errorf("Unexpected next heartbeat %s", next)
+	}
+	if _, due := h.Due(start.Add(9 * time.Second)); due {
+		t.Errorf("Expect no heartbeat within the silence")
+	}
+	beat, due := h.Due(start.Add(10 * time.Second))
+	if !due || beat.ItemValue != 21.5 || beat.ItemOldValue != 21.5 || beat.SubCategory != HEARTBEAT_SUBCATEGORY ||
+		!beat.ChangedTimestamp.Equal(start.Add(10*time.Second)) || !beat.PreviousTimestamp.Equal(start) {
+		t.Errorf("Unexpected heartbeat %v %+v", due, beat)
+	}
+	if _, due = h.Due(start.Add(15 * time.Second)); due {
+		t.Errorf("Expect the silence to start again after a heartbeat")
+	}
+}
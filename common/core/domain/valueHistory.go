@@ -0,0 +1,200 @@
+package domain
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// TimedValue is one entry of a property value history
+type TimedValue struct {
+	Value interface{}
+	Time  time.Time
+}
+
+// ValueWindowStats holds the time weighted statistics of a numeric value over a window.  The history only holds so
+// many values, so Covered tells how much of the window the statistics are taken over; it is less than the window
+// when the oldest value held is newer than the start of the window.
+type ValueWindowStats struct {
+	Average float64
+	Min     float64
+	Max     float64
+	Samples int
+	Covered time.Duration
+}
+
+// CoversWindow is true when the statistics are taken over the whole of a window of the given length
+func (s ValueWindowStats) CoversWindow(window time.Duration) bool {
+	return s.Covered >= window
+}
+
+// ValueHistory is a fixed size ring buffer of recent values, oldest first.  It is not safe for concurrent use - the
+// owner is expected to hold its own lock.
+type ValueHistory struct {
+	values []TimedValue
+	start  int
+	count  int
+}
+
+// NewValueHistory creates a history holding up to capacity values (at least one)
+func NewValueHistory(capacity int) *ValueHistory {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &ValueHistory{values: make([]TimedValue, capacity)}
+}
+
+// Add records a value, dropping the oldest value when the buffer is full.  Values are expected in time order.
+func (h *ValueHistory) Add(value interface{}, t time.Time) {
+	ndx := (h.start + h.count) % len(h.values)
+	h.values[ndx] = TimedValue{Value: value, Time: t}
+	if h.count < len(h.values) {
+		h.count++
+	} else {
+		h.start = (h.start + 1) % len(h.values)
+	}
+}
+
+// Len returns the number of values held
+func (h *ValueHistory) Len() int {
+	return h.count
+}
+
+func (h *ValueHistory) at(i int) TimedValue {
+	return h.values[(h.start+i)%len(h.values)]
+}
+
+// Last returns up to n of the most recent values, oldest first
+func (h *ValueHistory) Last(n int) []TimedValue {
+	if n > h.count {
+		n = h.count
+	}
+	if n < 0 {
+		n = 0
+	}
+	ret := make([]TimedValue, 0, n)
+	for i := h.count - n; i < h.count; i++ {
+		ret = append(ret, h.at(i))
+	}
+	return ret
+}
+
+// ValueAt returns the value in effect at time t; false if t is before the oldest value held
+func (h *ValueHistory) ValueAt(t time.Time) (interface{}, bool) {
+	for i := h.count - 1; i >= 0; i-- {
+		if entry := h.at(i); !entry.Time.After(t) {
+			return entry.Value, true
+		}
+	}
+	return nil, false
+}
+
+// segments calls fxn for each span of time within [from, to] during which one value was in effect
+func (h *ValueHistory) segments(from time.Time, to time.Time, fxn func(value interface{}, span time.Duration)) {
+	for i := 0; i < h.count; i++ {
+		entry := h.at(i)
+		segStart := entry.Time
+		segEnd := to
+		if i+1 < h.count {
+			segEnd = h.at(i + 1).Time
+		}
+		if segStart.Before(from) {
+			segStart = from
+		}
+		if segEnd.After(to) {
+			segEnd = to
+		}
+		if segEnd.After(segStart) {
+			fxn(entry.Value, segEnd.Sub(segStart))
+		}
+	}
+}
+
+// WindowStats computes the time weighted average, minimum and maximum of the values in effect during [from, to], along
+// with the part of the window the values held cover.  Non numeric values are skipped; an error is returned if no
+// numeric value was in effect.
+func (h *ValueHistory) WindowStats(from time.Time, to time.Time) (ValueWindowStats, error) {
+	stats := ValueWindowStats{Min: math.Inf(1), Max: math.Inf(-1)}
+	var weighted float64
+	var total time.Duration
+	h.segments(from, to, func(value interface{}, span time.Duration) {
+		stats.Covered += span
+		num, ok := ValueAsFloat64(value)
+		if !ok {
+			return
+		}
+		weighted += num * span.Seconds()
+		total += span
+		stats.Samples++
+		stats.Min = math.Min(stats.Min, num)
+		stats.Max = math.Max(stats.Max, num)
+	})
+	if stats.Samples == 0 {
+		return ValueWindowStats{}, fmt.Errorf("no numeric values between %s and %s", from, to)
+	}
+	if total > 0 {
+		stats.Average = weighted / total.Seconds()
+	}
+	return stats, nil
+}
+
+// DurationInValue returns how long during [from, to] the value was equal to the given value
+func (h *ValueHistory) DurationInValue(value interface{}, from time.Time, to time.Time) time.Duration {
+	var total time.Duration
+	h.segments(from, to, func(v interface{}, span time.Duration) {
+		if ValuesEqual(v, value) {
+			total += span
+		}
+	})
+	return total
+}
+
+// ValueAsFloat64 converts numeric (and numeric string) values for use in calculations; booleans count as 0/1
+func ValueAsFloat64(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// ValuesEqual compares two property values, numerically if both are numeric
+func ValuesEqual(a interface{}, b interface{}) bool {
+	if af, ok := ValueAsFloat64(a); ok {
+		if bf, ok := ValueAsFloat64(b); ok {
+			return af == bf
+		}
+	}
+	return fmt.Sprintf("%v", a) == fmt.Sprintf("%v", b)
+}
@@ -0,0 +1,107 @@
+package domain
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValueHistoryRing(t *testing.T) {
+	base := time.Date(2021, 8, 13, 10, 0, 0, 0, time.UTC)
+	h := NewValueHistory(3)
+	for i := 1; i <= 5; i++ {
+		h.Add(i, base.Add(time.Duration(i)*time.Second))
+	}
+	if h.Len() != 3 {
+		t.Fatalf("Len() = %d; want 3", h.Len())
+	}
+	last := h.Last(10)
+	if len(last) != 3 || last[0].Value != 3 || last[2].Value != 5 {
+		t.Errorf("Last(10) = %+v; want values 3,4,5", last)
+	}
+	last = h.Last(2)
+	if len(last) != 2 || last[0].Value != 4 || last[1].Value != 5 {
+		t.Errorf("Last(2) = %+v; want values 4,5", last)
+	}
+}
+
+func TestValueHistoryValueAt(t *testing.T) {
+	base := time.Date(2021, 8, 13, 10, 0, 0, 0, time.UTC)
+	h := NewValueHistory(10)
+	h.Add(1.0, base)
+	h.Add(2.0, base.Add(10*time.Second))
+
+	var tests = []struct {
+		at       time.Time
+		expected interface{}
+		found    bool
+	}{
+		{base.Add(-time.Second), nil, false},
+		{base, 1.0, true},
+		{base.Add(9 * time.Second), 1.0, true},
+		{base.Add(10 * time.Second), 2.0, true},
+		{base.Add(time.Hour), 2.0, true},
+	}
+	for _, test := range tests {
+		val, found := h.ValueAt(test.at)
+		if found != test.found || val != test.expected {
+			t.Errorf("ValueAt(%s) = %v, %t; want %v, %t", test.at, val, found, test.expected, test.found)
+		}
+	}
+}
+
+func TestValueHistoryWindowStats(t *testing.T) {
+	base := time.Date(2021, 8, 13, 10, 0, 0, 0, time.UTC)
+	h := NewValueHistory(10)
+	h.Add(100.0, base)
+	h.Add(200.0, base.Add(30*time.Second))
+	h.Add(50.0, base.Add(40*time.Second))
+
+	//window starts part way through the first value: 100 for 20s, 200 for 10s, 50 for 10s
+	stats, err := h.WindowStats(base.Add(10*time.Second), base.Add(50*time.Second))
+	if err != nil {
+		t.Fatalf("WindowStats failed: %s", err)
+	}
+	if stats.Average != 112.5 || stats.Min != 50 || stats.Max != 200 || stats.Samples != 3 {
+		t.Errorf("WindowStats = %+v; want average 112.5, min 50, max 200, 3 samples", stats)
+	}
+	if stats.Covered != 40*time.Second || !stats.CoversWindow(40*time.Second) {
+		t.Errorf("WindowStats covered %s; want the whole 40s window", stats.Covered)
+	}
+
+	//window starting before the oldest value only covers the part from the oldest value on
+	stats, err = h.WindowStats(base.Add(-20*time.Second), base.Add(20*time.Second))
+	if err != nil || stats.Covered != 20*time.Second || stats.CoversWindow(40*time.Second) {
+		t.Errorf("WindowStats before the oldest value = %+v, %v; want 20s of the 40s window covered", stats, err)
+	}
+
+	//window after the last change only sees the last value
+	stats, err = h.WindowStats(base.Add(45*time.Second), base.Add(60*time.Second))
+	if err != nil || stats.Average != 50 || stats.Min != 50 || stats.Max != 50 {
+		t.Errorf("WindowStats after last change = %+v, %v; want 50 throughout", stats, err)
+	}
+
+	if _, err = h.WindowStats(base.Add(-time.Minute), base.Add(-time.Second)); err == nil {
+		t.Errorf("Expect an error for a window before any values")
+	}
+}
+
+func TestValueHistoryDurationInValue(t *testing.T) {
+	base := time.Date(2021, 8, 13, 10, 0, 0, 0, time.UTC)
+	h := NewValueHistory(10)
+	h.Add("Execute", base)
+	h.Add("Held", base.Add(20*time.Second))
+	h.Add("Execute", base.Add(25*time.Second))
+
+	if d := h.DurationInValue("Execute", base, base.Add(time.Minute)); d != 55*time.Second {
+		t.Errorf("DurationInValue(Execute) = %s; want 55s", d)
+	}
+	if d := h.DurationInValue("Held", base.Add(22*time.Second), base.Add(time.Minute)); d != 3*time.Second {
+		t.Errorf("DurationInValue(Held) = %s; want 3s", d)
+	}
+
+	n := NewValueHistory(10)
+	n.Add(int64(1), base)
+	if d := n.DurationInValue(1.0, base, base.Add(time.Second)); d != time.Second {
+		t.Errorf("Expect numeric comparison across types; got %s", d)
+	}
+}
@@ -2,6 +2,7 @@ package ports
 
 import (
 	"sync"
+	"time"
 )
 
 type DaemonAdminCommand struct {
@@ -11,6 +12,19 @@ type DaemonAdminCommand struct {
 	Results map[string]interface{}
 }
 
+//DaemonScheduledJob describes a command that a daemon submits to itself on a cron expression or interval
+type DaemonScheduledJob struct {
+	Name     string
+	Daemon   string
+	Command  string
+	Schedule string
+	Params   map[string]interface{}
+	LastRun  time.Time
+	NextRun  time.Time
+	RunCount int
+	LastErr  string
+}
+
 type DaemonCommandFunction func(d DaemonIF, params map[string]interface{}) (map[string]interface{}, error)
 
 type DaemonIF interface {
@@ -30,6 +44,9 @@ type DaemonIF interface {
 	RemoveDaemonChild(DaemonChild DaemonIF)
 	SetTerminationWaitGroup(wg *sync.WaitGroup)
 	GetCommands() map[DaemonCommandIF]DaemonCommandFunction
+	AddScheduledCommand(jobName string, schedule string, cmd DaemonCommandIF, params map[string]interface{}) error
+	RemoveScheduledCommand(jobName string)
+	GetSchedule() []DaemonScheduledJob
 }
 
 type DaemonStateIF interface {
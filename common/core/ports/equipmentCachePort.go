@@ -1,21 +1,65 @@
 package ports
 
+import "github.com/Spruik/libre-common/common/core/domain"
+
 //The EquipmentCachePort interface defines the functions to support the caching of equipment references
 type EquipmentCachePort interface {
 
 	//RefreshCache builds/rebuilds the internal cache of equipment references based on the given configuration map
 	RefreshCache()
-	//GetEquipmentCacheList returns the entire cache as a map keyed by the equipment name
-	GetEquipmentCacheList() *map[string]*ManagedEquipmentPort
+	//GetEquipmentCacheSnapshot returns the cached equipment, ordered by name.  The list is a copy that later
+	//  refreshes do not change
+	GetEquipmentCacheSnapshot() []*ManagedEquipmentPort
+	//ForEachEquipment calls visit for each cached equipment, ordered by name, until visit returns false
+	ForEachEquipment(visit func(mgdEq *ManagedEquipmentPort) bool)
 	//GetCachedEquipmentItem returns the managed equipment structure keyed by the given equipment name
 	GetCachedEquipmentItem(equipName string) *ManagedEquipmentPort
 	GetCachedEquipmentItemById(equipId string) *ManagedEquipmentPort
-	SetEquipmentChangeNoticeFunction(handlingFxn func(notice EquipmentCacheChangeNotice))
+	//SubscribeToChanges registers a listener for equipment and property changes found by RefreshCache.
+	//  The returned id is used to unsubscribe
+	SubscribeToChanges(listener func(notice EquipmentCacheChangeNotice)) int
+	//UnsubscribeToChanges removes the listener registered with the given id
+	UnsubscribeToChanges(subscriptionId int)
 	StartMonitoring()
 	StopMonitoring()
+
+	//GetParent returns the parent of the equipment in the hierarchy - the parent need not be a cached equipment
+	GetParent(equipId string) (domain.EquipmentHierarchyNode, bool)
+	//GetChildren returns the direct children of the equipment that are known to the cache
+	GetChildren(equipId string) []domain.EquipmentHierarchyNode
+	//GetAncestors returns the ancestors of the equipment, nearest first
+	GetAncestors(equipId string) []domain.EquipmentHierarchyNode
+	//GetDescendants returns all equipment below the equipment that are known to the cache
+	GetDescendants(equipId string) []domain.EquipmentHierarchyNode
+	//GetEquipmentPath returns the ISA-95 path of the equipment, for example "Enterprise/Site/Area/Line"
+	GetEquipmentPath(equipId string) string
+	//GetEquipmentNodeByPath returns the equipment (cached or ancestor) at the given ISA-95 path
+	GetEquipmentNodeByPath(path string) (domain.EquipmentHierarchyNode, bool)
+	//GetCachedEquipmentItemByPath returns the managed equipment at the given ISA-95 path
+	GetCachedEquipmentItemByPath(path string) *ManagedEquipmentPort
+	//RollupProperty aggregates (SUM, AVG, MIN, MAX, COUNT) a numeric property across the cached descendants of the equipment
+	RollupProperty(equipId string, propName string, aggregate string) (float64, error)
 }
 
+type EquipmentCacheChangeType string
+
+const (
+	EquipmentCacheAdd                     EquipmentCacheChangeType = "ADD"
+	EquipmentCacheRemove                  EquipmentCacheChangeType = "REMOVE"
+	EquipmentCacheClassChanged            EquipmentCacheChangeType = "CLASS_CHANGED"
+	EquipmentCachePropertyAdded           EquipmentCacheChangeType = "PROPERTY_ADDED"
+	EquipmentCachePropertyRemoved         EquipmentCacheChangeType = "PROPERTY_REMOVED"
+	EquipmentCachePropertyDataTypeChanged EquipmentCacheChangeType = "PROPERTY_DATATYPE_CHANGED"
+	EquipmentCachePropertyRedefined       EquipmentCacheChangeType = "PROPERTY_REDEFINED"
+)
+
+//EquipmentCacheChangeNotice describes one change found by RefreshCache.  PropertyName is set for the property changes;
+//  OldValue and NewValue hold the data types for PROPERTY_DATATYPE_CHANGED, the addresses for PROPERTY_REDEFINED and
+//  the class ids for CLASS_CHANGED
 type EquipmentCacheChangeNotice struct {
-	ChangeType string //TODO - should probably be an enum
-	EqId       string
+	ChangeType   EquipmentCacheChangeType
+	EqId         string
+	PropertyName string
+	OldValue     string
+	NewValue     string
 }
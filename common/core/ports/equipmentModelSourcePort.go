@@ -0,0 +1,12 @@
+package ports
+
+import "github.com/Spruik/libre-common/common/core/domain"
+
+//The EquipmentModelSourcePort interface supplies the equipment model when it does not come from the data store,
+//  for example from a file at a site with no GraphQL server
+type EquipmentModelSourcePort interface {
+	//GetResolvedProperties returns the properties of the equipment with class properties and overrides applied
+	GetResolvedProperties(eqId string) (map[string]domain.ResolvedProperty, error)
+	//GetEquipmentById returns the equipment with the given id, active or not
+	GetEquipmentById(eqId string) (domain.Equipment, error)
+}
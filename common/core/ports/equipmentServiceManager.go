@@ -31,4 +31,8 @@ type EquipmentServiceManagerIF interface {
 	Start(wg *sync.WaitGroup) error
 	//Shutdown is called to tear down and end the processing
 	Shutdown() error
+	//GetRunnerStats reports the state of the processing thread of every live equipment
+	GetRunnerStats() []EquipmentRunnerStats
+	//GetFilterStats reports the counts of every value change filter of every live equipment
+	GetFilterStats() []ValueChangeFilterStats
 }
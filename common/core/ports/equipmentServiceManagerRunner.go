@@ -3,11 +3,34 @@ package ports
 import (
 	"github.com/Spruik/libre-common/common/core/domain"
 	"sync"
+	"time"
 )
 
 //The EquipmentServiceManagerRunnerIF interface defines the handler function called by the EquipmentServiceManager
 //  for each "live" equipment (in a separate thread)
 type EquipmentServiceManagerRunnerIF interface {
-	Prepare(eqId string)
-	Run(rqstChannel chan domain.EquipmentServiceRequest, wg *sync.WaitGroup)
+	//Prepare binds the runner to the managed equipment and initializes the tag change handlers
+	Prepare(mgdEq *ManagedEquipmentPort)
+	//Run starts the processing thread for the equipment - the WaitGroup is marked done when the thread ends
+	Run(wg *sync.WaitGroup)
+	//SendRequest passes a request to the processing thread and waits for the acknowledgement
+	SendRequest(request domain.EquipmentServiceRequest) domain.EquipmentServiceRequest
+	//Stop sends a shutdown request and waits for the processing thread to end
+	Stop() error
+	//GetStats reports the queue depth and processing latency of the runner
+	GetStats() EquipmentRunnerStats
+}
+
+//EquipmentRunnerStats reports the state of the processing thread for one equipment
+type EquipmentRunnerStats struct {
+	EquipmentId    string
+	EquipmentName  string
+	Running        bool
+	QueueDepth     int
+	Processed      int64
+	Failed         int64
+	Restarts       int
+	LastLatency    time.Duration
+	AverageLatency time.Duration
+	MaxLatency     time.Duration
 }
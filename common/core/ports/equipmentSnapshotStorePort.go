@@ -0,0 +1,14 @@
+package ports
+
+import "github.com/Spruik/libre-common/common/core/domain"
+
+//The EquipmentSnapshotStorePort interface defines a local store for managed equipment state, so that the equipment
+//  can be warm started after a restart
+type EquipmentSnapshotStorePort interface {
+	//SaveSnapshot stores the snapshot, replacing any earlier snapshot of the same equipment
+	SaveSnapshot(snapshot domain.EquipmentSnapshot) error
+	//LoadSnapshot returns the latest snapshot of the equipment, or nil if there is none
+	LoadSnapshot(equipmentId string) (*domain.EquipmentSnapshot, error)
+	//DeleteSnapshot removes the snapshot of the equipment
+	DeleteSnapshot(equipmentId string) error
+}
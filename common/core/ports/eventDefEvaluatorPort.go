@@ -4,4 +4,7 @@ import "github.com/Spruik/libre-common/common/core/domain"
 
 type EventDefEvaluatorPort interface {
 	EvaluateEventDef(mgdEq *ManagedEquipmentPort, eventDefId string, evalContext *map[string]interface{}) (bool, *domain.EventDefinition, map[string]interface{}, error)
+	//EvaluateExpression evaluates an expression against the equipment properties, with the same functions as
+	//  trigger expressions - used for the end conditions of extended events
+	EvaluateExpression(mgdEq *ManagedEquipmentPort, expression string, evalContext *map[string]interface{}) (interface{}, error)
 }
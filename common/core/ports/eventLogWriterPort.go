@@ -0,0 +1,14 @@
+package ports
+
+import "github.com/Spruik/libre-common/common/core/domain"
+
+//The EventLogWriterPort interface persists the EventLog records of extended equipment events.  Writes are queued so
+//  that a slow or unavailable data store never holds up tag change handling.
+type EventLogWriterPort interface {
+	//EventStarted queues the write of a newly opened event; persisted is called with the data store id once written
+	EventStarted(log domain.EventLog, persisted func(id string))
+	//EventEnded queues the write of the end of an event.  An event that was never written is added complete.
+	EventEnded(log domain.EventLog)
+	//Close stops taking writes and waits a while for the queued writes to finish
+	Close() error
+}
@@ -0,0 +1,12 @@
+package ports
+
+import "github.com/Spruik/libre-common/common/core/domain"
+
+//The HistorianWriterPort interface writes points to the historian in batches.  Writes are queued so that a slow or
+//  unavailable historian never holds up tag change handling.
+type HistorianWriterPort interface {
+	//WritePoint queues the write of the point
+	WritePoint(point domain.HistorianPoint)
+	//Close stops taking writes and waits a while for the queued points to be written
+	Close() error
+}
@@ -0,0 +1,22 @@
+package ports
+
+import (
+	"time"
+
+	"github.com/Spruik/libre-common/common/core/domain"
+)
+
+//The JobTrackerPort interface tracks the job running on each equipment, opening a JobResponse when an order starts
+//  and closing it when the order ends
+type JobTrackerPort interface {
+	//StartJob starts a job for the job order on the equipment, ending the job already running there
+	StartJob(equipmentId string, jobOrder string, start time.Time) domain.JobResponse
+	//EndJob ends the job running on the equipment, if there is one
+	EndJob(equipmentId string, end time.Time) (domain.JobResponse, bool)
+	//GetCurrentJob returns the job running on the equipment, if there is one
+	GetCurrentJob(equipmentId string) (domain.JobResponse, bool)
+	//GetJobResponseId returns the data store id of a job once it has been written
+	GetJobResponseId(localId string) (string, bool)
+	//IsOrderStartEvent is true for the event definitions of an OrderStartRuleset
+	IsOrderStartEvent(eventDefId string) bool
+}
@@ -1,6 +1,7 @@
 package ports
 
 import (
+	"github.com/Spruik/libre-common/common/core/domain"
 	"github.com/influxdata/influxdb-client-go/v2/api"
 	"time"
 )
@@ -16,6 +17,9 @@ type LibreHistorianPort interface {
 
 	AddDataPointRaw(measurement string, tags map[string]string, fields map[string]interface{}, ts time.Time) error
 
+	//AddDataPointsRaw writes the points together, in one request to the data store
+	AddDataPointsRaw(points []domain.HistorianPoint) error
+
 	AddEqPropDataPoint(measurement string, eqId string, eqName string, propId string, propName string, propValue interface{}, ts time.Time) error
 
 	QueryRaw(query string) (*api.QueryTableResult, error)
@@ -2,11 +2,23 @@ package ports
 
 import (
 	"github.com/Spruik/libre-common/common/core/domain"
+	"time"
 )
 
 type ManagedEquipmentPort interface {
 	UpdatePropertyValue(propName string, propValue interface{}) error
+	//UpdatePropertyValueAndQuality updates a property with the quality its source reported; UpdatePropertyValue
+	//  counts the value as Good
+	UpdatePropertyValueAndQuality(propName string, propValue interface{}, quality domain.TagQuality) error
 	AddEvent(eventName string, eventDesc domain.EquipmentEventDescriptor) error
+	//OpenEventLog starts an extended event; it fails if an event of the same definition is already open
+	OpenEventLog(log domain.EventLog) error
+	//GetOpenEventLogs returns the extended events that have not ended, oldest first
+	GetOpenEventLogs() []domain.EventLog
+	//CloseEventLog ends the open event of the definition, returning it with its end time and duration
+	CloseEventLog(eventDefinitionId string, end time.Time) (domain.EventLog, bool)
+	//SetEventLogId records the data store id of an open event once it has been written
+	SetEventLogId(localId string, id string)
 	SetConfigLevel(level int)
 	GetConfigLevel() int
 	SendRequest(request domain.EquipmentServiceRequest) domain.EquipmentServiceRequest
@@ -15,10 +27,31 @@ type ManagedEquipmentPort interface {
 	GetEquipmentName() string
 	GetEquipmentDescription() string
 	GetEquipmentLevel() string
+	GetEquipmentClassId() string
+	GetEquipmentClassName() string
+	//UpdateEquipmentDefinition replaces the equipment record (class, description, parent) after a cache refresh
+	UpdateEquipmentDefinition(eqInst domain.Equipment)
+	//SetPropertyDefinition adds or redefines a property; the current value is kept unless the data type changed
+	SetPropertyDefinition(desc domain.EquipmentPropertyDescriptor)
+	//RemoveProperty drops a property that is no longer defined for the equipment
+	RemoveProperty(propName string)
 	GetPropertyValue(propName string) interface{}
 	GetPropertyMap() map[string]domain.EquipmentPropertyDescriptor
 	GetEventList() *[]domain.EquipmentEventDescriptor
 	GetProperty(name string) domain.EquipmentPropertyDescriptor
+	//GetPropertyHistory returns up to n of the most recent values of the property, oldest first
+	GetPropertyHistory(propName string, n int) []domain.TimedValue
+	//GetPropertyValueAt returns the value the property had at the given time, if it is still in the history
+	GetPropertyValueAt(propName string, at time.Time) (interface{}, bool)
+	//GetPropertyWindowStats returns the time weighted average, min and max of the property over the recent window,
+	//  and how much of the window the history held covers
+	GetPropertyWindowStats(propName string, window time.Duration) (domain.ValueWindowStats, error)
+	//GetPropertyDurationInValue returns how long during the recent window the property had the given value
+	GetPropertyDurationInValue(propName string, value interface{}, window time.Duration) time.Duration
+	//Snapshot captures the property values and events for persistence
+	Snapshot() domain.EquipmentSnapshot
+	//RestoreSnapshot reloads property values and events saved by Snapshot
+	RestoreSnapshot(snapshot domain.EquipmentSnapshot) error
 }
 
 /////////////////////////////////////////////////////////////////////////////////
@@ -0,0 +1,22 @@
+package ports
+
+import (
+	"time"
+
+	"github.com/Spruik/libre-common/common/core/domain"
+)
+
+//The OEECalculatorPort interface computes the Availability, Performance, Quality and OEE of each equipment from its
+//  machine state, its production counts, its ideal cycle time and the planned busy time of its work calendar
+type OEECalculatorPort interface {
+	//RecordMachineState records a PackML state change of the equipment; it is running while in Execute
+	RecordMachineState(mgdEq *ManagedEquipmentPort, state domain.MachineState, at time.Time)
+	//RecordCount records units made by the equipment, of which defective were defective
+	RecordCount(mgdEq *ManagedEquipmentPort, total float64, defective float64, at time.Time)
+	//GetOEE returns the measures of the current period of the kind for the equipment so far
+	GetOEE(equipmentId string, kind domain.OEEPeriodKind) (domain.OEEPeriod, bool)
+	//Start begins computing and publishing the results periodically
+	Start() error
+	//Stop ends the periodic computing
+	Stop()
+}
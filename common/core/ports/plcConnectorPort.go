@@ -33,7 +33,16 @@ type PlcConnectorPort interface {
 	// provide the tag data to the caller via the channel.  Changes are identified using the changeFiler in an
 	// implementation-specific way
 	ListenForPlcTagChanges(c chan domain.StdMessageStruct, changeFilter map[string]interface{})
-	Unsubscribe(equipmentId *string, topicList []string) error
+
+	//Unsubscribe stops listening for the given tags of a client.  The client is the "Client" entry and the tag
+	// names are the "Topic" values of the changeFilter given to ListenForPlcTagChanges; the implementor maps them
+	// to its own subscriptions the same way
+	Unsubscribe(clientName *string, tagNames []string) error
+
+	//StopListening forgets the channel given to ListenForPlcTagChanges for the client; changes that still arrive
+	// for the client afterwards are dropped
+	StopListening(clientName string)
+
 	//GetTagHistory requests all of the changes to the given tags during the specified time range
 	GetTagHistory(startTS time.Time, endTS time.Time, inTagDefs []domain.StdMessageStruct) []domain.StdMessageStruct
 }
@@ -0,0 +1,12 @@
+package ports
+
+import "github.com/Spruik/libre-common/common/core/domain"
+
+//The QuantityLogWriterPort interface persists QuantityLog records.  Writes are queued so that a slow or unavailable
+//  data store never holds up tag change handling.
+type QuantityLogWriterPort interface {
+	//WriteQuantityLog queues the write of the quantity
+	WriteQuantityLog(log domain.QuantityLog)
+	//Close stops taking writes and waits a while for the queued writes to finish
+	Close() error
+}
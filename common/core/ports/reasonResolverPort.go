@@ -0,0 +1,11 @@
+package ports
+
+import "github.com/Spruik/libre-common/common/core/domain"
+
+//The ReasonResolverPort interface maps PLC fault, stop and scrap codes to the Reason hierarchy of the data store
+type ReasonResolverPort interface {
+	//ResolveReason returns the reason for the code in scope for the equipment; an empty class matches any class
+	ResolveReason(equipmentId string, equipmentClassId string, code string, class domain.ReasonClass) (domain.ResolvedReason, bool)
+	//Refresh reloads the reasons and overrides
+	Refresh()
+}
@@ -0,0 +1,9 @@
+package ports
+
+//The UnitOfMeasureConverterPort interface defines the conversion of property values between units of measure
+type UnitOfMeasureConverterPort interface {
+	//Refresh reloads the configured conversions from the data store
+	Refresh() error
+	//Convert converts a numeric value from one unit to another
+	Convert(value float64, fromUoM string, toUoM string) (float64, error)
+}
@@ -9,6 +9,34 @@ type ValueChangeFilterPort interface {
 	PassValueThrough(tagChange domain.StdMessageStruct) (bool, error)
 }
 
+//The ValueChangeEmitterPort interface is also provided by filters that pass changes on by themselves, such as a
+//  change held back until its rate limit allows it or the heartbeat of an unchanged value.  The emitted changes go
+//  through the filters after the emitting one.
+type ValueChangeEmitterPort interface {
+	//StartEmitting gives the filter the function that passes its own changes on
+	StartEmitting(emit func(tagChange domain.StdMessageStruct))
+	//StopEmitting ends the emitting; changes emitted after it are dropped
+	StopEmitting()
+}
+
+//ValueChangeFilterStats counts what one filter of an equipment did with the changes given to it, and the changes it
+//  passed on by itself
+type ValueChangeFilterStats struct {
+	EquipmentId   string
+	EquipmentName string
+	Filter        string
+	Passed        int64
+	Dropped       int64
+	Failed        int64
+	Emitted       int64
+}
+
+//The ValueChangeFilterStatsPort interface is also provided by filters made of other filters, to report the counts
+//  of each of them
+type ValueChangeFilterStatsPort interface {
+	GetFilterStats() []ValueChangeFilterStats
+}
+
 //////////////////////////////////
 
 type ValueChangeFilterFactoryPort interface {
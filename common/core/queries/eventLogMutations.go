@@ -0,0 +1,168 @@
+package queries
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Spruik/libre-common/common/core/domain"
+	"github.com/Spruik/libre-common/common/core/ports"
+)
+
+//the GraphQL input types are named after the schema types, as the client uses the Go type name for the variable type
+
+type EquipmentRef struct {
+	Id string `json:"id"`
+}
+
+type JobResponseRef struct {
+	Id string `json:"id"`
+}
+
+type UnitOfMeasureRef struct {
+	Code string `json:"code"`
+}
+
+//DateTime is a time as the schema's DateTime scalar
+type DateTime string
+
+type AddEventLogInput struct {
+	IsActive           bool              `json:"isActive"`
+	JobResponse        JobResponseRef    `json:"jobResponse"`
+	Equipment          EquipmentRef      `json:"equipment"`
+	StartDateTime      time.Time         `json:"startDateTime"`
+	EndDateTime        *time.Time        `json:"endDateTime,omitempty"`
+	Duration           *float64          `json:"duration,omitempty"`
+	ReasonCode         string            `json:"reasonCode,omitempty"`
+	ReasonText         string            `json:"reasonText,omitempty"`
+	ReasonCategoryCode string            `json:"reasonCategoryCode,omitempty"`
+	ReasonValue        *float64          `json:"reasonValue,omitempty"`
+	ReasonValueUoM     *UnitOfMeasureRef `json:"reasonValueUoM,omitempty"`
+	Comments           string            `json:"comments,omitempty"`
+}
+
+type EventLogFilter struct {
+	Id []string `json:"id"`
+}
+
+type EventLogPatch struct {
+	EndDateTime        *time.Time        `json:"endDateTime,omitempty"`
+	Duration           *float64          `json:"duration,omitempty"`
+	ReasonCode         string            `json:"reasonCode,omitempty"`
+	ReasonText         string            `json:"reasonText,omitempty"`
+	ReasonCategoryCode string            `json:"reasonCategoryCode,omitempty"`
+	ReasonValue        *float64          `json:"reasonValue,omitempty"`
+	ReasonValueUoM     *UnitOfMeasureRef `json:"reasonValueUoM,omitempty"`
+	Comments           string            `json:"comments,omitempty"`
+}
+
+type UpdateEventLogInput struct {
+	Filter EventLogFilter `json:"filter"`
+	Set    EventLogPatch  `json:"set"`
+}
+
+func unitOfMeasureRef(code string) *UnitOfMeasureRef {
+	if code == "" {
+		return nil
+	}
+	return &UnitOfMeasureRef{Code: code}
+}
+
+func newAddEventLogInput(log domain.EventLog) AddEventLogInput {
+	input := AddEventLogInput{
+		IsActive:           true,
+		JobResponse:        JobResponseRef{Id: log.JobResponseId},
+		Equipment:          EquipmentRef{Id: log.EquipmentId},
+		StartDateTime:      log.StartDateTime,
+		ReasonCode:         log.ReasonCode,
+		ReasonText:         log.ReasonText,
+		ReasonCategoryCode: log.ReasonCategory,
+		ReasonValue:        log.ReasonValue,
+		ReasonValueUoM:     unitOfMeasureRef(log.ReasonUoM),
+		Comments:           log.Comments,
+	}
+	if !log.IsOpen() {
+		input.EndDateTime = log.EndDateTime
+		input.Duration = &log.Duration
+	}
+	return input
+}
+
+//AddEventLog writes the event, open or closed, and returns its data store id.  The schema requires a job response.
+func AddEventLog(txn ports.LibreDataStoreTransactionPort, log domain.EventLog) (string, error) {
+	var m struct {
+		AddEventLog struct {
+			EventLog []struct {
+				Id string `json:"id"`
+			} `json:"eventLog"`
+		} `graphql:"addEventLog(input: $input)"`
+	}
+	if log.JobResponseId == "" {
+		return "", fmt.Errorf("event %s of %s has no job response", log.Name, log.EquipmentId)
+	}
+	variables := map[string]interface{}{
+		"input": []AddEventLogInput{newAddEventLogInput(log)},
+	}
+	if err := txn.ExecuteMutation(&m, variables); err != nil {
+		return "", err
+	}
+	if len(m.AddEventLog.EventLog) == 0 {
+		return "", fmt.Errorf("addEventLog returned no event log")
+	}
+	return m.AddEventLog.EventLog[0].Id, nil
+}
+
+//FindOpenEventLogId returns the data store id of the open event of the equipment that started at the given time, or
+//  "" if there is none - for an event whose start was written before a restart lost its id
+func FindOpenEventLogId(txn ports.LibreDataStoreTransactionPort, equipmentId string, start time.Time) (string, error) {
+	var q struct {
+		QueryEventLog []struct {
+			Id        string
+			Equipment *struct {
+				Id string
+			}
+		} `graphql:"queryEventLog(filter: {isActive: true, startDateTime: {eq: $start}, not: {has: endDateTime}})"`
+	}
+	variables := map[string]interface{}{
+		"start": DateTime(start.Format(time.RFC3339Nano)),
+	}
+	if err := txn.ExecuteQuery(&q, variables); err != nil {
+		return "", err
+	}
+	for _, item := range q.QueryEventLog {
+		if item.Equipment != nil && item.Equipment.Id == equipmentId {
+			return item.Id, nil
+		}
+	}
+	return "", nil
+}
+
+//CloseEventLog sets the end of an event written by AddEventLog, along with a reason that may have been given since
+func CloseEventLog(txn ports.LibreDataStoreTransactionPort, id string, log domain.EventLog) error {
+	var m struct {
+		UpdateEventLog struct {
+			NumUids int `json:"numUids"`
+		} `graphql:"updateEventLog(input: $input)"`
+	}
+	variables := map[string]interface{}{
+		"input": UpdateEventLogInput{
+			Filter: EventLogFilter{Id: []string{id}},
+			Set: EventLogPatch{
+				EndDateTime:        log.EndDateTime,
+				Duration:           &log.Duration,
+				ReasonCode:         log.ReasonCode,
+				ReasonText:         log.ReasonText,
+				ReasonCategoryCode: log.ReasonCategory,
+				ReasonValue:        log.ReasonValue,
+				ReasonValueUoM:     unitOfMeasureRef(log.ReasonUoM),
+				Comments:           log.Comments,
+			},
+		},
+	}
+	if err := txn.ExecuteMutation(&m, variables); err != nil {
+		return err
+	}
+	if m.UpdateEventLog.NumUids == 0 {
+		return fmt.Errorf("updateEventLog found no event log with id %s", id)
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package queries
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Spruik/libre-common/common/core/domain"
+	"github.com/Spruik/libre-common/common/core/ports"
+)
+
+type JobOrderRef struct {
+	Name string `json:"name"`
+}
+
+type AddJobResponseInput struct {
+	IsActive        bool         `json:"isActive"`
+	CreatedDateTime time.Time    `json:"createdDateTime"`
+	Equipment       EquipmentRef `json:"equipment"`
+	JobOrder        *JobOrderRef `json:"jobOrder,omitempty"`
+	StartDateTime   time.Time    `json:"startDateTime"`
+	EndDateTime     *time.Time   `json:"endDateTime,omitempty"`
+	ActualDuration  *float64     `json:"actualDuration,omitempty"`
+}
+
+type JobResponseFilter struct {
+	Id []string `json:"id"`
+}
+
+type JobResponsePatch struct {
+	ModifiedDateTime time.Time  `json:"modifiedDateTime"`
+	EndDateTime      *time.Time `json:"endDateTime"`
+	ActualDuration   float64    `json:"actualDuration"`
+}
+
+type UpdateJobResponseInput struct {
+	Filter JobResponseFilter `json:"filter"`
+	Set    JobResponsePatch  `json:"set"`
+}
+
+//AddJobResponse writes the job, running or ended, and returns its data store id
+func AddJobResponse(txn ports.LibreDataStoreTransactionPort, job domain.JobResponse) (string, error) {
+	var m struct {
+		AddJobResponse struct {
+			JobResponse []struct {
+				Id string `json:"id"`
+			} `json:"jobResponse"`
+		} `graphql:"addJobResponse(input: $input)"`
+	}
+	input := AddJobResponseInput{
+		IsActive:        true,
+		CreatedDateTime: time.Now(),
+		Equipment:       EquipmentRef{Id: job.EquipmentId},
+		StartDateTime:   job.StartDateTime,
+	}
+	if job.JobOrder != "" {
+		input.JobOrder = &JobOrderRef{Name: job.JobOrder}
+	}
+	if !job.IsOpen() {
+		input.EndDateTime = job.EndDateTime
+		input.ActualDuration = &job.ActualDuration
+	}
+	variables := map[string]interface{}{
+		"input": []AddJobResponseInput{input},
+	}
+	if err := txn.ExecuteMutation(&m, variables); err != nil {
+		return "", err
+	}
+	if len(m.AddJobResponse.JobResponse) == 0 {
+		return "", fmt.Errorf("addJobResponse returned no job response")
+	}
+	return m.AddJobResponse.JobResponse[0].Id, nil
+}
+
+//EndJobResponse sets the end of a job written by AddJobResponse
+func EndJobResponse(txn ports.LibreDataStoreTransactionPort, id string, job domain.JobResponse) error {
+	var m struct {
+		UpdateJobResponse struct {
+			NumUids int `json:"numUids"`
+		} `graphql:"updateJobResponse(input: $input)"`
+	}
+	variables := map[string]interface{}{
+		"input": UpdateJobResponseInput{
+			Filter: JobResponseFilter{Id: []string{id}},
+			Set: JobResponsePatch{
+				ModifiedDateTime: time.Now(),
+				EndDateTime:      job.EndDateTime,
+				ActualDuration:   job.ActualDuration,
+			},
+		},
+	}
+	if err := txn.ExecuteMutation(&m, variables); err != nil {
+		return err
+	}
+	if m.UpdateJobResponse.NumUids == 0 {
+		return fmt.Errorf("updateJobResponse found no job response with id %s", id)
+	}
+	return nil
+}
+
+//GetRunningJobResponses returns the active job responses that have not ended, so running jobs survive a restart
+func GetRunningJobResponses(txn ports.LibreDataStoreTransactionPort) ([]domain.JobResponse, error) {
+	var q struct {
+		QueryJobResponse []struct {
+			Id            string
+			StartDateTime time.Time
+			Equipment     struct {
+				Id string
+			}
+			JobOrder *struct {
+				Name string
+			}
+		} `graphql:"queryJobResponse(filter: {isActive: true, not: {has: endDateTime}})"`
+	}
+	if err := txn.ExecuteQuery(&q, nil); err != nil {
+		return nil, err
+	}
+	ret := make([]domain.JobResponse, 0, len(q.QueryJobResponse))
+	for _, item := range q.QueryJobResponse {
+		job := domain.JobResponse{
+			Id:            item.Id,
+			EquipmentId:   item.Equipment.Id,
+			StartDateTime: item.StartDateTime,
+		}
+		if item.JobOrder != nil {
+			job.JobOrder = item.JobOrder.Name
+		}
+		ret = append(ret, job)
+	}
+	return ret, nil
+}
+
+//GetOrderStartEventDefIds returns the ids of the event definitions of all OrderStartRulesets
+func GetOrderStartEventDefIds(txn ports.LibreDataStoreTransactionPort) ([]string, error) {
+	var q struct {
+		QueryOrderStartRuleset []struct {
+			EventDefs []struct {
+				Id string
+			}
+		} `graphql:"queryOrderStartRuleset"`
+	}
+	if err := txn.ExecuteQuery(&q, nil); err != nil {
+		return nil, err
+	}
+	ret := make([]string, 0)
+	for _, ruleset := range q.QueryOrderStartRuleset {
+		for _, def := range ruleset.EventDefs {
+			ret = append(ret, def.Id)
+		}
+	}
+	return ret, nil
+}
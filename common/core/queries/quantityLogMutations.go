@@ -0,0 +1,54 @@
+package queries
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Spruik/libre-common/common/core/domain"
+	"github.com/Spruik/libre-common/common/core/ports"
+)
+
+type AddQuantityLogInput struct {
+	IsActive    bool               `json:"isActive"`
+	Equipment   EquipmentRef       `json:"equipment"`
+	JobResponse JobResponseRef     `json:"jobResponse"`
+	Type        domain.MaterialUse `json:"type"`
+	Quantity    float64            `json:"quantity"`
+	ReasonCode  string             `json:"reasonCode,omitempty"`
+	ReasonText  string             `json:"reasonText,omitempty"`
+	Timestamp   time.Time          `json:"timestamp"`
+}
+
+//AddQuantityLog writes the quantity and returns its data store id.  The schema requires a job response.
+func AddQuantityLog(txn ports.LibreDataStoreTransactionPort, log domain.QuantityLog) (string, error) {
+	var m struct {
+		AddQuantityLog struct {
+			QuantityLog []struct {
+				Id string `json:"id"`
+			} `json:"quantityLog"`
+		} `graphql:"addQuantityLog(input: $input)"`
+	}
+	if log.JobResponseId == "" {
+		return "", fmt.Errorf("quantity log of %s has no job response", log.EquipmentId)
+	}
+	input := AddQuantityLogInput{
+		IsActive:    true,
+		Equipment:   EquipmentRef{Id: log.EquipmentId},
+		JobResponse: JobResponseRef{Id: log.JobResponseId},
+		Type:        log.Type,
+		Quantity:    log.Quantity,
+		ReasonCode:  log.ReasonCode,
+		ReasonText:  log.ReasonText,
+		Timestamp:   log.Timestamp,
+	}
+	variables := map[string]interface{}{
+		"input": []AddQuantityLogInput{input},
+	}
+	if err := txn.ExecuteMutation(&m, variables); err != nil {
+		return "", err
+	}
+	if len(m.AddQuantityLog.QuantityLog) == 0 {
+		return "", fmt.Errorf("addQuantityLog returned no quantity log")
+	}
+	return m.AddQuantityLog.QuantityLog[0].Id, nil
+}
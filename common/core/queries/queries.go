@@ -42,6 +42,23 @@ func GetActiveEquipmentByLevelListWithIncExc(txn ports.LibreDataStoreTransaction
 	return q.QueryEquipment, err
 }
 
+//GetAllEquipmentForSelection returns all equipment, active or not, with the fields equipment selection rules match on
+func GetAllEquipmentForSelection(txn ports.LibreDataStoreTransactionPort) ([]domain.SelectableEquipment, error) {
+	var q struct {
+		QueryEquipment []domain.SelectableEquipment `graphql:"queryEquipment"`
+	}
+	err := txn.ExecuteQuery(&q, nil)
+	return q.QueryEquipment, err
+}
+
+func GetAllEquipmentClassParents(txn ports.LibreDataStoreTransactionPort) ([]domain.EquipmentClassAndParent, error) {
+	var q struct {
+		QueryEquipmentClass []domain.EquipmentClassAndParent `graphql:"queryEquipmentClass"`
+	}
+	err := txn.ExecuteQuery(&q, nil)
+	return q.QueryEquipmentClass, err
+}
+
 func GetEquipmentByName(txn ports.LibreDataStoreTransactionPort, eqName string) (domain.Equipment, error) {
 	var q struct {
 		QueryEquipment []domain.Equipment `graphql:"queryEquipment (filter:{name:{eq:$eqName}}) "`
@@ -219,6 +236,44 @@ func GetAliasPropertyNameForSystem(txn ports.LibreDataStoreTransactionPort, syst
 	return "", err
 }
 
+func GetEquipmentPropertiesAndOverridesById(txn ports.LibreDataStoreTransactionPort, eqId string) (domain.EquipmentPropertiesAndOverrides, error) {
+	var q struct {
+		GetEquipment domain.EquipmentPropertiesAndOverrides `graphql:"getEquipment(id:$eqId) " json:"getEquipment"`
+	}
+	var variables = map[string]interface{}{
+		"eqId": graphql.ID(eqId),
+	}
+	err := txn.ExecuteQuery(&q, variables)
+	return q.GetEquipment, err
+}
+
+//GetResolvedPropertiesForEquipment returns the properties of the equipment and its equipment class hierarchy with
+//  the equipment's property overrides applied, recording where each attribute came from
+func GetResolvedPropertiesForEquipment(txn ports.LibreDataStoreTransactionPort, eqId string) (map[string]domain.ResolvedProperty, error) {
+	eqInst, err := GetEquipmentPropertiesAndOverridesById(txn, eqId)
+	if err != nil {
+		return map[string]domain.ResolvedProperty{}, err
+	}
+	//work up through the equipment classes, nearest first
+	classChain := make([]domain.EquipmentClassPropertiesAndParent, 0)
+	visited := map[string]bool{}
+	currEqcId := eqInst.EquipmentClass.Id
+	for currEqcId != "" && !visited[currEqcId] {
+		visited[currEqcId] = true
+		var eqcInst domain.EquipmentClassPropertiesAndParent
+		eqcInst, err = GetEquipmentClassPropertiesAndParentById(txn, currEqcId)
+		if err != nil {
+			break
+		}
+		classChain = append(classChain, eqcInst)
+		currEqcId = eqcInst.Parent.Id
+	}
+	return domain.ResolveEquipmentProperties(eqInst.Properties, classChain, eqInst.PropertyOverrides), err
+}
+
+//GetAllPropertiesForEquipment returns the properties of the equipment and its equipment classes by name, a class
+//  property replacing an equipment property of the same name and a parent class replacing its child.  It ignores
+//  equipment property overrides; GetResolvedPropertiesForEquipment gives the properties as the equipment runs them.
 func GetAllPropertiesForEquipment(txn ports.LibreDataStoreTransactionPort, eqId string) (map[string]domain.Property, error) {
 	//need to look for properties attached to the Equipment and to it's equipment class (and equipment class parents)
 	var fullPropertyList = map[string]domain.Property{}
@@ -340,3 +395,29 @@ func GetAllActiveWorkCalendar(txn ports.LibreDataStoreTransactionPort) ([]domain
 	}
 	return ret, err
 }
+
+func GetActiveUnitOfMeasureConversions(txn ports.LibreDataStoreTransactionPort) ([]domain.UnitOfMeasureConversion, error) {
+	var q struct {
+		QueryUnitOfMeasureConversion []domain.UnitOfMeasureConversion `graphql:"queryUnitOfMeasureConversion(filter:{isActive:true})"`
+	}
+	err := txn.ExecuteQuery(&q, nil)
+	return q.QueryUnitOfMeasureConversion, err
+}
+
+//GetAllReasons returns every reason with the fields needed to resolve codes, including inactive ones so that the
+//  hierarchy stays complete
+func GetAllReasons(txn ports.LibreDataStoreTransactionPort) ([]domain.Reason, error) {
+	var q struct {
+		QueryReason []domain.Reason `graphql:"queryReason"`
+	}
+	err := txn.ExecuteQuery(&q, nil)
+	return q.QueryReason, err
+}
+
+func GetAllEquipmentReasonOverrides(txn ports.LibreDataStoreTransactionPort) ([]domain.EquipmentReasonOverride, error) {
+	var q struct {
+		QueryEquipmentReasonOverride []domain.EquipmentReasonOverride `graphql:"queryEquipmentReasonOverride(filter: {isActive: true})"`
+	}
+	err := txn.ExecuteQuery(&q, nil)
+	return q.QueryEquipmentReasonOverride, err
+}
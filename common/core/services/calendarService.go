@@ -257,7 +257,7 @@ func (s *calendarService) publishWorkCalendarType(equip domain.Equipment, calend
 		ItemId:           "",
 		ItemValue:        string(calendarEntryType),
 		ItemDataType:     domain.DataTypeString,
-		TagQuality:       1,
+		TagQuality:       int(domain.Good),
 		Err:              nil,
 		ChangedTimestamp: time.Now().UTC(),
 		Category:         domain.SVCRQST_TAGDATA,
@@ -283,7 +283,7 @@ func (s *calendarService) publishWorkCalendarEntryNames(equip domain.Equipment,
 		ItemId:           "",
 		ItemValue:        calendarEntry,
 		ItemDataType:     domain.DataTypeString,
-		TagQuality:       1,
+		TagQuality:       int(domain.Good),
 		Err:              nil,
 		ChangedTimestamp: time.Now().UTC(),
 		Category:         domain.SVCRQST_TAGDATA,
@@ -310,7 +310,7 @@ func TestCalendarService(t *testing.T) {
 		ItemId:           "",
 		ItemValue:        string(domain.PlannedBusyTime),
 		ItemDataType:     domain.DataTypeString,
-		TagQuality:       1,
+		TagQuality:       int(domain.Good),
 		Err:              nil,
 		ChangedTimestamp: time.Now().UTC(),
 		Category:         domain.SVCRQST_TAGDATA,
@@ -325,7 +325,7 @@ func TestCalendarService(t *testing.T) {
 		ItemId:           "",
 		ItemValue:        "Shift A",
 		ItemDataType:     domain.DataTypeString,
-		TagQuality:       1,
+		TagQuality:       int(domain.Good),
 		Err:              nil,
 		ChangedTimestamp: time.Now().UTC(),
 		Category:         domain.SVCRQST_TAGDATA,
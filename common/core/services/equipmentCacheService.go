@@ -1,6 +1,7 @@
 package services
 
 import (
+	"github.com/Spruik/libre-common/common/core/domain"
 	"github.com/Spruik/libre-common/common/core/ports"
 )
 
@@ -27,8 +28,12 @@ func (s *equipmentCacheService) RefreshCache() {
 	s.port.RefreshCache()
 }
 
-func (s *equipmentCacheService) GetEquipmentCacheList() *map[string]*ports.ManagedEquipmentPort {
-	return s.port.GetEquipmentCacheList()
+func (s *equipmentCacheService) GetEquipmentCacheSnapshot() []*ports.ManagedEquipmentPort {
+	return s.port.GetEquipmentCacheSnapshot()
+}
+
+func (s *equipmentCacheService) ForEachEquipment(visit func(mgdEq *ports.ManagedEquipmentPort) bool) {
+	s.port.ForEachEquipment(visit)
 }
 
 func (s *equipmentCacheService) GetCachedEquipmentItem(equipName string) *ports.ManagedEquipmentPort {
@@ -38,8 +43,12 @@ func (s *equipmentCacheService) GetCachedEquipmentItem(equipName string) *ports.
 func (s *equipmentCacheService) GetCachedEquipmentItemById(equipId string) *ports.ManagedEquipmentPort {
 	return s.port.GetCachedEquipmentItemById(equipId)
 }
-func (s *equipmentCacheService) SetEquipmentChangeNoticeFunction(handlingFxn func(notice ports.EquipmentCacheChangeNotice)) {
-	s.port.SetEquipmentChangeNoticeFunction(handlingFxn)
+func (s *equipmentCacheService) SubscribeToChanges(listener func(notice ports.EquipmentCacheChangeNotice)) int {
+	return s.port.SubscribeToChanges(listener)
+}
+
+func (s *equipmentCacheService) UnsubscribeToChanges(subscriptionId int) {
+	s.port.UnsubscribeToChanges(subscriptionId)
 }
 
 func (s *equipmentCacheService) StartMonitoring() {
@@ -50,3 +59,35 @@ func (s *equipmentCacheService) StopMonitoring() {
 	s.port.StopMonitoring()
 
 }
+
+func (s *equipmentCacheService) GetParent(equipId string) (domain.EquipmentHierarchyNode, bool) {
+	return s.port.GetParent(equipId)
+}
+
+func (s *equipmentCacheService) GetChildren(equipId string) []domain.EquipmentHierarchyNode {
+	return s.port.GetChildren(equipId)
+}
+
+func (s *equipmentCacheService) GetAncestors(equipId string) []domain.EquipmentHierarchyNode {
+	return s.port.GetAncestors(equipId)
+}
+
+func (s *equipmentCacheService) GetDescendants(equipId string) []domain.EquipmentHierarchyNode {
+	return s.port.GetDescendants(equipId)
+}
+
+func (s *equipmentCacheService) GetEquipmentPath(equipId string) string {
+	return s.port.GetEquipmentPath(equipId)
+}
+
+func (s *equipmentCacheService) GetEquipmentNodeByPath(path string) (domain.EquipmentHierarchyNode, bool) {
+	return s.port.GetEquipmentNodeByPath(path)
+}
+
+func (s *equipmentCacheService) GetCachedEquipmentItemByPath(path string) *ports.ManagedEquipmentPort {
+	return s.port.GetCachedEquipmentItemByPath(path)
+}
+
+func (s *equipmentCacheService) RollupProperty(equipId string, propName string, aggregate string) (float64, error) {
+	return s.port.RollupProperty(equipId, propName, aggregate)
+}
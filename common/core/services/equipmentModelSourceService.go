@@ -0,0 +1,33 @@
+package services
+
+import (
+	"github.com/Spruik/libre-common/common/core/domain"
+	"github.com/Spruik/libre-common/common/core/ports"
+)
+
+type equipmentModelSourceService struct {
+	port ports.EquipmentModelSourcePort
+}
+
+func NewEquipmentModelSourceService(port ports.EquipmentModelSourcePort) *equipmentModelSourceService {
+	var ret = equipmentModelSourceService{}
+	ret.port = port
+	return &ret
+}
+
+var equipmentModelSourceServiceInstance *equipmentModelSourceService = nil
+
+func SetEquipmentModelSourceServiceInstance(inst *equipmentModelSourceService) {
+	equipmentModelSourceServiceInstance = inst
+}
+func GetEquipmentModelSourceServiceInstance() *equipmentModelSourceService {
+	return equipmentModelSourceServiceInstance
+}
+
+func (s *equipmentModelSourceService) GetResolvedProperties(eqId string) (map[string]domain.ResolvedProperty, error) {
+	return s.port.GetResolvedProperties(eqId)
+}
+
+func (s *equipmentModelSourceService) GetEquipmentById(eqId string) (domain.Equipment, error) {
+	return s.port.GetEquipmentById(eqId)
+}
@@ -35,3 +35,11 @@ func (s *equipmentServiceManagerService) Start(wg *sync.WaitGroup) error {
 func (s *equipmentServiceManagerService) Shutdown() error {
 	return s.port.Shutdown()
 }
+
+func (s *equipmentServiceManagerService) GetRunnerStats() []ports.EquipmentRunnerStats {
+	return s.port.GetRunnerStats()
+}
+
+func (s *equipmentServiceManagerService) GetFilterStats() []ports.ValueChangeFilterStats {
+	return s.port.GetFilterStats()
+}
@@ -0,0 +1,37 @@
+package services
+
+import (
+	"github.com/Spruik/libre-common/common/core/domain"
+	"github.com/Spruik/libre-common/common/core/ports"
+)
+
+type equipmentSnapshotStoreService struct {
+	port ports.EquipmentSnapshotStorePort
+}
+
+func NewEquipmentSnapshotStoreService(port ports.EquipmentSnapshotStorePort) *equipmentSnapshotStoreService {
+	var ret = equipmentSnapshotStoreService{}
+	ret.port = port
+	return &ret
+}
+
+var equipmentSnapshotStoreServiceInstance *equipmentSnapshotStoreService = nil
+
+func SetEquipmentSnapshotStoreServiceInstance(inst *equipmentSnapshotStoreService) {
+	equipmentSnapshotStoreServiceInstance = inst
+}
+func GetEquipmentSnapshotStoreServiceInstance() *equipmentSnapshotStoreService {
+	return equipmentSnapshotStoreServiceInstance
+}
+
+func (s *equipmentSnapshotStoreService) SaveSnapshot(snapshot domain.EquipmentSnapshot) error {
+	return s.port.SaveSnapshot(snapshot)
+}
+
+func (s *equipmentSnapshotStoreService) LoadSnapshot(equipmentId string) (*domain.EquipmentSnapshot, error) {
+	return s.port.LoadSnapshot(equipmentId)
+}
+
+func (s *equipmentSnapshotStoreService) DeleteSnapshot(equipmentId string) error {
+	return s.port.DeleteSnapshot(equipmentId)
+}
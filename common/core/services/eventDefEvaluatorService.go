@@ -27,3 +27,7 @@ func GetEventDefEvaluatorServiceInstance() *eventDefEvaluatorService {
 func (s *eventDefEvaluatorService) EvaluateEventDef(mgdEq *ports.ManagedEquipmentPort, eventDefId string, evalContext *map[string]interface{}) (bool, *domain.EventDefinition, map[string]interface{}, error) {
 	return s.port.EvaluateEventDef(mgdEq, eventDefId, evalContext)
 }
+
+func (s *eventDefEvaluatorService) EvaluateExpression(mgdEq *ports.ManagedEquipmentPort, expression string, evalContext *map[string]interface{}) (interface{}, error) {
+	return s.port.EvaluateExpression(mgdEq, expression, evalContext)
+}
@@ -0,0 +1,37 @@
+package services
+
+import (
+	"github.com/Spruik/libre-common/common/core/domain"
+	"github.com/Spruik/libre-common/common/core/ports"
+)
+
+type eventLogWriterService struct {
+	port ports.EventLogWriterPort
+}
+
+func NewEventLogWriterService(port ports.EventLogWriterPort) *eventLogWriterService {
+	var ret = eventLogWriterService{}
+	ret.port = port
+	return &ret
+}
+
+var eventLogWriterServiceInstance *eventLogWriterService = nil
+
+func SetEventLogWriterServiceInstance(inst *eventLogWriterService) {
+	eventLogWriterServiceInstance = inst
+}
+func GetEventLogWriterServiceInstance() *eventLogWriterService {
+	return eventLogWriterServiceInstance
+}
+
+func (s *eventLogWriterService) EventStarted(log domain.EventLog, persisted func(id string)) {
+	s.port.EventStarted(log, persisted)
+}
+
+func (s *eventLogWriterService) EventEnded(log domain.EventLog) {
+	s.port.EventEnded(log)
+}
+
+func (s *eventLogWriterService) Close() error {
+	return s.port.Close()
+}
@@ -0,0 +1,33 @@
+package services
+
+import (
+	"github.com/Spruik/libre-common/common/core/domain"
+	"github.com/Spruik/libre-common/common/core/ports"
+)
+
+type historianWriterService struct {
+	port ports.HistorianWriterPort
+}
+
+func NewHistorianWriterService(port ports.HistorianWriterPort) *historianWriterService {
+	var ret = historianWriterService{}
+	ret.port = port
+	return &ret
+}
+
+var historianWriterServiceInstance *historianWriterService = nil
+
+func SetHistorianWriterServiceInstance(inst *historianWriterService) {
+	historianWriterServiceInstance = inst
+}
+func GetHistorianWriterServiceInstance() *historianWriterService {
+	return historianWriterServiceInstance
+}
+
+func (s *historianWriterService) WritePoint(point domain.HistorianPoint) {
+	s.port.WritePoint(point)
+}
+
+func (s *historianWriterService) Close() error {
+	return s.port.Close()
+}
@@ -0,0 +1,47 @@
+package services
+
+import (
+	"time"
+
+	"github.com/Spruik/libre-common/common/core/domain"
+	"github.com/Spruik/libre-common/common/core/ports"
+)
+
+type jobTrackerService struct {
+	port ports.JobTrackerPort
+}
+
+func NewJobTrackerService(port ports.JobTrackerPort) *jobTrackerService {
+	var ret = jobTrackerService{}
+	ret.port = port
+	return &ret
+}
+
+var jobTrackerServiceInstance *jobTrackerService = nil
+
+func SetJobTrackerServiceInstance(inst *jobTrackerService) {
+	jobTrackerServiceInstance = inst
+}
+func GetJobTrackerServiceInstance() *jobTrackerService {
+	return jobTrackerServiceInstance
+}
+
+func (s *jobTrackerService) StartJob(equipmentId string, jobOrder string, start time.Time) domain.JobResponse {
+	return s.port.StartJob(equipmentId, jobOrder, start)
+}
+
+func (s *jobTrackerService) EndJob(equipmentId string, end time.Time) (domain.JobResponse, bool) {
+	return s.port.EndJob(equipmentId, end)
+}
+
+func (s *jobTrackerService) GetCurrentJob(equipmentId string) (domain.JobResponse, bool) {
+	return s.port.GetCurrentJob(equipmentId)
+}
+
+func (s *jobTrackerService) GetJobResponseId(localId string) (string, bool) {
+	return s.port.GetJobResponseId(localId)
+}
+
+func (s *jobTrackerService) IsOrderStartEvent(eventDefId string) bool {
+	return s.port.IsOrderStartEvent(eventDefId)
+}
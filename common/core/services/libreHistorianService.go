@@ -1,6 +1,7 @@
 package services
 
 import (
+	"github.com/Spruik/libre-common/common/core/domain"
 	"github.com/Spruik/libre-common/common/core/ports"
 	"github.com/influxdata/influxdb-client-go/v2/api"
 	"time"
@@ -34,6 +35,9 @@ func (s *libreHistorianService) Close() error {
 func (s *libreHistorianService) AddDataPointRaw(measurement string, tags map[string]string, fields map[string]interface{}, ts time.Time) error {
 	return s.port.AddDataPointRaw(measurement, tags, fields, ts)
 }
+func (s *libreHistorianService) AddDataPointsRaw(points []domain.HistorianPoint) error {
+	return s.port.AddDataPointsRaw(points)
+}
 func (s *libreHistorianService) AddEqPropDataPoint(measurement string, eqId string, eqName string, propId string, propName string, propValue interface{}, ts time.Time) error {
 	return s.port.AddEqPropDataPoint(measurement, eqId, eqName, propId, propName, propValue, ts)
 }
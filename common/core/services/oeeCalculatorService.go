@@ -0,0 +1,47 @@
+package services
+
+import (
+	"time"
+
+	"github.com/Spruik/libre-common/common/core/domain"
+	"github.com/Spruik/libre-common/common/core/ports"
+)
+
+type oeeCalculatorService struct {
+	port ports.OEECalculatorPort
+}
+
+func NewOEECalculatorService(port ports.OEECalculatorPort) *oeeCalculatorService {
+	var ret = oeeCalculatorService{}
+	ret.port = port
+	return &ret
+}
+
+var oeeCalculatorServiceInstance *oeeCalculatorService = nil
+
+func SetOEECalculatorServiceInstance(inst *oeeCalculatorService) {
+	oeeCalculatorServiceInstance = inst
+}
+func GetOEECalculatorServiceInstance() *oeeCalculatorService {
+	return oeeCalculatorServiceInstance
+}
+
+func (s *oeeCalculatorService) RecordMachineState(mgdEq *ports.ManagedEquipmentPort, state domain.MachineState, at time.Time) {
+	s.port.RecordMachineState(mgdEq, state, at)
+}
+
+func (s *oeeCalculatorService) RecordCount(mgdEq *ports.ManagedEquipmentPort, total float64, defective float64, at time.Time) {
+	s.port.RecordCount(mgdEq, total, defective, at)
+}
+
+func (s *oeeCalculatorService) GetOEE(equipmentId string, kind domain.OEEPeriodKind) (domain.OEEPeriod, bool) {
+	return s.port.GetOEE(equipmentId, kind)
+}
+
+func (s *oeeCalculatorService) Start() error {
+	return s.port.Start()
+}
+
+func (s *oeeCalculatorService) Stop() {
+	s.port.Stop()
+}
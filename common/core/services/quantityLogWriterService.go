@@ -0,0 +1,33 @@
+package services
+
+import (
+	"github.com/Spruik/libre-common/common/core/domain"
+	"github.com/Spruik/libre-common/common/core/ports"
+)
+
+type quantityLogWriterService struct {
+	port ports.QuantityLogWriterPort
+}
+
+func NewQuantityLogWriterService(port ports.QuantityLogWriterPort) *quantityLogWriterService {
+	var ret = quantityLogWriterService{}
+	ret.port = port
+	return &ret
+}
+
+var quantityLogWriterServiceInstance *quantityLogWriterService = nil
+
+func SetQuantityLogWriterServiceInstance(inst *quantityLogWriterService) {
+	quantityLogWriterServiceInstance = inst
+}
+func GetQuantityLogWriterServiceInstance() *quantityLogWriterService {
+	return quantityLogWriterServiceInstance
+}
+
+func (s *quantityLogWriterService) WriteQuantityLog(log domain.QuantityLog) {
+	s.port.WriteQuantityLog(log)
+}
+
+func (s *quantityLogWriterService) Close() error {
+	return s.port.Close()
+}
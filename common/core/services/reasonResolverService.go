@@ -0,0 +1,33 @@
+package services
+
+import (
+	"github.com/Spruik/libre-common/common/core/domain"
+	"github.com/Spruik/libre-common/common/core/ports"
+)
+
+type reasonResolverService struct {
+	port ports.ReasonResolverPort
+}
+
+func NewReasonResolverService(port ports.ReasonResolverPort) *reasonResolverService {
+	var ret = reasonResolverService{}
+	ret.port = port
+	return &ret
+}
+
+var reasonResolverServiceInstance *reasonResolverService = nil
+
+func SetReasonResolverServiceInstance(inst *reasonResolverService) {
+	reasonResolverServiceInstance = inst
+}
+func GetReasonResolverServiceInstance() *reasonResolverService {
+	return reasonResolverServiceInstance
+}
+
+func (s *reasonResolverService) ResolveReason(equipmentId string, equipmentClassId string, code string, class domain.ReasonClass) (domain.ResolvedReason, bool) {
+	return s.port.ResolveReason(equipmentId, equipmentClassId, code, class)
+}
+
+func (s *reasonResolverService) Refresh() {
+	s.port.Refresh()
+}
@@ -0,0 +1,32 @@
+package services
+
+import (
+	"github.com/Spruik/libre-common/common/core/ports"
+)
+
+type unitOfMeasureConverterService struct {
+	port ports.UnitOfMeasureConverterPort
+}
+
+func NewUnitOfMeasureConverterService(port ports.UnitOfMeasureConverterPort) *unitOfMeasureConverterService {
+	var ret = unitOfMeasureConverterService{}
+	ret.port = port
+	return &ret
+}
+
+var unitOfMeasureConverterServiceInstance *unitOfMeasureConverterService = nil
+
+func SetUnitOfMeasureConverterServiceInstance(inst *unitOfMeasureConverterService) {
+	unitOfMeasureConverterServiceInstance = inst
+}
+func GetUnitOfMeasureConverterServiceInstance() *unitOfMeasureConverterService {
+	return unitOfMeasureConverterServiceInstance
+}
+
+func (s *unitOfMeasureConverterService) Refresh() error {
+	return s.port.Refresh()
+}
+
+func (s *unitOfMeasureConverterService) Convert(value float64, fromUoM string, toUoM string) (float64, error) {
+	return s.port.Convert(value, fromUoM, toUoM)
+}
@@ -149,7 +149,7 @@ func (s *edgeConnectorGraphQL) subscribe(client string, sub *domain.DataSubscrip
 			ItemValue:     string(j),
 			//ItemOldValue:  "",
 			ItemDataType: "",
-			TagQuality:   0,
+			TagQuality:   int(domain.Good),
 			Err:          nil,
 			//ChangedTime:   time.Time{},
 			Category: "EVENT",
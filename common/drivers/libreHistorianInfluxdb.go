@@ -10,6 +10,7 @@ import (
 	libreLogger "github.com/Spruik/libre-logging"
 	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
 	"github.com/influxdata/influxdb-client-go/v2/api"
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
 )
 
 type libreHistorianInfluxdb struct {
@@ -84,6 +85,18 @@ func (s *libreHistorianInfluxdb) AddDataPointRaw(measurement string, tags map[st
 	return s.writeAPI.WritePoint(context.Background(), p)
 }
 
+func (s *libreHistorianInfluxdb) AddDataPointsRaw(points []domain.HistorianPoint) error {
+	if len(points) == 0 {
+		return nil
+	}
+	pts := make([]*write.Point, 0, len(points))
+	for _, point := range points {
+		pts = append(pts, influxdb2.NewPoint(point.Measurement, point.Tags, point.Fields, point.Timestamp))
+	}
+	// write the batch in one request
+	return s.writeAPI.WritePoint(context.Background(), pts...)
+}
+
 func (s *libreHistorianInfluxdb) AddEqPropDataPoint(measurement string, eqId string, eqName string, propId string, propName string, propValue interface{}, ts time.Time) error {
 	// Create point using fluent style
 	p := influxdb2.NewPointWithMeasurement(measurement).
@@ -8,6 +8,7 @@ import (
 	"net/url"
 	"os"
 	"regexp"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -28,9 +29,11 @@ type plcConnectorMQTT struct {
 	mqttConnectionManager *autopaho.ConnectionManager
 	mqttClient            *paho.Client
 	ChangeChannels        map[string]chan domain.StdMessageStruct
+	listenerDone          map[string]chan struct{} // closed when the client stops listening
 
 	topicTemplateList    []string
 	topicParseRegExpList []*regexp.Regexp
+	subscriptions        *plcTopicSubscriptions
 
 	listenMutex sync.Mutex
 
@@ -41,6 +44,7 @@ func NewPlcConnectorMQTT(configHook string) *plcConnectorMQTT {
 	s := plcConnectorMQTT{
 		mqttClient:           nil,
 		ChangeChannels:       make(map[string]chan domain.StdMessageStruct),
+		listenerDone:         make(map[string]chan struct{}),
 		topicTemplateList:    make([]string, 0),
 		topicParseRegExpList: make([]*regexp.Regexp, 0),
 		listenMutex:          sync.Mutex{},
@@ -63,6 +67,7 @@ func NewPlcConnectorMQTT(configHook string) *plcConnectorMQTT {
 			s.topicParseRegExpList = append(s.topicParseRegExpList, regexp.MustCompile(topicRE))
 		}
 	}
+	s.subscriptions = newPlcTopicSubscriptions(s.topicTemplateList)
 
 	return &s
 }
@@ -172,43 +177,58 @@ func (s *plcConnectorMQTT) WriteTags(outTagDefs []domain.StdMessageStruct) []dom
 func (s *plcConnectorMQTT) ListenForPlcTagChanges(c chan domain.StdMessageStruct, changeFilter map[string]interface{}) {
 	clientName := fmt.Sprintf("%s", changeFilter["Client"])
 	s.LogDebugf("ListenForPlcTagChanges called for Client %s", clientName)
-	s.ChangeChannels[clientName] = c
 	//declare the handler for received messages
 	s.mqttClient.Router = paho.NewSingleHandlerRouter(s.receivedMessageHandler)
 	//need to subscribe to the topics in the changeFilter
-	var topicSet = make(map[string]struct{})
+	tagNames := make([]string, 0, len(changeFilter))
 	for key, val := range changeFilter {
 		s.LogDebugf("topic map item: %s=%s", key, val)
 		if strings.Contains(key, "Topic") {
-			for _, tmpl := range s.topicTemplateList {
-				var topic string = tmpl
-				topic = strings.Replace(topic, "<EQNAME>", clientName, -1)
-				topic = strings.Replace(topic, "<TAGNAME>", fmt.Sprintf("%s", val), -1)
-				var i, j int
-				i = strings.Index(topic, "<")
-				for i >= 0 {
-					j = strings.Index(topic, ">")
-					topic = topic[0:i] + "+" + topic[j+1:]
-					i = strings.Index(topic, "<")
-				}
-				topicSet[topic] = struct{}{}
-			}
+			tagNames = append(tagNames, fmt.Sprintf("%s", val))
 		}
 	}
-	for key := range topicSet {
-		s.LogDebugf("subscription topic: %s", key)
-		s.SubscribeToTopic(fmt.Sprintf("%v", key))
+	s.listenMutex.Lock()
+	s.ChangeChannels[clientName] = c
+	if _, exists := s.listenerDone[clientName]; !exists {
+		s.listenerDone[clientName] = make(chan struct{})
+	}
+	topics := s.subscriptions.add(clientName, tagNames)
+	s.listenMutex.Unlock()
+	for _, topic := range topics {
+		s.LogDebugf("subscription topic: %s", topic)
+		s.SubscribeToTopic(topic)
 	}
 }
-func (s *plcConnectorMQTT) Unsubscribe(equipmentId *string, topicList []string) error {
+
+//Unsubscribe implements the interface by unsubscribing from the topics the tags were expanded to, keeping any topic
+//  still needed by another tag
+func (s *plcConnectorMQTT) Unsubscribe(clientName *string, tagNames []string) error {
+	s.listenMutex.Lock()
+	topics := s.subscriptions.remove(*clientName, tagNames)
+	s.listenMutex.Unlock()
+	if len(topics) == 0 {
+		return nil
+	}
 	u := paho.Unsubscribe{
-		Topics:     topicList,
+		Topics:     topics,
 		Properties: nil,
 	}
 	_, err := s.mqttConnectionManager.Unsubscribe(context.Background(), &u)
 	return err
 }
 
+//StopListening implements the interface by forgetting the channel of the client, and giving up on any change
+//  waiting to be delivered to it
+func (s *plcConnectorMQTT) StopListening(clientName string) {
+	s.listenMutex.Lock()
+	defer s.listenMutex.Unlock()
+	delete(s.ChangeChannels, clientName)
+	if done, exists := s.listenerDone[clientName]; exists {
+		close(done)
+		delete(s.listenerDone, clientName)
+	}
+}
+
 func (s *plcConnectorMQTT) GetTagHistory(startTS time.Time, endTS time.Time, inTagDefs []domain.StdMessageStruct) []domain.StdMessageStruct {
 	_ = startTS
 	_ = endTS
@@ -252,7 +272,8 @@ func (s *plcConnectorMQTT) receivedMessageHandler(m *paho.Publish) {
 		OwningAsset:      tokenMap["EQNAME"],
 		ItemName:         tokenMap["TAGNAME"],
 		ItemValue:        string(m.Payload),
-		TagQuality:       128,
+		ItemUoM:          tokenMap["UOM"],
+		TagQuality:       mqttTagQuality(tokenMap),
 		Err:              nil,
 		ChangedTimestamp: time.Now(),
 		//Category:    tokenMap["CATEGORY"],
@@ -266,7 +287,17 @@ func (s *plcConnectorMQTT) receivedMessageHandler(m *paho.Publish) {
 			}
 		}
 	}
-	s.ChangeChannels[tokenMap["EQNAME"]] <- tagStruct
+	s.listenMutex.Lock()
+	c, exists := s.ChangeChannels[tokenMap["EQNAME"]]
+	done := s.listenerDone[tokenMap["EQNAME"]]
+	s.listenMutex.Unlock()
+	if !exists {
+		s.LogDebugf("dropping message for topic %s, nothing is listening for %s", m.Topic, tokenMap["EQNAME"])
+		return
+	}
+	if !deliverPlcChange(c, done, tagStruct) {
+		s.LogDebugf("dropping message for topic %s, %s stopped listening", m.Topic, tokenMap["EQNAME"])
+	}
 }
 
 func (s *plcConnectorMQTT) parseTopic(topic string) map[string]string {
@@ -285,3 +316,109 @@ func (s *plcConnectorMQTT) parseTopic(topic string) map[string]string {
 	}
 	return ret
 }
+
+//mqttTagQuality is the quality given by the QUALITY token of the topic (a name or a number), or Good when the topic
+//  has none; a payload that arrived at all is taken to be good
+func mqttTagQuality(tokenMap map[string]string) int {
+	text, exists := tokenMap["QUALITY"]
+	if !exists || text == "" {
+		return int(domain.Good)
+	}
+	quality, err := domain.ParseTagQuality(text)
+	if err != nil {
+		return int(domain.Bad)
+	}
+	return int(quality)
+}
+
+//deliverPlcChange sends a change on the channel of a client, unless the client stops listening first.  The done
+//  channel is closed by StopListening, so the full channel of a stopped client cannot block the goroutine that
+//  delivers the changes of every client.
+func deliverPlcChange(c chan domain.StdMessageStruct, done chan struct{}, tagData domain.StdMessageStruct) bool {
+	select {
+	case c <- tagData:
+		return true
+	case <-done:
+		return false
+	}
+}
+
+//plcTopicSubscriptions expands the topic templates for the tags each client listens for, and keeps track of them so
+//  that a topic shared by several tags (a template without <TAGNAME>) is only given up when none of them remain
+type plcTopicSubscriptions struct {
+	templates []string
+	tags      map[string]map[string]struct{}
+}
+
+func newPlcTopicSubscriptions(templates []string) *plcTopicSubscriptions {
+	return &plcTopicSubscriptions{
+		templates: templates,
+		tags:      make(map[string]map[string]struct{}),
+	}
+}
+
+//add records the tags of the client and returns the topics they expand to
+func (t *plcTopicSubscriptions) add(clientName string, tagNames []string) []string {
+	clientTags, exists := t.tags[clientName]
+	if !exists {
+		clientTags = make(map[string]struct{})
+		t.tags[clientName] = clientTags
+	}
+	topicSet := make(map[string]struct{})
+	for _, tagName := range tagNames {
+		clientTags[tagName] = struct{}{}
+		for _, topic := range t.expand(clientName, tagName) {
+			topicSet[topic] = struct{}{}
+		}
+	}
+	return topicList(topicSet)
+}
+
+//remove forgets the tags of the client and returns the topics that no remaining tag of any client expands to
+func (t *plcTopicSubscriptions) remove(clientName string, tagNames []string) []string {
+	clientTags := t.tags[clientName]
+	topicSet := make(map[string]struct{})
+	for _, tagName := range tagNames {
+		delete(clientTags, tagName)
+		for _, topic := range t.expand(clientName, tagName) {
+			topicSet[topic] = struct{}{}
+		}
+	}
+	if len(clientTags) == 0 {
+		delete(t.tags, clientName)
+	}
+	for otherClient, otherTags := range t.tags {
+		for tagName := range otherTags {
+			for _, topic := range t.expand(otherClient, tagName) {
+				delete(topicSet, topic)
+			}
+		}
+	}
+	return topicList(topicSet)
+}
+
+//expand fills <EQNAME> and <TAGNAME> into each template, and turns any other token into a single level wildcard
+func (t *plcTopicSubscriptions) expand(clientName string, tagName string) []string {
+	topics := make([]string, 0, len(t.templates))
+	for _, tmpl := range t.templates {
+		topic := strings.Replace(tmpl, "<EQNAME>", clientName, -1)
+		topic = strings.Replace(topic, "<TAGNAME>", tagName, -1)
+		i := strings.Index(topic, "<")
+		for i >= 0 {
+			j := strings.Index(topic, ">")
+			topic = topic[0:i] + "+" + topic[j+1:]
+			i = strings.Index(topic, "<")
+		}
+		topics = append(topics, topic)
+	}
+	return topics
+}
+
+func topicList(topicSet map[string]struct{}) []string {
+	topics := make([]string, 0, len(topicSet))
+	for topic := range topicSet {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	return topics
+}
@@ -0,0 +1,63 @@
+package drivers
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Spruik/libre-common/common/core/domain"
+)
+
+func TestPlcTopicSubscriptionsExpandTagNamesOnUnsubscribe(t *testing.T) {
+	subs := newPlcTopicSubscriptions([]string{"plant/<EQNAME>/<TAGNAME>/<UOM>", "plant/<EQNAME>/status"})
+
+	topics := subs.add("Filler1", []string{"Speed", "Count"})
+	expected := []string{"plant/Filler1/Count/+", "plant/Filler1/Speed/+", "plant/Filler1/status"}
+	if !reflect.DeepEqual(topics, expected) {
+		t.Fatalf("expected subscription topics %v, got %v", expected, topics)
+	}
+	subs.add("Filler2", []string{"Speed"})
+
+	// the status topic is still needed by the Count tag of Filler1
+	topics = subs.remove("Filler1", []string{"Speed"})
+	expected = []string{"plant/Filler1/Speed/+"}
+	if !reflect.DeepEqual(topics, expected) {
+		t.Fatalf("expected unsubscribe topics %v, got %v", expected, topics)
+	}
+
+	topics = subs.remove("Filler1", []string{"Count"})
+	expected = []string{"plant/Filler1/Count/+", "plant/Filler1/status"}
+	if !reflect.DeepEqual(topics, expected) {
+		t.Fatalf("expected unsubscribe topics %v, got %v", expected, topics)
+	}
+	if _, exists := subs.tags["Filler1"]; exists {
+		t.Errorf("expected Filler1 to be forgotten once its last tag is removed")
+	}
+}
+
+func TestStopListeningReleasesABlockedDelivery(t *testing.T) {
+	s := &plcConnectorMQTT{
+		ChangeChannels: map[string]chan domain.StdMessageStruct{},
+		listenerDone:   map[string]chan struct{}{},
+	}
+	// nothing reads the channel, as when the pump of a stopped worker has ended with its buffer full
+	c := make(chan domain.StdMessageStruct)
+	done := make(chan struct{})
+	s.ChangeChannels["Filler1"] = c
+	s.listenerDone["Filler1"] = done
+
+	delivered := make(chan bool)
+	go func() { delivered <- deliverPlcChange(c, done, domain.StdMessageStruct{ItemName: "Speed"}) }()
+	s.StopListening("Filler1")
+	select {
+	case ok := <-delivered:
+		if ok {
+			t.Errorf("expected the change to be dropped once Filler1 stopped listening")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected StopListening to release the blocked delivery")
+	}
+	if _, exists := s.listenerDone["Filler1"]; exists {
+		t.Errorf("expected Filler1 to be forgotten once it stopped listening")
+	}
+}
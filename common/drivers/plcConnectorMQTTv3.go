@@ -30,9 +30,11 @@ type plcConnectorMQTTv3 struct {
 
 	mqttClient     *mqtt.Client
 	ChangeChannels map[string]chan domain.StdMessageStruct
+	listenerDone   map[string]chan struct{} // closed when the client stops listening
 
 	topicTemplateList    []string
 	topicParseRegExpList []*regexp.Regexp
+	subscriptions        *plcTopicSubscriptions
 
 	listenMutex sync.Mutex
 }
@@ -41,6 +43,7 @@ func NewPlcConnectorMQTTv3(configCategoryName string) *plcConnectorMQTTv3 {
 	s := plcConnectorMQTTv3{
 		mqttClient:           nil,
 		ChangeChannels:       make(map[string]chan domain.StdMessageStruct),
+		listenerDone:         make(map[string]chan struct{}),
 		topicTemplateList:    make([]string, 0),
 		topicParseRegExpList: make([]*regexp.Regexp, 0),
 		listenMutex:          sync.Mutex{},
@@ -59,6 +62,7 @@ func NewPlcConnectorMQTTv3(configCategoryName string) *plcConnectorMQTTv3 {
 			s.topicParseRegExpList = append(s.topicParseRegExpList, regexp.MustCompile(topicRE))
 		}
 	}
+	s.subscriptions = newPlcTopicSubscriptions(s.topicTemplateList)
 
 	return &s
 }
@@ -158,40 +162,54 @@ func (s *plcConnectorMQTTv3) WriteTags(outTagDefs []domain.StdMessageStruct) []d
 func (s *plcConnectorMQTTv3) ListenForPlcTagChanges(c chan domain.StdMessageStruct, changeFilter map[string]interface{}) {
 	clientName := fmt.Sprintf("%s", changeFilter["Client"])
 	s.LogDebugf("ListenForPlcTagChanges called for Client %s", clientName)
-	s.ChangeChannels[clientName] = c
 	//declare the handler for received messages
 	//s.mqttClient.Router = mqtt.NewSingleHandlerRouter(s.receivedMessageHandler)
 	//need to subscribe to the topics in the changeFilter
-	var topicSet = make(map[string]struct{})
+	tagNames := make([]string, 0, len(changeFilter))
 	for key, val := range changeFilter {
 		s.LogDebugf("topic map item: %s=%s", key, val)
 		if strings.Contains(key, "Topic") {
-			for _, tmpl := range s.topicTemplateList {
-				var topic string = tmpl
-				topic = strings.Replace(topic, "<EQNAME>", clientName, -1)
-				topic = strings.Replace(topic, "<TAGNAME>", fmt.Sprintf("%s", val), -1)
-				s.LogDebug(topic)
-				var i, j int
-				i = strings.Index(topic, "<")
-				for i >= 0 {
-					j = strings.Index(topic, ">")
-					topic = topic[0:i] + "+" + topic[j+1:]
-					i = strings.Index(topic, "<")
-				}
-				topicSet[topic] = struct{}{}
-			}
+			tagNames = append(tagNames, fmt.Sprintf("%s", val))
 		}
 	}
-	s.LogDebug("topicSet....................")
-	s.LogDebug(topicSet)
-	for key := range topicSet {
-		s.LogDebugf("subscription topic: %s", key)
-		go s.SubscribeToTopic(fmt.Sprintf("%v", key))
+	s.listenMutex.Lock()
+	s.ChangeChannels[clientName] = c
+	if _, exists := s.listenerDone[clientName]; !exists {
+		s.listenerDone[clientName] = make(chan struct{})
+	}
+	topics := s.subscriptions.add(clientName, tagNames)
+	s.listenMutex.Unlock()
+	for _, topic := range topics {
+		s.LogDebugf("subscription topic: %s", topic)
+		go s.SubscribeToTopic(topic)
 	}
 }
-func (s *plcConnectorMQTTv3) Unsubscribe(equipmentId *string, topicList []string) error {
-	//ToDo: Implement Unsubscribe
-	return nil
+
+//Unsubscribe implements the interface by unsubscribing from the topics the tags were expanded to, keeping any topic
+//  still needed by another tag
+func (s *plcConnectorMQTTv3) Unsubscribe(clientName *string, tagNames []string) error {
+	s.listenMutex.Lock()
+	topics := s.subscriptions.remove(*clientName, tagNames)
+	s.listenMutex.Unlock()
+	if len(topics) == 0 {
+		return nil
+	}
+	c := *s.mqttClient
+	token := c.Unsubscribe(topics...)
+	token.Wait()
+	return token.Error()
+}
+
+//StopListening implements the interface by forgetting the channel of the client, and giving up on any change
+//  waiting to be delivered to it
+func (s *plcConnectorMQTTv3) StopListening(clientName string) {
+	s.listenMutex.Lock()
+	defer s.listenMutex.Unlock()
+	delete(s.ChangeChannels, clientName)
+	if done, exists := s.listenerDone[clientName]; exists {
+		close(done)
+		delete(s.listenerDone, clientName)
+	}
 }
 func (s *plcConnectorMQTTv3) GetTagHistory(startTS time.Time, endTS time.Time, inTagDefs []domain.StdMessageStruct) []domain.StdMessageStruct {
 	_ = startTS
@@ -220,7 +238,8 @@ func (s *plcConnectorMQTTv3) receivedMessageHandler(client mqtt.Client, msg mqtt
 		OwningAsset: tokenMap["EQNAME"],
 		ItemName:    tokenMap["TAGNAME"],
 		ItemValue:   string(msg.Payload()),
-		TagQuality:  128,
+		ItemUoM:     tokenMap["UOM"],
+		TagQuality:  mqttTagQuality(tokenMap),
 		Err:         nil,
 	}
 	if tagStruct.ItemName == "" {
@@ -231,7 +250,17 @@ func (s *plcConnectorMQTTv3) receivedMessageHandler(client mqtt.Client, msg mqtt
 			}
 		}
 	}
-	s.ChangeChannels[tokenMap["EQNAME"]] <- tagStruct
+	s.listenMutex.Lock()
+	c, exists := s.ChangeChannels[tokenMap["EQNAME"]]
+	done := s.listenerDone[tokenMap["EQNAME"]]
+	s.listenMutex.Unlock()
+	if !exists {
+		s.LogDebugf("dropping message for topic %s, nothing is listening for %s", msg.Topic(), tokenMap["EQNAME"])
+		return
+	}
+	if !deliverPlcChange(c, done, tagStruct) {
+		s.LogDebugf("dropping message for topic %s, %s stopped listening", msg.Topic(), tokenMap["EQNAME"])
+	}
 }
 
 func (s *plcConnectorMQTTv3) parseTopic(topic string) map[string]string {
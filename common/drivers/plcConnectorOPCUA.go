@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/Spruik/libre-common/common/core/domain"
@@ -20,22 +21,31 @@ type plcConnectorOPCUA struct {
 	//inherit logging
 	libreLogger.LoggingEnabler
 
-	uaClient           *opcua.Client
+	uaClient          *opcua.Client
+	subscriptions     map[string]*opcuaClientSubscription // by client name
+	connectionContext context.Context
+	ChangeChannels    map[string]chan domain.StdMessageStruct
+	listenerDone      map[string]chan struct{} // closed when the client stops listening
+	aliasSystem       string
+	nodeMap           map[string]uint32
+	clientHandleMap   map[uint32]string
+	listenMutex       sync.Mutex
+}
+
+//opcuaClientSubscription is the subscription of one client, with the monitored items of its tags
+type opcuaClientSubscription struct {
 	subscription       *opcua.Subscription
-	connectionContext  context.Context
-	ChangeChannels     map[string]chan domain.StdMessageStruct
-	aliasSystem        string
-	nodeMap            map[string]uint32
-	clientHandleMap    map[uint32]string
-	monitoredItemIdMap map[string]uint32
+	cancel             context.CancelFunc // ends the goroutine receiving the notifications of the subscription
+	monitoredItemIdMap map[string]uint32  // by node id
 }
 
 func NewPlcConnectorOPCUA(configHook string) *plcConnectorOPCUA {
 	s := plcConnectorOPCUA{
-		ChangeChannels:     map[string]chan domain.StdMessageStruct{},
-		nodeMap:            make(map[string]uint32),
-		clientHandleMap:    make(map[uint32]string),
-		monitoredItemIdMap: make(map[string]uint32),
+		subscriptions:   map[string]*opcuaClientSubscription{},
+		ChangeChannels:  map[string]chan domain.StdMessageStruct{},
+		listenerDone:    map[string]chan struct{}{},
+		nodeMap:         make(map[string]uint32),
+		clientHandleMap: make(map[uint32]string),
 	}
 	s.SetConfigCategory(configHook)
 	loggerHook, cerr := s.GetConfigItemWithDefault(domain.LOGGER_CONFIG_HOOK_TOKEN, domain.DEFAULT_LOGGER_NAME)
@@ -168,70 +178,140 @@ func (s *plcConnectorOPCUA) WriteTags(outTagDefs []domain.StdMessageStruct) []do
 	_ = outTagDefs
 	return []domain.StdMessageStruct{}
 }
-func (s *plcConnectorOPCUA) ListenForPlcTagChanges(c chan domain.StdMessageStruct, changeFilter map[string]interface{}) {
-	//TODO - use the "subscribe" processing to get changes for the given tags - need to figure out things
-	//  like how the interval is configured
 
+//ListenForPlcTagChanges implements the interface by monitoring the nodes of the topics in the subscription of the
+//  client, which is created by the first call for the client
+func (s *plcConnectorOPCUA) ListenForPlcTagChanges(c chan domain.StdMessageStruct, changeFilter map[string]interface{}) {
 	clientName := fmt.Sprintf("%s", changeFilter["Client"])
 	s.LogDebugf("ListenForPlcTagChanges called for Client %s", clientName)
+	s.listenMutex.Lock()
 	s.ChangeChannels[clientName] = c
+	done, exists := s.listenerDone[clientName]
+	if !exists {
+		done = make(chan struct{})
+		s.listenerDone[clientName] = done
+	}
+	s.listenMutex.Unlock()
 
-	notifyCh := make(chan *opcua.PublishNotificationData)
-
-	sub, err := s.uaClient.Subscribe(&opcua.SubscriptionParameters{
-		Interval: time.Second,
-	}, notifyCh)
+	clientSub, err := s.clientSubscription(clientName)
 	if err != nil {
-		log.Fatal(err)
+		s.LogErrorf("failed to create the OPCUA subscription of %s: %s", clientName, err)
+		return
 	}
-	log.Printf("Created subscription with id %v", sub.SubscriptionID)
-	s.subscription = sub
 	for key, val := range changeFilter {
 		if strings.Index(key, "Topic") == 0 {
-			id, err := ua.ParseNodeID(val.(string))
+			nodeName := fmt.Sprintf("%s", val)
+			id, err := ua.ParseNodeID(nodeName)
 			if err != nil {
 				s.LogInfof("Skipping OPCUA subscription to item %s because it's external name %s is not compliant", key, val)
 				continue
 			}
-			clientHandle, ok := s.nodeMap[val.(string)]
+			s.listenMutex.Lock()
+			clientHandle, ok := s.nodeMap[nodeName]
 			if !ok {
 				clientHandle = uint32(len(s.nodeMap) + 1)
-				s.nodeMap[val.(string)] = clientHandle
-				s.clientHandleMap[clientHandle] = val.(string)
+				s.nodeMap[nodeName] = clientHandle
+				s.clientHandleMap[clientHandle] = nodeName
 			}
+			s.listenMutex.Unlock()
 			miCreateRequest := valueRequest(id, clientHandle)
 
-			res, err := sub.Monitor(ua.TimestampsToReturnBoth, miCreateRequest)
-			if err != nil || res.Results[0].StatusCode != ua.StatusOK {
-				s.LogErrorf("failed to subscribe to node %s : %s", val.(string), err)
+			res, err := clientSub.subscription.Monitor(ua.TimestampsToReturnBoth, miCreateRequest)
+			if err == nil && res.Results[0].StatusCode != ua.StatusOK {
+				err = res.Results[0].StatusCode
+			}
+			if err != nil {
+				s.LogErrorf("failed to subscribe to node %s : %s", nodeName, err)
 				temp := err.Error()
 				tagData := domain.StdMessageStruct{
 					Err:              &temp,
-					ItemName:         val.(string),
+					ItemName:         nodeName,
 					ChangedTimestamp: time.Now().UTC(),
 					Category:         "TAGDATA",
 				}
-				s.ChangeChannels[clientName] <- tagData
+				deliverPlcChange(c, done, tagData)
 				continue
 			}
+			s.listenMutex.Lock()
 			for _, v := range res.Results {
-				s.monitoredItemIdMap[val.(string)] = v.MonitoredItemID
+				clientSub.monitoredItemIdMap[nodeName] = v.MonitoredItemID
 			}
-
+			s.listenMutex.Unlock()
 		}
 	}
-	go s.startSubscription(clientName, s.connectionContext, notifyCh)
 }
+
+//clientSubscription returns the subscription of the client, creating it and starting the goroutine that receives
+//  its notifications if needed
+func (s *plcConnectorOPCUA) clientSubscription(clientName string) (*opcuaClientSubscription, error) {
+	s.listenMutex.Lock()
+	defer s.listenMutex.Unlock()
+	if clientSub, exists := s.subscriptions[clientName]; exists {
+		return clientSub, nil
+	}
+	notifyCh := make(chan *opcua.PublishNotificationData)
+	sub, err := s.uaClient.Subscribe(&opcua.SubscriptionParameters{
+		Interval: time.Second,
+	}, notifyCh)
+	if err != nil {
+		return nil, err
+	}
+	s.LogDebugf("Created subscription with id %v for %s", sub.SubscriptionID, clientName)
+	ctx, cancel := context.WithCancel(s.connectionContext)
+	clientSub := &opcuaClientSubscription{
+		subscription:       sub,
+		cancel:             cancel,
+		monitoredItemIdMap: map[string]uint32{},
+	}
+	s.subscriptions[clientName] = clientSub
+	go s.startSubscription(clientName, ctx, notifyCh)
+	return clientSub, nil
+}
+
 func valueRequest(nodeID *ua.NodeID, handle uint32) *ua.MonitoredItemCreateRequest {
 	return opcua.NewMonitoredItemCreateRequestWithDefaults(nodeID, ua.AttributeIDValue, handle)
 }
-func (s *plcConnectorOPCUA) Unsubscribe(equipmentId *string, topicList []string) error {
-	if s.subscription != nil {
-		for _, node := range topicList {
-			s.subscription.Unmonitor(s.monitoredItemIdMap[node])
+
+//Unsubscribe implements the interface by removing the monitored items of the tags from the subscription of the
+//  client
+func (s *plcConnectorOPCUA) Unsubscribe(clientName *string, tagNames []string) error {
+	s.listenMutex.Lock()
+	clientSub, exists := s.subscriptions[*clientName]
+	ids := make([]uint32, 0, len(tagNames))
+	if exists {
+		for _, node := range tagNames {
+			if id, monitored := clientSub.monitoredItemIdMap[node]; monitored {
+				ids = append(ids, id)
+				delete(clientSub.monitoredItemIdMap, node)
+			}
 		}
 	}
-	return nil
+	s.listenMutex.Unlock()
+	if len(ids) == 0 {
+		return nil
+	}
+	_, err := clientSub.subscription.Unmonitor(ids...)
+	return err
+}
+
+//StopListening implements the interface by forgetting the channel of the client, and cancelling its subscription
+func (s *plcConnectorOPCUA) StopListening(clientName string) {
+	s.listenMutex.Lock()
+	delete(s.ChangeChannels, clientName)
+	if done, exists := s.listenerDone[clientName]; exists {
+		close(done)
+		delete(s.listenerDone, clientName)
+	}
+	clientSub, exists := s.subscriptions[clientName]
+	delete(s.subscriptions, clientName)
+	s.listenMutex.Unlock()
+	if !exists {
+		return
+	}
+	clientSub.cancel()
+	if err := clientSub.subscription.Cancel(s.connectionContext); err != nil {
+		s.LogErrorf("failed to cancel the OPCUA subscription of %s: %s", clientName, err)
+	}
 }
 
 func (s *plcConnectorOPCUA) GetTagHistory(startTS time.Time, endTS time.Time, inTagDefs []domain.StdMessageStruct) []domain.StdMessageStruct {
@@ -260,17 +340,27 @@ func (s *plcConnectorOPCUA) startSubscription(clientName string, ctx context.Con
 			switch x := res.Value.(type) {
 			case *ua.DataChangeNotification:
 				for _, item := range x.MonitoredItems {
-					data := item.Value.Value.Value()
-					s.LogDebugf("MonitoredItem with client handle %v = %v", item.ClientHandle, data)
+					var data interface{}
+					if item.Value.Value != nil {
+						data = item.Value.Value.Value()
+					}
+					s.LogDebugf("MonitoredItem with client handle %v = %v (status %v)", item.ClientHandle, data, item.Value.Status)
+					s.listenMutex.Lock()
+					itemName := s.clientHandleMap[item.ClientHandle]
+					c, exists := s.ChangeChannels[clientName]
+					done := s.listenerDone[clientName]
+					s.listenMutex.Unlock()
 					tagData := domain.StdMessageStruct{
 						OwningAsset:      "", //will be completed by channel listener
-						ItemName:         s.clientHandleMap[item.ClientHandle],
+						ItemName:         itemName,
 						ItemValue:        fmt.Sprintf("%v", data),
-						TagQuality:       128,
+						TagQuality:       int(domain.TagQualityFromOPCUAStatus(uint32(item.Value.Status))),
 						Err:              nil,
 						ChangedTimestamp: item.Value.ServerTimestamp, //time.now.utc
 					}
-					s.ChangeChannels[clientName] <- tagData
+					if !exists || !deliverPlcChange(c, done, tagData) {
+						s.LogDebugf("dropping change of %s, nothing is listening for %s", tagData.ItemName, clientName)
+					}
 				}
 
 			case *ua.EventNotificationList:
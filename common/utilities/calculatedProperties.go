@@ -0,0 +1,100 @@
+package utilities
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/PaesslerAG/gval"
+	"github.com/Spruik/libre-common/common/core/domain"
+)
+
+//calculatedPropertySet holds the parsed expressions of the CALCULATED properties of one equipment along with the
+//  order in which they must be computed
+type calculatedPropertySet struct {
+	graph       *domain.PropertyDependencyGraph
+	expressions map[string]string
+	evaluables  map[string]gval.Evaluable
+}
+
+//newCalculatedPropertySet parses each expression once.  Properties whose expression does not parse, or which are
+//  part of a dependency cycle, are left out of the set and reported in the returned errors.
+func newCalculatedPropertySet(expressions map[string]string) (*calculatedPropertySet, []error) {
+	set := &calculatedPropertySet{
+		expressions: map[string]string{},
+		evaluables:  map[string]gval.Evaluable{},
+	}
+	errs := make([]error, 0)
+	dependencies := map[string][]string{}
+	names := make([]string, 0, len(expressions))
+	for name := range expressions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		evaluable, inputs, err := parseCalculatedExpression(expressions[name])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("calculated property %s has a bad expression '%s': %s", name, expressions[name], err))
+			continue
+		}
+		set.expressions[name] = expressions[name]
+		set.evaluables[name] = evaluable
+		dependencies[name] = inputs
+	}
+	var err error
+	set.graph, err = domain.NewPropertyDependencyGraph(dependencies)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	return set, errs
+}
+
+//parseCalculatedExpression parses a gval expression, collecting the names of the properties it reads
+func parseCalculatedExpression(expression string) (gval.Evaluable, []string, error) {
+	inputs := make([]string, 0)
+	seen := map[string]bool{}
+	language := gval.NewLanguage(gval.Full(), gval.VariableSelector(func(path gval.Evaluables) gval.Evaluable {
+		if len(path) > 0 && path[0].IsConst() {
+			if name, err := path[0].EvalString(context.Background(), nil); err == nil && !seen[name] {
+				seen[name] = true
+				inputs = append(inputs, name)
+			}
+		}
+		return func(c context.Context, parameter interface{}) (interface{}, error) {
+			current := parameter
+			for _, element := range path {
+				key, err := element.EvalString(c, parameter)
+				if err != nil {
+					return nil, err
+				}
+				values, ok := current.(map[string]interface{})
+				if !ok {
+					return nil, fmt.Errorf("cannot select '%s' from %T", key, current)
+				}
+				if current, ok = values[key]; !ok {
+					return nil, fmt.Errorf("unknown property '%s'", key)
+				}
+			}
+			return current, nil
+		}
+	}))
+	evaluable, err := language.NewEvaluable(expression)
+	return evaluable, inputs, err
+}
+
+//Affected returns the calculated properties to recompute when the named property changes, in evaluation order
+func (c *calculatedPropertySet) Affected(changed string) []string {
+	if c == nil {
+		return []string{}
+	}
+	return c.graph.Affected(changed)
+}
+
+//Evaluate computes one calculated property from the given property values
+func (c *calculatedPropertySet) Evaluate(name string, values map[string]interface{}) (interface{}, error) {
+	evaluable, exists := c.evaluables[name]
+	if !exists {
+		return nil, fmt.Errorf("%s is not a calculated property", name)
+	}
+	return evaluable(context.Background(), values)
+}
@@ -0,0 +1,115 @@
+package utilities
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	libreConfig "github.com/Spruik/libre-configuration"
+	"github.com/Spruik/libre-configuration/shared"
+)
+
+//getConfigStanzaIfPresent returns the named configuration stanza, or nil if the category or the stanza is not configured
+//  (asking the configuration service for a stanza of a missing category panics, so check the category first)
+func getConfigStanzaIfPresent(cfg *libreConfig.ConfigurationEnabler, key string) *shared.ConfigItem {
+	if _, err := cfg.GetConfigItemWithDefault(key, ""); err != nil {
+		return nil
+	}
+	stanza, err := cfg.GetConfigStanza(key)
+	if err != nil || stanza == nil || (stanza.Value == "" && len(stanza.Children) == 0) {
+		return nil
+	}
+	return stanza
+}
+
+//getConfigChildValue returns the value of the named child of a configuration stanza item
+func getConfigChildValue(item *shared.ConfigItem, name string) string {
+	if item != nil {
+		for _, child := range item.Children {
+			if child.Name == name {
+				return child.Value
+			}
+		}
+	}
+	return ""
+}
+
+//getConfigStringList returns the values of a configured list (or comma separated string), or the defaults if the
+//  list is not configured
+func getConfigStringList(cfg *libreConfig.ConfigurationEnabler, key string, defaults []string) []string {
+	stanza := getConfigStanzaIfPresent(cfg, key)
+	if stanza == nil {
+		return defaults
+	}
+	ret := make([]string, 0, len(stanza.Children))
+	if len(stanza.Children) == 0 {
+		for _, item := range strings.Split(stanza.Value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				ret = append(ret, item)
+			}
+		}
+		return ret
+	}
+	for _, child := range stanza.Children {
+		if child.Value != "" {
+			ret = append(ret, child.Value)
+		}
+	}
+	return ret
+}
+
+//getConfigStringWithDefault reads a text item from the configuration, returning the default if the item is missing
+//  or empty
+func getConfigStringWithDefault(cfg *libreConfig.ConfigurationEnabler, key string, def string) string {
+	if val, err := cfg.GetConfigItemWithDefault(key, def); err == nil && val != "" {
+		return val
+	}
+	return def
+}
+
+//getConfigDurationWithDefault reads a duration ("30s", "5m") from the configuration, returning the default if the item
+//  is missing or empty.  A malformed value returns the default along with the parse error.
+func getConfigDurationWithDefault(cfg *libreConfig.ConfigurationEnabler, key string, def time.Duration) (time.Duration, error) {
+	str, err := cfg.GetConfigItemWithDefault(key, "")
+	if err != nil || str == "" {
+		return def, nil
+	}
+	dur, err := time.ParseDuration(str)
+	if err != nil {
+		return def, fmt.Errorf("bad duration '%s' for config item %s: %s", str, key, err)
+	}
+	return dur, nil
+}
+
+//getConfigIntWithDefault reads a whole number from the configuration, returning the default if the item is missing
+//  or empty.  A malformed value returns the default along with the parse error.
+func getConfigIntWithDefault(cfg *libreConfig.ConfigurationEnabler, key string, def int) (int, error) {
+	str, err := cfg.GetConfigItemWithDefault(key, "")
+	if err != nil || str == "" {
+		return def, nil
+	}
+	val, err := strconv.Atoi(strings.TrimSpace(str))
+	if err != nil {
+		return def, fmt.Errorf("bad number '%s' for config item %s: %s", str, key, err)
+	}
+	return val, nil
+}
+
+//getConfigDurationStanza reads a stanza of named durations, such as a duration per property.  A malformed value
+//  returns the error naming the item.
+func getConfigDurationStanza(cfg *libreConfig.ConfigurationEnabler, key string) (map[string]time.Duration, error) {
+	ret := map[string]time.Duration{}
+	stanza := getConfigStanzaIfPresent(cfg, key)
+	if stanza == nil {
+		return ret, nil
+	}
+	for _, child := range stanza.Children {
+		dur, err := time.ParseDuration(strings.TrimSpace(child.Value))
+		if err != nil {
+			return ret, fmt.Errorf("bad duration '%s' for %s in config item %s: %s", child.Value, child.Name, key, err)
+		}
+		ret[child.Name] = dur
+	}
+	return ret, nil
+}
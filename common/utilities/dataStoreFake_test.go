@@ -0,0 +1,122 @@
+package utilities
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/Spruik/libre-common/common/core/ports"
+	"github.com/hasura/go-graphql-client"
+)
+
+//fakeDataStore records the GraphQL built for each query and mutation, and answers them with canned data
+type fakeDataStore struct {
+	mutex     sync.Mutex
+	queries   []string
+	mutations []string
+	inputs    []string          // the JSON of the variables of each mutation
+	responses map[string]string // the JSON data of a query or mutation, by its root field
+	failures  int               // how many of the next operations fail
+}
+
+func newFakeDataStore() *fakeDataStore {
+	return &fakeDataStore{responses: map[string]string{}}
+}
+
+func (s *fakeDataStore) Connect() error { return nil }
+func (s *fakeDataStore) Close() error   { return nil }
+
+func (s *fakeDataStore) BeginTransaction(forUpdate bool, name string) ports.LibreDataStoreTransactionPort {
+	return &fakeDataStoreTransaction{store: s}
+}
+
+func (s *fakeDataStore) GetSubscription(q interface{}, vars map[string]interface{}) ports.LibreDataStoreSubscriptionPort {
+	return nil
+}
+
+//recordedInputs returns a copy of the JSON of the variables of the mutations built so far
+func (s *fakeDataStore) recordedInputs() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]string{}, s.inputs...)
+}
+
+//recorded returns a copy of the queries and mutations built so far
+func (s *fakeDataStore) recorded() ([]string, []string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]string{}, s.queries...), append([]string{}, s.mutations...)
+}
+
+func (s *fakeDataStore) fail() error {
+	if s.failures > 0 {
+		s.failures--
+		return fmt.Errorf("data store unavailable")
+	}
+	return nil
+}
+
+type fakeDataStoreTransaction struct {
+	store *fakeDataStore
+}
+
+func (t *fakeDataStoreTransaction) ExecuteQuery(q interface{}, vars map[string]interface{}) error {
+	query, err := graphql.ConstructQuery(q, vars)
+	if err != nil {
+		return err
+	}
+	t.store.mutex.Lock()
+	defer t.store.mutex.Unlock()
+	t.store.queries = append(t.store.queries, query)
+	if err = t.store.fail(); err != nil {
+		return err
+	}
+	body := queryBody(query)
+	for root, data := range t.store.responses {
+		if strings.HasPrefix(body, "{"+root+"(") || strings.HasPrefix(body, "{"+root+" ") || strings.HasPrefix(body, "{"+root+"{") {
+			return graphql.UnmarshalGraphQL([]byte(data), q)
+		}
+	}
+	return nil
+}
+
+func (t *fakeDataStoreTransaction) ExecuteMutation(m interface{}, vars map[string]interface{}) error {
+	mutation, err := graphql.ConstructMutation(m, vars)
+	if err != nil {
+		return err
+	}
+	t.store.mutex.Lock()
+	defer t.store.mutex.Unlock()
+	t.store.mutations = append(t.store.mutations, mutation)
+	input, _ := json.Marshal(vars)
+	t.store.inputs = append(t.store.inputs, string(input))
+	if err = t.store.fail(); err != nil {
+		return err
+	}
+	for root, data := range t.store.responses {
+		if strings.Contains(mutation, "{"+root+"(") {
+			return graphql.UnmarshalGraphQL([]byte(data), m)
+		}
+	}
+	return nil
+}
+
+func (t *fakeDataStoreTransaction) Commit()  {}
+func (t *fakeDataStoreTransaction) Dispose() {}
+
+//queryBody drops the variable declarations the client puts ahead of the selections of a query with variables
+func queryBody(query string) string {
+	if strings.HasPrefix(query, "query") {
+		if ndx := strings.Index(query, "{"); ndx >= 0 {
+			return query[ndx:]
+		}
+	}
+	return query
+}
+
+//jsonData wraps the value as the JSON data of a query with the given root field
+func jsonData(root string, value interface{}) string {
+	raw, _ := json.Marshal(map[string]interface{}{root: value})
+	return string(raw)
+}
@@ -0,0 +1,69 @@
+package utilities
+
+import (
+	"github.com/Spruik/libre-common/common/core/ports"
+	libreConfig "github.com/Spruik/libre-configuration"
+	libreLogger "github.com/Spruik/libre-logging"
+)
+
+//dataStoreWriteQueue runs data store writes one at a time on a background goroutine, retrying failed writes, so
+//  callers handling tag changes never wait on the data store.  Writes run in the order they were queued.
+type dataStoreWriteQueue struct {
+	queuedWriter
+
+	storeIF ports.LibreDataStorePort
+	txnName string
+	queue   chan dataStoreWrite
+}
+
+type dataStoreWrite struct {
+	description string
+	//write runs the mutations and commits; it is called again with a new transaction after a failure
+	write func(txn ports.LibreDataStoreTransactionPort) error
+}
+
+//newDataStoreWriteQueue reads the retry, close and queue settings of a queuedWriter from the owner's configuration
+//  category
+func newDataStoreWriteQueue(cfg *libreConfig.ConfigurationEnabler, logger *libreLogger.LoggingEnabler, storeIF ports.LibreDataStorePort, txnName string) (*dataStoreWriteQueue, error) {
+	q := dataStoreWriteQueue{
+		storeIF: storeIF,
+		txnName: txnName,
+	}
+	if err := q.configure(cfg, logger, "Data store writer", defaultWriteRetryAttempts, defaultWriteQueueSize); err != nil {
+		return nil, err
+	}
+	q.queue = make(chan dataStoreWrite, q.queueSize)
+	go q.writeQueued()
+	return &q, nil
+}
+
+//enqueue never blocks; when the queue is full or closed the write is dropped with a warning
+func (q *dataStoreWriteQueue) enqueue(description string, write func(txn ports.LibreDataStoreTransactionPort) error) {
+	q.offer("the write of "+description, func() bool {
+		select {
+		case q.queue <- dataStoreWrite{description: description, write: write}:
+			return true
+		default:
+			return false
+		}
+	})
+}
+
+//close stops taking writes and waits up to the close timeout for the queued writes to finish
+func (q *dataStoreWriteQueue) close() error {
+	return q.closeAndWait(func() { close(q.queue) }, func() int { return len(q.queue) })
+}
+
+func (q *dataStoreWriteQueue) writeQueued() {
+	defer close(q.done)
+	for write := range q.queue {
+		write := write
+		q.writeWithRetries(write.description, func() error { return q.runWrite(write) })
+	}
+}
+
+func (q *dataStoreWriteQueue) runWrite(write dataStoreWrite) error {
+	txn := q.storeIF.BeginTransaction(true, q.txnName)
+	defer txn.Dispose()
+	return write.write(txn)
+}
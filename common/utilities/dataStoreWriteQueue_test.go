@@ -0,0 +1,90 @@
+package utilities
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Spruik/libre-common/common/core/ports"
+	libreLogger "github.com/Spruik/libre-logging"
+)
+
+func newTestWriteQueue(queueSize int) *dataStoreWriteQueue {
+	logger := &libreLogger.LoggingEnabler{}
+	logger.SetLoggerConfigHook("dataStoreWriteQueueTest")
+	q := &dataStoreWriteQueue{
+		queuedWriter: queuedWriter{
+			logger:        logger,
+			name:          "Data store writer",
+			retryAttempts: 3,
+			retryDelay:    time.Millisecond,
+			closeTimeout:  time.Second,
+			done:          make(chan struct{}),
+		},
+		storeIF: newFakeDataStore(),
+		txnName: "writeQueueTest",
+		queue:   make(chan dataStoreWrite, queueSize),
+	}
+	go q.writeQueued()
+	return q
+}
+
+//writeLog records the attempts of each write, in order
+type writeLog struct {
+	mutex    sync.Mutex
+	attempts []string
+}
+
+func (l *writeLog) write(name string, failures int) func(txn ports.LibreDataStoreTransactionPort) error {
+	return func(txn ports.LibreDataStoreTransactionPort) error {
+		l.mutex.Lock()
+		defer l.mutex.Unlock()
+		l.attempts = append(l.attempts, name)
+		if failures > 0 {
+			failures--
+			return fmt.Errorf("write of %s failed", name)
+		}
+		return nil
+	}
+}
+
+func TestDataStoreWriteQueueRetriesThenDrops(t *testing.T) {
+	q := newTestWriteQueue(10)
+	log := &writeLog{}
+	q.enqueue("flaky", log.write("flaky", 2))
+	q.enqueue("broken", log.write("broken", 5))
+	q.enqueue("fine", log.write("fine", 0))
+	if err := q.close(); err != nil {
+		t.Fatalf("close failed: %s", err)
+	}
+	//flaky succeeds on its third attempt, broken is dropped after three and the queue carries on
+	expected := []string{"flaky", "flaky", "flaky", "broken", "broken", "broken", "fine"}
+	if !reflect.DeepEqual(log.attempts, expected) {
+		t.Errorf("Expect attempts %v; got %v", expected, log.attempts)
+	}
+}
+
+func TestDataStoreWriteQueueDropsWritesWhenFullOrClosed(t *testing.T) {
+	q := newTestWriteQueue(1)
+	log := &writeLog{}
+	started := make(chan struct{})
+	release := make(chan struct{})
+	q.enqueue("blocking", func(txn ports.LibreDataStoreTransactionPort) error {
+		close(started)
+		<-release
+		return log.write("blocking", 0)(txn)
+	})
+	<-started
+	q.enqueue("queued", log.write("queued", 0))
+	q.enqueue("overflow", log.write("overflow", 0))
+	close(release)
+	if err := q.close(); err != nil {
+		t.Fatalf("close failed: %s", err)
+	}
+	q.enqueue("late", log.write("late", 0))
+	if expected := []string{"blocking", "queued"}; !reflect.DeepEqual(log.attempts, expected) {
+		t.Errorf("Expect only the writes that fit in the open queue; got %v", log.attempts)
+	}
+}
@@ -31,6 +31,8 @@ type DaemonBase struct {
 	terminationWaitGroup   *sync.WaitGroup
 	commandMutex           sync.Mutex
 	acceptCommandWaitLimit time.Duration
+	scheduledJobs          []*daemonScheduledJob
+	scheduleMutex          sync.Mutex
 }
 
 func NewDaemonBase(name string, initialState ports.DaemonStateIF, parentWG *sync.WaitGroup, configHook string) *DaemonBase {
@@ -59,6 +61,8 @@ func NewDaemonBase(name string, initialState ports.DaemonStateIF, parentWG *sync
 	d.adminChannel = make(chan ports.DaemonAdminCommand)
 	d.terminationWaitGroup = nil
 	d.commandMutex = sync.Mutex{}
+	d.scheduledJobs = make([]*daemonScheduledJob, 0)
+	d.scheduleMutex = sync.Mutex{}
 	acceptLimitStr, derr := d.GetConfigItemWithDefault("commandWaitDuration", "1000ms")
 	if derr == nil {
 		d.acceptCommandWaitLimit, derr = time.ParseDuration(acceptLimitStr)
@@ -104,6 +108,7 @@ func (d *DaemonBase) Run(params map[string]interface{}) {
 		for _, child := range d.daemonChildren {
 			child.Run(params)
 		}
+		d.loadScheduleFromConfig()
 		var run = true
 		// var processed = 0
 		for run {
@@ -112,6 +117,9 @@ func (d *DaemonBase) Run(params map[string]interface{}) {
 			if err != nil {
 				panic(err)
 			}
+			if !d.state.IsTerminalState() {
+				d.runDueScheduledCommands(time.Now())
+			}
 			if d.state.IsTerminalState() {
 				d.LogInfo(d.name, "latest command resulted in a terminal state - ending", d.state.GetStateName())
 				run = false
@@ -150,38 +158,9 @@ func (d *DaemonBase) acceptCommand() error {
 			d.parentWaitGroup.Add(1)
 			defer d.parentWaitGroup.Done()
 		}
-		resp := map[string]interface{}{}
-		d.LogDebugf("%s looking for a function to implement %s where map is: %+v", d.name, chgCmd.Cmd.GetCommandName(), d.formatControlFxnMap())
-		cmdFxn := d.controlFxns[chgCmd.Cmd]
-		d.LogDebugf("%s looked for a function to implement %s where map is: %+v", d.name, chgCmd.Cmd.GetCommandName(), d.formatControlFxnMap())
-		d.LogDebugf("%s found: %+v", d.name, cmdFxn)
-		if cmdFxn != nil {
-			resp, err = cmdFxn(d, chgCmd.Params)
-			if err != nil {
-				panic(err)
-			}
-			d.LogDebug(d.name, "processed command message", chgCmd.Cmd.GetCommandName())
-		}
-		if len(d.daemonChildren) > 0 {
-			d.LogDebugf("%s start sending %s command to children", d.name, chgCmd.Cmd.GetCommandName())
-			for _, child := range d.daemonChildren {
-				d.LogDebug(d.name, "sending command to child", child.GetName(), chgCmd.Cmd.GetCommandName())
-				childresp, submitErr := child.SubmitCommand(chgCmd.Cmd, chgCmd.Params)
-				if submitErr != nil {
-					panic(submitErr)
-				}
-				if childresp != nil {
-					resp[child.GetName()] = childresp
-				}
-				d.LogDebug(d.name, "sent command message to child", child.GetName(), chgCmd.Cmd.GetCommandName())
-			}
-			d.LogDebugf("%s waiting for child completion of %s", d.name, chgCmd.Cmd.GetCommandName())
-			d.localWaitGroup.Wait()
-			d.LogDebugf("%s done waiting for child completion of %s", d.name, chgCmd.Cmd.GetCommandName())
-		}
-		if chgCmd.Cmd.HasTargetState() {
-			d.LogInfof("DAEMON '%s' SETTING STATE TO %s", d.name, chgCmd.Cmd.GetTargetState().GetStateName())
-			d.SetState(chgCmd.Cmd.GetTargetState())
+		resp, cmdErr := d.executeCommand(chgCmd.Cmd, chgCmd.Params)
+		if cmdErr != nil {
+			panic(cmdErr)
 		}
 		if len(resp) > 0 {
 			chgCmd.Results = resp
@@ -196,6 +175,48 @@ func (d *DaemonBase) acceptCommand() error {
 	return err
 }
 
+//executeCommand runs the command function (if any), passes the command on to the children and applies the target state
+func (d *DaemonBase) executeCommand(cmd ports.DaemonCommandIF, params map[string]interface{}) (map[string]interface{}, error) {
+	var err error
+	resp := map[string]interface{}{}
+	d.LogDebugf("%s looking for a function to implement %s where map is: %+v", d.name, cmd.GetCommandName(), d.formatControlFxnMap())
+	cmdFxn := d.controlFxns[cmd]
+	d.LogDebugf("%s looked for a function to implement %s where map is: %+v", d.name, cmd.GetCommandName(), d.formatControlFxnMap())
+	d.LogDebugf("%s found: %+v", d.name, cmdFxn)
+	if cmdFxn != nil {
+		resp, err = cmdFxn(d, params)
+		if err != nil {
+			return nil, err
+		}
+		if resp == nil {
+			resp = map[string]interface{}{}
+		}
+		d.LogDebug(d.name, "processed command message", cmd.GetCommandName())
+	}
+	if len(d.daemonChildren) > 0 {
+		d.LogDebugf("%s start sending %s command to children", d.name, cmd.GetCommandName())
+		for _, child := range d.daemonChildren {
+			d.LogDebug(d.name, "sending command to child", child.GetName(), cmd.GetCommandName())
+			childresp, submitErr := child.SubmitCommand(cmd, params)
+			if submitErr != nil {
+				return nil, submitErr
+			}
+			if childresp != nil {
+				resp[child.GetName()] = childresp
+			}
+			d.LogDebug(d.name, "sent command message to child", child.GetName(), cmd.GetCommandName())
+		}
+		d.LogDebugf("%s waiting for child completion of %s", d.name, cmd.GetCommandName())
+		d.localWaitGroup.Wait()
+		d.LogDebugf("%s done waiting for child completion of %s", d.name, cmd.GetCommandName())
+	}
+	if cmd.HasTargetState() {
+		d.LogInfof("DAEMON '%s' SETTING STATE TO %s", d.name, cmd.GetTargetState().GetStateName())
+		d.SetState(cmd.GetTargetState())
+	}
+	return resp, nil
+}
+
 func (d *DaemonBase) formatControlFxnMap() string {
 	var ret = ""
 	for key, val := range d.controlFxns {
@@ -0,0 +1,178 @@
+package utilities
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Spruik/libre-common/common/core/domain"
+	"github.com/Spruik/libre-common/common/core/ports"
+	"github.com/Spruik/libre-configuration/shared"
+)
+
+//daemonScheduledJob pairs the reportable job information with the parsed schedule and the command to run
+type daemonScheduledJob struct {
+	info     ports.DaemonScheduledJob
+	cmd      ports.DaemonCommandIF
+	schedule *domain.CronSchedule
+}
+
+//AddScheduledCommand registers a command to be run by this daemon on the given schedule.  The schedule is either a
+//  five field cron expression ("0 2 * * *"), a descriptor ("@daily") or an interval ("@every 1h")
+func (d *DaemonBase) AddScheduledCommand(jobName string, schedule string, cmd ports.DaemonCommandIF, params map[string]interface{}) error {
+	if cmd == nil {
+		return fmt.Errorf("no command given for scheduled job '%s' of daemon %s", jobName, d.name)
+	}
+	sched, err := domain.ParseCronSchedule(schedule)
+	if err != nil {
+		return err
+	}
+	d.scheduleMutex.Lock()
+	defer d.scheduleMutex.Unlock()
+	for _, job := range d.scheduledJobs {
+		if job.info.Name == jobName {
+			return fmt.Errorf("daemon %s already has a scheduled job named '%s'", d.name, jobName)
+		}
+	}
+	job := &daemonScheduledJob{
+		info: ports.DaemonScheduledJob{
+			Name:     jobName,
+			Daemon:   d.name,
+			Command:  cmd.GetCommandName(),
+			Schedule: sched.String(),
+			Params:   params,
+			NextRun:  sched.Next(time.Now()),
+		},
+		cmd:      cmd,
+		schedule: sched,
+	}
+	d.scheduledJobs = append(d.scheduledJobs, job)
+	d.LogInfof("%s scheduled job '%s' to run %s on '%s' - next run at %s", d.name, jobName, job.info.Command, job.info.Schedule, job.info.NextRun)
+	return nil
+}
+
+func (d *DaemonBase) RemoveScheduledCommand(jobName string) {
+	d.scheduleMutex.Lock()
+	defer d.scheduleMutex.Unlock()
+	for ndx, job := range d.scheduledJobs {
+		if job.info.Name == jobName {
+			d.scheduledJobs = append(d.scheduledJobs[:ndx], d.scheduledJobs[ndx+1:]...)
+			d.LogInfof("%s removed scheduled job '%s'", d.name, jobName)
+			return
+		}
+	}
+}
+
+//GetSchedule returns a copy of the scheduled jobs of this daemon and its children
+func (d *DaemonBase) GetSchedule() []ports.DaemonScheduledJob {
+	d.scheduleMutex.Lock()
+	ret := make([]ports.DaemonScheduledJob, 0, len(d.scheduledJobs))
+	for _, job := range d.scheduledJobs {
+		ret = append(ret, job.info)
+	}
+	d.scheduleMutex.Unlock()
+	for _, child := range d.daemonChildren {
+		ret = append(ret, child.GetSchedule()...)
+	}
+	return ret
+}
+
+//runDueScheduledCommands is called from the daemon processing loop, so scheduled commands are executed in the same
+//  thread as commands arriving through the admin channel
+func (d *DaemonBase) runDueScheduledCommands(now time.Time) {
+	d.scheduleMutex.Lock()
+	due := make([]*daemonScheduledJob, 0)
+	for _, job := range d.scheduledJobs {
+		if !job.info.NextRun.IsZero() && !now.Before(job.info.NextRun) {
+			due = append(due, job)
+		}
+	}
+	d.scheduleMutex.Unlock()
+
+	for _, job := range due {
+		d.runScheduledJob(job, now)
+	}
+}
+
+//runScheduledJob runs a due job and records the result; like a command from the admin channel, it holds the parent
+//  wait group while it runs, so the parent doesn't end under it
+func (d *DaemonBase) runScheduledJob(job *daemonScheduledJob, now time.Time) {
+	if d.parentWaitGroup != nil {
+		d.parentWaitGroup.Add(1)
+		defer d.parentWaitGroup.Done()
+	}
+	d.LogInfof("%s running scheduled job '%s' (%s)", d.name, job.info.Name, job.info.Command)
+	_, err := d.executeCommand(job.cmd, job.info.Params)
+	d.scheduleMutex.Lock()
+	job.info.LastRun = now
+	job.info.RunCount++
+	job.info.NextRun = job.schedule.Next(now)
+	if err != nil {
+		job.info.LastErr = err.Error()
+	} else {
+		job.info.LastErr = ""
+	}
+	d.scheduleMutex.Unlock()
+	if err != nil {
+		d.LogErrorf("%s scheduled job '%s' failed: %s", d.name, job.info.Name, err)
+	}
+}
+
+//loadScheduleFromConfig reads the optional SCHEDULE list from the daemon configuration, for example:
+//  "SCHEDULE": [
+//    {"name": "nightlyRefresh", "command": "RefreshCache", "cron": "0 2 * * *"},
+//    {"name": "compaction", "command": "Compact", "interval": "1h", "params": {"bucket": "raw"}}
+//  ]
+func (d *DaemonBase) loadScheduleFromConfig() {
+	if stanza := getConfigStanzaIfPresent(&d.ConfigurationEnabler, "SCHEDULE"); stanza != nil {
+		d.loadSchedule(stanza)
+	}
+}
+
+//loadSchedule schedules each entry of the SCHEDULE stanza; a bad entry is logged and skipped
+func (d *DaemonBase) loadSchedule(stanza *shared.ConfigItem) {
+	for _, entry := range stanza.Children {
+		cmdName := getConfigChildValue(entry, "command")
+		jobName := getConfigChildValue(entry, "name")
+		if jobName == "" {
+			jobName = cmdName
+		}
+		spec := getConfigChildValue(entry, "cron")
+		if interval := getConfigChildValue(entry, "interval"); interval != "" {
+			spec = "@every " + interval
+		}
+		params := map[string]interface{}{}
+		for _, item := range entry.Children {
+			if item.Name == "params" {
+				for _, param := range item.Children {
+					params[param.Name] = param.Value
+				}
+			}
+		}
+		cmd := d.findCommand(cmdName)
+		if cmd == nil {
+			d.LogErrorf("%s cannot schedule job '%s' - no command named '%s'", d.name, jobName, cmdName)
+			continue
+		}
+		if err := d.AddScheduledCommand(jobName, spec, cmd, params); err != nil {
+			d.LogErrorf("%s failed to schedule job '%s': %s", d.name, jobName, err)
+		}
+	}
+}
+
+//findCommand looks up a command implemented by this daemon or any of its children by (case insensitive) name
+func (d *DaemonBase) findCommand(cmdName string) ports.DaemonCommandIF {
+	for cmd := range d.controlFxns {
+		if strings.EqualFold(cmd.GetCommandName(), cmdName) {
+			return cmd
+		}
+	}
+	for _, child := range d.daemonChildren {
+		for cmd := range child.GetCommands() {
+			if strings.EqualFold(cmd.GetCommandName(), cmdName) {
+				return cmd
+			}
+		}
+	}
+	return nil
+}
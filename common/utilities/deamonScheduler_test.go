@@ -0,0 +1,157 @@
+package utilities
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Spruik/libre-common/common/core/ports"
+	"github.com/Spruik/libre-configuration/shared"
+)
+
+var schedulerTestState = NewDaemonState("schedulerTestRunning", true, false)
+
+func newTestSchedulerDaemon(name string, wg *sync.WaitGroup) *DaemonBase {
+	return NewDaemonBase(name, schedulerTestState, wg, "daemonSchedulerTest")
+}
+
+//addTestCommand gives the daemon a command that counts its runs and returns the error it is given
+func addTestCommand(d *DaemonBase, name string, err error, runs *int) ports.DaemonCommandIF {
+	cmd := NewDaemonCommand(name, nil, nil)
+	d.AddCommandFxn(cmd, func(d ports.DaemonIF, params map[string]interface{}) (map[string]interface{}, error) {
+		*runs++
+		return nil, err
+	})
+	return cmd
+}
+
+func TestDaemonScheduleAddAndRemove(t *testing.T) {
+	parent := newTestSchedulerDaemon("parent", nil)
+	child := newTestSchedulerDaemon("child", nil)
+	parent.AddDaemonChild(child)
+	var runs int
+	cmd := addTestCommand(parent, "Refresh", nil, &runs)
+
+	if err := parent.AddScheduledCommand("nightly", "0 2 * * *", cmd, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := parent.AddScheduledCommand("nightly", "@hourly", cmd, nil); err == nil {
+		t.Errorf("Expect a second job of the same name to be refused")
+	}
+	if err := parent.AddScheduledCommand("bad", "not a schedule", cmd, nil); err == nil {
+		t.Errorf("Expect a bad schedule to be refused")
+	}
+	if err := parent.AddScheduledCommand("none", "@hourly", nil, nil); err == nil {
+		t.Errorf("Expect a job without a command to be refused")
+	}
+	if err := child.AddScheduledCommand("hourly", "@every 1h", addTestCommand(child, "Compact", nil, &runs), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	schedule := parent.GetSchedule()
+	if len(schedule) != 2 || schedule[0].Name != "nightly" || schedule[1].Name != "hourly" || schedule[1].Daemon != "child" {
+		t.Fatalf("Expect the jobs of the daemon and its child; got %+v", schedule)
+	}
+	if schedule[0].Command != "Refresh" || schedule[0].NextRun.Hour() != 2 {
+		t.Errorf("Expect the nightly job to run Refresh at 2am; got %+v", schedule[0])
+	}
+	parent.RemoveScheduledCommand("nightly")
+	parent.RemoveScheduledCommand("unknown")
+	if schedule = parent.GetSchedule(); len(schedule) != 1 || schedule[0].Name != "hourly" {
+		t.Errorf("Expect only the child's job after the removal; got %+v", schedule)
+	}
+}
+
+func TestDaemonRunsDueScheduledCommands(t *testing.T) {
+	d := newTestSchedulerDaemon("daemon", nil)
+	var goodRuns, badRuns int
+	if err := d.AddScheduledCommand("good", "@every 1h", addTestCommand(d, "Good", nil, &goodRuns), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.AddScheduledCommand("bad", "@every 1h", addTestCommand(d, "Bad", fmt.Errorf("failed"), &badRuns), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	d.runDueScheduledCommands(time.Now())
+	if goodRuns != 0 || badRuns != 0 {
+		t.Fatalf("Expect no job to be due yet; got %d and %d runs", goodRuns, badRuns)
+	}
+	now := time.Now().Add(90 * time.Minute)
+	d.runDueScheduledCommands(now)
+	if goodRuns != 1 || badRuns != 1 {
+		t.Fatalf("Expect both jobs to run once; got %d and %d runs", goodRuns, badRuns)
+	}
+	for _, job := range d.GetSchedule() {
+		if job.RunCount != 1 || !job.LastRun.Equal(now) || !job.NextRun.Equal(now.Add(time.Hour)) {
+			t.Errorf("Expect %s to have run once and be due in an hour; got %+v", job.Name, job)
+		}
+		if (job.Name == "bad") != (job.LastErr == "failed") {
+			t.Errorf("Expect only the bad job to record its error; got %+v", job)
+		}
+	}
+}
+
+func TestDaemonScheduledCommandHoldsTheParentWaitGroup(t *testing.T) {
+	var wg sync.WaitGroup
+	d := newTestSchedulerDaemon("daemon", &wg)
+	waited := make(chan struct{})
+	held := false
+	cmd := NewDaemonCommand("Hold", nil, nil)
+	d.AddCommandFxn(cmd, func(d ports.DaemonIF, params map[string]interface{}) (map[string]interface{}, error) {
+		go func() {
+			wg.Wait()
+			close(waited)
+		}()
+		select {
+		case <-waited:
+		case <-time.After(20 * time.Millisecond):
+			held = true
+		}
+		return nil, nil
+	})
+	if err := d.AddScheduledCommand("hold", "@every 1m", cmd, nil); err != nil {
+		t.Fatal(err)
+	}
+	d.runDueScheduledCommands(time.Now().Add(time.Hour))
+	if !held {
+		t.Errorf("Expect the parent wait group to be held while the scheduled command runs")
+	}
+	select {
+	case <-waited:
+	case <-time.After(time.Second):
+		t.Errorf("Expect the parent wait group to be released after the scheduled command")
+	}
+}
+
+func TestDaemonLoadsTheScheduleFromConfig(t *testing.T) {
+	d := newTestSchedulerDaemon("daemon", nil)
+	var runs int
+	addTestCommand(d, "RefreshCache", nil, &runs)
+	addTestCommand(d, "Compact", nil, &runs)
+	item := func(name string, value string, children ...*shared.ConfigItem) *shared.ConfigItem {
+		return &shared.ConfigItem{Name: name, Value: value, Children: children}
+	}
+	stanza := item("SCHEDULE", "",
+		item("0", "", item("name", "nightlyRefresh"), item("command", "RefreshCache"), item("cron", "0 2 * * *")),
+		item("1", "", item("command", "compact"), item("interval", "1h"), item("params", "", item("bucket", "raw"))),
+		item("2", "", item("name", "unknown"), item("command", "NoSuchCommand"), item("cron", "@daily")),
+		item("3", "", item("name", "badCron"), item("command", "Compact"), item("cron", "every day")),
+	)
+	d.loadSchedule(stanza)
+
+	schedule := d.GetSchedule()
+	if len(schedule) != 2 {
+		t.Fatalf("Expect the unknown command and the bad schedule to be skipped; got %+v", schedule)
+	}
+	if schedule[0].Name != "nightlyRefresh" || schedule[0].Command != "RefreshCache" {
+		t.Errorf("Expect the nightly refresh job; got %+v", schedule[0])
+	}
+	//a job without a name is named by its command, and the command is found whatever its case
+	if schedule[1].Name != "compact" || schedule[1].Command != "Compact" || schedule[1].Params["bucket"] != "raw" {
+		t.Errorf("Expect the compaction job with its params; got %+v", schedule[1])
+	}
+	if next := schedule[1].NextRun.Sub(time.Now()); next <= 0 || next > time.Hour {
+		t.Errorf("Expect the compaction job to be due within the hour; got %s", next)
+	}
+}
@@ -2,12 +2,13 @@ package utilities
 
 import (
 	"fmt"
+	"sort"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/Spruik/libre-common/common/core/domain"
 	"github.com/Spruik/libre-common/common/core/ports"
-	"github.com/Spruik/libre-common/common/core/queries"
 	"github.com/Spruik/libre-common/common/core/services"
 	libreConfig "github.com/Spruik/libre-configuration"
 	libreLogger "github.com/Spruik/libre-logging"
@@ -22,22 +23,42 @@ type equipmentCacheDefault struct {
 
 	dataStore          ports.LibreDataStorePort
 	finderIF           ports.EquipmentFinderPort
-	nameCache          map[string]*ports.ManagedEquipmentPort
-	idCache            map[string]*ports.ManagedEquipmentPort
+	refreshMutex       sync.Mutex
+	contentsMutex      sync.RWMutex
+	contents           *equipmentCacheContents
 	configLevel        int
 	monitorChanges     bool
+	monitorMutex       sync.Mutex
 	monitoringChannel  chan string
-	equipmentChangeFxn func(notice ports.EquipmentCacheChangeNotice)
+	listenerMutex      sync.Mutex
+	listeners          map[int]func(notice ports.EquipmentCacheChangeNotice)
+	nextSubscriptionId int
+}
+
+//equipmentCacheContents is never changed once published - RefreshCache builds a new copy and swaps it in,
+//  so readers can use what they got without locking
+type equipmentCacheContents struct {
+	byName    map[string]*ports.ManagedEquipmentPort
+	byId      map[string]*ports.ManagedEquipmentPort
+	hierarchy *domain.EquipmentHierarchy
+}
+
+func newEquipmentCacheContents() *equipmentCacheContents {
+	return &equipmentCacheContents{
+		byName:    map[string]*ports.ManagedEquipmentPort{},
+		byId:      map[string]*ports.ManagedEquipmentPort{},
+		hierarchy: domain.NewEquipmentHierarchy(),
+	}
 }
 
 func NewEquipmentCacheDefault(configHook string, storeIF ports.LibreDataStorePort, finderIF ports.EquipmentFinderPort) *equipmentCacheDefault {
 	s := equipmentCacheDefault{
 		dataStore:         storeIF,
 		finderIF:          finderIF,
-		nameCache:         map[string]*ports.ManagedEquipmentPort{},
-		idCache:           map[string]*ports.ManagedEquipmentPort{},
+		contents:          newEquipmentCacheContents(),
 		configLevel:       0,
 		monitoringChannel: nil,
+		listeners:         map[int]func(notice ports.EquipmentCacheChangeNotice){},
 	}
 	s.SetConfigCategory(configHook)
 	loggerHook, cerr := s.GetConfigItemWithDefault(domain.LOGGER_CONFIG_HOOK_TOKEN, domain.DEFAULT_LOGGER_NAME)
@@ -51,120 +72,345 @@ func NewEquipmentCacheDefault(configHook string, storeIF ports.LibreDataStorePor
 	if err != nil {
 		panic(fmt.Sprintf("FAILED IN CONFIGURATION SETUP FOR EQUIPMENT CACHE - BAD CONFIG VALUE FOR 'MonitorChanges' :'%s' [%s]", monStr, err))
 	}
-	s.equipmentChangeFxn = s.defaultEqChangeNoticeHandler
 	return &s
 }
 
-func (s *equipmentCacheDefault) defaultEqChangeNoticeHandler(notice ports.EquipmentCacheChangeNotice) {
-	s.LogWarnf("EquipmentCache noticed an equipment change in the database, but no handler has been set to processes the change.")
+//notifyListeners passes the notice to each subscriber in subscription order
+func (s *equipmentCacheDefault) notifyListeners(notice ports.EquipmentCacheChangeNotice) {
+	s.listenerMutex.Lock()
+	ids := make([]int, 0, len(s.listeners))
+	for id := range s.listeners {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	listeners := make([]func(notice ports.EquipmentCacheChangeNotice), 0, len(ids))
+	for _, id := range ids {
+		listeners = append(listeners, s.listeners[id])
+	}
+	s.listenerMutex.Unlock()
+
+	if len(listeners) == 0 {
+		s.LogWarnf("EquipmentCache noticed an equipment change (%s of %s), but no listener has subscribed to process the change.", notice.ChangeType, notice.EqId)
+		return
+	}
+	//listeners are called without the lock so they may subscribe or unsubscribe
+	for _, listener := range listeners {
+		listener(notice)
+	}
+}
+
+//current returns the published contents of the cache
+func (s *equipmentCacheDefault) current() *equipmentCacheContents {
+	s.contentsMutex.RLock()
+	defer s.contentsMutex.RUnlock()
+	return s.contents
 }
 
 func (s *equipmentCacheDefault) RefreshCache() {
+	s.refreshMutex.Lock()
+	notices := s.refreshContents()
+	s.refreshMutex.Unlock()
+
+	//listeners are told once the new contents are published, so they can look up the changed equipment
+	for _, notice := range notices {
+		s.notifyListeners(notice)
+	}
+
+	//check the monitor settings and crank up monitoring if configured and not already running
+	if s.monitorChanges && s.getMonitoringChannel() == nil {
+		s.StartMonitoring()
+	}
+}
+
+//refreshContents builds and publishes new contents from the equipment finder.  The refresh mutex must be held by the caller.
+func (s *equipmentCacheDefault) refreshContents() []ports.EquipmentCacheChangeNotice {
 	s.configLevel++
 	eqList, err := s.finderIF.FindEquipment()
-	if err == nil {
-		for _, eq := range eqList {
-			//update the caches
-			existingNameItem := s.nameCache[eq.Name]
-			existingIdItem := s.idCache[eq.Id]
-			if existingNameItem == nil || existingIdItem == nil {
-				//add a new entry
-				newEntry := services.GetManagedEquipmentFactoryServiceInstance().GetNewInstance(eq)
-				newEntry.SetConfigLevel(s.configLevel)
-				s.nameCache[eq.Name] = &newEntry
-				s.idCache[eq.Id] = &newEntry
-				if s.equipmentChangeFxn != nil {
-					s.equipmentChangeFxn(ports.EquipmentCacheChangeNotice{
-						ChangeType: "ADD",
-						EqId:       eq.Id,
-					})
-				}
-			} else {
-				s.updateProperties(eq, existingNameItem)
-				(*existingNameItem).SetConfigLevel(s.configLevel)
-			}
+	if err != nil {
+		s.LogErrorf("FAILED IN CACHE REFRESH: %s", err)
+		return nil
+	}
+	prev := s.current()
+	next := newEquipmentCacheContents()
+	for name, mgdEq := range prev.byName {
+		next.byName[name] = mgdEq
+	}
+	for id, mgdEq := range prev.byId {
+		next.byId[id] = mgdEq
+	}
+	notices := make([]ports.EquipmentCacheChangeNotice, 0)
+	for _, eq := range eqList {
+		//update the caches
+		existingNameItem := next.byName[eq.Name]
+		existingIdItem := next.byId[eq.Id]
+		if existingNameItem == nil || existingIdItem == nil {
+			//add a new entry
+			newEntry := services.GetManagedEquipmentFactoryServiceInstance().GetNewInstance(eq)
+			newEntry.SetConfigLevel(s.configLevel)
+			next.byName[eq.Name] = &newEntry
+			next.byId[eq.Id] = &newEntry
+			notices = append(notices, ports.EquipmentCacheChangeNotice{
+				ChangeType: ports.EquipmentCacheAdd,
+				EqId:       eq.Id,
+			})
+		} else {
+			notices = append(notices, s.updateEquipment(eq, existingNameItem)...)
+			(*existingNameItem).SetConfigLevel(s.configLevel)
 		}
-		//scrub the non-referenced items
-		for id, mgdEq := range s.idCache {
-			if mgdEq != nil && (*mgdEq).GetConfigLevel() != s.configLevel {
-				//equipment has been removed
-				delete(s.nameCache, (*mgdEq).GetEquipmentName())
-				delete(s.idCache, id)
-				if s.equipmentChangeFxn != nil {
-					s.equipmentChangeFxn(ports.EquipmentCacheChangeNotice{
-						ChangeType: "REMOVE",
-						EqId:       id,
-					})
-				}
-			}
+	}
+	//scrub the non-referenced items
+	for id, mgdEq := range next.byId {
+		if mgdEq != nil && (*mgdEq).GetConfigLevel() != s.configLevel {
+			//equipment has been removed
+			delete(next.byId, id)
+			notices = append(notices, ports.EquipmentCacheChangeNotice{
+				ChangeType: ports.EquipmentCacheRemove,
+				EqId:       id,
+			})
 		}
-	} else {
-		s.LogErrorf("FAILED IN CACHE REFRESH: %s", err)
 	}
-
-	//check the monitor settings and crank up monitoring if configured and not already running
-	if s.monitorChanges && s.monitoringChannel == nil {
-		s.StartMonitoring()
+	for name, mgdEq := range next.byName {
+		if mgdEq != nil && (*mgdEq).GetConfigLevel() != s.configLevel {
+			delete(next.byName, name)
+		}
 	}
+	next.hierarchy = s.buildHierarchy(eqList)
+
+	s.contentsMutex.Lock()
+	s.contents = next
+	s.contentsMutex.Unlock()
+	return notices
 }
 
-func (s *equipmentCacheDefault) updateProperties(eq domain.Equipment, item *ports.ManagedEquipmentPort) {
+//updateEquipment brings a cached equipment in line with the database and returns the changes found
+func (s *equipmentCacheDefault) updateEquipment(eq domain.Equipment, item *ports.ManagedEquipmentPort) []ports.EquipmentCacheChangeNotice {
+	notices := make([]ports.EquipmentCacheChangeNotice, 0)
+	if oldClassId := (*item).GetEquipmentClassId(); oldClassId != eq.EquipmentClass.Id {
+		notices = append(notices, ports.EquipmentCacheChangeNotice{
+			ChangeType: ports.EquipmentCacheClassChanged,
+			EqId:       eq.Id,
+			OldValue:   oldClassId,
+			NewValue:   eq.EquipmentClass.Id,
+		})
+	}
+	(*item).UpdateEquipmentDefinition(eq)
+
 	//get the properties for the equipment
-	txn := s.dataStore.BeginTransaction(false, "eqpropsforcacheupdate")
-	defer txn.Dispose()
-	eqPropMap, err := queries.GetAllPropertiesForEquipment(txn, eq.Id)
+	eqPropMap, err := getResolvedProperties(s.dataStore, "eqpropsforcacheupdate", eq.Id)
 	if err != nil {
+		//without the properties every property would look deleted
 		s.LogErrorf("FAILED IN FETCH OF EQUIPMENT PROPERTIES IN CACHE REFRESH! (%s)", eq.Name)
+		return notices
 	}
-	//add any EQ properties that are missing from the current managed equipment instance
-	// note - not trying to remove properties that might have been deleted from the EQ
 	currProps := (*item).GetPropertyMap()
-	for eqPropName, eqProp := range eqPropMap {
-		_, exists := currProps[eqPropName]
-		if !exists {
-			currProps[eqPropName] = domain.EquipmentPropertyDescriptor{
-				Name:             eqPropName,
-				DataType:         eqProp.DataType,
-				Value:            nil,
-				ClassPropertyId:  eqProp.Id,
-				EquipmentClassId: eqProp.EquipmentClass.Id,
-				LastUpdate:       time.Time{},
-			}
-		}
+	diff := domain.DiffPropertyDefinitions(currProps, eqPropMap)
+	for _, name := range diff.Added {
+		(*item).SetPropertyDefinition(s.propertyDescriptorWithDefault(name, eqPropMap[name]))
+		notices = append(notices, ports.EquipmentCacheChangeNotice{
+			ChangeType:   ports.EquipmentCachePropertyAdded,
+			EqId:         eq.Id,
+			PropertyName: name,
+		})
+	}
+	for _, name := range diff.Removed {
+		(*item).RemoveProperty(name)
+		notices = append(notices, ports.EquipmentCacheChangeNotice{
+			ChangeType:   ports.EquipmentCachePropertyRemoved,
+			EqId:         eq.Id,
+			PropertyName: name,
+		})
+	}
+	for _, name := range diff.DataTypeChanged {
+		(*item).SetPropertyDefinition(s.propertyDescriptorWithDefault(name, eqPropMap[name]))
+		notices = append(notices, ports.EquipmentCacheChangeNotice{
+			ChangeType:   ports.EquipmentCachePropertyDataTypeChanged,
+			EqId:         eq.Id,
+			PropertyName: name,
+			OldValue:     currProps[name].DataType,
+			NewValue:     eqPropMap[name].DataType,
+		})
+	}
+	for _, name := range diff.Redefined {
+		(*item).SetPropertyDefinition(newEquipmentPropertyDescriptor(name, eqPropMap[name], nil))
+		notices = append(notices, ports.EquipmentCacheChangeNotice{
+			ChangeType:   ports.EquipmentCachePropertyRedefined,
+			EqId:         eq.Id,
+			PropertyName: name,
+			OldValue:     currProps[name].Address,
+			NewValue:     eqPropMap[name].Address,
+		})
+	}
+	return notices
+}
+
+//propertyDescriptorWithDefault builds the descriptor of a new or retyped property, starting from its default value
+func (s *equipmentCacheDefault) propertyDescriptorWithDefault(name string, prop domain.ResolvedProperty) domain.EquipmentPropertyDescriptor {
+	val, err := domain.ConvertPropertyValueStringToTypedValue(prop.DataType, prop.Value)
+	if err != nil {
+		s.LogErrorf("Failed data format conversion for property %s with value string %s.  Error=%s", name, prop.Value, err)
+	}
+	return newEquipmentPropertyDescriptor(name, prop, val)
+}
+
+func (s *equipmentCacheDefault) GetEquipmentCacheSnapshot() []*ports.ManagedEquipmentPort {
+	contents := s.current()
+	names := make([]string, 0, len(contents.byName))
+	for name := range contents.byName {
+		names = append(names, name)
 	}
+	sort.Strings(names)
+	ret := make([]*ports.ManagedEquipmentPort, 0, len(names))
+	for _, name := range names {
+		ret = append(ret, contents.byName[name])
+	}
+	return ret
 }
 
-func (s *equipmentCacheDefault) GetEquipmentCacheList() *map[string]*ports.ManagedEquipmentPort {
-	return &s.nameCache
+func (s *equipmentCacheDefault) ForEachEquipment(visit func(mgdEq *ports.ManagedEquipmentPort) bool) {
+	for _, mgdEq := range s.GetEquipmentCacheSnapshot() {
+		if !visit(mgdEq) {
+			return
+		}
+	}
 }
 
 func (s *equipmentCacheDefault) GetCachedEquipmentItem(equipName string) *ports.ManagedEquipmentPort {
-	return s.nameCache[equipName]
+	return s.current().byName[equipName]
 }
 
 func (s *equipmentCacheDefault) GetCachedEquipmentItemById(equipId string) *ports.ManagedEquipmentPort {
-	return s.idCache[equipId]
+	return s.current().byId[equipId]
+}
+
+func (s *equipmentCacheDefault) SubscribeToChanges(listener func(notice ports.EquipmentCacheChangeNotice)) int {
+	s.listenerMutex.Lock()
+	defer s.listenerMutex.Unlock()
+	s.nextSubscriptionId++
+	s.listeners[s.nextSubscriptionId] = listener
+	return s.nextSubscriptionId
+}
+
+func (s *equipmentCacheDefault) UnsubscribeToChanges(subscriptionId int) {
+	s.listenerMutex.Lock()
+	defer s.listenerMutex.Unlock()
+	delete(s.listeners, subscriptionId)
+}
+
+//buildHierarchy builds the tree of the cached equipment, fetching the ancestors that are not cached themselves
+func (s *equipmentCacheDefault) buildHierarchy(eqList []domain.Equipment) *domain.EquipmentHierarchy {
+	hierarchy := domain.NewEquipmentHierarchy()
+	for _, eq := range eqList {
+		hierarchy.Add(hierarchyNodeForEquipment(eq))
+	}
+	fetched := map[string]bool{}
+	for missing := hierarchy.MissingParents(); len(missing) > 0; missing = hierarchy.MissingParents() {
+		progress := false
+		for _, parentId := range missing {
+			if fetched[parentId] {
+				continue
+			}
+			fetched[parentId] = true
+			parent, err := getEquipmentById(s.dataStore, "eqhierarchy", parentId)
+			if err != nil || parent.Id == "" {
+				s.LogWarnf("Failed to fetch parent equipment %s for the equipment hierarchy: %v", parentId, err)
+				continue
+			}
+			hierarchy.Add(hierarchyNodeForEquipment(parent))
+			progress = true
+		}
+		if !progress {
+			break
+		}
+	}
+	return hierarchy
+}
+
+func hierarchyNodeForEquipment(eq domain.Equipment) domain.EquipmentHierarchyNode {
+	return domain.EquipmentHierarchyNode{
+		Id:             eq.Id,
+		Name:           eq.Name,
+		EquipmentLevel: eq.EquipmentLevel,
+		ParentId:       eq.Parent.Id,
+	}
+}
+
+func (s *equipmentCacheDefault) GetParent(equipId string) (domain.EquipmentHierarchyNode, bool) {
+	return s.current().hierarchy.Parent(equipId)
+}
+
+func (s *equipmentCacheDefault) GetChildren(equipId string) []domain.EquipmentHierarchyNode {
+	return s.current().hierarchy.Children(equipId)
+}
+
+func (s *equipmentCacheDefault) GetAncestors(equipId string) []domain.EquipmentHierarchyNode {
+	return s.current().hierarchy.Ancestors(equipId)
+}
+
+func (s *equipmentCacheDefault) GetDescendants(equipId string) []domain.EquipmentHierarchyNode {
+	return s.current().hierarchy.Descendants(equipId)
+}
+
+func (s *equipmentCacheDefault) GetEquipmentPath(equipId string) string {
+	return s.current().hierarchy.Path(equipId)
+}
+
+func (s *equipmentCacheDefault) GetEquipmentNodeByPath(path string) (domain.EquipmentHierarchyNode, bool) {
+	return s.current().hierarchy.FindByPath(path)
+}
+
+func (s *equipmentCacheDefault) GetCachedEquipmentItemByPath(path string) *ports.ManagedEquipmentPort {
+	contents := s.current()
+	if node, found := contents.hierarchy.FindByPath(path); found {
+		return contents.byId[node.Id]
+	}
+	return nil
+}
+
+//RollupProperty only includes cached descendants that have a numeric value for the property
+func (s *equipmentCacheDefault) RollupProperty(equipId string, propName string, aggregate string) (float64, error) {
+	contents := s.current()
+	values := make([]float64, 0)
+	for _, node := range contents.hierarchy.Descendants(equipId) {
+		mgdEq := contents.byId[node.Id]
+		if mgdEq == nil {
+			continue
+		}
+		if num, ok := domain.ValueAsFloat64((*mgdEq).GetPropertyValue(propName)); ok {
+			values = append(values, num)
+		}
+	}
+	return domain.AggregateValues(values, aggregate)
+}
+
+//getMonitoringChannel is locked on its own - the monitoring goroutine calls RefreshCache, which checks it
+func (s *equipmentCacheDefault) getMonitoringChannel() chan string {
+	s.monitorMutex.Lock()
+	defer s.monitorMutex.Unlock()
+	return s.monitoringChannel
 }
 
-func (s *equipmentCacheDefault) SetEquipmentChangeNoticeFunction(handlingFxn func(notice ports.EquipmentCacheChangeNotice)) {
-	s.equipmentChangeFxn = handlingFxn
+func (s *equipmentCacheDefault) setMonitoringChannel(c chan string) {
+	s.monitorMutex.Lock()
+	defer s.monitorMutex.Unlock()
+	s.monitoringChannel = c
 }
 
 func (s *equipmentCacheDefault) StartMonitoring() {
-	if s.monitoringChannel == nil {
-		s.monitoringChannel = make(chan string)
-		s.monitorEquipmentChanges()
+	if s.getMonitoringChannel() == nil {
+		monitoringChannel := make(chan string)
+		s.setMonitoringChannel(monitoringChannel)
+		s.monitorEquipmentChanges(monitoringChannel)
 		select {
-		case resp := <-s.monitoringChannel:
+		case resp := <-monitoringChannel:
 			if resp == "RUNNING" {
 				s.LogInfo("Equipment Cache monitoring started successfully")
 			} else {
 				s.LogError("Equipment Cache monitoring start FAILED")
-				s.monitoringChannel = nil
+				s.setMonitoringChannel(nil)
 			}
 		case <-time.After(5 * time.Second):
 			s.LogError("Equipment Cache monitoring start TIMED OUT")
-			s.monitoringChannel = nil
+			s.setMonitoringChannel(nil)
 		}
 	} else {
 		s.LogErrorf("Monitoring already active when StartMonitoring was called!")
@@ -173,22 +419,21 @@ func (s *equipmentCacheDefault) StartMonitoring() {
 
 func (s *equipmentCacheDefault) StopMonitoring() {
 	s.LogDebug("Equipment Cache StopMonitoring begins")
-	if s.monitoringChannel != nil {
+	if monitoringChannel := s.getMonitoringChannel(); monitoringChannel != nil {
 		s.LogDebug("sending END to monitoring channel")
-		s.monitoringChannel <- "END"
+		monitoringChannel <- "END"
 		select {
-		case resp := <-s.monitoringChannel:
+		case resp := <-monitoringChannel:
 			s.LogDebugf("got response on monitoring channel: %s", resp)
 			if resp == "DONE" {
 				s.LogInfo("Equipment Cache monitoring stopped successfully")
 			} else {
 				s.LogError("Equipment Cache monitoring stop FAILED")
-				s.monitoringChannel = nil
 			}
 		case <-time.After(5 * time.Second):
 			s.LogError("Equipment Cache monitoring stop TIMED OUT")
-			s.monitoringChannel = nil
 		}
+		s.setMonitoringChannel(nil)
 	} else {
 		s.LogError("Monitoring already inactive when StopMonitoring was called!")
 	}
@@ -196,7 +441,7 @@ func (s *equipmentCacheDefault) StopMonitoring() {
 }
 
 ////////////////////////////////////////////////////////////////////////
-func (s *equipmentCacheDefault) monitorEquipmentChanges() {
+func (s *equipmentCacheDefault) monitorEquipmentChanges(monitoringChannel chan string) {
 	finderChannel := make(chan ports.EquipmentFinderChangeNotice)
 	go func(c chan ports.EquipmentFinderChangeNotice, m chan string) {
 		m <- "RUNNING"
@@ -232,6 +477,6 @@ func (s *equipmentCacheDefault) monitorEquipmentChanges() {
 			}
 		}
 		m <- "DONE"
-	}(finderChannel, s.monitoringChannel)
+	}(finderChannel, monitoringChannel)
 	s.finderIF.SubscribeToChanges(finderChannel)
 }
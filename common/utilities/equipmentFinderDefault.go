@@ -2,6 +2,7 @@ package utilities
 
 import (
 	"encoding/json"
+	"fmt"
 	"strings"
 	"time"
 
@@ -24,8 +25,12 @@ type equipmentFinderDefault struct {
 	daSubChan        chan []byte
 	monitorAdminChan chan string
 	monitoring       bool
+	selector         *domain.EquipmentSelector
 }
 
+//NewEquipmentFinderDefault creates the default finder.  Equipment is selected by SELECTION_RULES when it is
+//  configured - a JSON equipment selection rule, see domain.EquipmentSelectionRule - otherwise by
+//  ACTIVE_EQ_LEVELS, INCLUDE_EQUIPMENT and EXCLUDE_EQUIPMENT
 func NewEquipmentFinderDefault(configHook string, storeIF ports.LibreDataStorePort) *equipmentFinderDefault {
 	s := equipmentFinderDefault{
 		dataStore:  storeIF,
@@ -37,6 +42,13 @@ func NewEquipmentFinderDefault(configHook string, storeIF ports.LibreDataStorePo
 		loggerHook = domain.DEFAULT_LOGGER_NAME
 	}
 	s.SetLoggerConfigHook(loggerHook)
+	rules, _ := s.GetConfigItemWithDefault("SELECTION_RULES", "")
+	if rules != "" {
+		var err error
+		if s.selector, err = domain.ParseEquipmentSelectionRule(rules); err != nil {
+			panic(fmt.Sprintf("FAILED IN CONFIGURATION SETUP FOR EQUIPMENT FINDER - BAD CONFIG VALUE FOR 'SELECTION_RULES' [%s]", err))
+		}
+	}
 	return &s
 }
 
@@ -44,6 +56,9 @@ func (s *equipmentFinderDefault) FindEquipment() ([]domain.Equipment, error) {
 	txn := s.dataStore.BeginTransaction(false, "findeq")
 	defer txn.Dispose()
 
+	if s.selector != nil {
+		return s.findBySelectionRules(txn)
+	}
 	eqElLevels, includeIds, excludeIds, _ := s.getQueryInput(txn)
 
 	eqs, err := queries.GetActiveEquipmentByLevelListWithIncExc(txn, eqElLevels, includeIds, excludeIds)
@@ -72,13 +87,9 @@ func (s *equipmentFinderDefault) UnsubscribeToChanges(notificationChannel chan p
 func (s *equipmentFinderDefault) SubscribeToChanges(notificationChannel chan ports.EquipmentFinderChangeNotice) {
 	txn := s.dataStore.BeginTransaction(false, "findeq4s")
 	defer txn.Dispose()
-	eqElLevels, includeIds, excludeIds, err := s.getQueryInput(txn)
+	subQuery, vars, err := s.getSubscriptionQuery(txn)
 	if err == nil {
-		vars := map[string]interface{}{"levels": eqElLevels, "includeIds": includeIds, "excludeIds": excludeIds}
-		var q struct {
-			QueryEquipment []domain.Equipment `graphql:"queryEquipment (filter:{isActive: true, and: {equipmentLevel: {in: $levels}, or: {id:$includeIds}, and: {not:{id:$excludeIds}}}}) "`
-		}
-		s.subPort = s.dataStore.GetSubscription(&q, vars)
+		s.subPort = s.dataStore.GetSubscription(subQuery, vars)
 		s.daSubChan = make(chan []byte)
 		s.monitorAdminChan = make(chan string)
 		s.monitoring = true
@@ -146,6 +157,89 @@ func (s *equipmentFinderDefault) SubscribeToChanges(notificationChannel chan por
 	}
 }
 
+//getSubscriptionQuery returns the query whose changes should trigger a new FindEquipment
+func (s *equipmentFinderDefault) getSubscriptionQuery(txn ports.LibreDataStoreTransactionPort) (interface{}, map[string]interface{}, error) {
+	if s.selector != nil {
+		//the rules can depend on any equipment, so watch all of it
+		var q struct {
+			QueryEquipment []domain.Equipment `graphql:"queryEquipment"`
+		}
+		return &q, nil, nil
+	}
+	eqElLevels, includeIds, excludeIds, err := s.getQueryInput(txn)
+	vars := map[string]interface{}{"levels": eqElLevels, "includeIds": includeIds, "excludeIds": excludeIds}
+	var q struct {
+		QueryEquipment []domain.Equipment `graphql:"queryEquipment (filter:{isActive: true, and: {equipmentLevel: {in: $levels}, or: {id:$includeIds}, and: {not:{id:$excludeIds}}}}) "`
+	}
+	return &q, vars, err
+}
+
+//findBySelectionRules offers all active equipment to the selection rules, and warns about rule references that
+//  match no equipment at all
+func (s *equipmentFinderDefault) findBySelectionRules(txn ports.LibreDataStoreTransactionPort) ([]domain.Equipment, error) {
+	allEq, err := queries.GetAllEquipmentForSelection(txn)
+	if err != nil {
+		return nil, err
+	}
+	classes, err := queries.GetAllEquipmentClassParents(txn)
+	if err != nil {
+		return nil, err
+	}
+	candidates := s.selectionCandidates(txn, allEq, classes)
+	for _, ref := range s.selector.Unresolved(candidates) {
+		s.LogWarnf("Equipment selection rules refer to %s, which matches no active equipment", ref)
+	}
+	ret := make([]domain.Equipment, 0)
+	for _, candidate := range candidates {
+		if s.selector.Matches(candidate) {
+			ret = append(ret, candidate.Equipment)
+		}
+	}
+	s.LogInfof("Equipment selection rules selected %d of %d active equipment", len(ret), len(candidates))
+	return ret, nil
+}
+
+func (s *equipmentFinderDefault) selectionCandidates(txn ports.LibreDataStoreTransactionPort, allEq []domain.SelectableEquipment, classes []domain.EquipmentClassAndParent) []domain.EquipmentSelectionCandidate {
+	//paths are built from all equipment, so an inactive area still names its active lines
+	hierarchy := domain.NewEquipmentHierarchy()
+	for _, eq := range allEq {
+		hierarchy.Add(hierarchyNodeForEquipment(eq.Equipment))
+	}
+	classById := map[string]domain.EquipmentClassAndParent{}
+	for _, cls := range classes {
+		classById[cls.Id] = cls
+	}
+	needsProperties := s.selector.NeedsProperties()
+	ret := make([]domain.EquipmentSelectionCandidate, 0, len(allEq))
+	for _, eq := range allEq {
+		if !eq.IsActive {
+			continue
+		}
+		candidate := domain.EquipmentSelectionCandidate{
+			Equipment:    eq.Equipment,
+			Path:         hierarchy.Path(eq.Id),
+			DataProvider: eq.DataProvider,
+		}
+		visited := map[string]bool{}
+		for clsId := eq.EquipmentClass.Id; clsId != "" && !visited[clsId]; clsId = classById[clsId].Parent.Id {
+			visited[clsId] = true
+			candidate.Classes = append(candidate.Classes, domain.IdNameTypenameRef{Id: clsId, Name: classById[clsId].Name})
+		}
+		if needsProperties {
+			props, err := queries.GetResolvedPropertiesForEquipment(txn, eq.Id)
+			if err != nil {
+				s.LogErrorf("Failed to fetch the properties of equipment %s for the selection rules: %s", eq.Name, err)
+			}
+			candidate.Properties = make(map[string]string, len(props))
+			for name, prop := range props {
+				candidate.Properties[name] = prop.Value
+			}
+		}
+		ret = append(ret, candidate)
+	}
+	return ret
+}
+
 func (s *equipmentFinderDefault) getQueryInput(txn ports.LibreDataStoreTransactionPort) ([]domain.EquipmentElementLevel, []string, []string, error) {
 	var err error
 	//get the equipment type list from config
@@ -169,8 +263,10 @@ func (s *equipmentFinderDefault) getQueryInput(txn ports.LibreDataStoreTransacti
 			//query for the matching equipment
 			var eq domain.Equipment
 			eq, err = queries.GetEquipmentByName(txn, eqName)
-			if err == nil {
+			if err == nil && eq.Id != "" {
 				includeIds = append(includeIds, eq.Id)
+			} else if err == nil {
+				s.LogWarnf("INCLUDE_EQUIPMENT names equipment '%s', which does not exist", eqName)
 			}
 		}
 	}
@@ -184,8 +280,10 @@ func (s *equipmentFinderDefault) getQueryInput(txn ports.LibreDataStoreTransacti
 			//query for the matching equipment
 			var eq domain.Equipment
 			eq, err = queries.GetEquipmentByName(txn, eqName)
-			if err == nil {
+			if err == nil && eq.Id != "" {
 				excludeIds = append(excludeIds, eq.Id)
+			} else if err == nil {
+				s.LogWarnf("EXCLUDE_EQUIPMENT names equipment '%s', which does not exist", eqName)
 			}
 		}
 	}
@@ -0,0 +1,52 @@
+package utilities
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Spruik/libre-common/common/core/domain"
+)
+
+func TestFindEquipmentBySelectionRules(t *testing.T) {
+	store := newFakeDataStore()
+	store.responses["queryEquipment"] = jsonData("queryEquipment", []map[string]interface{}{
+		{"id": "1", "name": "Line1", "equipmentLevel": "Line", "isActive": true},
+		{"id": "2", "name": "Filler1", "equipmentLevel": "WorkCell", "isActive": true, "dataProvider": "MQTT",
+			"parent": map[string]string{"id": "1"}, "equipmentClass": map[string]string{"id": "c-rotary", "name": "RotaryFiller"}},
+		{"id": "3", "name": "Filler2", "equipmentLevel": "WorkCell", "isActive": true,
+			"parent": map[string]string{"id": "1"}, "equipmentClass": map[string]string{"id": "c-filler", "name": "Filler"}},
+		{"id": "4", "name": "Filler3", "equipmentLevel": "WorkCell", "isActive": false, "dataProvider": "MQTT",
+			"parent": map[string]string{"id": "1"}, "equipmentClass": map[string]string{"id": "c-filler", "name": "Filler"}},
+	})
+	store.responses["queryEquipmentClass"] = jsonData("queryEquipmentClass", []map[string]interface{}{
+		{"id": "c-filler", "name": "Filler"},
+		{"id": "c-rotary", "name": "RotaryFiller", "parent": map[string]string{"id": "c-filler", "name": "Filler"}},
+	})
+	finder := NewEquipmentFinderDefault("equipmentFinderTest", store)
+	var err error
+	if finder.selector, err = domain.ParseEquipmentSelectionRule(`{"class": "Filler", "subtree": "Line1", "dataProvider": "MQTT"}`); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := finder.FindEquipment()
+	if err != nil {
+		t.Fatalf("FindEquipment failed: %s", err)
+	}
+	names := make([]string, 0, len(found))
+	for _, eq := range found {
+		names = append(names, eq.Name)
+	}
+	if expected := []string{"Filler1"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("FindEquipment() found %v; want %v", names, expected)
+	}
+
+	queries, _ := store.recorded()
+	if len(queries) != 2 {
+		t.Fatalf("Expect the equipment and class queries; got %v", queries)
+	}
+	for _, query := range queries {
+		if err = checkQueryShape(t, query); err != nil {
+			t.Errorf("Expect the selection query to match the schema: %s", err)
+		}
+	}
+}
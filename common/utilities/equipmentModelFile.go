@@ -0,0 +1,250 @@
+package utilities
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Spruik/libre-common/common/core/domain"
+	"github.com/Spruik/libre-common/common/core/ports"
+	libreConfig "github.com/Spruik/libre-configuration"
+	libreLogger "github.com/Spruik/libre-logging"
+	"gopkg.in/yaml.v2"
+)
+
+//equipmentModelFile reads the equipment model from a local JSON or YAML file in the shape of domain.EquipmentModel.
+//  It is both the EquipmentFinderPort and the EquipmentModelSourcePort, so the edge stack can run without a
+//  GraphQL server - set it as the equipment model source service as well as the finder.
+type equipmentModelFile struct {
+	//inherit logging functions
+	libreLogger.LoggingEnabler
+
+	//inherit config functions
+	libreConfig.ConfigurationEnabler
+
+	fileName     string
+	pollInterval time.Duration
+
+	mutex       sync.RWMutex
+	model       *domain.EquipmentModel
+	modTime     time.Time
+	size        int64
+	subscribers []chan ports.EquipmentFinderChangeNotice
+	pollStop    chan struct{}
+
+	//the version of the file the subscribers were last told about; a reload by FindEquipment doesn't move it
+	notifiedModTime time.Time
+	notifiedSize    int64
+}
+
+//defaultModelPollInterval is used when POLL_INTERVAL is not configured
+const defaultModelPollInterval = 2 * time.Second
+
+//NewEquipmentModelFile creates the file model source.  The configuration category may contain:
+//  MODEL_FILE - the path of the model; ".yaml" and ".yml" files are read as YAML, anything else as JSON
+//  POLL_INTERVAL - how often the file is checked for changes while there are subscribers
+func NewEquipmentModelFile(configHook string) *equipmentModelFile {
+	s := equipmentModelFile{}
+	s.SetConfigCategory(configHook)
+	loggerHook, cerr := s.GetConfigItemWithDefault(domain.LOGGER_CONFIG_HOOK_TOKEN, domain.DEFAULT_LOGGER_NAME)
+	if cerr != nil {
+		loggerHook = domain.DEFAULT_LOGGER_NAME
+	}
+	s.SetLoggerConfigHook(loggerHook)
+	s.fileName, _ = s.GetConfigItemWithDefault("MODEL_FILE", "")
+	if s.fileName == "" {
+		panic("FAILED IN CONFIGURATION SETUP FOR EQUIPMENT MODEL FILE - 'MODEL_FILE' IS NOT CONFIGURED")
+	}
+	var err error
+	s.pollInterval, err = getConfigDurationWithDefault(&s.ConfigurationEnabler, "POLL_INTERVAL", defaultModelPollInterval)
+	if err != nil {
+		panic(fmt.Sprintf("FAILED IN CONFIGURATION SETUP FOR EQUIPMENT MODEL FILE - %s", err))
+	}
+	if _, err = s.reload(); err != nil {
+		panic(fmt.Sprintf("FAILED IN CONFIGURATION SETUP FOR EQUIPMENT MODEL FILE - %s", err))
+	}
+	s.notifiedModTime, s.notifiedSize = s.modTime, s.size
+	return &s
+}
+
+//reload reads the file if it changed since the last read; a file that fails to load leaves the previous model in place
+func (s *equipmentModelFile) reload() (bool, error) {
+	info, err := os.Stat(s.fileName)
+	if err != nil {
+		return false, err
+	}
+	s.mutex.RLock()
+	unchanged := s.model != nil && info.ModTime().Equal(s.modTime) && info.Size() == s.size
+	s.mutex.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+	raw, err := ioutil.ReadFile(s.fileName)
+	if err != nil {
+		return false, err
+	}
+	model, err := parseEquipmentModel(raw, isYamlFile(s.fileName))
+	if err != nil {
+		return false, fmt.Errorf("bad equipment model in '%s': %s", s.fileName, err)
+	}
+	s.mutex.Lock()
+	s.model = model
+	s.modTime = info.ModTime()
+	s.size = info.Size()
+	s.mutex.Unlock()
+	s.LogInfof("Loaded equipment model from %s with %d equipment", s.fileName, len(model.Equipment))
+	return true, nil
+}
+
+func isYamlFile(fileName string) bool {
+	ext := strings.ToLower(filepath.Ext(fileName))
+	return ext == ".yaml" || ext == ".yml"
+}
+
+//parseEquipmentModel reads YAML through its JSON form, so both file types use the json tags of the domain structs
+func parseEquipmentModel(raw []byte, isYaml bool) (*domain.EquipmentModel, error) {
+	if isYaml {
+		var doc interface{}
+		if err := yaml.Unmarshal(raw, &doc); err != nil {
+			return nil, err
+		}
+		var err error
+		if raw, err = json.Marshal(yamlToJSONValue(doc)); err != nil {
+			return nil, err
+		}
+	}
+	var model domain.EquipmentModel
+	if err := json.Unmarshal(raw, &model); err != nil {
+		return nil, err
+	}
+	if err := model.Validate(); err != nil {
+		return nil, err
+	}
+	return &model, nil
+}
+
+//yamlToJSONValue converts the map[interface{}]interface{} maps of the YAML decoder to maps json can marshal
+func yamlToJSONValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[interface{}]interface{}:
+		ret := make(map[string]interface{}, len(val))
+		for key, item := range val {
+			ret[fmt.Sprintf("%v", key)] = yamlToJSONValue(item)
+		}
+		return ret
+	case []interface{}:
+		ret := make([]interface{}, len(val))
+		for ndx, item := range val {
+			ret[ndx] = yamlToJSONValue(item)
+		}
+		return ret
+	}
+	return v
+}
+
+func (s *equipmentModelFile) currentModel() *domain.EquipmentModel {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.model
+}
+
+func (s *equipmentModelFile) FindEquipment() ([]domain.Equipment, error) {
+	if _, err := s.reload(); err != nil {
+		s.LogErrorf("Failed to reload the equipment model, using the last good model: %s", err)
+	}
+	return s.currentModel().ActiveEquipment(), nil
+}
+
+func (s *equipmentModelFile) GetResolvedProperties(eqId string) (map[string]domain.ResolvedProperty, error) {
+	return s.currentModel().ResolvedProperties(eqId)
+}
+
+func (s *equipmentModelFile) GetEquipmentById(eqId string) (domain.Equipment, error) {
+	if eq, found := s.currentModel().EquipmentById(eqId); found {
+		return eq, nil
+	}
+	return domain.Equipment{}, fmt.Errorf("no equipment with id '%s' in the equipment model", eqId)
+}
+
+//SubscribeToChanges starts polling the file when the first subscriber arrives
+func (s *equipmentModelFile) SubscribeToChanges(notificationChannel chan ports.EquipmentFinderChangeNotice) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.subscribers = append(s.subscribers, notificationChannel)
+	if s.pollStop == nil {
+		s.pollStop = make(chan struct{})
+		go s.pollForChanges(s.pollStop)
+	}
+}
+
+//UnsubscribeToChanges stops polling the file when the last subscriber leaves
+func (s *equipmentModelFile) UnsubscribeToChanges(notificationChannel chan ports.EquipmentFinderChangeNotice) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for ndx, c := range s.subscribers {
+		if c == notificationChannel {
+			s.subscribers = append(s.subscribers[:ndx], s.subscribers[ndx+1:]...)
+			break
+		}
+	}
+	if len(s.subscribers) == 0 && s.pollStop != nil {
+		close(s.pollStop)
+		s.pollStop = nil
+	}
+}
+
+func (s *equipmentModelFile) pollForChanges(stop chan struct{}) {
+	ticker := time.NewTicker(s.pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if _, err := s.reload(); err != nil {
+				s.LogErrorf("Failed to reload the equipment model, keeping the last good model: %s", err)
+				continue
+			}
+			if s.takeUnnotifiedChange() {
+				s.notifySubscribers(stop)
+			}
+		}
+	}
+}
+
+//takeUnnotifiedChange reports whether the loaded model is newer than the one the subscribers were last told about,
+//  and marks it as told; the model may have been loaded by FindEquipment since the last poll
+func (s *equipmentModelFile) takeUnnotifiedChange() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.modTime.Equal(s.notifiedModTime) && s.size == s.notifiedSize {
+		return false
+	}
+	s.notifiedModTime, s.notifiedSize = s.modTime, s.size
+	return true
+}
+
+//notifySubscribers gives each subscriber a poll interval to take the notice, so one stalled subscriber can't stop the others
+func (s *equipmentModelFile) notifySubscribers(stop chan struct{}) {
+	s.mutex.RLock()
+	subscribers := append([]chan ports.EquipmentFinderChangeNotice{}, s.subscribers...)
+	notice := ports.EquipmentFinderChangeNotice{
+		ChangeType: "EquipmentChange",
+		Equipment:  s.model.ActiveEquipment(),
+	}
+	s.mutex.RUnlock()
+	for _, c := range subscribers {
+		select {
+		case c <- notice:
+		case <-stop:
+			return
+		case <-time.After(s.pollInterval):
+			s.LogWarnf("Equipment model change notice was not taken by a subscriber")
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package utilities
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Spruik/libre-common/common/core/ports"
+)
+
+const testModelJSON = `{
+	"equipmentClasses": [{"id": "filler", "properties": [{"id": "f-count", "name": "count", "dataType": "INT32"}]}],
+	"equipment": [
+		{"id": "line1", "name": "Line1", "equipmentLevel": "Line"},
+		{"id": "filler1", "name": "Filler1", "equipmentLevel": "WorkCell", "equipmentClass": {"id": "filler"}, "parent": {"id": "line1"},
+			"propertyOverrides": [{"id": "o1", "property": {"id": "f-count"}, "address": "Line1.Filler1.Count"}]}
+	]
+}`
+
+const testModelYAML = `
+equipmentClasses:
+  - id: filler
+    properties:
+      - {id: f-count, name: count, dataType: INT32}
+equipment:
+  - {id: line1, name: Line1, equipmentLevel: Line}
+  - id: filler1
+    name: Filler1
+    equipmentLevel: WorkCell
+    equipmentClass: {id: filler}
+    parent: {id: line1}
+    propertyOverrides:
+      - {id: o1, property: {id: f-count}, address: Line1.Filler1.Count}
+`
+
+//writeTestModel writes the model and moves its modification time on, so a rewrite within the file system's
+//  time resolution is still seen as a change
+func writeTestModel(t *testing.T, fileName string, content string, modTime time.Time) {
+	if err := ioutil.WriteFile(fileName, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(fileName, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+//newTestEquipmentModelFile loads the model as NewEquipmentModelFile does, without needing a configuration
+func newTestEquipmentModelFile(t *testing.T, name string, content string) *equipmentModelFile {
+	fileName := filepath.Join(t.TempDir(), name)
+	writeTestModel(t, fileName, content, time.Now().Add(-time.Hour))
+	s := &equipmentModelFile{fileName: fileName, pollInterval: 10 * time.Millisecond}
+	s.SetLoggerConfigHook("equipmentModelFileTest")
+	if _, err := s.reload(); err != nil {
+		t.Fatalf("Failed to load %s: %s", name, err)
+	}
+	s.notifiedModTime, s.notifiedSize = s.modTime, s.size
+	return s
+}
+
+func TestEquipmentModelFileLoadsJSONAndYAML(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		content string
+	}{
+		{"model.json", testModelJSON},
+		{"model.yaml", testModelYAML},
+		{"model.yml", testModelYAML},
+	} {
+		s := newTestEquipmentModelFile(t, test.name, test.content)
+		equipment, err := s.FindEquipment()
+		if err != nil || len(equipment) != 2 {
+			t.Errorf("%s: expect 2 equipment; got %+v, %v", test.name, equipment, err)
+			continue
+		}
+		props, err := s.GetResolvedProperties("filler1")
+		if err != nil || props["count"].Address != "Line1.Filler1.Count" {
+			t.Errorf("%s: expect the override address for count; got %+v, %v", test.name, props, err)
+		}
+		if eq, err := s.GetEquipmentById("line1"); err != nil || eq.Name != "Line1" {
+			t.Errorf("%s: expect Line1 by id; got %+v, %v", test.name, eq, err)
+		}
+	}
+}
+
+func TestEquipmentModelFileKeepsTheLastGoodModel(t *testing.T) {
+	s := newTestEquipmentModelFile(t, "model.json", testModelJSON)
+	writeTestModel(t, s.fileName, `{"equipment": [`, time.Now())
+	if _, err := s.reload(); err == nil {
+		t.Errorf("Expect a bad model to fail to load")
+	}
+	if equipment, _ := s.FindEquipment(); len(equipment) != 2 {
+		t.Errorf("Expect the last good model to be kept; got %+v", equipment)
+	}
+}
+
+func TestEquipmentModelFileNotifiesSubscribersOfAnEdit(t *testing.T) {
+	s := newTestEquipmentModelFile(t, "model.json", testModelJSON)
+	notices := make(chan ports.EquipmentFinderChangeNotice, 1)
+	s.SubscribeToChanges(notices)
+	defer s.UnsubscribeToChanges(notices)
+
+	writeTestModel(t, s.fileName, `{"equipment": [{"id": "line1", "name": "Line1", "equipmentLevel": "Line"}]}`, time.Now())
+	//a caller reading the model before the next poll must not swallow the change
+	if equipment, _ := s.FindEquipment(); len(equipment) != 1 {
+		t.Fatalf("Expect the edited model to be read; got %+v", equipment)
+	}
+	select {
+	case notice := <-notices:
+		if len(notice.Equipment) != 1 || notice.Equipment[0].Name != "Line1" {
+			t.Errorf("Expect the notice to carry the edited model; got %+v", notice.Equipment)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Expect an edit of the file to notify the subscribers")
+	}
+	select {
+	case notice := <-notices:
+		t.Errorf("Expect one notice per edit; got another %+v", notice)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,30 @@
+package utilities
+
+import (
+	"github.com/Spruik/libre-common/common/core/domain"
+	"github.com/Spruik/libre-common/common/core/ports"
+	"github.com/Spruik/libre-common/common/core/queries"
+	"github.com/Spruik/libre-common/common/core/services"
+)
+
+//getResolvedProperties reads the properties of the equipment from the equipment model source service when one is
+//  set, otherwise from the data store
+func getResolvedProperties(dataStore ports.LibreDataStorePort, txnName string, eqId string) (map[string]domain.ResolvedProperty, error) {
+	if source := services.GetEquipmentModelSourceServiceInstance(); source != nil {
+		return source.GetResolvedProperties(eqId)
+	}
+	txn := dataStore.BeginTransaction(false, txnName)
+	defer txn.Dispose()
+	return queries.GetResolvedPropertiesForEquipment(txn, eqId)
+}
+
+//getEquipmentById reads the equipment from the equipment model source service when one is set, otherwise from the
+//  data store
+func getEquipmentById(dataStore ports.LibreDataStorePort, txnName string, eqId string) (domain.Equipment, error) {
+	if source := services.GetEquipmentModelSourceServiceInstance(); source != nil {
+		return source.GetEquipmentById(eqId)
+	}
+	txn := dataStore.BeginTransaction(false, txnName)
+	defer txn.Dispose()
+	return queries.GetEquipmentById(txn, eqId)
+}
@@ -0,0 +1,551 @@
+package utilities
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Spruik/libre-common/common/core/domain"
+	"github.com/Spruik/libre-common/common/core/ports"
+	"github.com/Spruik/libre-common/common/core/services"
+	libreConfig "github.com/Spruik/libre-configuration"
+	libreLogger "github.com/Spruik/libre-logging"
+)
+
+//defaultTagChangeHandlers is used when the TAG_CHANGE_HANDLERS list is not configured
+var defaultTagChangeHandlers = []string{"TagChangeHandlerPropInMemory"}
+
+//defaultSnapshotInterval is used when SNAPSHOT_INTERVAL is not configured
+const defaultSnapshotInterval = time.Minute
+
+//tagChannelSize is the number of tag changes buffered for each equipment ahead of its processing thread
+const tagChannelSize = 100
+
+//equipmentWorker holds everything the manager started for one live equipment
+type equipmentWorker struct {
+	mgdEq       *ports.ManagedEquipmentPort
+	runner      ports.EquipmentServiceManagerRunnerIF
+	filters     []ports.ValueChangeFilterPort
+	filterKeys  []string
+	counters    []valueChangeFilterCounters // one for each filter
+	tagMutex    sync.Mutex
+	tagNames    map[string]string // the tag subscribed for each property - its address, or its name
+	properties  map[string]string // the property of each tag subscribed
+	tagChannel  chan domain.StdMessageStruct
+	emitChannel chan emittedTagChange
+	stopChannel chan struct{}
+	pumpDone    chan struct{}
+}
+
+//addTag records the tag subscribed for a property and returns it
+func (w *equipmentWorker) addTag(prop domain.EquipmentPropertyDescriptor) string {
+	tagName := tagNameOf(prop)
+	w.tagMutex.Lock()
+	defer w.tagMutex.Unlock()
+	w.tagNames[prop.Name] = tagName
+	w.properties[tagName] = prop.Name
+	return tagName
+}
+
+//tagOf returns the tag subscribed for a property
+func (w *equipmentWorker) tagOf(propName string) (string, bool) {
+	w.tagMutex.Lock()
+	defer w.tagMutex.Unlock()
+	tagName, exists := w.tagNames[propName]
+	return tagName, exists
+}
+
+//tagNameOf is the tag of a property: its address, or its name when it has none
+func tagNameOf(prop domain.EquipmentPropertyDescriptor) string {
+	if prop.Address != "" {
+		return prop.Address
+	}
+	return prop.Name
+}
+
+//removeTag forgets the tag subscribed for a property, returning it
+func (w *equipmentWorker) removeTag(propName string) (string, bool) {
+	w.tagMutex.Lock()
+	defer w.tagMutex.Unlock()
+	tagName, exists := w.tagNames[propName]
+	if exists {
+		delete(w.tagNames, propName)
+		delete(w.properties, tagName)
+	}
+	return tagName, exists
+}
+
+//subscribedTags lists the tags subscribed for all of the properties
+func (w *equipmentWorker) subscribedTags() []string {
+	w.tagMutex.Lock()
+	defer w.tagMutex.Unlock()
+	ret := make([]string, 0, len(w.tagNames))
+	for _, tagName := range w.tagNames {
+		ret = append(ret, tagName)
+	}
+	return ret
+}
+
+//propertyOf gives the tag change the name of the property whose tag it is; a change the connector reports by property
+//  name is left as it is
+func (w *equipmentWorker) propertyOf(tagData domain.StdMessageStruct) domain.StdMessageStruct {
+	w.tagMutex.Lock()
+	defer w.tagMutex.Unlock()
+	if propName, exists := w.properties[tagData.ItemName]; exists {
+		tagData.ItemName = propName
+	}
+	return tagData
+}
+
+//emittedTagChange is a change a filter passed on by itself, which only goes through the filters after it
+type emittedTagChange struct {
+	filterNdx int
+	tagData   domain.StdMessageStruct
+}
+
+type equipmentServiceManagerDefault struct {
+	//inherit logging functions
+	libreLogger.LoggingEnabler
+
+	//inherit config functions
+	libreConfig.ConfigurationEnabler
+
+	loggerHook   string
+	dataStore    ports.LibreDataStorePort
+	cache        ports.EquipmentCachePort
+	plcConnector ports.PlcConnectorPort
+	cacheSubId   int
+	handlerKeys  []string
+	filterKeys   []string
+
+	snapshotInterval time.Duration
+	snapshotStop     chan struct{}
+
+	mutex   sync.Mutex
+	workers map[string]*equipmentWorker
+	wg      *sync.WaitGroup
+	started bool
+}
+
+//NewEquipmentServiceManagerDefault creates the default manager.  The configuration category may contain:
+//  TAG_CHANGE_HANDLERS - the factory keys of the handlers run, in order, for every tag change
+//  VALUE_CHANGE_FILTERS - the factory keys of the filters a tag change must pass before it is handled
+//  SNAPSHOT_INTERVAL - how often equipment state is saved to the snapshot store service, if one is set ("0" = only at shutdown)
+func NewEquipmentServiceManagerDefault(configHook string, storeIF ports.LibreDataStorePort, cacheIF ports.EquipmentCachePort, plcConnector ports.PlcConnectorPort) *equipmentServiceManagerDefault {
+	s := equipmentServiceManagerDefault{
+		dataStore:    storeIF,
+		cache:        cacheIF,
+		plcConnector: plcConnector,
+		workers:      map[string]*equipmentWorker{},
+	}
+	s.SetConfigCategory(configHook)
+	loggerHook, cerr := s.GetConfigItemWithDefault(domain.LOGGER_CONFIG_HOOK_TOKEN, domain.DEFAULT_LOGGER_NAME)
+	if cerr != nil {
+		loggerHook = domain.DEFAULT_LOGGER_NAME
+	}
+	s.SetLoggerConfigHook(loggerHook)
+	s.loggerHook = loggerHook
+	s.handlerKeys = getConfigStringList(&s.ConfigurationEnabler, "TAG_CHANGE_HANDLERS", defaultTagChangeHandlers)
+	s.filterKeys = getConfigStringList(&s.ConfigurationEnabler, "VALUE_CHANGE_FILTERS", []string{})
+	var err error
+	s.snapshotInterval, err = getConfigDurationWithDefault(&s.ConfigurationEnabler, "SNAPSHOT_INTERVAL", defaultSnapshotInterval)
+	if err != nil {
+		panic(fmt.Sprintf("FAILED IN CONFIGURATION SETUP FOR EQUIPMENT SERVICE MANAGER - %s", err))
+	}
+	return &s
+}
+
+func (s *equipmentServiceManagerDefault) Initialize() error {
+	if s.cache == nil {
+		return fmt.Errorf("equipment service manager has no equipment cache")
+	}
+	if s.plcConnector == nil {
+		return fmt.Errorf("equipment service manager has no PLC connector")
+	}
+	if services.GetTagChangeHandlerFactoryServiceInstance() == nil {
+		return fmt.Errorf("equipment service manager requires the tag change handler factory service")
+	}
+	if len(s.filterKeys) > 0 && services.GetValueChangeFilterFactoryServiceInstance() == nil {
+		return fmt.Errorf("equipment service manager requires the value change filter factory service")
+	}
+	if s.cacheSubId != 0 {
+		s.cache.UnsubscribeToChanges(s.cacheSubId)
+	}
+	s.cacheSubId = s.cache.SubscribeToChanges(s.handleEquipmentChange)
+	s.cache.RefreshCache()
+	s.LogInfof("Equipment service manager initialized with handlers %v and filters %v", s.handlerKeys, s.filterKeys)
+	return nil
+}
+
+func (s *equipmentServiceManagerDefault) Start(wg *sync.WaitGroup) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.started {
+		return fmt.Errorf("equipment service manager is already started")
+	}
+	s.wg = wg
+	s.started = true
+	for _, mgdEq := range s.cache.GetEquipmentCacheSnapshot() {
+		if err := s.startWorker(mgdEq); err != nil {
+			s.LogErrorf("Failed to start processing for equipment %s: %s", (*mgdEq).GetEquipmentName(), err)
+		}
+	}
+	s.LogInfof("Equipment service manager started processing for %d equipment", len(s.workers))
+	if services.GetEquipmentSnapshotStoreServiceInstance() != nil && s.snapshotInterval > 0 {
+		s.snapshotStop = make(chan struct{})
+		go s.saveSnapshotsPeriodically(s.snapshotStop)
+	}
+	return nil
+}
+
+func (s *equipmentServiceManagerDefault) Shutdown() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.snapshotStop != nil {
+		close(s.snapshotStop)
+		s.snapshotStop = nil
+	}
+	var firstErr error
+	for eqId, worker := range s.workers {
+		err := s.stopWorker(eqId)
+		//the processing thread has ended, so this is the final state of the equipment
+		s.saveSnapshot(worker)
+		if err != nil {
+			s.LogErrorf("Failed to stop processing for equipment %s: %s", eqId, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	s.started = false
+	return firstErr
+}
+
+func (s *equipmentServiceManagerDefault) GetRunnerStats() []ports.EquipmentRunnerStats {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	ret := make([]ports.EquipmentRunnerStats, 0, len(s.workers))
+	for _, worker := range s.workers {
+		stats := worker.runner.GetStats()
+		stats.QueueDepth += len(worker.tagChannel) + len(worker.emitChannel)
+		ret = append(ret, stats)
+	}
+	return ret
+}
+
+func (s *equipmentServiceManagerDefault) GetFilterStats() []ports.ValueChangeFilterStats {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	ret := make([]ports.ValueChangeFilterStats, 0)
+	for eqId, worker := range s.workers {
+		eqName := (*worker.mgdEq).GetEquipmentName()
+		stats := make([]ports.ValueChangeFilterStats, 0, len(worker.filters))
+		for ndx, filter := range worker.filters {
+			stats = appendValueChangeFilterStats(stats, worker.filterKeys[ndx], filter, &worker.counters[ndx])
+		}
+		for _, filterStats := range stats {
+			filterStats.EquipmentId = eqId
+			filterStats.EquipmentName = eqName
+			ret = append(ret, filterStats)
+		}
+	}
+	return ret
+}
+
+//handleEquipmentChange starts and stops equipment processing as the cache adds and removes equipment, and
+//  keeps the tag subscriptions in step with the equipment properties
+func (s *equipmentServiceManagerDefault) handleEquipmentChange(notice ports.EquipmentCacheChangeNotice) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if !s.started {
+		//equipment present at Start is picked up from the cache list
+		return
+	}
+	switch notice.ChangeType {
+	case ports.EquipmentCacheAdd:
+		mgdEq := s.cache.GetCachedEquipmentItemById(notice.EqId)
+		if mgdEq == nil {
+			s.LogErrorf("Equipment cache reported equipment %s was added, but it is not in the cache", notice.EqId)
+			return
+		}
+		if err := s.startWorker(mgdEq); err != nil {
+			s.LogErrorf("Failed to start processing for added equipment %s: %s", notice.EqId, err)
+		}
+	case ports.EquipmentCacheRemove:
+		if err := s.stopWorker(notice.EqId); err != nil {
+			s.LogErrorf("Failed to stop processing for removed equipment %s: %s", notice.EqId, err)
+		}
+		if store := services.GetEquipmentSnapshotStoreServiceInstance(); store != nil {
+			if err := store.DeleteSnapshot(notice.EqId); err != nil {
+				s.LogWarnf("Failed to delete snapshot of removed equipment %s: %s", notice.EqId, err)
+			}
+		}
+	case ports.EquipmentCachePropertyAdded:
+		s.subscribeProperty(notice.EqId, notice.PropertyName)
+	case ports.EquipmentCachePropertyRemoved:
+		s.unsubscribeProperty(notice.EqId, notice.PropertyName)
+	case ports.EquipmentCachePropertyRedefined:
+		s.resubscribeProperty(notice.EqId, notice.PropertyName)
+	case ports.EquipmentCacheClassChanged, ports.EquipmentCachePropertyDataTypeChanged:
+		//the managed equipment is updated in place, and the tag subscriptions follow the properties added and removed
+		s.LogInfof("Equipment %s changed: %s %s '%s' -> '%s'", notice.EqId, notice.ChangeType, notice.PropertyName, notice.OldValue, notice.NewValue)
+	default:
+		s.LogWarnf("Equipment service manager ignoring equipment change notice: %+v", notice)
+	}
+}
+
+//subscribeProperty listens for the tag of a property added to running equipment.  The manager mutex must be held by the caller.
+func (s *equipmentServiceManagerDefault) subscribeProperty(eqId string, propName string) {
+	worker, exists := s.workers[eqId]
+	if !exists {
+		return
+	}
+	eqName := (*worker.mgdEq).GetEquipmentName()
+	prop, exists := (*worker.mgdEq).GetPropertyMap()[propName]
+	if !exists {
+		s.LogWarnf("Equipment cache reported property %s was added to equipment %s, but it is not in the property map", propName, eqName)
+		return
+	}
+	tagName := worker.addTag(prop)
+	s.plcConnector.ListenForPlcTagChanges(worker.tagChannel, map[string]interface{}{
+		"Client":           eqName,
+		"EQ":               eqName,
+		"Topic" + propName: tagName,
+	})
+	s.LogInfof("Subscribed to new property %s of equipment %s as tag %s", propName, eqName, tagName)
+}
+
+//unsubscribeProperty stops listening for the tag of a property removed from running equipment.  The manager mutex must be held by the caller.
+func (s *equipmentServiceManagerDefault) unsubscribeProperty(eqId string, propName string) {
+	worker, exists := s.workers[eqId]
+	if !exists {
+		return
+	}
+	eqName := (*worker.mgdEq).GetEquipmentName()
+	if tagName, exists := worker.removeTag(propName); exists {
+		if err := s.plcConnector.Unsubscribe(&eqName, []string{tagName}); err != nil {
+			s.LogWarnf("Failed to unsubscribe property %s of equipment %s: %s", propName, eqId, err)
+		}
+	}
+}
+
+//resubscribeProperty moves the subscription of a redefined property to its new tag, when its address changed.  The
+//  manager mutex must be held by the caller.
+func (s *equipmentServiceManagerDefault) resubscribeProperty(eqId string, propName string) {
+	worker, exists := s.workers[eqId]
+	if !exists {
+		return
+	}
+	prop, exists := (*worker.mgdEq).GetPropertyMap()[propName]
+	if !exists {
+		return
+	}
+	if tagName, subscribed := worker.tagOf(propName); subscribed && tagName == tagNameOf(prop) {
+		return
+	}
+	s.unsubscribeProperty(eqId, propName)
+	s.subscribeProperty(eqId, propName)
+}
+
+//startWorker builds the handler and filter chains for the equipment, starts its runner and subscribes to its tags.
+//  The manager mutex must be held by the caller.
+func (s *equipmentServiceManagerDefault) startWorker(mgdEq *ports.ManagedEquipmentPort) error {
+	eqId := (*mgdEq).GetEquipmentId()
+	eqName := (*mgdEq).GetEquipmentName()
+	if _, exists := s.workers[eqId]; exists {
+		return fmt.Errorf("processing for equipment %s is already running", eqName)
+	}
+
+	handlers := make([]ports.TagChangeHandlerPort, 0, len(s.handlerKeys))
+	for _, key := range s.handlerKeys {
+		handler := services.GetTagChangeHandlerFactoryServiceInstance().CreateHandlerInstance(key, mgdEq)
+		if handler == nil {
+			return fmt.Errorf("unknown tag change handler '%s'", key)
+		}
+		handlers = append(handlers, handler)
+	}
+	filters := make([]ports.ValueChangeFilterPort, 0, len(s.filterKeys))
+	for _, key := range s.filterKeys {
+		filter := services.GetValueChangeFilterFactoryServiceInstance().CreateFilterInstance(key, mgdEq)
+		if filter == nil {
+			return fmt.Errorf("unknown value change filter '%s'", key)
+		}
+		if err := filter.Initialize(); err != nil {
+			return fmt.Errorf("failed to initialize value change filter '%s': %s", key, err)
+		}
+		filters = append(filters, filter)
+	}
+
+	worker := &equipmentWorker{
+		mgdEq:       mgdEq,
+		runner:      NewEquipmentServiceManagerRunnerDefault(s.loggerHook, s.dataStore, &handlers),
+		filters:     filters,
+		filterKeys:  append([]string{}, s.filterKeys...),
+		counters:    make([]valueChangeFilterCounters, len(filters)),
+		tagNames:    map[string]string{},
+		properties:  map[string]string{},
+		tagChannel:  make(chan domain.StdMessageStruct, tagChannelSize),
+		emitChannel: make(chan emittedTagChange, tagChannelSize),
+		stopChannel: make(chan struct{}),
+		pumpDone:    make(chan struct{}),
+	}
+	//the snapshot is restored first, so the handlers initialize from the restored property values
+	s.restoreSnapshot(mgdEq)
+	worker.runner.Prepare(mgdEq)
+	worker.runner.Run(s.wg)
+	if s.wg != nil {
+		s.wg.Add(1)
+	}
+	go s.pumpTagChanges(worker)
+	for ndx, filter := range filters {
+		if emitter, isEmitter := filter.(ports.ValueChangeEmitterPort); isEmitter {
+			emitter.StartEmitting(s.emitFunc(worker, ndx))
+		}
+	}
+
+	changeFilter := map[string]interface{}{
+		"Client": eqName,
+		"EQ":     eqName,
+	}
+	for propName, prop := range (*mgdEq).GetPropertyMap() {
+		changeFilter["Topic"+propName] = worker.addTag(prop)
+	}
+	s.plcConnector.ListenForPlcTagChanges(worker.tagChannel, changeFilter)
+	s.workers[eqId] = worker
+	s.LogInfof("Started processing for equipment %s with %d tag subscriptions", eqName, len(worker.tagNames))
+	return nil
+}
+
+//stopWorker unsubscribes the equipment tags, stops the connector sending to its tag channel and ends its processing.
+//  The manager mutex must be held by the caller.
+func (s *equipmentServiceManagerDefault) stopWorker(eqId string) error {
+	worker, exists := s.workers[eqId]
+	if !exists {
+		return nil
+	}
+	delete(s.workers, eqId)
+	eqName := (*worker.mgdEq).GetEquipmentName()
+	if err := s.plcConnector.Unsubscribe(&eqName, worker.subscribedTags()); err != nil {
+		s.LogWarnf("Failed to unsubscribe tags for equipment %s: %s", eqName, err)
+	}
+	s.plcConnector.StopListening(eqName)
+	for _, filter := range worker.filters {
+		if emitter, isEmitter := filter.(ports.ValueChangeEmitterPort); isEmitter {
+			emitter.StopEmitting()
+		}
+	}
+	close(worker.stopChannel)
+	<-worker.pumpDone
+	return worker.runner.Stop()
+}
+
+//pumpTagChanges passes the tag changes for one equipment through the filter chain and on to its runner
+func (s *equipmentServiceManagerDefault) pumpTagChanges(worker *equipmentWorker) {
+	defer func() {
+		close(worker.pumpDone)
+		if s.wg != nil {
+			s.wg.Done()
+		}
+	}()
+	for {
+		select {
+		case <-worker.stopChannel:
+			return
+		case tagData := <-worker.tagChannel:
+			tagData = worker.propertyOf(tagData)
+			if s.passesFilters(worker, tagData, 0) {
+				s.handleTagChange(worker, tagData)
+			}
+		case emitted := <-worker.emitChannel:
+			if s.passesFilters(worker, emitted.tagData, emitted.filterNdx+1) {
+				s.handleTagChange(worker, emitted.tagData)
+			}
+		}
+	}
+}
+
+//handleTagChange sends a tag change that passed the filters to the runner of the equipment
+func (s *equipmentServiceManagerDefault) handleTagChange(worker *equipmentWorker, tagData domain.StdMessageStruct) {
+	ack := worker.runner.SendRequest(domain.EquipmentServiceRequest{
+		ServiceType: domain.SVCRQST_TAGDATA,
+		Time:        time.Now(),
+		TagInfo:     tagData,
+	})
+	if ack.ServiceType != domain.SVCRQST_TAGDATA_ACK {
+		s.LogWarnf("Tag change %s for equipment %s was not handled: %s", tagData.ItemName, (*worker.mgdEq).GetEquipmentName(), ack.Message)
+	} else if ack.Message != "" {
+		s.LogDebugf("Tag change %s for equipment %s handled: %s", tagData.ItemName, (*worker.mgdEq).GetEquipmentName(), ack.Message)
+	}
+}
+
+//emitFunc returns the function a filter uses to pass changes on by itself; once the worker stops they are dropped
+func (s *equipmentServiceManagerDefault) emitFunc(worker *equipmentWorker, filterNdx int) func(tagData domain.StdMessageStruct) {
+	return func(tagData domain.StdMessageStruct) {
+		worker.counters[filterNdx].countEmitted()
+		select {
+		case worker.emitChannel <- emittedTagChange{filterNdx: filterNdx, tagData: tagData}:
+		case <-worker.stopChannel:
+		}
+	}
+}
+
+//passesFilters applies the filter chain in order from the given filter; a filter error drops the change
+func (s *equipmentServiceManagerDefault) passesFilters(worker *equipmentWorker, tagData domain.StdMessageStruct, fromNdx int) bool {
+	for ndx := fromNdx; ndx < len(worker.filters); ndx++ {
+		pass, err := worker.filters[ndx].PassValueThrough(tagData)
+		worker.counters[ndx].count(pass, err)
+		if err != nil {
+			s.LogErrorf("Value change filter failed for tag %s of equipment %s: %s", tagData.ItemName, (*worker.mgdEq).GetEquipmentName(), err)
+			return false
+		}
+		if !pass {
+			return false
+		}
+	}
+	return true
+}
+
+//restoreSnapshot warm starts the equipment from the snapshot store service, if one is set
+func (s *equipmentServiceManagerDefault) restoreSnapshot(mgdEq *ports.ManagedEquipmentPort) {
+	store := services.GetEquipmentSnapshotStoreServiceInstance()
+	if store == nil {
+		return
+	}
+	snapshot, err := store.LoadSnapshot((*mgdEq).GetEquipmentId())
+	if err != nil {
+		s.LogErrorf("Failed to load snapshot for equipment %s: %s", (*mgdEq).GetEquipmentName(), err)
+		return
+	}
+	if snapshot != nil {
+		if err = (*mgdEq).RestoreSnapshot(*snapshot); err != nil {
+			s.LogErrorf("Failed to restore snapshot for equipment %s: %s", (*mgdEq).GetEquipmentName(), err)
+		}
+	}
+}
+
+func (s *equipmentServiceManagerDefault) saveSnapshot(worker *equipmentWorker) {
+	store := services.GetEquipmentSnapshotStoreServiceInstance()
+	if store == nil {
+		return
+	}
+	if err := store.SaveSnapshot((*worker.mgdEq).Snapshot()); err != nil {
+		s.LogErrorf("Failed to save snapshot for equipment %s: %s", (*worker.mgdEq).GetEquipmentName(), err)
+	}
+}
+
+func (s *equipmentServiceManagerDefault) saveSnapshotsPeriodically(stop chan struct{}) {
+	ticker := time.NewTicker(s.snapshotInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			s.mutex.Lock()
+			for _, worker := range s.workers {
+				s.saveSnapshot(worker)
+			}
+			s.mutex.Unlock()
+		}
+	}
+}
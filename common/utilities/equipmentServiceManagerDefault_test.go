@@ -0,0 +1,117 @@
+package utilities
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Spruik/libre-common/common/core/domain"
+	"github.com/Spruik/libre-common/common/core/ports"
+)
+
+//fakePlcConnector records the tags each client listens for and unsubscribes from
+type fakePlcConnector struct {
+	mutex        sync.Mutex
+	listened     []string
+	unsubscribed []string
+	stopped      []string
+}
+
+func (c *fakePlcConnector) Connect() error {
+	return nil
+}
+
+func (c *fakePlcConnector) Close() error {
+	return nil
+}
+
+func (c *fakePlcConnector) ReadTags(inTagDefs []domain.StdMessageStruct) []domain.StdMessageStruct {
+	return []domain.StdMessageStruct{}
+}
+
+func (c *fakePlcConnector) WriteTags(outTagDefs []domain.StdMessageStruct) []domain.StdMessageStruct {
+	return []domain.StdMessageStruct{}
+}
+
+func (c *fakePlcConnector) ListenForPlcTagChanges(ch chan domain.StdMessageStruct, changeFilter map[string]interface{}) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for key, val := range changeFilter {
+		if strings.HasPrefix(key, "Topic") {
+			c.listened = append(c.listened, fmt.Sprintf("%s:%s", changeFilter["Client"], val))
+		}
+	}
+}
+
+func (c *fakePlcConnector) Unsubscribe(clientName *string, tagNames []string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, tagName := range tagNames {
+		c.unsubscribed = append(c.unsubscribed, fmt.Sprintf("%s:%s", *clientName, tagName))
+	}
+	return nil
+}
+
+func (c *fakePlcConnector) StopListening(clientName string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.stopped = append(c.stopped, clientName)
+}
+
+func (c *fakePlcConnector) GetTagHistory(startTS time.Time, endTS time.Time, inTagDefs []domain.StdMessageStruct) []domain.StdMessageStruct {
+	return []domain.StdMessageStruct{}
+}
+
+//newTestManagerWithWorker returns a started manager with a worker for the equipment, listening for its properties
+func newTestManagerWithWorker(mgdEq *ports.ManagedEquipmentPort) (*equipmentServiceManagerDefault, *equipmentWorker, *fakePlcConnector) {
+	plc := &fakePlcConnector{}
+	worker := &equipmentWorker{
+		mgdEq:      mgdEq,
+		tagNames:   map[string]string{},
+		properties: map[string]string{},
+		tagChannel: make(chan domain.StdMessageStruct, tagChannelSize),
+	}
+	for _, prop := range (*mgdEq).GetPropertyMap() {
+		worker.addTag(prop)
+	}
+	s := &equipmentServiceManagerDefault{
+		plcConnector: plc,
+		workers:      map[string]*equipmentWorker{(*mgdEq).GetEquipmentId(): worker},
+		started:      true,
+	}
+	s.SetLoggerConfigHook("equipmentServiceManagerTest")
+	return s, worker, plc
+}
+
+func TestRedefinedPropertyMovesToItsNewAddress(t *testing.T) {
+	mgdEq := newTestManagedEquipment()
+	(*mgdEq).SetPropertyDefinition(domain.EquipmentPropertyDescriptor{Name: "Temp", DataType: "FLOAT", Address: "Filler.TempOld"})
+	(*mgdEq).SetPropertyDefinition(domain.EquipmentPropertyDescriptor{Name: "Speed", DataType: "FLOAT"})
+	s, worker, plc := newTestManagerWithWorker(mgdEq)
+
+	//a unit change leaves the tag as it is
+	(*mgdEq).SetPropertyDefinition(domain.EquipmentPropertyDescriptor{Name: "Speed", DataType: "FLOAT", UnitOfMeasure: "rpm"})
+	s.handleEquipmentChange(ports.EquipmentCacheChangeNotice{ChangeType: ports.EquipmentCachePropertyRedefined, EqId: "eq1", PropertyName: "Speed"})
+	if len(plc.listened) != 0 || len(plc.unsubscribed) != 0 {
+		t.Fatalf("Expect no resubscription when the address is unchanged; listened %v, unsubscribed %v", plc.listened, plc.unsubscribed)
+	}
+
+	(*mgdEq).SetPropertyDefinition(domain.EquipmentPropertyDescriptor{Name: "Temp", DataType: "FLOAT", Address: "Filler.TempNew"})
+	s.handleEquipmentChange(ports.EquipmentCacheChangeNotice{ChangeType: ports.EquipmentCachePropertyRedefined, EqId: "eq1", PropertyName: "Temp",
+		OldValue: "Filler.TempOld", NewValue: "Filler.TempNew"})
+	if expected := []string{"Filler1:Filler.TempOld"}; !reflect.DeepEqual(plc.unsubscribed, expected) {
+		t.Errorf("Expect the old tag to be unsubscribed; got %v", plc.unsubscribed)
+	}
+	if expected := []string{"Filler1:Filler.TempNew"}; !reflect.DeepEqual(plc.listened, expected) {
+		t.Errorf("Expect the new tag to be subscribed; got %v", plc.listened)
+	}
+	if name := worker.propertyOf(domain.StdMessageStruct{ItemName: "Filler.TempNew"}).ItemName; name != "Temp" {
+		t.Errorf("Expect a change of the new tag to map to Temp; got %s", name)
+	}
+	if name := worker.propertyOf(domain.StdMessageStruct{ItemName: "Filler.TempOld"}).ItemName; name == "Temp" {
+		t.Errorf("Expect a late change of the old tag not to map to Temp")
+	}
+}
@@ -1,11 +1,21 @@
 package utilities
 
 import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Spruik/libre-common/common/core/domain"
 	"github.com/Spruik/libre-common/common/core/ports"
 	"github.com/Spruik/libre-logging"
-	"sync"
 )
 
+//runnerRestartDelay is the pause before the processing thread is restarted after a panic
+const runnerRestartDelay = time.Second
+
+//runnerStopTimeout bounds the wait for the processing thread to end after the shutdown request is acknowledged
+const runnerStopTimeout = 5 * time.Second
+
 type equipmentServiceManagerRunnerDefault struct {
 	//inherit logging functions
 	libreLogger.LoggingEnabler
@@ -13,33 +23,166 @@ type equipmentServiceManagerRunnerDefault struct {
 	dataStore         ports.LibreDataStorePort
 	tagChangeHandlers *[]ports.TagChangeHandlerPort
 	mgdEq             *ports.ManagedEquipmentPort
+	restartDelay      time.Duration
+
+	//the managed equipment request channel carries both requests and acks, so only one request may be in flight
+	sendMutex    sync.Mutex
+	statsMutex   sync.Mutex
+	running      bool
+	done         chan struct{}
+	queueDepth   int
+	processed    int64
+	failed       int64
+	restarts     int
+	lastLatency  time.Duration
+	maxLatency   time.Duration
+	totalLatency time.Duration
 }
 
 func NewEquipmentServiceManagerRunnerDefault(loggerHook string, storeIF ports.LibreDataStorePort, tagChangeHandlers *[]ports.TagChangeHandlerPort) *equipmentServiceManagerRunnerDefault {
 	s := equipmentServiceManagerRunnerDefault{
 		dataStore:         storeIF,
 		tagChangeHandlers: tagChangeHandlers,
+		restartDelay:      runnerRestartDelay,
 	}
 	s.SetLoggerConfigHook(loggerHook)
 	return &s
 }
 
-func (s *equipmentServiceManagerRunnerDefault) Prepare(mgdEd *ports.ManagedEquipmentPort) {
+func (s *equipmentServiceManagerRunnerDefault) Prepare(mgdEq *ports.ManagedEquipmentPort) {
 	for _, handler := range *s.tagChangeHandlers {
 		handler.Initialize()
 	}
-	s.mgdEq = mgdEd
+	s.mgdEq = mgdEq
 }
 
 func (s *equipmentServiceManagerRunnerDefault) Run(wg *sync.WaitGroup) {
-	//called in a separate thread to handle a specific piece of equipment
-	s.LogInfof("Starting equipment processing thread for equipment %s", (*s.mgdEq).GetEquipmentId())
-	wg.Add(1)
-	running := true
-	for running {
-		(*s.mgdEq).AcceptRequest(s.tagChangeHandlers)
+	s.statsMutex.Lock()
+	if s.running {
+		s.statsMutex.Unlock()
+		s.LogWarnf("Run called for equipment %s when the processing thread is already running", (*s.mgdEq).GetEquipmentName())
+		return
 	}
+	s.running = true
+	s.done = make(chan struct{})
+	s.statsMutex.Unlock()
+	//register with the caller's wait group before the thread starts, so a Wait() cannot slip in ahead of us
 	if wg != nil {
-		wg.Done()
+		wg.Add(1)
+	}
+	go func() {
+		defer func() {
+			s.statsMutex.Lock()
+			s.running = false
+			close(s.done)
+			s.statsMutex.Unlock()
+			if wg != nil {
+				wg.Done()
+			}
+		}()
+		s.LogInfof("Starting equipment processing thread for equipment %s", (*s.mgdEq).GetEquipmentId())
+		for !s.acceptUntilShutdown() {
+			s.statsMutex.Lock()
+			s.restarts++
+			s.statsMutex.Unlock()
+			s.LogWarnf("Restarting equipment processing thread for equipment %s in %s", (*s.mgdEq).GetEquipmentName(), s.restartDelay)
+			time.Sleep(s.restartDelay)
+		}
+		s.LogInfof("Equipment processing thread for equipment %s has ended", (*s.mgdEq).GetEquipmentId())
+	}()
+}
+
+//acceptUntilShutdown processes requests until a shutdown request is accepted (returns true) or a panic occurs (returns
+//  false).  The managed equipment acknowledges the failed request with an error before the panic reaches here.
+func (s *equipmentServiceManagerRunnerDefault) acceptUntilShutdown() (shutdown bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.LogErrorf("Equipment processing thread for equipment %s panicked: %+v", (*s.mgdEq).GetEquipmentName(), r)
+			shutdown = false
+		}
+	}()
+	for (*s.mgdEq).AcceptRequest(s.tagChangeHandlers) {
+	}
+	return true
+}
+
+func (s *equipmentServiceManagerRunnerDefault) SendRequest(request domain.EquipmentServiceRequest) domain.EquipmentServiceRequest {
+	s.statsMutex.Lock()
+	if !s.running {
+		s.statsMutex.Unlock()
+		return domain.EquipmentServiceRequest{
+			Time:    time.Now(),
+			Message: fmt.Sprintf("equipment processing thread for %s is not running", (*s.mgdEq).GetEquipmentName()),
+		}
+	}
+	s.queueDepth++
+	s.statsMutex.Unlock()
+
+	start := time.Now()
+	s.sendMutex.Lock()
+	ack := (*s.mgdEq).SendRequest(request)
+	s.sendMutex.Unlock()
+	latency := time.Since(start)
+
+	s.statsMutex.Lock()
+	s.queueDepth--
+	if ack.ServiceType == domain.SVCRQST_ERROR_ACK {
+		s.failed++
+	}
+	if ack.ServiceType == domain.SVCRQST_TAGDATA_ACK {
+		s.processed++
+		s.lastLatency = latency
+		s.totalLatency += latency
+		if latency > s.maxLatency {
+			s.maxLatency = latency
+		}
+	}
+	s.statsMutex.Unlock()
+	return ack
+}
+
+func (s *equipmentServiceManagerRunnerDefault) Stop() error {
+	s.statsMutex.Lock()
+	running := s.running
+	done := s.done
+	s.statsMutex.Unlock()
+	if !running {
+		return nil
+	}
+	ack := s.SendRequest(domain.EquipmentServiceRequest{
+		ServiceType: domain.SVCRQST_SHUTDOWN,
+		Time:        time.Now(),
+		Message:     "Shutdown requested by the equipment service manager",
+	})
+	if ack.ServiceType != domain.SVCRQST_SHUTDOWN_ACK {
+		return fmt.Errorf("unexpected response to shutdown request for equipment %s: %+v", (*s.mgdEq).GetEquipmentName(), ack)
+	}
+	select {
+	case <-done:
+		return nil
+	case <-time.After(runnerStopTimeout):
+		return fmt.Errorf("timed out waiting for the processing thread of equipment %s to end", (*s.mgdEq).GetEquipmentName())
+	}
+}
+
+func (s *equipmentServiceManagerRunnerDefault) GetStats() ports.EquipmentRunnerStats {
+	s.statsMutex.Lock()
+	defer s.statsMutex.Unlock()
+	stats := ports.EquipmentRunnerStats{
+		Running:     s.running,
+		QueueDepth:  s.queueDepth,
+		Processed:   s.processed,
+		Failed:      s.failed,
+		Restarts:    s.restarts,
+		LastLatency: s.lastLatency,
+		MaxLatency:  s.maxLatency,
+	}
+	if s.mgdEq != nil {
+		stats.EquipmentId = (*s.mgdEq).GetEquipmentId()
+		stats.EquipmentName = (*s.mgdEq).GetEquipmentName()
+	}
+	if s.processed > 0 {
+		stats.AverageLatency = s.totalLatency / time.Duration(s.processed)
 	}
+	return stats
 }
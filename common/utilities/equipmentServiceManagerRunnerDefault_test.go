@@ -0,0 +1,51 @@
+package utilities
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Spruik/libre-common/common/core/domain"
+	"github.com/Spruik/libre-common/common/core/ports"
+)
+
+func TestRunnerRestartsAfterAPanicAndStops(t *testing.T) {
+	handler := &testTagChangeHandler{panicAcking: "Worse"}
+	runner := NewEquipmentServiceManagerRunnerDefault("equipmentRunnerTest", newFakeDataStore(), &[]ports.TagChangeHandlerPort{handler})
+	runner.restartDelay = time.Millisecond
+	runner.Prepare(newTestManagedEquipment())
+	wg := &sync.WaitGroup{}
+	runner.Run(wg)
+	send := func(itemName string) domain.EquipmentServiceRequest {
+		return runner.SendRequest(domain.EquipmentServiceRequest{ServiceType: domain.SVCRQST_TAGDATA, Time: time.Now(), TagInfo: domain.StdMessageStruct{ItemName: itemName}})
+	}
+
+	if ack := send("Speed"); ack.ServiceType != domain.SVCRQST_TAGDATA_ACK {
+		t.Fatalf("Expect the tag change to be processed; got %+v", ack)
+	}
+	if ack := send("Worse"); ack.ServiceType != domain.SVCRQST_ERROR_ACK {
+		t.Fatalf("Expect an error ack for the request that panicked; got %+v", ack)
+	}
+	//the restarted thread picks up the next request
+	if ack := send("Count"); ack.ServiceType != domain.SVCRQST_TAGDATA_ACK {
+		t.Fatalf("Expect the restarted thread to process the tag change; got %+v", ack)
+	}
+	stats := runner.GetStats()
+	if !stats.Running || stats.Processed != 2 || stats.Failed != 1 || stats.Restarts != 1 || stats.EquipmentName != "Filler1" {
+		t.Errorf("Expect 2 processed, 1 failed and 1 restart; got %+v", stats)
+	}
+
+	if err := runner.Stop(); err != nil {
+		t.Fatalf("Stop failed: %s", err)
+	}
+	wg.Wait()
+	if runner.GetStats().Running {
+		t.Errorf("Expect the runner to report it stopped")
+	}
+	if ack := send("Speed"); ack.ServiceType != "" {
+		t.Errorf("Expect a request to a stopped runner not to be processed; got %+v", ack)
+	}
+	if err := runner.Stop(); err != nil {
+		t.Errorf("Expect stopping a stopped runner to do nothing; got %s", err)
+	}
+}
@@ -0,0 +1,103 @@
+package utilities
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/Spruik/libre-common/common/core/domain"
+	libreConfig "github.com/Spruik/libre-configuration"
+	libreLogger "github.com/Spruik/libre-logging"
+)
+
+//equipmentSnapshotStoreFile keeps one JSON file per equipment in the configured SNAPSHOT_DIRECTORY
+type equipmentSnapshotStoreFile struct {
+	//inherit logging functions
+	libreLogger.LoggingEnabler
+
+	//inherit config functions
+	libreConfig.ConfigurationEnabler
+
+	directory string
+	mutex     sync.Mutex
+}
+
+func NewEquipmentSnapshotStoreFile(configHook string) *equipmentSnapshotStoreFile {
+	s := equipmentSnapshotStoreFile{}
+	s.SetConfigCategory(configHook)
+	loggerHook, cerr := s.GetConfigItemWithDefault(domain.LOGGER_CONFIG_HOOK_TOKEN, domain.DEFAULT_LOGGER_NAME)
+	if cerr != nil {
+		loggerHook = domain.DEFAULT_LOGGER_NAME
+	}
+	s.SetLoggerConfigHook(loggerHook)
+	s.directory = getConfigStringWithDefault(&s.ConfigurationEnabler, "SNAPSHOT_DIRECTORY", "snapshots")
+	if err := os.MkdirAll(s.directory, 0755); err != nil {
+		panic(fmt.Sprintf("FAILED IN CONFIGURATION SETUP FOR EQUIPMENT SNAPSHOT STORE - CANNOT CREATE DIRECTORY '%s' [%s]", s.directory, err))
+	}
+	return &s
+}
+
+func (s *equipmentSnapshotStoreFile) snapshotFileName(equipmentId string) string {
+	return filepath.Join(s.directory, url.PathEscape(equipmentId)+".json")
+}
+
+//SaveSnapshot writes to a temporary file and renames it, so a crash mid-write never leaves a truncated snapshot
+func (s *equipmentSnapshotStoreFile) SaveSnapshot(snapshot domain.EquipmentSnapshot) error {
+	raw, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	tmpFile, err := ioutil.TempFile(s.directory, "snapshot-*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmpFile.Write(raw)
+	if err == nil {
+		err = tmpFile.Sync()
+	}
+	if cerr := tmpFile.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmpFile.Name(), s.snapshotFileName(snapshot.EquipmentId))
+	}
+	if err != nil {
+		_ = os.Remove(tmpFile.Name())
+		return err
+	}
+	s.LogDebugf("Saved snapshot of equipment %s with %d properties", snapshot.EquipmentName, len(snapshot.Properties))
+	return nil
+}
+
+func (s *equipmentSnapshotStoreFile) LoadSnapshot(equipmentId string) (*domain.EquipmentSnapshot, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	raw, err := ioutil.ReadFile(s.snapshotFileName(equipmentId))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var snapshot domain.EquipmentSnapshot
+	if err = json.Unmarshal(raw, &snapshot); err != nil {
+		return nil, fmt.Errorf("corrupt snapshot for equipment %s: %s", equipmentId, err)
+	}
+	return &snapshot, nil
+}
+
+func (s *equipmentSnapshotStoreFile) DeleteSnapshot(equipmentId string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	err := os.Remove(s.snapshotFileName(equipmentId))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,77 @@
+package utilities
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Spruik/libre-common/common/core/domain"
+)
+
+func newTestSnapshotStore(t *testing.T) *equipmentSnapshotStoreFile {
+	s := &equipmentSnapshotStoreFile{directory: t.TempDir()}
+	s.SetLoggerConfigHook("equipmentSnapshotStoreTest")
+	return s
+}
+
+func TestSnapshotStoreFileSavesAndLoads(t *testing.T) {
+	s := newTestSnapshotStore(t)
+	count := "42"
+	snapshot := domain.EquipmentSnapshot{
+		EquipmentId:   "line1/filler1",
+		EquipmentName: "Filler1",
+		Taken:         time.Now().UTC().Truncate(time.Second),
+		Properties:    []domain.PropertySnapshot{{Name: "count", DataType: "INT32", Value: &count}},
+	}
+	if err := s.SaveSnapshot(snapshot); err != nil {
+		t.Fatal(err)
+	}
+	//the snapshot is renamed into place, so no temporary file is left, and the id is escaped for the file name
+	files, _ := filepath.Glob(filepath.Join(s.directory, "*"))
+	if len(files) != 1 || filepath.Base(files[0]) != "line1%2Ffiller1.json" {
+		t.Errorf("Expect only the snapshot file; got %v", files)
+	}
+	loaded, err := s.LoadSnapshot("line1/filler1")
+	if err != nil || loaded == nil {
+		t.Fatalf("Expect the saved snapshot to load; got %v, %v", loaded, err)
+	}
+	if loaded.EquipmentName != "Filler1" || !loaded.Taken.Equal(snapshot.Taken) || len(loaded.Properties) != 1 || *loaded.Properties[0].Value != "42" {
+		t.Errorf("Expect the saved snapshot back; got %+v", loaded)
+	}
+
+	if err := s.DeleteSnapshot("line1/filler1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteSnapshot("line1/filler1"); err != nil {
+		t.Errorf("Expect deleting a missing snapshot to succeed; got %s", err)
+	}
+}
+
+func TestSnapshotStoreFileLoadsMissingAndCorruptFiles(t *testing.T) {
+	s := newTestSnapshotStore(t)
+	if loaded, err := s.LoadSnapshot("none"); loaded != nil || err != nil {
+		t.Errorf("Expect no snapshot and no error for a missing file; got %v, %v", loaded, err)
+	}
+	if err := ioutil.WriteFile(s.snapshotFileName("corrupt"), []byte(`{"equipmentId": "corr`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if loaded, err := s.LoadSnapshot("corrupt"); loaded != nil || err == nil {
+		t.Errorf("Expect an error for a corrupt file; got %v, %v", loaded, err)
+	}
+}
+
+func TestSnapshotStoreFileRemovesTheTemporaryFileOfAFailedSave(t *testing.T) {
+	s := newTestSnapshotStore(t)
+	//a directory in the way of the snapshot file makes the rename fail
+	if err := os.Mkdir(s.snapshotFileName("blocked"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SaveSnapshot(domain.EquipmentSnapshot{EquipmentId: "blocked"}); err == nil {
+		t.Errorf("Expect the save to fail")
+	}
+	if files, _ := filepath.Glob(filepath.Join(s.directory, "*.tmp")); len(files) != 0 {
+		t.Errorf("Expect the temporary file to be removed; got %v", files)
+	}
+}
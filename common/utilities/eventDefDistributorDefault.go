@@ -27,9 +27,11 @@ func NewEventDefDistributorDefault(configHook string) *eventDefDistributorDefaul
 }
 
 type eventStuct struct {
-	Equipment string
-	Event     string
-	Payload   map[string]interface{}
+	Equipment     string
+	EquipmentPath string `json:",omitempty"`
+	Event         string
+	Payload       map[string]interface{}
+	Units         map[string]string `json:",omitempty"`
 }
 
 func (s *eventDefDistributorDefault) DistributeEventDef(eqId, eqName string, eventDef *domain.EventDefinition, computedPayload map[string]interface{}) error {
@@ -45,9 +47,11 @@ func (s *eventDefDistributorDefault) DistributeEventDef(eqId, eqName string, eve
 
 	//now pass it to the libre connector
 	payloadData := eventStuct{
-		Equipment: eqName,
-		Event:     eventDef.Name,
-		Payload:   computedPayload,
+		Equipment:     eqName,
+		EquipmentPath: s.equipmentPath(eqId),
+		Event:         eventDef.Name,
+		Payload:       computedPayload,
+		Units:         s.payloadUnits(eqId, computedPayload),
 	}
 	jsonBytes, err := json.Marshal(&payloadData)
 	if err == nil {
@@ -57,7 +61,7 @@ func (s *eventDefDistributorDefault) DistributeEventDef(eqId, eqName string, eve
 			ItemName:         string(eventDef.MessageClass),
 			ItemValue:        string(jsonBytes),
 			ItemDataType:     "STRING",
-			TagQuality:       0,
+			TagQuality:       int(domain.Good),
 			Err:              nil,
 			Category:         "EVENT",
 			ChangedTimestamp: time.Now(),
@@ -66,3 +70,33 @@ func (s *eventDefDistributorDefault) DistributeEventDef(eqId, eqName string, eve
 	}
 	return err
 }
+
+//payloadUnits returns the units of the payload entries that are equipment properties with a unit of measure
+func (s *eventDefDistributorDefault) payloadUnits(eqId string, payload map[string]interface{}) map[string]string {
+	cache := services.GetEquipmentCacheServiceInstance()
+	if cache == nil {
+		return nil
+	}
+	mgdEq := cache.GetCachedEquipmentItemById(eqId)
+	if mgdEq == nil {
+		return nil
+	}
+	var units map[string]string
+	for name := range payload {
+		if uom := (*mgdEq).GetProperty(name).UnitOfMeasure; uom != "" {
+			if units == nil {
+				units = map[string]string{}
+			}
+			units[name] = uom
+		}
+	}
+	return units
+}
+
+//equipmentPath returns the ISA-95 path of the equipment so consumers can place the event without another lookup
+func (s *eventDefDistributorDefault) equipmentPath(eqId string) string {
+	if cache := services.GetEquipmentCacheServiceInstance(); cache != nil {
+		return cache.GetEquipmentPath(eqId)
+	}
+	return ""
+}
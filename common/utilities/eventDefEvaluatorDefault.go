@@ -6,6 +6,7 @@ import (
 	"github.com/Spruik/libre-common/common/core/domain"
 	"github.com/Spruik/libre-common/common/core/ports"
 	"github.com/Spruik/libre-common/common/core/queries"
+	"github.com/Spruik/libre-common/common/core/services"
 	"github.com/Spruik/libre-configuration"
 	"github.com/Spruik/libre-logging"
 	"strconv"
@@ -53,8 +54,9 @@ func (s *eventDefEvaluatorDefault) EvaluateEventDef(mgdEq *ports.ManagedEquipmen
 		}
 		var result interface{}
 		var retBool bool
+		historyFxns := s.expressionLanguage(mgdEq)
 		s.LogDebugf("EVALUATING [%s] with %+v", evtDef.TriggerExpression, vals)
-		result, err = gval.Evaluate(evtDef.TriggerExpression, vals)
+		result, err = gval.Evaluate(evtDef.TriggerExpression, vals, historyFxns)
 		s.LogInfof("Raw EVAL result is: %v", result)
 		// a short wait to make sure that we have cached values that may be written at the same time. Example, Order Number and Material Number tags
 		// might get updated in the PLC at the same time, we might use Order Number in the trigger and material number in the payload, we want to
@@ -87,7 +89,7 @@ func (s *eventDefEvaluatorDefault) EvaluateEventDef(mgdEq *ports.ManagedEquipmen
 						var fieldVal interface{}
 						var fieldErr error
 						for _, field := range evtDef.PayloadFields {
-							fieldVal, fieldErr = gval.Evaluate(field.Expression, vals)
+							fieldVal, fieldErr = gval.Evaluate(field.Expression, vals, historyFxns)
 							if fieldErr == nil {
 								//add to our field map
 								fieldMap[field.Name] = fieldVal
@@ -115,3 +117,201 @@ func (s *eventDefEvaluatorDefault) EvaluateEventDef(mgdEq *ports.ManagedEquipmen
 	}
 	return false, nil, nil, err
 }
+
+func (s *eventDefEvaluatorDefault) EvaluateExpression(mgdEq *ports.ManagedEquipmentPort, expression string, evalContext *map[string]interface{}) (interface{}, error) {
+	vals := make(map[string]interface{})
+	for key, val := range (*mgdEq).GetPropertyMap() {
+		vals[key] = val.Value
+	}
+	if evalContext != nil {
+		for key, val := range *evalContext {
+			vals[key] = val
+		}
+	}
+	return gval.Evaluate(expression, vals, s.expressionLanguage(mgdEq))
+}
+
+//expressionLanguage is the functions available to trigger, payload and end expressions
+func (s *eventDefEvaluatorDefault) expressionLanguage(mgdEq *ports.ManagedEquipmentPort) gval.Language {
+	return gval.NewLanguage(s.historyFunctions(mgdEq), s.hierarchyFunctions(mgdEq), s.jobFunctions(mgdEq), s.qualityFunctions(mgdEq))
+}
+
+//historyFunctions makes the property value history of the equipment available to expressions:
+//  historyLast("speed", 5)               - the last 5 values, oldest first
+//  historyValueAt("speed", "30s")        - the value 30 seconds ago (windows are durations or seconds)
+//  historyAvg/historyMin/historyMax("speed", "30s") - time weighted statistics over the last 30 seconds
+//  historyCoverage("speed", "30s")       - seconds of the last 30 seconds the history held covers
+//  historyDuration("state", "Held", "1m") - seconds the property had the value during the last minute
+//  so "speed was above 100 for the last 30s" is historyMin("speed", "30s") > 100.  The statistics are an error until
+//  the history held covers the whole window, so the condition is not met on the first few values after a start.
+func (s *eventDefEvaluatorDefault) historyFunctions(mgdEq *ports.ManagedEquipmentPort) gval.Language {
+	windowStat := func(name string, partial bool, pick func(stats domain.ValueWindowStats) float64) gval.Language {
+		return gval.Function(name, func(args ...interface{}) (interface{}, error) {
+			if len(args) != 2 {
+				return nil, fmt.Errorf("%s expects (property, window)", name)
+			}
+			window, err := historyWindowArg(args[1])
+			if err != nil {
+				return nil, err
+			}
+			stats, err := (*mgdEq).GetPropertyWindowStats(fmt.Sprintf("%v", args[0]), window)
+			if err != nil {
+				return nil, err
+			}
+			if !partial && !stats.CoversWindow(window) {
+				return nil, fmt.Errorf("%s: the history of %v only covers %s of the %s window", name, args[0], stats.Covered, window)
+			}
+			return pick(stats), nil
+		})
+	}
+	return gval.NewLanguage(
+		gval.Function("historyLast", func(propName string, n float64) []interface{} {
+			ret := make([]interface{}, 0)
+			for _, entry := range (*mgdEq).GetPropertyHistory(propName, int(n)) {
+				ret = append(ret, entry.Value)
+			}
+			return ret
+		}),
+		gval.Function("historyValueAt", func(args ...interface{}) (interface{}, error) {
+			if len(args) != 2 {
+				return nil, fmt.Errorf("historyValueAt expects (property, age)")
+			}
+			age, err := historyWindowArg(args[1])
+			if err != nil {
+				return nil, err
+			}
+			val, _ := (*mgdEq).GetPropertyValueAt(fmt.Sprintf("%v", args[0]), time.Now().Add(-age))
+			return val, nil
+		}),
+		gval.Function("historyDuration", func(args ...interface{}) (interface{}, error) {
+			if len(args) != 3 {
+				return nil, fmt.Errorf("historyDuration expects (property, value, window)")
+			}
+			window, err := historyWindowArg(args[2])
+			if err != nil {
+				return nil, err
+			}
+			return (*mgdEq).GetPropertyDurationInValue(fmt.Sprintf("%v", args[0]), args[1], window).Seconds(), nil
+		}),
+		windowStat("historyAvg", false, func(stats domain.ValueWindowStats) float64 { return stats.Average }),
+		windowStat("historyMin", false, func(stats domain.ValueWindowStats) float64 { return stats.Min }),
+		windowStat("historyMax", false, func(stats domain.ValueWindowStats) float64 { return stats.Max }),
+		windowStat("historyCoverage", true, func(stats domain.ValueWindowStats) float64 { return stats.Covered.Seconds() }),
+	)
+}
+
+//hierarchyFunctions makes the position of the equipment in the ISA-95 hierarchy available to expressions:
+//  equipmentPath()             - the path of the equipment, for example "Acme/Sydney/Packing/Line1"
+//  parentName()                - the name of the parent equipment
+//  rollup("goodCount", "SUM")  - a property aggregated (SUM, AVG, MIN, MAX, COUNT) across the descendants
+func (s *eventDefEvaluatorDefault) hierarchyFunctions(mgdEq *ports.ManagedEquipmentPort) gval.Language {
+	eqId := (*mgdEq).GetEquipmentId()
+	return gval.NewLanguage(
+		gval.Function("equipmentPath", func(args ...interface{}) (interface{}, error) {
+			cache := services.GetEquipmentCacheServiceInstance()
+			if cache == nil {
+				return "", nil
+			}
+			return cache.GetEquipmentPath(eqId), nil
+		}),
+		gval.Function("parentName", func(args ...interface{}) (interface{}, error) {
+			cache := services.GetEquipmentCacheServiceInstance()
+			if cache == nil {
+				return "", nil
+			}
+			parent, _ := cache.GetParent(eqId)
+			return parent.Name, nil
+		}),
+		gval.Function("rollup", func(args ...interface{}) (interface{}, error) {
+			if len(args) != 2 {
+				return nil, fmt.Errorf("rollup expects (property, aggregate)")
+			}
+			cache := services.GetEquipmentCacheServiceInstance()
+			if cache == nil {
+				return nil, fmt.Errorf("rollup needs the equipment cache service")
+			}
+			return cache.RollupProperty(eqId, fmt.Sprintf("%v", args[0]), fmt.Sprintf("%v", args[1]))
+		}),
+	)
+}
+
+//jobFunctions makes the job running on the equipment available to expressions:
+//  jobRunning()  - true while a job is running
+//  jobOrder()    - the job order of the running job, or "" if there is none
+//  jobDuration() - seconds since the running job started, or 0 if there is none
+func (s *eventDefEvaluatorDefault) jobFunctions(mgdEq *ports.ManagedEquipmentPort) gval.Language {
+	eqId := (*mgdEq).GetEquipmentId()
+	currentJob := func() (domain.JobResponse, bool) {
+		tracker := services.GetJobTrackerServiceInstance()
+		if tracker == nil {
+			return domain.JobResponse{}, false
+		}
+		return tracker.GetCurrentJob(eqId)
+	}
+	return gval.NewLanguage(
+		gval.Function("jobRunning", func(args ...interface{}) (interface{}, error) {
+			_, running := currentJob()
+			return running, nil
+		}),
+		gval.Function("jobOrder", func(args ...interface{}) (interface{}, error) {
+			job, _ := currentJob()
+			return job.JobOrder, nil
+		}),
+		gval.Function("jobDuration", func(args ...interface{}) (interface{}, error) {
+			if job, running := currentJob(); running {
+				return time.Since(job.StartDateTime).Seconds(), nil
+			}
+			return 0.0, nil
+		}),
+	)
+}
+
+//qualityFunctions makes the quality of the property values available to expressions:
+//  quality("speed")      - the quality of the last value, for example 192 for Good
+//  isGood("speed"), isUncertain("speed"), isBad("speed") - the category of that quality
+//  so a trigger that ignores bad data is isGood("speed") && speed > 100
+func (s *eventDefEvaluatorDefault) qualityFunctions(mgdEq *ports.ManagedEquipmentPort) gval.Language {
+	propertyQuality := func(name string, args []interface{}) (domain.TagQuality, error) {
+		if len(args) != 1 {
+			return domain.Bad, fmt.Errorf("%s expects (property)", name)
+		}
+		propName := fmt.Sprintf("%v", args[0])
+		prop, exists := (*mgdEq).GetPropertyMap()[propName]
+		if !exists {
+			return domain.Bad, fmt.Errorf("%s: unknown property %s", name, propName)
+		}
+		return prop.Quality, nil
+	}
+	category := func(name string, is func(quality domain.TagQuality) bool) gval.Language {
+		return gval.Function(name, func(args ...interface{}) (interface{}, error) {
+			quality, err := propertyQuality(name, args)
+			if err != nil {
+				return nil, err
+			}
+			return is(quality), nil
+		})
+	}
+	return gval.NewLanguage(
+		gval.Function("quality", func(args ...interface{}) (interface{}, error) {
+			quality, err := propertyQuality("quality", args)
+			if err != nil {
+				return nil, err
+			}
+			return float64(quality), nil
+		}),
+		category("isGood", domain.TagQuality.IsGood),
+		category("isUncertain", domain.TagQuality.IsUncertain),
+		category("isBad", domain.TagQuality.IsBad),
+	)
+}
+
+//historyWindowArg accepts a duration string ("30s") or a number of seconds
+func historyWindowArg(arg interface{}) (time.Duration, error) {
+	if str, ok := arg.(string); ok {
+		return time.ParseDuration(str)
+	}
+	if secs, ok := domain.ValueAsFloat64(arg); ok {
+		return time.Duration(secs * float64(time.Second)), nil
+	}
+	return 0, fmt.Errorf("bad history window '%v'", arg)
+}
@@ -0,0 +1,125 @@
+package utilities
+
+import (
+	"fmt"
+
+	"github.com/Spruik/libre-common/common/core/domain"
+	"github.com/Spruik/libre-common/common/core/ports"
+	"github.com/Spruik/libre-common/common/core/queries"
+	libreConfig "github.com/Spruik/libre-configuration"
+	libreLogger "github.com/Spruik/libre-logging"
+)
+
+//eventLogWriterGraphQL writes EventLog records with GraphQL mutations through a dataStoreWriteQueue, so the writes
+//  for one event always reach the data store in the order they were queued
+type eventLogWriterGraphQL struct {
+	//inherit logging functions
+	libreLogger.LoggingEnabler
+
+	//inherit config functions
+	libreConfig.ConfigurationEnabler
+
+	writes *dataStoreWriteQueue
+
+	//ids maps the local id of each written event to its data store id; only used by the queued writes
+	ids map[string]string
+}
+
+//NewEventLogWriterGraphQL creates the writer and starts its queue.  The configuration category may contain the
+//  RETRY_ATTEMPTS, RETRY_DELAY, CLOSE_TIMEOUT and QUEUE_SIZE settings of the write queue.
+func NewEventLogWriterGraphQL(configHook string, storeIF ports.LibreDataStorePort) *eventLogWriterGraphQL {
+	s := eventLogWriterGraphQL{
+		ids: map[string]string{},
+	}
+	s.SetConfigCategory(configHook)
+	loggerHook, cerr := s.GetConfigItemWithDefault(domain.LOGGER_CONFIG_HOOK_TOKEN, domain.DEFAULT_LOGGER_NAME)
+	if cerr != nil {
+		loggerHook = domain.DEFAULT_LOGGER_NAME
+	}
+	s.SetLoggerConfigHook(loggerHook)
+	var err error
+	if s.writes, err = newDataStoreWriteQueue(&s.ConfigurationEnabler, &s.LoggingEnabler, storeIF, "eventLogWriter"); err != nil {
+		panic(fmt.Sprintf("FAILED IN CONFIGURATION SETUP FOR EVENT LOG WRITER - %s", err))
+	}
+	return &s
+}
+
+//EventStarted queues the write of an open event.  An event without a job is skipped, as the schema requires one.
+func (s *eventLogWriterGraphQL) EventStarted(log domain.EventLog, persisted func(id string)) {
+	if !s.hasJob(log) {
+		return
+	}
+	s.writes.enqueue(describeEventLog(log), func(txn ports.LibreDataStoreTransactionPort) error {
+		if err := s.resolveJob(&log); err != nil {
+			return err
+		}
+		id, err := queries.AddEventLog(txn, log)
+		if err != nil {
+			return err
+		}
+		txn.Commit()
+		s.ids[log.LocalId] = id
+		if persisted != nil {
+			persisted(id)
+		}
+		return nil
+	})
+}
+
+//EventEnded queues the close of an event, or the write of the whole event if its start was not written
+func (s *eventLogWriterGraphQL) EventEnded(log domain.EventLog) {
+	if log.Id == "" && !s.hasJob(log) {
+		return
+	}
+	s.writes.enqueue(describeEventLog(log), func(txn ports.LibreDataStoreTransactionPort) error {
+		id := log.Id
+		if id == "" {
+			id = s.ids[log.LocalId]
+		}
+		var err error
+		if id == "" {
+			//an event restored after a restart may have been written without its id being recorded
+			if id, err = queries.FindOpenEventLogId(txn, log.EquipmentId, log.StartDateTime); err != nil {
+				return err
+			}
+		}
+		if id == "" {
+			//the start was never written (or was dropped), so write the whole event now
+			if err = s.resolveJob(&log); err == nil {
+				_, err = queries.AddEventLog(txn, log)
+			}
+		} else {
+			err = queries.CloseEventLog(txn, id, log)
+		}
+		if err != nil {
+			return err
+		}
+		txn.Commit()
+		delete(s.ids, log.LocalId)
+		return nil
+	})
+}
+
+func (s *eventLogWriterGraphQL) Close() error {
+	return s.writes.close()
+}
+
+//hasJob is false for an event that can never be written for want of a job, logging that it is skipped
+func (s *eventLogWriterGraphQL) hasJob(log domain.EventLog) bool {
+	if canResolveJobResponseId(log.JobResponseId, log.JobLocalId) {
+		return true
+	}
+	s.LogWarnf("Skipping the %s, it has no job response", describeEventLog(log))
+	return false
+}
+
+//resolveJob links the event to the data store id of its job, as the schema requires
+func (s *eventLogWriterGraphQL) resolveJob(log *domain.EventLog) error {
+	var err error
+	log.JobResponseId, err = resolveJobResponseId(log.JobResponseId, log.JobLocalId)
+	return err
+}
+
+func describeEventLog(log domain.EventLog) string {
+	return fmt.Sprintf("event %s (%s)", log.Name, log.LocalId)
+}
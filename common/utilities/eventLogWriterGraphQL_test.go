@@ -0,0 +1,67 @@
+package utilities
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Spruik/libre-common/common/core/domain"
+)
+
+func TestEventLogWriterSkipsEventsWithoutAJob(t *testing.T) {
+	store := newFakeDataStore()
+	store.responses["addEventLog"] = jsonData("addEventLog", map[string]interface{}{
+		"eventLog": []map[string]string{{"id": "e1"}},
+	})
+	writer := NewEventLogWriterGraphQL("eventLogWriterTest", store)
+	start := time.Now()
+	jobless := domain.EventLog{LocalId: "l1", Name: "Stopped", EquipmentId: "eq1", StartDateTime: start}
+	writer.EventStarted(jobless, nil)
+	jobless.Close(start.Add(time.Minute))
+	writer.EventEnded(jobless)
+	withJob := domain.EventLog{LocalId: "l2", Name: "Stopped", EquipmentId: "eq1", StartDateTime: start, JobResponseId: "j1"}
+	writer.EventStarted(withJob, nil)
+	if err := writer.Close(); err != nil {
+		t.Fatalf("close failed: %s", err)
+	}
+	inputs := store.recordedInputs()
+	if len(inputs) != 1 {
+		t.Fatalf("Expect only the event with a job to be written; got %v", inputs)
+	}
+	for _, expected := range []string{`"isActive":true`, `"jobResponse":{"id":"j1"}`} {
+		if !strings.Contains(inputs[0], expected) {
+			t.Errorf("Expect the input to contain %s; got %s", expected, inputs[0])
+		}
+	}
+}
+
+func TestEventLogWriterClosesAnEventWrittenBeforeARestart(t *testing.T) {
+	store := newFakeDataStore()
+	start := time.Date(2026, 10, 1, 8, 0, 0, 0, time.UTC)
+	store.responses["queryEventLog"] = jsonData("queryEventLog", []map[string]interface{}{
+		{"id": "e-other", "equipment": map[string]string{"id": "eq2"}},
+		{"id": "e1", "equipment": map[string]string{"id": "eq1"}},
+	})
+	store.responses["updateEventLog"] = jsonData("updateEventLog", map[string]interface{}{"numUids": 1})
+	writer := NewEventLogWriterGraphQL("eventLogWriterTest", store)
+	//restored from a snapshot taken before the id of the written start was recorded
+	restored := domain.EventLog{LocalId: "l1", Name: "Stopped", EquipmentId: "eq1", StartDateTime: start, JobResponseId: "j1"}
+	restored.Close(start.Add(time.Minute))
+	writer.EventEnded(restored)
+	if err := writer.Close(); err != nil {
+		t.Fatalf("close failed: %s", err)
+	}
+	queries, mutations := store.recorded()
+	if len(queries) != 1 || !strings.Contains(queries[0], "queryEventLog") {
+		t.Fatalf("Expect the open event to be looked up; got %v", queries)
+	}
+	if err := checkQueryShape(t, queries[0]); err != nil {
+		t.Errorf("Expect the lookup to match the schema: %s", err)
+	}
+	if len(mutations) != 1 || !strings.Contains(mutations[0], "updateEventLog") {
+		t.Fatalf("Expect the event found to be closed rather than written again; got %v", mutations)
+	}
+	if inputs := store.recordedInputs(); !strings.Contains(inputs[0], `"id":["e1"]`) {
+		t.Errorf("Expect the event of the equipment to be closed; got %s", inputs[0])
+	}
+}
@@ -0,0 +1,134 @@
+package utilities
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Spruik/libre-common/common/core/domain"
+	"github.com/Spruik/libre-common/common/core/services"
+	libreConfig "github.com/Spruik/libre-configuration"
+	libreLogger "github.com/Spruik/libre-logging"
+)
+
+//historianWriterDefault collects queued points into batches and writes them to the libre historian service on a
+//  background goroutine, so callers handling tag changes never wait on the historian.  A batch is written when it is
+//  full, or when it is older than the flush interval.  A batch is written in one call to the historian; one that
+//  fails is tried again as a whole, and dropped with an error after the last attempt.
+type historianWriterDefault struct {
+	//inherit logging functions
+	libreLogger.LoggingEnabler
+
+	//inherit config functions
+	libreConfig.ConfigurationEnabler
+
+	queuedWriter
+
+	batchSize     int
+	flushInterval time.Duration
+	queue         chan domain.HistorianPoint
+}
+
+const (
+	defaultHistorianBatchSize     = 100
+	defaultHistorianFlushInterval = time.Second
+	defaultHistorianRetryAttempts = 3
+	defaultHistorianQueueSize     = 10000
+)
+
+//NewHistorianWriterDefault creates the writer and starts it.  Besides the retry, close and queue settings of a
+//  queuedWriter, the configuration category may contain:
+//  BATCH_SIZE - how many points are written together
+//  FLUSH_INTERVAL - how long a batch that is not full waits before it is written
+func NewHistorianWriterDefault(configHook string) *historianWriterDefault {
+	s := historianWriterDefault{}
+	s.SetConfigCategory(configHook)
+	loggerHook, cerr := s.GetConfigItemWithDefault(domain.LOGGER_CONFIG_HOOK_TOKEN, domain.DEFAULT_LOGGER_NAME)
+	if cerr != nil {
+		loggerHook = domain.DEFAULT_LOGGER_NAME
+	}
+	s.SetLoggerConfigHook(loggerHook)
+	if err := s.readConfig(); err != nil {
+		panic(fmt.Sprintf("FAILED IN CONFIGURATION SETUP FOR HISTORIAN WRITER - %s", err))
+	}
+	s.queue = make(chan domain.HistorianPoint, s.queueSize)
+	go s.writeQueued()
+	return &s
+}
+
+//readConfig reads the batch settings and those of the queued writer
+func (s *historianWriterDefault) readConfig() error {
+	var err error
+	if s.batchSize, err = getConfigIntWithDefault(&s.ConfigurationEnabler, "BATCH_SIZE", defaultHistorianBatchSize); err != nil {
+		return err
+	}
+	if s.batchSize < 1 {
+		s.batchSize = 1
+	}
+	if s.flushInterval, err = getConfigDurationWithDefault(&s.ConfigurationEnabler, "FLUSH_INTERVAL", defaultHistorianFlushInterval); err != nil {
+		return err
+	}
+	if s.flushInterval <= 0 {
+		return fmt.Errorf("FLUSH_INTERVAL must be positive, got %s", s.flushInterval)
+	}
+	return s.configure(&s.ConfigurationEnabler, &s.LoggingEnabler, "Historian writer", defaultHistorianRetryAttempts, defaultHistorianQueueSize)
+}
+
+//WritePoint never blocks; when the queue is full or closed the point is dropped with a warning
+func (s *historianWriterDefault) WritePoint(point domain.HistorianPoint) {
+	s.offer("a point of "+point.Measurement, func() bool {
+		select {
+		case s.queue <- point:
+			return true
+		default:
+			return false
+		}
+	})
+}
+
+//Close stops taking points and waits up to the close timeout for the queued points to be written
+func (s *historianWriterDefault) Close() error {
+	return s.closeAndWait(func() { close(s.queue) }, func() int { return len(s.queue) })
+}
+
+func (s *historianWriterDefault) writeQueued() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+	batch := make([]domain.HistorianPoint, 0, s.batchSize)
+	for {
+		select {
+		case point, open := <-s.queue:
+			if !open {
+				s.writeBatch(batch)
+				return
+			}
+			batch = append(batch, point)
+			if len(batch) >= s.batchSize {
+				s.writeBatch(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				s.writeBatch(batch)
+				batch = batch[:0]
+			}
+		}
+	}
+}
+
+//writeBatch writes the points, trying again until they are written or out of attempts
+func (s *historianWriterDefault) writeBatch(batch []domain.HistorianPoint) {
+	if len(batch) == 0 {
+		return
+	}
+	s.writeWithRetries(fmt.Sprintf("%d historian points", len(batch)), func() error { return s.writePoints(batch) })
+}
+
+//writePoints writes the points with one call to the historian
+func (s *historianWriterDefault) writePoints(points []domain.HistorianPoint) error {
+	historian := services.GetLibreHistorianServiceInstance()
+	if historian == nil {
+		return fmt.Errorf("no historian service is set")
+	}
+	return historian.AddDataPointsRaw(points)
+}
@@ -0,0 +1,117 @@
+package utilities
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Spruik/libre-common/common/core/domain"
+	"github.com/Spruik/libre-common/common/core/services"
+	"github.com/influxdata/influxdb-client-go/v2/api"
+)
+
+//fakeHistorian records the measurements of each call that writes points, failing the first calls it is told to
+type fakeHistorian struct {
+	mutex    sync.Mutex
+	failures int
+	calls    [][]string
+}
+
+func (h *fakeHistorian) Connect() error {
+	return nil
+}
+
+func (h *fakeHistorian) Close() error {
+	return nil
+}
+
+func (h *fakeHistorian) AddDataPointRaw(measurement string, tags map[string]string, fields map[string]interface{}, ts time.Time) error {
+	return h.AddDataPointsRaw([]domain.HistorianPoint{{Measurement: measurement, Tags: tags, Fields: fields, Timestamp: ts}})
+}
+
+func (h *fakeHistorian) AddDataPointsRaw(points []domain.HistorianPoint) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	call := make([]string, 0, len(points))
+	for _, point := range points {
+		call = append(call, point.Measurement)
+	}
+	h.calls = append(h.calls, call)
+	if h.failures > 0 {
+		h.failures--
+		return fmt.Errorf("historian is unavailable")
+	}
+	return nil
+}
+
+func (h *fakeHistorian) AddEqPropDataPoint(measurement string, eqId string, eqName string, propId string, propName string, propValue interface{}, ts time.Time) error {
+	return h.AddDataPointRaw(measurement, map[string]string{"equipmentId": eqId}, map[string]interface{}{propName: propValue}, ts)
+}
+
+func (h *fakeHistorian) QueryRaw(query string) (*api.QueryTableResult, error) {
+	return nil, fmt.Errorf("not supported")
+}
+
+func (h *fakeHistorian) QueryRecentPointHistory(backTimeToken string, pointName string) (*api.QueryTableResult, error) {
+	return nil, fmt.Errorf("not supported")
+}
+
+func (h *fakeHistorian) QueryLatestFromPointHistory(pointName string) (*api.QueryTableResult, error) {
+	return nil, fmt.Errorf("not supported")
+}
+
+//newTestHistorianWriter starts a writer on a fake historian, which is set as the historian service until the test ends
+func newTestHistorianWriter(t *testing.T, batchSize int, failures int) (*historianWriterDefault, *fakeHistorian) {
+	historian := &fakeHistorian{failures: failures}
+	previous := services.GetLibreHistorianServiceInstance()
+	services.SetLibreHistorianServiceInstance(services.NewLibreHistorianService(historian))
+	t.Cleanup(func() { services.SetLibreHistorianServiceInstance(previous) })
+	s := &historianWriterDefault{
+		queuedWriter: queuedWriter{
+			name:          "Historian writer",
+			retryAttempts: 3,
+			retryDelay:    time.Millisecond,
+			closeTimeout:  time.Second,
+			done:          make(chan struct{}),
+		},
+		batchSize:     batchSize,
+		flushInterval: time.Hour,
+		queue:         make(chan domain.HistorianPoint, 100),
+	}
+	s.SetLoggerConfigHook("historianWriterTest")
+	s.logger = &s.LoggingEnabler
+	go s.writeQueued()
+	return s, historian
+}
+
+func TestHistorianWriterWritesEachBatchInOneCall(t *testing.T) {
+	writer, historian := newTestHistorianWriter(t, 3, 0)
+	for _, measurement := range []string{"a", "b", "c", "d", "e", "f", "g"} {
+		writer.WritePoint(domain.HistorianPoint{Measurement: measurement, Timestamp: time.Now()})
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("close failed: %s", err)
+	}
+	//the last batch is not full, and is written on close
+	expected := [][]string{{"a", "b", "c"}, {"d", "e", "f"}, {"g"}}
+	if !reflect.DeepEqual(historian.calls, expected) {
+		t.Errorf("Expect the calls %v; got %v", expected, historian.calls)
+	}
+}
+
+func TestHistorianWriterRetriesThenDropsABatch(t *testing.T) {
+	writer, historian := newTestHistorianWriter(t, 2, 4)
+	for _, measurement := range []string{"a", "b", "c", "d"} {
+		writer.WritePoint(domain.HistorianPoint{Measurement: measurement, Timestamp: time.Now()})
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("close failed: %s", err)
+	}
+	//the first batch is dropped after three attempts, the second is written on its second
+	expected := [][]string{{"a", "b"}, {"a", "b"}, {"a", "b"}, {"c", "d"}, {"c", "d"}}
+	if !reflect.DeepEqual(historian.calls, expected) {
+		t.Errorf("Expect the calls %v; got %v", expected, historian.calls)
+	}
+}
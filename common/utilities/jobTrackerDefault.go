@@ -0,0 +1,202 @@
+package utilities
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Spruik/libre-common/common/core/domain"
+	"github.com/Spruik/libre-common/common/core/ports"
+	"github.com/Spruik/libre-common/common/core/queries"
+	"github.com/Spruik/libre-common/common/core/services"
+	libreConfig "github.com/Spruik/libre-configuration"
+	libreLogger "github.com/Spruik/libre-logging"
+	"github.com/google/uuid"
+)
+
+//jobTrackerDefault keeps the running job of each equipment in memory and writes the JobResponse records through a
+//  dataStoreWriteQueue.  Jobs still running in the data store are picked up when it starts, so a restart does not
+//  lose them.
+type jobTrackerDefault struct {
+	//inherit logging functions
+	libreLogger.LoggingEnabler
+
+	//inherit config functions
+	libreConfig.ConfigurationEnabler
+
+	writes *dataStoreWriteQueue
+
+	mutex       sync.RWMutex
+	running     map[string]domain.JobResponse // by equipment id
+	ids         map[string]string             // data store id by local id
+	orderStarts map[string]bool               // event definition ids of the OrderStartRulesets
+}
+
+//NewJobTrackerDefault creates the tracker, loading the running jobs and the order start rulesets from the data
+//  store.  The configuration category may contain the RETRY_ATTEMPTS, RETRY_DELAY, CLOSE_TIMEOUT and QUEUE_SIZE
+//  settings of the write queue.
+func NewJobTrackerDefault(configHook string, storeIF ports.LibreDataStorePort) *jobTrackerDefault {
+	s := jobTrackerDefault{
+		running:     map[string]domain.JobResponse{},
+		ids:         map[string]string{},
+		orderStarts: map[string]bool{},
+	}
+	s.SetConfigCategory(configHook)
+	loggerHook, cerr := s.GetConfigItemWithDefault(domain.LOGGER_CONFIG_HOOK_TOKEN, domain.DEFAULT_LOGGER_NAME)
+	if cerr != nil {
+		loggerHook = domain.DEFAULT_LOGGER_NAME
+	}
+	s.SetLoggerConfigHook(loggerHook)
+	var err error
+	if s.writes, err = newDataStoreWriteQueue(&s.ConfigurationEnabler, &s.LoggingEnabler, storeIF, "jobTracker"); err != nil {
+		panic(fmt.Sprintf("FAILED IN CONFIGURATION SETUP FOR JOB TRACKER - %s", err))
+	}
+	s.load(storeIF)
+	return &s
+}
+
+func (s *jobTrackerDefault) load(storeIF ports.LibreDataStorePort) {
+	txn := storeIF.BeginTransaction(false, "jobTrackerInit")
+	defer txn.Dispose()
+	jobs, err := queries.GetRunningJobResponses(txn)
+	if err != nil {
+		s.LogErrorf("Failed to load the running jobs: %s", err)
+	}
+	for _, job := range jobs {
+		//if the data store has several running jobs for an equipment, the latest is the one running
+		if current, exists := s.running[job.EquipmentId]; !exists || job.StartDateTime.After(current.StartDateTime) {
+			job.LocalId = uuid.New().String()
+			s.running[job.EquipmentId] = job
+			s.ids[job.LocalId] = job.Id
+		}
+	}
+	defIds, err := queries.GetOrderStartEventDefIds(txn)
+	if err != nil {
+		s.LogErrorf("Failed to load the order start rulesets: %s", err)
+	}
+	for _, defId := range defIds {
+		s.orderStarts[defId] = true
+	}
+	s.LogInfof("Job tracker loaded %d running jobs and %d order start events", len(s.running), len(s.orderStarts))
+}
+
+func (s *jobTrackerDefault) StartJob(equipmentId string, jobOrder string, start time.Time) domain.JobResponse {
+	s.EndJob(equipmentId, start)
+	job := domain.NewJobResponse(uuid.New().String(), equipmentId, jobOrder, start)
+	s.mutex.Lock()
+	s.running[equipmentId] = job
+	s.mutex.Unlock()
+	s.LogInfof("Started job %s (%s) on %s", job.JobOrder, job.LocalId, equipmentId)
+	s.writes.enqueue(describeJob(job), func(txn ports.LibreDataStoreTransactionPort) error {
+		id, err := queries.AddJobResponse(txn, job)
+		if err != nil {
+			return err
+		}
+		txn.Commit()
+		s.setJobId(job.LocalId, id)
+		return nil
+	})
+	return job
+}
+
+func (s *jobTrackerDefault) EndJob(equipmentId string, end time.Time) (domain.JobResponse, bool) {
+	s.mutex.Lock()
+	job, exists := s.running[equipmentId]
+	delete(s.running, equipmentId)
+	s.mutex.Unlock()
+	if !exists {
+		return job, false
+	}
+	job.End(end)
+	s.LogInfof("Ended job %s (%s) on %s after %vs", job.JobOrder, job.LocalId, equipmentId, job.ActualDuration)
+	s.writes.enqueue(describeJob(job), func(txn ports.LibreDataStoreTransactionPort) error {
+		//the start was queued first, so the id is known unless its write was dropped
+		id, written := s.GetJobResponseId(job.LocalId)
+		var err error
+		if written {
+			err = queries.EndJobResponse(txn, id, job)
+		} else {
+			_, err = queries.AddJobResponse(txn, job)
+		}
+		if err != nil {
+			return err
+		}
+		txn.Commit()
+		return nil
+	})
+	return job, true
+}
+
+func (s *jobTrackerDefault) setJobId(localId string, id string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.ids[localId] = id
+	for eqId, job := range s.running {
+		if job.LocalId == localId {
+			job.Id = id
+			s.running[eqId] = job
+		}
+	}
+}
+
+func (s *jobTrackerDefault) GetCurrentJob(equipmentId string) (domain.JobResponse, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	job, exists := s.running[equipmentId]
+	return job, exists
+}
+
+func (s *jobTrackerDefault) GetJobResponseId(localId string) (string, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	id, exists := s.ids[localId]
+	return id, exists
+}
+
+func (s *jobTrackerDefault) IsOrderStartEvent(eventDefId string) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.orderStarts[eventDefId]
+}
+
+func describeJob(job domain.JobResponse) string {
+	return fmt.Sprintf("job %s (%s) on %s", job.JobOrder, job.LocalId, job.EquipmentId)
+}
+
+//currentJobRefs returns the ids of the job running on the equipment for linking records to it - the data store id
+//  once the job has been written, otherwise its local id
+func currentJobRefs(equipmentId string) (id string, localId string) {
+	tracker := services.GetJobTrackerServiceInstance()
+	if tracker == nil {
+		return "", ""
+	}
+	if job, running := tracker.GetCurrentJob(equipmentId); running {
+		if job.Id != "" {
+			return job.Id, ""
+		}
+		return "", job.LocalId
+	}
+	return "", ""
+}
+
+//canResolveJobResponseId is false for a record with no job, or with a job linked by local id but no job tracker to
+//  find it.  Such a record can never be written, as the schema requires a job response, so it is not queued.
+func canResolveJobResponseId(id string, localId string) bool {
+	return id != "" || (localId != "" && services.GetJobTrackerServiceInstance() != nil)
+}
+
+//resolveJobResponseId returns the data store id of the job of a record when it was linked by local id.  It fails
+//  while the job has not been written yet, so the queued write of the record is retried.
+func resolveJobResponseId(id string, localId string) (string, error) {
+	if id != "" || localId == "" {
+		return id, nil
+	}
+	tracker := services.GetJobTrackerServiceInstance()
+	if tracker == nil {
+		return "", fmt.Errorf("no job tracker to find the job %s", localId)
+	}
+	if jobId, written := tracker.GetJobResponseId(localId); written {
+		return jobId, nil
+	}
+	return "", fmt.Errorf("job %s has not been written yet", localId)
+}
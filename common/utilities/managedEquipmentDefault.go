@@ -1,12 +1,14 @@
 package utilities
 
 import (
+	"fmt"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/Spruik/libre-common/common/core/domain"
 	"github.com/Spruik/libre-common/common/core/ports"
-	"github.com/Spruik/libre-common/common/core/queries"
+	"github.com/Spruik/libre-common/common/core/services"
 	libreConfig "github.com/Spruik/libre-configuration"
 	libreLogger "github.com/Spruik/libre-logging"
 )
@@ -23,8 +25,19 @@ type managedEquipmentDefault struct {
 	RequestChannel chan domain.EquipmentServiceRequest
 	props          map[string]domain.EquipmentPropertyDescriptor
 	events         []domain.EquipmentEventDescriptor
+	maxEvents      int
+	openEvents     map[string]domain.EventLog
+	historySize    int
+	history        map[string]*domain.ValueHistory
+	calculated     *calculatedPropertySet
 }
 
+//defaultPropertyHistorySize is used when PROPERTY_HISTORY_SIZE is not configured
+const defaultPropertyHistorySize = 100
+
+//defaultMaxEvents is used when MAX_EVENTS is not configured
+const defaultMaxEvents = 200
+
 func NewManagedEquipmentDefault(configHook string, eqInst domain.Equipment, dataStore ports.LibreDataStorePort) *managedEquipmentDefault {
 	s := managedEquipmentDefault{
 		EquipInst:      eqInst,
@@ -32,6 +45,8 @@ func NewManagedEquipmentDefault(configHook string, eqInst domain.Equipment, data
 		RequestChannel: make(chan domain.EquipmentServiceRequest),
 		props:          map[string]domain.EquipmentPropertyDescriptor{},
 		events:         make([]domain.EquipmentEventDescriptor, 0),
+		openEvents:     map[string]domain.EventLog{},
+		history:        map[string]*domain.ValueHistory{},
 	}
 	s.SetConfigCategory(configHook)
 	loggerHook, cerr := s.GetConfigItemWithDefault(domain.LOGGER_CONFIG_HOOK_TOKEN, domain.DEFAULT_LOGGER_NAME)
@@ -39,10 +54,18 @@ func NewManagedEquipmentDefault(configHook string, eqInst domain.Equipment, data
 		loggerHook = domain.DEFAULT_LOGGER_NAME
 	}
 	s.SetLoggerConfigHook(loggerHook)
+	historySize, err := getConfigIntWithDefault(&s.ConfigurationEnabler, "PROPERTY_HISTORY_SIZE", defaultPropertyHistorySize)
+	if err != nil {
+		panic(fmt.Sprintf("FAILED IN CONFIGURATION SETUP FOR MANAGED EQUIPMENT - BAD CONFIG VALUE FOR 'PROPERTY_HISTORY_SIZE' [%v]", err))
+	}
+	s.historySize = historySize
+	maxEvents, err := getConfigIntWithDefault(&s.ConfigurationEnabler, "MAX_EVENTS", defaultMaxEvents)
+	if err != nil || maxEvents < 1 {
+		panic(fmt.Sprintf("FAILED IN CONFIGURATION SETUP FOR MANAGED EQUIPMENT - BAD CONFIG VALUE FOR 'MAX_EVENTS' [%v]", err))
+	}
+	s.maxEvents = maxEvents
 
-	txn := dataStore.BeginTransaction(false, "getProp"+s.GetEquipmentName())
-	defer txn.Dispose()
-	proplist, err := getAllPropertiesForEquipment(txn, eqInst.Id)
+	proplist, err := getResolvedProperties(dataStore, "getProp"+s.GetEquipmentName(), eqInst.Id)
 	if err == nil {
 		var props = map[string]domain.EquipmentPropertyDescriptor{}
 		var val interface{} = nil
@@ -51,66 +74,88 @@ func NewManagedEquipmentDefault(configHook string, eqInst domain.Equipment, data
 			if err != nil {
 				s.LogErrorf("Failed data format conversion for property %s with value string %s.  Error=%s", prop.Name, prop.Value, err)
 			}
-			clsPropId := ""
-			if prop.EquipmentClass.Id != "" {
-				clsPropId = prop.Id
-			}
-			props[name] = domain.EquipmentPropertyDescriptor{
-				Name:             name,
-				DataType:         prop.DataType,
-				Value:            val,
-				ClassPropertyId:  clsPropId,
-				EquipmentClassId: prop.EquipmentClass.Id,
-				LastUpdate:       time.Time{},
-			}
+			props[name] = newEquipmentPropertyDescriptor(name, prop, val)
 		}
 		s.props = props
 	}
+	s.loadCalculatedProperties()
 	return &s
 }
 
-func getAllPropertiesForEquipment(txn ports.LibreDataStoreTransactionPort, eqId string) (map[string]domain.Property, error) {
-	//need to look for properties attached to the Equipment and to it's equipment class (and equipment class parents)
-	var fullPropertyList = map[string]domain.Property{}
-	//first check equipment
-	var eqInst domain.Equipment
-	var err error = nil
-	eqInst, err = queries.GetEquipmentById(txn, eqId)
-	if err == nil {
-		for _, p := range eqInst.Properties {
-			fullPropertyList[p.Name] = p
-		}
-
-		//now work up through the equipment classes
-		currEqcId := eqInst.EquipmentClass.Id
-		var eqcInst domain.EquipmentClassPropertiesAndParent
-		for currEqcId != "" {
-			eqcInst, err = queries.GetEquipmentClassPropertiesAndParentById(txn, currEqcId)
-			if err == nil {
-				for _, p := range eqcInst.Properties {
-					fullPropertyList[p.Name] = p
-				}
-				currEqcId = eqcInst.Parent.Id
-			} else {
-				currEqcId = ""
+//loadCalculatedProperties parses the expressions of the CALCULATED properties and orders them by their inputs
+//  The caller must hold the mutex once the equipment is in use
+func (s *managedEquipmentDefault) loadCalculatedProperties() {
+	s.calculated = nil
+	expressions := map[string]string{}
+	for name, prop := range s.props {
+		if prop.Type == domain.PropertyTypeCalculated {
+			if prop.Expression == "" {
+				s.LogErrorf("Calculated property %s of equipment %s has no expression", name, s.EquipInst.Name)
+				continue
 			}
+			expressions[name] = prop.Expression
 		}
 	}
-	return fullPropertyList, err
+	if len(expressions) == 0 {
+		return
+	}
+	var errs []error
+	s.calculated, errs = newCalculatedPropertySet(expressions)
+	for _, err := range errs {
+		s.LogErrorf("Equipment %s: %s", s.EquipInst.Name, err)
+	}
+	s.LogInfof("Equipment %s has %d calculated properties", s.EquipInst.Name, len(s.calculated.graph.Order()))
+}
+
+//newEquipmentPropertyDescriptor builds the run time descriptor of a resolved property; a property without a
+//  value has a Bad quality until it is updated
+func newEquipmentPropertyDescriptor(name string, prop domain.ResolvedProperty, value interface{}) domain.EquipmentPropertyDescriptor {
+	clsPropId := ""
+	if prop.Sources.Definition == domain.PropertySourceClass {
+		clsPropId = prop.Id
+	}
+	quality := domain.Good
+	if value == nil {
+		quality = domain.Bad
+	}
+	return domain.EquipmentPropertyDescriptor{
+		Name:             name,
+		DataType:         prop.DataType,
+		Type:             prop.Type,
+		Expression:       prop.Expression,
+		Address:          prop.Address,
+		StoreHistory:     prop.StoreHistory,
+		UnitOfMeasure:    prop.UnitOfMeasure.Code,
+		Value:            value,
+		Quality:          quality,
+		ClassPropertyId:  clsPropId,
+		EquipmentClassId: prop.Sources.ClassId,
+		LastUpdate:       time.Time{},
+		Sources:          prop.Sources,
+	}
 }
 
 func (s *managedEquipmentDefault) UpdatePropertyValue(propName string, propValue interface{}) error {
+	return s.UpdatePropertyValueAndQuality(propName, propValue, domain.Good)
+}
+
+func (s *managedEquipmentDefault) UpdatePropertyValueAndQuality(propName string, propValue interface{}, quality domain.TagQuality) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	pd, exists := s.props[propName]
 	if exists {
 		val, err := domain.ConvertPropertyValueStringToTypedValue(pd.DataType, propValue)
 		if err == nil {
+			if quality != pd.Quality {
+				s.LogInfof("Property quality: %s of equipment %s is %s (%d), was %s", propName, s.EquipInst.Name, quality, int(quality), pd.Quality)
+			}
 			pd.Value = val
+			pd.Quality = quality
 			pd.LastUpdate = time.Now()
 			s.props[propName] = pd
+			s.recordHistory(propName, val, pd.LastUpdate)
 		}
-		s.LogInfof("Property update: %s %+v (%T) @ %s", propName, pd.Value, pd.Value, pd.LastUpdate)
+		s.LogInfof("Property update: %s %+v (%T) %s @ %s", propName, pd.Value, pd.Value, pd.Quality, pd.LastUpdate)
 	} else {
 		//choosing to ignore updates for unknown properties (event evaluator attempts to update payload references)
 		s.LogDebugf("ignoring update request for unknown property: %s=%s", propName, propValue)
@@ -121,12 +166,80 @@ func (s *managedEquipmentDefault) UpdatePropertyValue(propName string, propValue
 func (s *managedEquipmentDefault) AddEvent(eventName string, eventDesc domain.EquipmentEventDescriptor) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	_ = eventName
-	//TODO - figure out event strucuture (start/end?)
+	if eventDesc.Name == "" {
+		eventDesc.Name = eventName
+	}
+	s.appendEvent(eventDesc)
+	return nil
+}
+
+//appendEvent keeps only the newest MAX_EVENTS events, as the list is carried in every snapshot.  The mutex must be
+//  held by the caller.
+func (s *managedEquipmentDefault) appendEvent(eventDesc domain.EquipmentEventDescriptor) {
 	s.events = append(s.events, eventDesc)
+	s.trimEvents()
+}
+
+func (s *managedEquipmentDefault) trimEvents() {
+	if excess := len(s.events) - s.maxEvents; excess > 0 {
+		s.events = append([]domain.EquipmentEventDescriptor{}, s.events[excess:]...)
+	}
+}
+
+//OpenEventLog keeps at most one open event per event definition
+func (s *managedEquipmentDefault) OpenEventLog(log domain.EventLog) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if open, exists := s.openEvents[log.EventDefinitionId]; exists {
+		return fmt.Errorf("event %s of equipment %s is already open since %s", open.Name, s.EquipInst.Name, open.StartDateTime)
+	}
+	s.openEvents[log.EventDefinitionId] = log
 	return nil
 }
 
+func (s *managedEquipmentDefault) GetOpenEventLogs() []domain.EventLog {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ret := make([]domain.EventLog, 0, len(s.openEvents))
+	for _, log := range s.openEvents {
+		ret = append(ret, log)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].StartDateTime.Before(ret[j].StartDateTime)
+	})
+	return ret
+}
+
+//CloseEventLog also adds the closed event to the event list of the equipment
+func (s *managedEquipmentDefault) CloseEventLog(eventDefinitionId string, end time.Time) (domain.EventLog, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	log, exists := s.openEvents[eventDefinitionId]
+	if !exists {
+		return domain.EventLog{}, false
+	}
+	delete(s.openEvents, eventDefinitionId)
+	log.Close(end)
+	s.appendEvent(domain.EquipmentEventDescriptor{
+		Name:   log.Name,
+		Time:   log.StartDateTime,
+		Params: log.Params,
+	})
+	return log, true
+}
+
+func (s *managedEquipmentDefault) SetEventLogId(localId string, id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for defId, log := range s.openEvents {
+		if log.LocalId == localId {
+			log.Id = id
+			s.openEvents[defId] = log
+			return
+		}
+	}
+}
+
 func (s *managedEquipmentDefault) GetPropertyValue(propName string) interface{} {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -138,6 +251,58 @@ func (s *managedEquipmentDefault) GetProperty(name string) domain.EquipmentPrope
 	return s.props[name]
 }
 
+//recordHistory adds the value to the history of the property.  The caller must hold the mutex.
+func (s *managedEquipmentDefault) recordHistory(propName string, value interface{}, at time.Time) {
+	if s.historySize <= 0 {
+		return
+	}
+	hist, exists := s.history[propName]
+	if !exists {
+		hist = domain.NewValueHistory(s.historySize)
+		s.history[propName] = hist
+	}
+	hist.Add(value, at)
+}
+
+func (s *managedEquipmentDefault) GetPropertyHistory(propName string, n int) []domain.TimedValue {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if hist, exists := s.history[propName]; exists {
+		return hist.Last(n)
+	}
+	return []domain.TimedValue{}
+}
+
+func (s *managedEquipmentDefault) GetPropertyValueAt(propName string, at time.Time) (interface{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if hist, exists := s.history[propName]; exists {
+		return hist.ValueAt(at)
+	}
+	return nil, false
+}
+
+func (s *managedEquipmentDefault) GetPropertyWindowStats(propName string, window time.Duration) (domain.ValueWindowStats, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	hist, exists := s.history[propName]
+	if !exists {
+		return domain.ValueWindowStats{}, fmt.Errorf("no history for property %s of equipment %s", propName, s.EquipInst.Name)
+	}
+	now := time.Now()
+	return hist.WindowStats(now.Add(-window), now)
+}
+
+func (s *managedEquipmentDefault) GetPropertyDurationInValue(propName string, value interface{}, window time.Duration) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if hist, exists := s.history[propName]; exists {
+		now := time.Now()
+		return hist.DurationInValue(value, now.Add(-window), now)
+	}
+	return 0
+}
+
 func (s *managedEquipmentDefault) SetConfigLevel(level int) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -172,28 +337,77 @@ func (s *managedEquipmentDefault) GetEquipmentLevel() string {
 	return string(s.EquipInst.EquipmentLevel)
 }
 
+func (s *managedEquipmentDefault) GetEquipmentClassId() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.EquipInst.EquipmentClass.Id
+}
+
+func (s *managedEquipmentDefault) GetEquipmentClassName() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.EquipInst.EquipmentClass.Name
+}
+
+func (s *managedEquipmentDefault) UpdateEquipmentDefinition(eqInst domain.Equipment) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.EquipInst = eqInst
+}
+
+func (s *managedEquipmentDefault) SetPropertyDefinition(desc domain.EquipmentPropertyDescriptor) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if curr, exists := s.props[desc.Name]; exists && curr.DataType == desc.DataType {
+		desc.Value = curr.Value
+		desc.Quality = curr.Quality
+		desc.LastUpdate = curr.LastUpdate
+	} else {
+		//the history of the old data type can't be compared with new values
+		delete(s.history, desc.Name)
+	}
+	s.props[desc.Name] = desc
+	s.loadCalculatedProperties()
+}
+
+func (s *managedEquipmentDefault) RemoveProperty(propName string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.props, propName)
+	delete(s.history, propName)
+	s.loadCalculatedProperties()
+}
+
 func (s *managedEquipmentDefault) SendRequest(request domain.EquipmentServiceRequest) domain.EquipmentServiceRequest {
 	s.RequestChannel <- request
 	ack := <-s.RequestChannel
 	return ack
 }
 
-func (s *managedEquipmentDefault) AcceptRequest(tagChangeHandlers *[]ports.TagChangeHandlerPort) bool {
+//AcceptRequest acknowledges every request, including one whose processing panics, so the sender waiting on the
+//  channel always gets the ack of its own request
+func (s *managedEquipmentDefault) AcceptRequest(tagChangeHandlers *[]ports.TagChangeHandlerPort) (keepRunning bool) {
 	rqst := <-s.RequestChannel
 	s.LogDebugf("Managed equipment %s received request through channel: %+v", s.EquipInst.Name, rqst)
+	defer func() {
+		if r := recover(); r != nil {
+			s.LogErrorf("Managed equipment %s failed to process %s request: %+v", s.EquipInst.Name, rqst.ServiceType, r)
+			s.RequestChannel <- domain.EquipmentServiceRequest{
+				ServiceType: domain.SVCRQST_ERROR_ACK,
+				Time:        time.Now(),
+				Message:     fmt.Sprintf("%s request failed: %+v", rqst.ServiceType, r),
+			}
+			//the sender has its answer; the runner restarts the processing thread
+			panic(r)
+		}
+	}()
 	switch rqst.ServiceType {
 	case domain.SVCRQST_TAGDATA:
 
-		var ackMsg string
-		handlerContext := make(map[string]interface{})
 		rqst.TagInfo.OwningAssetId = s.EquipInst.Id
-		for _, handler := range *tagChangeHandlers {
-			err := handler.HandleTagChange(rqst.TagInfo, &handlerContext)
-			if err != nil {
-				s.LogErrorf("Failed to update Equipment Property from tag %+v with error: %s", rqst.TagInfo, err)
-			}
-			ackMsg += handler.GetAckMessage(err)
-		}
+		rqst.TagInfo = s.normaliseUnits(rqst.TagInfo)
+		ackMsg := s.runTagChangeHandlers(rqst.TagInfo, tagChangeHandlers)
+		ackMsg += s.recomputeCalculatedProperties(rqst.TagInfo.ItemName, tagChangeHandlers)
 		s.RequestChannel <- domain.EquipmentServiceRequest{
 			ServiceType: domain.SVCRQST_TAGDATA_ACK,
 			Time:        time.Now(),
@@ -211,14 +425,229 @@ func (s *managedEquipmentDefault) AcceptRequest(tagChangeHandlers *[]ports.TagCh
 			TagInfo:     domain.StdMessageStruct{},
 		}
 		return false
+
+	default:
+
+		s.RequestChannel <- domain.EquipmentServiceRequest{
+			ServiceType: domain.SVCRQST_ERROR_ACK,
+			Time:        time.Now(),
+			Message:     fmt.Sprintf("unknown request type '%s'", rqst.ServiceType),
+		}
 	}
 	return true
 }
 
+func (s *managedEquipmentDefault) Snapshot() domain.EquipmentSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snapshot := domain.EquipmentSnapshot{
+		EquipmentId:   s.EquipInst.Id,
+		EquipmentName: s.EquipInst.Name,
+		Taken:         time.Now(),
+		Properties:    make([]domain.PropertySnapshot, 0, len(s.props)),
+		Events:        append([]domain.EquipmentEventDescriptor{}, s.events...),
+	}
+	for _, log := range s.openEvents {
+		snapshot.OpenEvents = append(snapshot.OpenEvents, log)
+	}
+	for _, prop := range s.props {
+		snapshot.Properties = append(snapshot.Properties, domain.NewPropertySnapshot(prop))
+	}
+	return snapshot
+}
+
+//RestoreSnapshot only restores properties that still exist with the same data type - the model may have changed
+//  while the equipment was not running
+func (s *managedEquipmentDefault) RestoreSnapshot(snapshot domain.EquipmentSnapshot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if snapshot.EquipmentId != s.EquipInst.Id {
+		return fmt.Errorf("snapshot of equipment %s cannot be restored to equipment %s", snapshot.EquipmentId, s.EquipInst.Id)
+	}
+	restored := 0
+	for _, propSnap := range snapshot.Properties {
+		pd, exists := s.props[propSnap.Name]
+		if !exists || pd.DataType != propSnap.DataType || propSnap.LastUpdate.IsZero() {
+			continue
+		}
+		val, err := propSnap.TypedValue()
+		if err != nil {
+			s.LogWarnf("Skipping snapshot value of property %s for equipment %s: %s", propSnap.Name, s.EquipInst.Name, err)
+			continue
+		}
+		pd.Value = val
+		pd.Quality = propSnap.TagQuality()
+		pd.LastUpdate = propSnap.LastUpdate
+		s.props[propSnap.Name] = pd
+		s.recordHistory(propSnap.Name, val, pd.LastUpdate)
+		restored++
+	}
+	s.events = append([]domain.EquipmentEventDescriptor{}, snapshot.Events...)
+	s.trimEvents()
+	//events left open at shutdown stay open, so they close with their true duration
+	s.openEvents = map[string]domain.EventLog{}
+	for _, log := range snapshot.OpenEvents {
+		s.openEvents[log.EventDefinitionId] = log
+	}
+	s.LogInfof("Restored %d property values, %d events and %d open events for equipment %s from snapshot taken %s", restored, len(s.events), len(s.openEvents), s.EquipInst.Name, snapshot.Taken)
+	return nil
+}
+
+//normaliseUnits converts a tag value reported in another unit to the unit of the property.  The source unit is the
+//  ItemUoM of the tag, or a UOM token parsed from the topic; a tag without a unit is taken to be in the property unit.
+func (s *managedEquipmentDefault) normaliseUnits(tagData domain.StdMessageStruct) domain.StdMessageStruct {
+	s.mu.Lock()
+	propUoM := s.props[tagData.ItemName].UnitOfMeasure
+	s.mu.Unlock()
+	srcUoM := tagData.ItemUoM
+	if srcUoM == "" && tagData.ItemNameExt != nil {
+		srcUoM = tagData.ItemNameExt["UOM"]
+	}
+	if propUoM == "" || srcUoM == "" || domain.NormaliseUnitCode(srcUoM) == domain.NormaliseUnitCode(propUoM) {
+		if propUoM != "" {
+			tagData.ItemUoM = propUoM
+		} else {
+			tagData.ItemUoM = srcUoM
+		}
+		return tagData
+	}
+	converter := services.GetUnitOfMeasureConverterServiceInstance()
+	if converter == nil {
+		s.LogWarnf("Tag %s of equipment %s is in %s but the property is in %s, and no unit converter is set", tagData.ItemName, s.EquipInst.Name, srcUoM, propUoM)
+		return tagData
+	}
+	convert := func(value interface{}) (interface{}, error) {
+		if _, isBool := value.(bool); !isBool {
+			if num, ok := domain.ValueAsFloat64(value); ok {
+				return converter.Convert(num, srcUoM, propUoM)
+			}
+		}
+		return value, fmt.Errorf("value '%v' is not numeric", value)
+	}
+	newVal, err := convert(tagData.ItemValue)
+	if err != nil {
+		s.LogErrorf("Failed to convert tag %s of equipment %s from %s to %s: %s", tagData.ItemName, s.EquipInst.Name, srcUoM, propUoM, err)
+		return tagData
+	}
+	tagData.ItemValue = newVal
+	if tagData.ItemOldValue != nil {
+		if oldVal, err := convert(tagData.ItemOldValue); err == nil {
+			tagData.ItemOldValue = oldVal
+		}
+	}
+	tagData.ItemUoM = propUoM
+	return tagData
+}
+
+func (s *managedEquipmentDefault) runTagChangeHandlers(tagData domain.StdMessageStruct, tagChangeHandlers *[]ports.TagChangeHandlerPort) string {
+	var ackMsg string
+	handlerContext := make(map[string]interface{})
+	for _, handler := range *tagChangeHandlers {
+		err := s.invokeTagChangeHandler(handler, tagData, &handlerContext)
+		if err != nil {
+			s.LogErrorf("Failed to update Equipment Property from tag %+v with error: %s", tagData, err)
+		}
+		ackMsg += handler.GetAckMessage(err)
+	}
+	return ackMsg
+}
+
+//recomputeCalculatedProperties evaluates the calculated properties that depend on the changed property and passes
+//  each new value through the tag change handlers, as if it had arrived from the PLC.  A calculated value has the
+//  worst quality of its inputs, and is passed on again when only its quality changed.
+func (s *managedEquipmentDefault) recomputeCalculatedProperties(changed string, tagChangeHandlers *[]ports.TagChangeHandlerPort) string {
+	s.mu.Lock()
+	calculated := s.calculated
+	s.mu.Unlock()
+	affected := calculated.Affected(changed)
+	if len(affected) == 0 {
+		return ""
+	}
+	s.mu.Lock()
+	values := make(map[string]interface{}, len(s.props))
+	qualities := make(map[string]domain.TagQuality, len(s.props))
+	dataTypes := make(map[string]string, len(s.props))
+	units := make(map[string]string, len(s.props))
+	for name, prop := range s.props {
+		values[name] = prop.Value
+		qualities[name] = prop.Quality
+		dataTypes[name] = prop.DataType
+		units[name] = prop.UnitOfMeasure
+	}
+	s.mu.Unlock()
+
+	var ackMsg string
+	for _, name := range affected {
+		if missing := missingCalculationInput(calculated, name, values); missing != "" {
+			s.LogDebugf("Not computing %s of equipment %s - input %s has no value yet", name, s.EquipInst.Name, missing)
+			continue
+		}
+		newVal, err := calculated.Evaluate(name, values)
+		if err != nil {
+			s.LogErrorf("Failed to compute calculated property %s of equipment %s: %s", name, s.EquipInst.Name, err)
+			continue
+		}
+		quality := calculationQuality(calculated, name, qualities)
+		oldVal := values[name]
+		if oldVal != nil && domain.ValuesEqual(oldVal, newVal) && quality == qualities[name] {
+			continue
+		}
+		values[name] = newVal
+		qualities[name] = quality
+		ackMsg += s.runTagChangeHandlers(domain.StdMessageStruct{
+			OwningAsset:      s.EquipInst.Name,
+			OwningAssetId:    s.EquipInst.Id,
+			ItemName:         name,
+			ItemValue:        newVal,
+			ItemOldValue:     oldVal,
+			ItemDataType:     dataTypes[name],
+			ItemUoM:          units[name],
+			TagQuality:       int(quality),
+			ChangedTimestamp: time.Now(),
+			Category:         string(domain.PropertyTypeCalculated),
+		}, tagChangeHandlers)
+	}
+	return ackMsg
+}
+
+func missingCalculationInput(calculated *calculatedPropertySet, name string, values map[string]interface{}) string {
+	for _, input := range calculated.graph.Inputs(name) {
+		if values[input] == nil {
+			return input
+		}
+	}
+	return ""
+}
+
+//calculationQuality is the worst quality of the inputs of the calculated property
+func calculationQuality(calculated *calculatedPropertySet, name string, qualities map[string]domain.TagQuality) domain.TagQuality {
+	inputs := calculated.graph.Inputs(name)
+	inputQualities := make([]domain.TagQuality, 0, len(inputs))
+	for _, input := range inputs {
+		inputQualities = append(inputQualities, qualities[input])
+	}
+	return domain.WorstTagQuality(inputQualities...)
+}
+
+//invokeTagChangeHandler runs one handler, turning a panic into an error so the request is still acknowledged
+func (s *managedEquipmentDefault) invokeTagChangeHandler(handler ports.TagChangeHandlerPort, tagData domain.StdMessageStruct, handlerContext *map[string]interface{}) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("tag change handler panicked: %+v", r)
+		}
+	}()
+	return handler.HandleTagChange(tagData, handlerContext)
+}
+
+//GetPropertyMap returns a copy, so callers can range over it while tag changes are processed
 func (s *managedEquipmentDefault) GetPropertyMap() map[string]domain.EquipmentPropertyDescriptor {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	return s.props
+	ret := make(map[string]domain.EquipmentPropertyDescriptor, len(s.props))
+	for name, prop := range s.props {
+		ret[name] = prop
+	}
+	return ret
 }
 
 func (s *managedEquipmentDefault) GetEventList() *[]domain.EquipmentEventDescriptor {
@@ -0,0 +1,118 @@
+package utilities
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Spruik/libre-common/common/core/domain"
+	"github.com/Spruik/libre-common/common/core/ports"
+)
+
+//newTestManagedEquipment is an equipment without properties, for the handlers and filters that need one to run
+func newTestManagedEquipment() *ports.ManagedEquipmentPort {
+	var mgdEq ports.ManagedEquipmentPort = NewManagedEquipmentDefault("managedEquipmentTest", domain.Equipment{Id: "eq1", Name: "Filler1"}, newFakeDataStore())
+	return &mgdEq
+}
+
+//testTagChangeHandler panics while handling or acknowledging the changes of the properties it is told to
+type testTagChangeHandler struct {
+	panicHandling string
+	panicAcking   string
+	handled       []string
+	lastItem      string
+}
+
+func (h *testTagChangeHandler) Initialize() {}
+
+func (h *testTagChangeHandler) HandleTagChange(tagData domain.StdMessageStruct, handlerContext *map[string]interface{}) error {
+	h.lastItem = tagData.ItemName
+	if tagData.ItemName == h.panicHandling {
+		panic("handler failed")
+	}
+	h.handled = append(h.handled, tagData.ItemName)
+	return nil
+}
+
+func (h *testTagChangeHandler) GetAckMessage(err error) string {
+	if h.lastItem == h.panicAcking {
+		panic("ack failed")
+	}
+	if err != nil {
+		return err.Error()
+	}
+	return "handled"
+}
+
+//acceptRecovering runs AcceptRequest, reporting whether it panicked
+func acceptRecovering(mgdEq *managedEquipmentDefault, handlers *[]ports.TagChangeHandlerPort) (keepRunning bool, panicked bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			panicked = true
+		}
+	}()
+	return mgdEq.AcceptRequest(handlers), false
+}
+
+func TestAcceptRequestAcknowledgesAFailedRequest(t *testing.T) {
+	mgdEq := NewManagedEquipmentDefault("managedEquipmentTest", domain.Equipment{Id: "eq1", Name: "Filler1"}, newFakeDataStore())
+	handler := &testTagChangeHandler{panicHandling: "Bad", panicAcking: "Worse"}
+	handlers := &[]ports.TagChangeHandlerPort{handler}
+	type result struct{ keepRunning, panicked bool }
+	results := make(chan result, 1)
+	accept := func() {
+		keepRunning, panicked := acceptRecovering(mgdEq, handlers)
+		results <- result{keepRunning, panicked}
+	}
+
+	//a handler that panics fails on its own, and the request is still processed
+	go accept()
+	ack := mgdEq.SendRequest(domain.EquipmentServiceRequest{ServiceType: domain.SVCRQST_TAGDATA, Time: time.Now(), TagInfo: domain.StdMessageStruct{ItemName: "Bad"}})
+	if ack.ServiceType != domain.SVCRQST_TAGDATA_ACK || !strings.Contains(ack.Message, "panicked") {
+		t.Errorf("Expect the panic of the handler to be reported in the ack; got %+v", ack)
+	}
+	if r := <-results; !r.keepRunning || r.panicked {
+		t.Errorf("Expect the equipment to keep accepting requests after a handler panicked; got %+v", r)
+	}
+
+	//a panic outside the handlers is acknowledged with an error, then passed on for the runner to restart
+	go accept()
+	ack = mgdEq.SendRequest(domain.EquipmentServiceRequest{ServiceType: domain.SVCRQST_TAGDATA, Time: time.Now(), TagInfo: domain.StdMessageStruct{ItemName: "Worse"}})
+	if ack.ServiceType != domain.SVCRQST_ERROR_ACK || ack.Message == "" {
+		t.Errorf("Expect an error ack for a request that panicked; got %+v", ack)
+	}
+	if r := <-results; !r.panicked {
+		t.Errorf("Expect the panic to be passed on after the ack")
+	}
+
+	go accept()
+	ack = mgdEq.SendRequest(domain.EquipmentServiceRequest{ServiceType: "UNKNOWN", Time: time.Now()})
+	if ack.ServiceType != domain.SVCRQST_ERROR_ACK {
+		t.Errorf("Expect an error ack for an unknown request; got %+v", ack)
+	}
+	<-results
+	go accept()
+	ack = mgdEq.SendRequest(domain.EquipmentServiceRequest{ServiceType: domain.SVCRQST_SHUTDOWN, Time: time.Now()})
+	if r := <-results; ack.ServiceType != domain.SVCRQST_SHUTDOWN_ACK || r.keepRunning {
+		t.Errorf("Expect the shutdown to be acknowledged and end the processing; got %+v", ack)
+	}
+}
+
+func TestEventListKeepsTheNewestEvents(t *testing.T) {
+	mgdEq := NewManagedEquipmentDefault("managedEquipmentTest", domain.Equipment{Id: "eq1", Name: "Filler1"}, newFakeDataStore())
+	mgdEq.maxEvents = 3
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		_ = mgdEq.AddEvent("Stop", domain.EquipmentEventDescriptor{Time: start.Add(time.Duration(i) * time.Second)})
+	}
+	events := mgdEq.Snapshot().Events
+	if len(events) != 3 || !events[0].Time.Equal(start.Add(2*time.Second)) {
+		t.Fatalf("Expect the snapshot to hold the newest 3 events; got %+v", events)
+	}
+	_ = mgdEq.OpenEventLog(domain.EventLog{EventDefinitionId: "ed1", Name: "Run", StartDateTime: start.Add(10 * time.Second)})
+	mgdEq.CloseEventLog("ed1", start.Add(20*time.Second))
+	events = *mgdEq.GetEventList()
+	if len(events) != 3 || events[2].Name != "Run" {
+		t.Errorf("Expect a closed event log to push out the oldest event; got %+v", events)
+	}
+}
@@ -0,0 +1,285 @@
+package utilities
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Spruik/libre-common/common/core/domain"
+	"github.com/Spruik/libre-common/common/core/ports"
+	"github.com/Spruik/libre-common/common/core/services"
+	libreConfig "github.com/Spruik/libre-configuration"
+	libreLogger "github.com/Spruik/libre-logging"
+)
+
+//oeeCalculatorDefault keeps an OEETracker for each equipment that reports a machine state or a count.  Every tick
+//  it reads the work calendars and the ideal cycle time of the equipment, stores the results of the current hour,
+//  shift and rolling window in the equipment's "<prefix>.<period>.<factor>" properties (such as OEE.Shift.Availability)
+//  when the equipment has them, and writes the rolling results and every hour and shift that ended to the historian.
+//  Equipment that is in no work calendar is taken to be always in planned busy time.  The configuration may contain:
+//  TICK_INTERVAL - how often the results are computed (1m)
+//  ROLLING_WINDOW - the length of the rolling window (1h)
+//  IDEAL_CYCLE_TIME_PROPERTY - the equipment property holding the ideal cycle time in seconds (IdealCycleTime)
+//  PROPERTY_PREFIX - the prefix of the result properties (OEE)
+//  MEASUREMENT - the historian measurement of the results (OEE)
+type oeeCalculatorDefault struct {
+	//inherit logging functions
+	libreLogger.LoggingEnabler
+
+	//inherit config functions
+	libreConfig.ConfigurationEnabler
+
+	calendar               ports.CalendarPort
+	calendars              []domain.WorkCalendar // the last work calendars read; only used by tick
+	tickInterval           time.Duration
+	rollingWindow          time.Duration
+	idealCycleTimeProperty string
+	propertyPrefix         string
+	measurement            string
+
+	mutex     sync.Mutex
+	equipment map[string]*oeeEquipment // by equipment id
+	ticker    *time.Ticker
+	stop      chan bool
+}
+
+type oeeEquipment struct {
+	mgdEq   *ports.ManagedEquipmentPort
+	tracker *domain.OEETracker
+}
+
+//NewOEECalculatorDefault creates the calculator; without a calendar all equipment is always in planned busy time
+func NewOEECalculatorDefault(configHook string, calendar ports.CalendarPort) *oeeCalculatorDefault {
+	s := oeeCalculatorDefault{
+		calendar:  calendar,
+		equipment: map[string]*oeeEquipment{},
+	}
+	s.SetConfigCategory(configHook)
+	loggerHook, cerr := s.GetConfigItemWithDefault(domain.LOGGER_CONFIG_HOOK_TOKEN, domain.DEFAULT_LOGGER_NAME)
+	if cerr != nil {
+		loggerHook = domain.DEFAULT_LOGGER_NAME
+	}
+	s.SetLoggerConfigHook(loggerHook)
+	var err error
+	if s.tickInterval, err = getConfigDurationWithDefault(&s.ConfigurationEnabler, "TICK_INTERVAL", time.Minute); err == nil {
+		s.rollingWindow, err = getConfigDurationWithDefault(&s.ConfigurationEnabler, "ROLLING_WINDOW", time.Hour)
+	}
+	if err == nil && (s.tickInterval <= 0 || s.rollingWindow <= 0) {
+		err = fmt.Errorf("TICK_INTERVAL and ROLLING_WINDOW must be positive")
+	}
+	if err != nil {
+		panic(fmt.Sprintf("FAILED IN CONFIGURATION SETUP FOR OEE CALCULATOR - %s", err))
+	}
+	s.idealCycleTimeProperty = getConfigStringWithDefault(&s.ConfigurationEnabler, "IDEAL_CYCLE_TIME_PROPERTY", "IdealCycleTime")
+	s.propertyPrefix = getConfigStringWithDefault(&s.ConfigurationEnabler, "PROPERTY_PREFIX", "OEE")
+	s.measurement = getConfigStringWithDefault(&s.ConfigurationEnabler, "MEASUREMENT", "OEE")
+	return &s
+}
+
+//track returns the tracker of the equipment, creating it if needed; it must be called with the mutex held
+func (s *oeeCalculatorDefault) track(mgdEq *ports.ManagedEquipmentPort, at time.Time) *domain.OEETracker {
+	eqId := (*mgdEq).GetEquipmentId()
+	eq, exists := s.equipment[eqId]
+	if !exists {
+		eq = &oeeEquipment{mgdEq: mgdEq, tracker: domain.NewOEETracker(s.rollingWindow, at)}
+		eq.tracker.SetIdealCycleTime(s.idealCycleTime(mgdEq))
+		s.equipment[eqId] = eq
+	}
+	return eq.tracker
+}
+
+func (s *oeeCalculatorDefault) RecordMachineState(mgdEq *ports.ManagedEquipmentPort, state domain.MachineState, at time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.track(mgdEq, at).SetRunning(state == domain.MachineStateExecute, at)
+}
+
+func (s *oeeCalculatorDefault) RecordCount(mgdEq *ports.ManagedEquipmentPort, total float64, defective float64, at time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.track(mgdEq, at).AddCount(total, defective, at)
+}
+
+func (s *oeeCalculatorDefault) GetOEE(equipmentId string, kind domain.OEEPeriodKind) (domain.OEEPeriod, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if eq, exists := s.equipment[equipmentId]; exists {
+		return eq.tracker.Current(kind)
+	}
+	return domain.OEEPeriod{}, false
+}
+
+func (s *oeeCalculatorDefault) Start() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.ticker != nil {
+		return fmt.Errorf("the OEE calculator is already started")
+	}
+	s.ticker = time.NewTicker(s.tickInterval)
+	s.stop = make(chan bool)
+	go func(ticker *time.Ticker, stop chan bool) {
+		for {
+			select {
+			case <-stop:
+				return
+			case now := <-ticker.C:
+				s.tick(now)
+			}
+		}
+	}(s.ticker, s.stop)
+	s.LogInfof("OEE calculator started with a tick of %s and a rolling window of %s", s.tickInterval, s.rollingWindow)
+	return nil
+}
+
+func (s *oeeCalculatorDefault) Stop() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.ticker == nil {
+		return
+	}
+	s.ticker.Stop()
+	close(s.stop)
+	s.ticker = nil
+	s.LogInfo("OEE calculator stopped")
+}
+
+//oeeResults are the results of one equipment gathered under the mutex, published after it is released
+type oeeResults struct {
+	mgdEq   *ports.ManagedEquipmentPort
+	current []domain.OEEPeriod
+	ended   []domain.OEEPeriod
+}
+
+func (s *oeeCalculatorDefault) tick(now time.Time) {
+	if s.calendar != nil {
+		//keep the calendars already read when they cannot be read again
+		if calendars, err := s.calendar.GetAllActiveWorkCalendar(); err != nil {
+			s.LogErrorf("OEE calculator failed to get the work calendars: %s", err)
+		} else {
+			s.calendars = calendars
+		}
+	}
+	s.mutex.Lock()
+	results := make([]oeeResults, 0, len(s.equipment))
+	for eqId, eq := range s.equipment {
+		planned, shift := s.plannedAt(s.calendars, eqId, now)
+		eq.tracker.SetIdealCycleTime(s.idealCycleTime(eq.mgdEq))
+		eq.tracker.SetPlanned(planned, shift, now)
+		eq.tracker.Advance(now)
+		result := oeeResults{mgdEq: eq.mgdEq, ended: eq.tracker.TakeEnded()}
+		for _, kind := range domain.OEEPeriodKinds {
+			if period, open := eq.tracker.Current(kind); open {
+				result.current = append(result.current, period)
+			}
+		}
+		results = append(results, result)
+	}
+	s.mutex.Unlock()
+	for _, result := range results {
+		s.publish(result)
+	}
+}
+
+//plannedAt finds whether the equipment is in planned busy time and the name of its shift, which is the description
+//  of the busy time entries it is in.  Equipment in no active work calendar is always in planned busy time.
+func (s *oeeCalculatorDefault) plannedAt(calendars []domain.WorkCalendar, equipmentId string, at time.Time) (bool, string) {
+	inCalendar := false
+	entryType := domain.PlannedShutdown
+	var shifts []string
+	for i := range calendars {
+		calendar := &calendars[i]
+		if !calendar.IsActive || !calendarHasEquipment(calendar, equipmentId) {
+			continue
+		}
+		inCalendar = true
+		entries, err := calendar.GetEntriesAtTime(at.UTC())
+		if err != nil {
+			s.LogErrorf("OEE calculator failed to get the entries of work calendar %s: %s", calendar.Name, err)
+			continue
+		}
+		for _, entry := range entries {
+			_, entryType = domain.CompareWorkCalendarEntryType(entryType, entry.EntryType)
+			if entry.EntryType == domain.PlannedBusyTime && entry.Description != "" {
+				shifts = append(shifts, entry.Description)
+			}
+		}
+	}
+	if !inCalendar {
+		return true, ""
+	}
+	return entryType == domain.PlannedBusyTime, strings.Join(shifts, ", ")
+}
+
+func calendarHasEquipment(calendar *domain.WorkCalendar, equipmentId string) bool {
+	for _, eq := range calendar.Equipment {
+		if eq.Id == equipmentId {
+			return true
+		}
+	}
+	return false
+}
+
+//idealCycleTime reads the ideal cycle time property of the equipment, in seconds
+func (s *oeeCalculatorDefault) idealCycleTime(mgdEq *ports.ManagedEquipmentPort) time.Duration {
+	if _, defined := (*mgdEq).GetPropertyMap()[s.idealCycleTimeProperty]; !defined {
+		return 0
+	}
+	seconds, isNumber := domain.ValueAsFloat64((*mgdEq).GetPropertyValue(s.idealCycleTimeProperty))
+	if !isNumber || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
+
+func (s *oeeCalculatorDefault) publish(result oeeResults) {
+	properties := (*result.mgdEq).GetPropertyMap()
+	for _, period := range result.current {
+		r := period.Measures.Result()
+		prefix := s.propertyPrefix + "." + string(period.Kind) + "."
+		for name, value := range map[string]float64{"Availability": r.Availability, "Performance": r.Performance, "Quality": r.Quality, "OEE": r.OEE} {
+			if _, defined := properties[prefix+name]; defined {
+				if err := (*result.mgdEq).UpdatePropertyValue(prefix+name, value); err != nil {
+					s.LogErrorf("Failed to update %s of %s: %s", prefix+name, (*result.mgdEq).GetEquipmentName(), err)
+				}
+			}
+		}
+		if period.Kind == domain.OEEPeriodRolling {
+			s.writeHistory(result.mgdEq, period)
+		}
+	}
+	for _, period := range result.ended {
+		s.writeHistory(result.mgdEq, period)
+	}
+}
+
+//writeHistory writes the results of a period to the historian, at the end of the period
+func (s *oeeCalculatorDefault) writeHistory(mgdEq *ports.ManagedEquipmentPort, period domain.OEEPeriod) {
+	historian := services.GetLibreHistorianServiceInstance()
+	if historian == nil {
+		return
+	}
+	r := period.Measures.Result()
+	tags := map[string]string{
+		"equipmentId":   (*mgdEq).GetEquipmentId(),
+		"equipmentName": (*mgdEq).GetEquipmentName(),
+		"period":        string(period.Kind),
+	}
+	if period.Kind == domain.OEEPeriodShift {
+		tags["shift"] = period.Name
+	}
+	fields := map[string]interface{}{
+		"availability":   r.Availability,
+		"performance":    r.Performance,
+		"quality":        r.Quality,
+		"oee":            r.OEE,
+		"plannedTime":    period.Measures.PlannedTime.Seconds(),
+		"runTime":        period.Measures.RunTime.Seconds(),
+		"totalCount":     period.Measures.TotalCount,
+		"defectiveCount": period.Measures.DefectiveCount,
+		"periodStart":    period.Start.UTC().Format(time.RFC3339),
+	}
+	if err := historian.AddDataPointRaw(s.measurement, tags, fields, period.End); err != nil {
+		s.LogErrorf("Failed to write the %s OEE of %s to the historian: %s", period.Kind, (*mgdEq).GetEquipmentName(), err)
+	}
+}
@@ -0,0 +1,63 @@
+package utilities
+
+import (
+	"fmt"
+
+	"github.com/Spruik/libre-common/common/core/domain"
+	"github.com/Spruik/libre-common/common/core/ports"
+	"github.com/Spruik/libre-common/common/core/queries"
+	libreConfig "github.com/Spruik/libre-configuration"
+	libreLogger "github.com/Spruik/libre-logging"
+)
+
+//quantityLogWriterGraphQL writes QuantityLog records with GraphQL mutations through a dataStoreWriteQueue
+type quantityLogWriterGraphQL struct {
+	//inherit logging functions
+	libreLogger.LoggingEnabler
+
+	//inherit config functions
+	libreConfig.ConfigurationEnabler
+
+	writes *dataStoreWriteQueue
+}
+
+//NewQuantityLogWriterGraphQL creates the writer and starts its queue.  The configuration category may contain the
+//  RETRY_ATTEMPTS, RETRY_DELAY, CLOSE_TIMEOUT and QUEUE_SIZE settings of the write queue.
+func NewQuantityLogWriterGraphQL(configHook string, storeIF ports.LibreDataStorePort) *quantityLogWriterGraphQL {
+	s := quantityLogWriterGraphQL{}
+	s.SetConfigCategory(configHook)
+	loggerHook, cerr := s.GetConfigItemWithDefault(domain.LOGGER_CONFIG_HOOK_TOKEN, domain.DEFAULT_LOGGER_NAME)
+	if cerr != nil {
+		loggerHook = domain.DEFAULT_LOGGER_NAME
+	}
+	s.SetLoggerConfigHook(loggerHook)
+	var err error
+	if s.writes, err = newDataStoreWriteQueue(&s.ConfigurationEnabler, &s.LoggingEnabler, storeIF, "quantityLogWriter"); err != nil {
+		panic(fmt.Sprintf("FAILED IN CONFIGURATION SETUP FOR QUANTITY LOG WRITER - %s", err))
+	}
+	return &s
+}
+
+//WriteQuantityLog queues the write of the quantity.  A quantity without a job is skipped, as the schema requires one.
+func (s *quantityLogWriterGraphQL) WriteQuantityLog(log domain.QuantityLog) {
+	description := fmt.Sprintf("quantity %v %s of %s for %s", log.Quantity, log.Type, log.Property, log.EquipmentId)
+	if !canResolveJobResponseId(log.JobResponseId, log.JobLocalId) {
+		s.LogWarnf("Skipping the %s, it has no job response", description)
+		return
+	}
+	s.writes.enqueue(description, func(txn ports.LibreDataStoreTransactionPort) error {
+		var err error
+		if log.JobResponseId, err = resolveJobResponseId(log.JobResponseId, log.JobLocalId); err != nil {
+			return err
+		}
+		if _, err = queries.AddQuantityLog(txn, log); err != nil {
+			return err
+		}
+		txn.Commit()
+		return nil
+	})
+}
+
+func (s *quantityLogWriterGraphQL) Close() error {
+	return s.writes.close()
+}
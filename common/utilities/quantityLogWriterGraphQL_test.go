@@ -0,0 +1,33 @@
+package utilities
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Spruik/libre-common/common/core/domain"
+)
+
+func TestQuantityLogWriterSkipsQuantitiesWithoutAJob(t *testing.T) {
+	store := newFakeDataStore()
+	store.responses["addQuantityLog"] = jsonData("addQuantityLog", map[string]interface{}{
+		"quantityLog": []map[string]string{{"id": "q1"}},
+	})
+	writer := NewQuantityLogWriterGraphQL("quantityLogWriterTest", store)
+	writer.WriteQuantityLog(domain.QuantityLog{EquipmentId: "eq1", Type: domain.MaterialUseProduced, Quantity: 5, Timestamp: time.Now()})
+	writer.WriteQuantityLog(domain.QuantityLog{EquipmentId: "eq1", JobLocalId: "local-job", Type: domain.MaterialUseProduced, Quantity: 5, Timestamp: time.Now()})
+	writer.WriteQuantityLog(domain.QuantityLog{EquipmentId: "eq1", JobResponseId: "j1", Type: domain.MaterialUseProduced, Quantity: 3, Timestamp: time.Now()})
+	if err := writer.Close(); err != nil {
+		t.Fatalf("close failed: %s", err)
+	}
+	//without a job tracker the job of the second quantity can never be found
+	inputs := store.recordedInputs()
+	if len(inputs) != 1 {
+		t.Fatalf("Expect only the quantity with a job to be written; got %v", inputs)
+	}
+	for _, expected := range []string{`"isActive":true`, `"jobResponse":{"id":"j1"}`, `"quantity":3`} {
+		if !strings.Contains(inputs[0], expected) {
+			t.Errorf("Expect the input to contain %s; got %s", expected, inputs[0])
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package utilities
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	libreConfig "github.com/Spruik/libre-configuration"
+	libreLogger "github.com/Spruik/libre-logging"
+)
+
+//queuedWriter holds what the background writers share: the retry and close settings, and the closed flag that guards
+//  their queue.  The owner keeps the queue itself, runs its writes through writeWithRetries, and closes done when the
+//  queue is drained.
+type queuedWriter struct {
+	logger        *libreLogger.LoggingEnabler
+	name          string
+	retryAttempts int
+	retryDelay    time.Duration
+	closeTimeout  time.Duration
+	queueSize     int
+
+	done   chan struct{}
+	mutex  sync.Mutex
+	closed bool
+}
+
+const (
+	defaultWriteRetryAttempts = 5
+	defaultWriteRetryDelay    = 2 * time.Second
+	defaultWriteCloseTimeout  = 10 * time.Second
+	defaultWriteQueueSize     = 1000
+)
+
+//configure reads the settings from the owner's configuration category:
+//  RETRY_ATTEMPTS - how many times a write is tried before it is dropped
+//  RETRY_DELAY - the wait after the first failed attempt, doubled after each further failure
+//  CLOSE_TIMEOUT - how long close waits for the queued writes
+//  QUEUE_SIZE - how many writes can be waiting; writes beyond this are dropped with a warning
+func (w *queuedWriter) configure(cfg *libreConfig.ConfigurationEnabler, logger *libreLogger.LoggingEnabler, name string, defaultAttempts int, defaultQueueSize int) error {
+	w.logger = logger
+	w.name = name
+	w.done = make(chan struct{})
+	var err error
+	if w.retryAttempts, err = getConfigIntWithDefault(cfg, "RETRY_ATTEMPTS", defaultAttempts); err != nil {
+		return err
+	}
+	if w.retryAttempts < 1 {
+		w.retryAttempts = 1
+	}
+	if w.retryDelay, err = getConfigDurationWithDefault(cfg, "RETRY_DELAY", defaultWriteRetryDelay); err != nil {
+		return err
+	}
+	if w.closeTimeout, err = getConfigDurationWithDefault(cfg, "CLOSE_TIMEOUT", defaultWriteCloseTimeout); err != nil {
+		return err
+	}
+	w.queueSize, err = getConfigIntWithDefault(cfg, "QUEUE_SIZE", defaultQueueSize)
+	return err
+}
+
+//offer runs send unless the writer is closed; send must not block, and returns false when the queue is full
+func (w *queuedWriter) offer(description string, send func() bool) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.closed {
+		w.logger.LogWarnf("%s is closed, dropping %s", w.name, description)
+		return
+	}
+	if !send() {
+		w.logger.LogWarnf("%s queue is full, dropping %s", w.name, description)
+	}
+}
+
+//closeAndWait closes the queue once, then waits up to the close timeout for the owner to drain it
+func (w *queuedWriter) closeAndWait(closeQueue func(), pending func() int) error {
+	w.mutex.Lock()
+	if !w.closed {
+		w.closed = true
+		closeQueue()
+	}
+	w.mutex.Unlock()
+	select {
+	case <-w.done:
+		return nil
+	case <-time.After(w.closeTimeout):
+		return fmt.Errorf("%s did not finish %d queued writes within %s", w.name, pending(), w.closeTimeout)
+	}
+}
+
+//writeWithRetries runs the write until it succeeds or is out of attempts, doubling the wait after each failure
+func (w *queuedWriter) writeWithRetries(description string, write func() error) {
+	delay := w.retryDelay
+	for attempt := 1; ; attempt++ {
+		err := write()
+		if err == nil {
+			return
+		}
+		if attempt >= w.retryAttempts {
+			w.logger.LogErrorf("Write of %s failed after %d attempts, dropping it: %s", description, w.retryAttempts, err)
+			return
+		}
+		w.logger.LogWarnf("Write of %s failed on attempt %d, retrying in %s: %s", description, attempt, delay, err)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
@@ -0,0 +1,131 @@
+package utilities
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Spruik/libre-common/common/core/domain"
+	"github.com/Spruik/libre-common/common/core/ports"
+	"github.com/Spruik/libre-common/common/core/queries"
+	"github.com/Spruik/libre-common/common/core/services"
+	libreConfig "github.com/Spruik/libre-configuration"
+	libreLogger "github.com/Spruik/libre-logging"
+)
+
+//reasonResolverDefault loads all reasons, equipment overrides and equipment class parents from the data store, and
+//  reloads them when they are older than the REFRESH_INTERVAL
+type reasonResolverDefault struct {
+	//inherit logging functions
+	libreLogger.LoggingEnabler
+
+	//inherit config functions
+	libreConfig.ConfigurationEnabler
+
+	storeIF         ports.LibreDataStorePort
+	refreshInterval time.Duration
+
+	mutex        sync.Mutex
+	loadedAt     time.Time
+	reasons      []domain.Reason
+	overrides    []domain.EquipmentReasonOverride
+	classParents map[string]string
+	resolvers    map[string]*domain.ReasonResolver // by equipment id and class id
+}
+
+//defaultReasonRefreshInterval is used when REFRESH_INTERVAL is not configured
+const defaultReasonRefreshInterval = 5 * time.Minute
+
+func NewReasonResolverDefault(configHook string, storeIF ports.LibreDataStorePort) *reasonResolverDefault {
+	s := reasonResolverDefault{
+		storeIF:   storeIF,
+		resolvers: map[string]*domain.ReasonResolver{},
+	}
+	s.SetConfigCategory(configHook)
+	loggerHook, cerr := s.GetConfigItemWithDefault(domain.LOGGER_CONFIG_HOOK_TOKEN, domain.DEFAULT_LOGGER_NAME)
+	if cerr != nil {
+		loggerHook = domain.DEFAULT_LOGGER_NAME
+	}
+	s.SetLoggerConfigHook(loggerHook)
+	var err error
+	s.refreshInterval, err = getConfigDurationWithDefault(&s.ConfigurationEnabler, "REFRESH_INTERVAL", defaultReasonRefreshInterval)
+	if err != nil {
+		panic(fmt.Sprintf("FAILED IN CONFIGURATION SETUP FOR REASON RESOLVER - %s", err))
+	}
+	return &s
+}
+
+func (s *reasonResolverDefault) ResolveReason(equipmentId string, equipmentClassId string, code string, class domain.ReasonClass) (domain.ResolvedReason, bool) {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return domain.ResolvedReason{}, false
+	}
+	s.mutex.Lock()
+	if time.Since(s.loadedAt) > s.refreshInterval {
+		s.load()
+	}
+	key := equipmentId + "/" + equipmentClassId
+	resolver, exists := s.resolvers[key]
+	if !exists {
+		resolver = domain.NewReasonResolver(s.reasons, s.overrides, equipmentId, s.classChain(equipmentClassId))
+		s.resolvers[key] = resolver
+	}
+	s.mutex.Unlock()
+	return resolver.Resolve(code, class)
+}
+
+func (s *reasonResolverDefault) Refresh() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.load()
+}
+
+//load must be called with the mutex held.  A failed load keeps the previous reasons until the next refresh.
+func (s *reasonResolverDefault) load() {
+	s.loadedAt = time.Now()
+	txn := s.storeIF.BeginTransaction(false, "reasonResolver")
+	defer txn.Dispose()
+	reasons, err := queries.GetAllReasons(txn)
+	if err != nil {
+		s.LogErrorf("Failed to load the reasons: %s", err)
+		return
+	}
+	overrides, err := queries.GetAllEquipmentReasonOverrides(txn)
+	if err != nil {
+		s.LogErrorf("Failed to load the equipment reason overrides: %s", err)
+		return
+	}
+	classes, err := queries.GetAllEquipmentClassParents(txn)
+	if err != nil {
+		s.LogErrorf("Failed to load the equipment classes: %s", err)
+		return
+	}
+	s.reasons = reasons
+	s.overrides = overrides
+	s.classParents = make(map[string]string, len(classes))
+	for _, cls := range classes {
+		s.classParents[cls.Id] = cls.Parent.Id
+	}
+	s.resolvers = map[string]*domain.ReasonResolver{}
+	s.LogDebugf("Loaded %d reasons and %d equipment reason overrides", len(reasons), len(overrides))
+}
+
+func (s *reasonResolverDefault) classChain(classId string) []string {
+	chain := make([]string, 0)
+	visited := map[string]bool{}
+	for ; classId != "" && !visited[classId]; classId = s.classParents[classId] {
+		visited[classId] = true
+		chain = append(chain, classId)
+	}
+	return chain
+}
+
+//resolveEquipmentReason resolves a code for the managed equipment when there is a reason resolver service
+func resolveEquipmentReason(mgdEq *ports.ManagedEquipmentPort, code string, class domain.ReasonClass) (domain.ResolvedReason, bool) {
+	resolver := services.GetReasonResolverServiceInstance()
+	if resolver == nil || code == "" {
+		return domain.ResolvedReason{}, false
+	}
+	return resolver.ResolveReason((*mgdEq).GetEquipmentId(), (*mgdEq).GetEquipmentClassId(), code, class)
+}
@@ -0,0 +1,201 @@
+package utilities
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+)
+
+//schemaPath is the SDL the data store is loaded with, relative to this package
+const schemaPath = "../../schema/libreSchma.sdl"
+
+//testSchema holds the fields of each type of the SDL and its enum names, enough to check the shape of a query
+type testSchema struct {
+	types map[string]map[string]string // the base type of each field, by type name
+	enums map[string]bool
+}
+
+var (
+	loadedSchema    *testSchema
+	loadSchemaOnce  sync.Once
+	schemaBlockRE   = regexp.MustCompile(`^(type|enum)\s+([A-Za-z0-9_]+)`)
+	schemaFieldRE   = regexp.MustCompile(`^([A-Za-z0-9_]+)\s*:\s*\[?\s*([A-Za-z0-9_]+)`)
+	schemaBuiltins  = map[string]bool{"ID": true, "String": true, "Int": true, "Int64": true, "Float": true, "Boolean": true, "DateTime": true}
+	queryRootPrefix = []string{"query", "get", "aggregate"}
+)
+
+//schemaForTest parses the SDL once for all of the tests
+func schemaForTest(t *testing.T) *testSchema {
+	loadSchemaOnce.Do(func() {
+		file, err := os.Open(schemaPath)
+		if err != nil {
+			return
+		}
+		defer file.Close()
+		schema := &testSchema{types: map[string]map[string]string{}, enums: map[string]bool{}}
+		var fields map[string]string
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if match := schemaBlockRE.FindStringSubmatch(line); match != nil {
+				fields = nil
+				if match[1] == "enum" {
+					schema.enums[match[2]] = true
+				} else {
+					fields = map[string]string{}
+					schema.types[match[2]] = fields
+				}
+				continue
+			}
+			if fields == nil {
+				continue
+			}
+			if match := schemaFieldRE.FindStringSubmatch(line); match != nil {
+				fields[match[1]] = match[2]
+			}
+		}
+		loadedSchema = schema
+	})
+	if loadedSchema == nil {
+		t.Fatalf("failed to load the schema from %s", schemaPath)
+	}
+	return loadedSchema
+}
+
+//checkQueryShape checks that every field a query selects exists on its type, that objects have a selection and that
+//  scalars and enums do not.  The type of a root field is named after its prefix, so queryEquipment is an Equipment.
+func checkQueryShape(t *testing.T, query string) error {
+	schema := schemaForTest(t)
+	parser := &queryShapeParser{text: queryBody(query)}
+	roots, err := parser.selectionSet()
+	if err != nil {
+		return fmt.Errorf("%s in %s", err, query)
+	}
+	for _, root := range roots {
+		typeName := ""
+		for _, prefix := range queryRootPrefix {
+			if strings.HasPrefix(root.name, prefix) {
+				typeName = strings.TrimPrefix(root.name, prefix)
+				break
+			}
+		}
+		if err = schema.checkSelection(typeName, root.children, root.name); err != nil {
+			return fmt.Errorf("%s in %s", err, query)
+		}
+	}
+	return nil
+}
+
+func (s *testSchema) checkSelection(typeName string, children []*queryShapeField, path string) error {
+	fields, isType := s.types[typeName]
+	if !isType {
+		if children != nil {
+			return fmt.Errorf("%s is a %s, which has no fields to select", path, typeName)
+		}
+		if !schemaBuiltins[typeName] && !s.enums[typeName] {
+			return fmt.Errorf("%s has the unknown type '%s'", path, typeName)
+		}
+		return nil
+	}
+	if children == nil {
+		return fmt.Errorf("%s is a %s, which needs fields selected", path, typeName)
+	}
+	for _, child := range children {
+		if child.name == "__typename" {
+			continue
+		}
+		fieldType, exists := fields[child.name]
+		if !exists {
+			return fmt.Errorf("%s has no field '%s'", typeName, child.name)
+		}
+		if err := s.checkSelection(fieldType, child.children, path+"."+child.name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//queryShapeField is a field of a query with its selection, nil for a field without one
+type queryShapeField struct {
+	name     string
+	children []*queryShapeField
+}
+
+//queryShapeParser reads the selections of a query as built by the graphql client, skipping the arguments
+type queryShapeParser struct {
+	text string
+	pos  int
+}
+
+func (p *queryShapeParser) skipSpace() {
+	for p.pos < len(p.text) && strings.ContainsRune(" \t\r\n,", rune(p.text[p.pos])) {
+		p.pos++
+	}
+}
+
+func (p *queryShapeParser) selectionSet() ([]*queryShapeField, error) {
+	p.skipSpace()
+	if p.pos >= len(p.text) || p.text[p.pos] != '{' {
+		return nil, fmt.Errorf("expected '{' at %d", p.pos)
+	}
+	p.pos++
+	ret := make([]*queryShapeField, 0)
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.text) {
+			return nil, fmt.Errorf("unexpected end of query")
+		}
+		if p.text[p.pos] == '}' {
+			p.pos++
+			return ret, nil
+		}
+		field, err := p.field()
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, field)
+	}
+}
+
+func (p *queryShapeParser) field() (*queryShapeField, error) {
+	start := p.pos
+	for p.pos < len(p.text) && (p.text[p.pos] == '_' || isAlphaNumeric(p.text[p.pos])) {
+		p.pos++
+	}
+	if p.pos == start {
+		return nil, fmt.Errorf("expected a field name at %d", p.pos)
+	}
+	field := &queryShapeField{name: p.text[start:p.pos]}
+	p.skipSpace()
+	if p.pos < len(p.text) && p.text[p.pos] == '(' {
+		depth := 0
+		for ; p.pos < len(p.text); p.pos++ {
+			if p.text[p.pos] == '(' {
+				depth++
+			} else if p.text[p.pos] == ')' {
+				depth--
+				if depth == 0 {
+					p.pos++
+					break
+				}
+			}
+		}
+		p.skipSpace()
+	}
+	if p.pos < len(p.text) && p.text[p.pos] == '{' {
+		children, err := p.selectionSet()
+		if err != nil {
+			return nil, err
+		}
+		field.children = children
+	}
+	return field, nil
+}
+
+func isAlphaNumeric(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
@@ -7,8 +7,10 @@ import (
 	"github.com/Spruik/libre-common/common/core/domain"
 	"github.com/Spruik/libre-common/common/core/ports"
 	"github.com/Spruik/libre-common/common/core/queries"
+	"github.com/Spruik/libre-common/common/core/services"
 	libreConfig "github.com/Spruik/libre-configuration"
 	libreLogger "github.com/Spruik/libre-logging"
+	"github.com/google/uuid"
 )
 
 type tagChangeHandlerEventEval struct {
@@ -21,21 +23,33 @@ type tagChangeHandlerEventEval struct {
 	eventDefEvalIF         ports.EventDefEvaluatorPort
 	eventDefDistIF         ports.EventDefDistributorPort
 	tagNameToEventDefIdMap map[string][]string
+	endExpressions         map[string]string // by event definition id
 }
 
-func NewTagChangeHandlerEventEval(mgdEq *ports.ManagedEquipmentPort, storeIF ports.LibreDataStorePort, eventDefEvalIF ports.EventDefEvaluatorPort, eventDefDistIF ports.EventDefDistributorPort) *tagChangeHandlerEventEval {
+//NewTagChangeHandlerEventEval creates the event evaluating handler.  An EventLog event ends when its trigger is no
+//  longer true, unless END_EXPRESSIONS gives it an end expression - a stanza of expressions named by event
+//  definition name or id
+func NewTagChangeHandlerEventEval(mgdEq *ports.ManagedEquipmentPort, configHook string, storeIF ports.LibreDataStorePort, eventDefEvalIF ports.EventDefEvaluatorPort, eventDefDistIF ports.EventDefDistributorPort) *tagChangeHandlerEventEval {
 	s := tagChangeHandlerEventEval{
 		mgdEq:          mgdEq,
 		storeIF:        storeIF,
 		eventDefEvalIF: eventDefEvalIF,
 		eventDefDistIF: eventDefDistIF,
 	}
+	s.SetConfigCategory(configHook)
 	s.SetLoggerConfigHook("EVNTEVAL")
 	return &s
 }
 
 func (s *tagChangeHandlerEventEval) Initialize() {
 	s.tagNameToEventDefIdMap = map[string][]string{}
+	s.endExpressions = map[string]string{}
+	configured := map[string]string{}
+	if stanza := getConfigStanzaIfPresent(&s.ConfigurationEnabler, "END_EXPRESSIONS"); stanza != nil {
+		for _, child := range stanza.Children {
+			configured[child.Name] = child.Value
+		}
+	}
 	//get all the event defs for this eq, it's eqc or that eqc's parents
 	txn := s.storeIF.BeginTransaction(false, "evtevalinit")
 	defer txn.Dispose()
@@ -43,6 +57,11 @@ func (s *tagChangeHandlerEventEval) Initialize() {
 	if err == nil {
 		//for each event def, for each trigger prop -> add to the map
 		for _, evtDef := range eventDefList {
+			if expr, exists := configured[evtDef.Id]; exists {
+				s.endExpressions[evtDef.Id] = expr
+			} else if expr, exists = configured[evtDef.Name]; exists {
+				s.endExpressions[evtDef.Id] = expr
+			}
 			for _, prop := range evtDef.TriggerProperties {
 				evtDefIds := s.tagNameToEventDefIdMap[string(prop.Name)]
 				if evtDefIds == nil {
@@ -63,6 +82,10 @@ func (s *tagChangeHandlerEventEval) HandleTagChange(tagData domain.StdMessageStr
 	if tagData.ItemName != "" {
 		_, isValid := (*s.mgdEq).GetPropertyMap()[tagData.ItemName]
 		if isValid {
+			eventTime := tagData.ChangedTimestamp
+			if eventTime.IsZero() {
+				eventTime = time.Now()
+			}
 			entry := s.tagNameToEventDefIdMap[tagData.ItemName]
 			if entry != nil {
 				//create the eval context from the tagData and existing handlerContext
@@ -94,19 +117,24 @@ func (s *tagChangeHandlerEventEval) HandleTagChange(tagData domain.StdMessageStr
 								}
 							}
 
-							//if evt def type is EventLog, then create an event on the equipment
-							if evtDef.MessageClass == "EventLog" {
-								s.addEventForEquipment((*s.mgdEq).GetEquipmentId(), evtDef, computedFields)
+							s.trackJob(evtDef, computedFields, eventTime)
+							//if evt def type is EventLog, then open an event on the equipment - a trigger that stays
+							//true while the event is open is not a new event, so it is not distributed again
+							if evtDef.MessageClass == "EventLog" && !s.openEventLog(evtDef, computedFields, eventTime) {
+								continue
 							}
 							//distribute the event
 							err = s.eventDefDistIF.DistributeEventDef((*s.mgdEq).GetEquipmentId(), (*s.mgdEq).GetEquipmentName(), evtDef, computedFields)
+						} else if evtDef != nil && evtDef.MessageClass == "EventLog" && s.endExpressions[evtDef.Id] == "" {
+							//with no end expression, the event lasts as long as its trigger is true
+							s.closeEventLog(evtDef.Id, eventTime)
 						}
 					} else {
 						s.LogErrorf("Failed in EvaluateEventDef with err=%+v", err)
 					}
 				}
 			}
-
+			s.checkEventLogEnds(handlerContext, eventTime)
 		} else {
 			err = fmt.Errorf("property %s is not defined for equipment %s", tagData.ItemName, tagData.OwningAsset)
 		}
@@ -122,28 +150,88 @@ func (s *tagChangeHandlerEventEval) GetAckMessage(err error) string {
 	}
 }
 
-func (s *tagChangeHandlerEventEval) addEventForEquipment(id string, def *domain.EventDefinition, fields map[string]interface{}) {
-	_ = id
-	_ = def
-	_ = fields
-	//Add an event instance to the target equipment using the event def info
-	//TODO - need to figure out how the start/end for extended events is defined by event defs
-	/*TODO - EventLog has the following to fill in:
-	jobResponse:  ???
-	equipment:  easy
-	startDateTime:  time of the evaluation?
-	endDateTime:  ????
-	duration:  computed from start/end?
-	reasonCode
-	reasonText
-	reasonValue
-	reasonUoM
-	comments
-	*/
-	evtDesc := domain.EquipmentEventDescriptor{
-		Name:   def.Name,
-		Time:   time.Now(), //TODO - should use some fixed time - eval time, tag change time?
-		Params: fields,
-	}
-	_ = (*s.mgdEq).AddEvent(def.Name, evtDesc)
+//trackJob starts a job for order start events and for JobResponse events, or ends the running job when a
+//  JobResponse event has the End job state
+func (s *tagChangeHandlerEventEval) trackJob(def *domain.EventDefinition, fields map[string]interface{}, at time.Time) {
+	tracker := services.GetJobTrackerServiceInstance()
+	if tracker == nil || (def.MessageClass != "JobResponse" && !tracker.IsOrderStartEvent(def.Id)) {
+		return
+	}
+	eqId := (*s.mgdEq).GetEquipmentId()
+	if def.MessageClass == "JobResponse" && domain.IsJobEndPayload(fields) {
+		tracker.EndJob(eqId, at)
+		return
+	}
+	jobOrder := ""
+	if order, exists := fields[domain.JOB_ORDER]; exists && order != nil {
+		jobOrder = fmt.Sprintf("%v", order)
+	}
+	tracker.StartJob(eqId, jobOrder, at)
+}
+
+//openEventLog opens an EventLog for the definition and queues its write, returning false if one is already open or
+//  its reason is ignored
+func (s *tagChangeHandlerEventEval) openEventLog(def *domain.EventDefinition, fields map[string]interface{}, start time.Time) bool {
+	log := domain.NewEventLog(uuid.New().String(), def, s.endExpressions[def.Id], (*s.mgdEq).GetEquipmentId(), start, fields)
+	if log.JobResponseId == "" {
+		log.JobResponseId, log.JobLocalId = currentJobRefs(log.EquipmentId)
+	}
+	//the payload gives the raw stop or fault code; the equipment may ignore some reasons altogether
+	if reason, found := resolveEquipmentReason(s.mgdEq, log.ReasonCode, domain.ReasonClassTime); found {
+		if reason.Ignore {
+			s.LogDebugf("Event %s of %s has reason %s, which the equipment ignores", def.Name, (*s.mgdEq).GetEquipmentName(), reason.Reason.Label)
+			return false
+		}
+		log.ApplyReason(reason)
+	}
+	if err := (*s.mgdEq).OpenEventLog(log); err != nil {
+		s.LogDebugf("Event %s is already open for %s", def.Name, (*s.mgdEq).GetEquipmentName())
+		return false
+	}
+	if writer := services.GetEventLogWriterServiceInstance(); writer != nil {
+		mgdEq := s.mgdEq
+		writer.EventStarted(log, func(id string) {
+			(*mgdEq).SetEventLogId(log.LocalId, id)
+		})
+	}
+	return true
+}
+
+//checkEventLogEnds closes the open events whose end expression has become true
+func (s *tagChangeHandlerEventEval) checkEventLogEnds(evalContext *map[string]interface{}, end time.Time) {
+	for _, log := range (*s.mgdEq).GetOpenEventLogs() {
+		if log.EndExpression == "" {
+			continue
+		}
+		result, err := s.eventDefEvalIF.EvaluateExpression(s.mgdEq, log.EndExpression, evalContext)
+		if err != nil {
+			s.LogErrorf("Failed to evaluate the end expression of event %s for %s: %s", log.Name, (*s.mgdEq).GetEquipmentName(), err)
+			continue
+		}
+		if ended, isBool := result.(bool); isBool && ended {
+			s.closeEventLog(log.EventDefinitionId, end)
+		}
+	}
+}
+
+//closeEventLog closes the open event of the definition, if any, then queues its write and distributes its end
+func (s *tagChangeHandlerEventEval) closeEventLog(eventDefId string, end time.Time) {
+	log, closed := (*s.mgdEq).CloseEventLog(eventDefId, end)
+	if !closed {
+		return
+	}
+	if writer := services.GetEventLogWriterServiceInstance(); writer != nil {
+		writer.EventEnded(log)
+	}
+	payload := map[string]interface{}{}
+	for key, val := range log.Params {
+		payload[key] = val
+	}
+	payload["startDateTime"] = log.StartDateTime
+	payload["endDateTime"] = log.EndDateTime
+	payload["duration"] = log.Duration
+	endDef := domain.EventDefinition{Id: log.EventDefinitionId, Name: log.Name, MessageClass: "EventLog"}
+	if err := s.eventDefDistIF.DistributeEventDef((*s.mgdEq).GetEquipmentId(), (*s.mgdEq).GetEquipmentName(), &endDef, payload); err != nil {
+		s.LogErrorf("Failed to distribute the end of event %s for %s: %s", log.Name, (*s.mgdEq).GetEquipmentName(), err)
+	}
 }
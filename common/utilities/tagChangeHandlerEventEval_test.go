@@ -0,0 +1,118 @@
+package utilities
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Spruik/libre-common/common/core/domain"
+	"github.com/Spruik/libre-common/common/core/ports"
+	"github.com/Spruik/libre-common/common/core/services"
+)
+
+//fakeEventDefEvaluator reports the triggers and end expressions the test sets as true
+type fakeEventDefEvaluator struct {
+	defs      map[string]*domain.EventDefinition
+	triggered map[string]bool // by event definition id
+	ended     map[string]bool // by end expression
+}
+
+func (e *fakeEventDefEvaluator) EvaluateEventDef(mgdEq *ports.ManagedEquipmentPort, eventDefId string, evalContext *map[string]interface{}) (bool, *domain.EventDefinition, map[string]interface{}, error) {
+	return e.triggered[eventDefId], e.defs[eventDefId], map[string]interface{}{domain.EVENTLOG_JOB_RESPONSE: "j1"}, nil
+}
+
+func (e *fakeEventDefEvaluator) EvaluateExpression(mgdEq *ports.ManagedEquipmentPort, expression string, evalContext *map[string]interface{}) (interface{}, error) {
+	return e.ended[expression], nil
+}
+
+//fakeEventDefDistributor records the events distributed, as name:start or name:end
+type fakeEventDefDistributor struct {
+	distributed []string
+}
+
+func (d *fakeEventDefDistributor) DistributeEventDef(eqId, eqName string, eventDef *domain.EventDefinition, computedPayload map[string]interface{}) error {
+	if _, ended := computedPayload["endDateTime"]; ended {
+		d.distributed = append(d.distributed, eventDef.Name+":end")
+	} else {
+		d.distributed = append(d.distributed, eventDef.Name+":start")
+	}
+	return nil
+}
+
+func TestEventEvalOpensAndClosesEventLogs(t *testing.T) {
+	store := newFakeDataStore()
+	stopped := map[string]interface{}{"id": "d-stop", "name": "Stopped", "messageClass": "EventLog", "triggerProperties": []map[string]string{{"name": "State"}}}
+	fault := map[string]interface{}{"id": "d-fault", "name": "Fault", "messageClass": "EventLog", "triggerProperties": []map[string]string{{"name": "FaultCode"}}}
+	store.responses["getEquipment"] = jsonData("getEquipment", map[string]interface{}{"id": "eq1", "name": "Filler1", "equipmentClass": map[string]string{"id": "c-filler"}})
+	store.responses["getEquipmentClass"] = jsonData("getEquipmentClass", map[string]interface{}{"id": "c-filler", "name": "Filler", "eventDefinitions": []interface{}{stopped, fault}})
+	store.responses["addEventLog"] = jsonData("addEventLog", map[string]interface{}{"eventLog": []map[string]string{{"id": "e1"}}})
+	store.responses["updateEventLog"] = jsonData("updateEventLog", map[string]interface{}{"numUids": 1})
+	writer := NewEventLogWriterGraphQL("eventLogWriterTest", store)
+	previous := services.GetEventLogWriterServiceInstance()
+	services.SetEventLogWriterServiceInstance(services.NewEventLogWriterService(writer))
+	defer services.SetEventLogWriterServiceInstance(previous)
+
+	mgdEq := newTestManagedEquipment()
+	(*mgdEq).SetPropertyDefinition(domain.EquipmentPropertyDescriptor{Name: "State", DataType: "STRING"})
+	(*mgdEq).SetPropertyDefinition(domain.EquipmentPropertyDescriptor{Name: "FaultCode", DataType: "STRING"})
+	eval := &fakeEventDefEvaluator{
+		defs: map[string]*domain.EventDefinition{
+			"d-stop":  {Id: "d-stop", Name: "Stopped", MessageClass: "EventLog"},
+			"d-fault": {Id: "d-fault", Name: "Fault", MessageClass: "EventLog"},
+		},
+		triggered: map[string]bool{},
+		ended:     map[string]bool{},
+	}
+	dist := &fakeEventDefDistributor{}
+	handler := NewTagChangeHandlerEventEval(mgdEq, "eventEvalTest", store, eval, dist)
+	handler.Initialize()
+	if !reflect.DeepEqual(handler.tagNameToEventDefIdMap, map[string][]string{"State": {"d-stop"}, "FaultCode": {"d-fault"}}) {
+		t.Fatalf("Expect the triggers of the class's event definitions to be mapped; got %v", handler.tagNameToEventDefIdMap)
+	}
+	handler.endExpressions["d-fault"] = "FaultCleared"
+
+	start := time.Date(2026, 10, 1, 8, 0, 0, 0, time.UTC)
+	change := func(property string, minutes int) {
+		tagData := domain.StdMessageStruct{ItemName: property, ItemValue: "x", ChangedTimestamp: start.Add(time.Duration(minutes) * time.Minute)}
+		if err := handler.HandleTagChange(tagData, &map[string]interface{}{}); err != nil {
+			t.Fatalf("HandleTagChange(%s) failed: %s", property, err)
+		}
+	}
+	eval.triggered["d-stop"] = true
+	change("State", 0)
+	change("State", 1) //still stopped, which is not a new event
+	eval.triggered["d-stop"] = false
+	change("State", 2) //without an end expression the event ends with its trigger
+	eval.triggered["d-fault"] = true
+	change("FaultCode", 3)
+	eval.triggered["d-fault"] = false
+	change("FaultCode", 4) //the fault lasts until its end expression is true
+	if open := (*mgdEq).GetOpenEventLogs(); len(open) != 1 || open[0].Name != "Fault" {
+		t.Fatalf("Expect the fault to stay open until its end expression is true; got %+v", open)
+	}
+	eval.ended["FaultCleared"] = true
+	change("State", 5)
+	if open := (*mgdEq).GetOpenEventLogs(); len(open) != 0 {
+		t.Errorf("Expect no open events; got %+v", open)
+	}
+	if expected := []string{"Stopped:start", "Stopped:end", "Fault:start", "Fault:end"}; !reflect.DeepEqual(dist.distributed, expected) {
+		t.Errorf("Expect the events distributed %v; got %v", expected, dist.distributed)
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Fatalf("close failed: %s", err)
+	}
+	_, mutations := store.recorded()
+	written := make([]string, 0, len(mutations))
+	for _, mutation := range mutations {
+		if strings.Contains(mutation, "addEventLog") {
+			written = append(written, "add")
+		} else if strings.Contains(mutation, "updateEventLog") {
+			written = append(written, "close")
+		}
+	}
+	if expected := []string{"add", "close", "add", "close"}; !reflect.DeepEqual(written, expected) {
+		t.Errorf("Expect each event to be written when it opens and closed when it ends; got %v", written)
+	}
+}
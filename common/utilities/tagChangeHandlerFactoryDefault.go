@@ -21,14 +21,18 @@ func (s *tagChangeHandlerFactoryDefault) CreateHandlerInstance(key string, mgdEq
 	case "TagChangeHandlerPropInMemory":
 		return NewTagChangeHandlerPropInMemory(mgdEq)
 	case "TagChangeHandlerPackMLStatus":
-		return NewTagChangeHandlerPackMLStatus(mgdEq)
+		return NewTagChangeHandlerPackMLStatus(mgdEq, key)
 	case "TagChangeHandlerPackMLAdmin":
-		return NewTagChangeHandlerPackMLAdmin(mgdEq)
+		return NewTagChangeHandlerPackMLAdmin(mgdEq, key)
 	case "TagChangeHandlerEventEval":
-		return NewTagChangeHandlerEventEval(mgdEq,
+		return NewTagChangeHandlerEventEval(mgdEq, key,
 			services.GetLibreDataStoreServiceInstance(),
 			services.GetEventDefEvaluatorServiceInstance(),
 			services.GetEventDefDistributorServiceInstance())
+	case "TagChangeHandlerQuantityLog":
+		return NewTagChangeHandlerQuantityLog(mgdEq, key)
+	case "TagChangeHandlerHistorian":
+		return NewTagChangeHandlerHistorian(mgdEq, key)
 	case "TagChangeHandlerSender":
 		return NewTagChangeHandlerSender(mgdEq)
 	}
@@ -0,0 +1,101 @@
+package utilities
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Spruik/libre-common/common/core/domain"
+	"github.com/Spruik/libre-common/common/core/ports"
+	"github.com/Spruik/libre-common/common/core/services"
+	libreConfig "github.com/Spruik/libre-configuration"
+	libreLogger "github.com/Spruik/libre-logging"
+)
+
+//tagChangeHandlerHistorian writes the changes of the properties that store history to the historian, through the
+//  historian writer so the tag change is never held up.  Whether a property stores history comes from its
+//  storeHistory, or from that of its equipment property override.  Each point has a field named after the property
+//  holding the value in the property's data type, and is tagged with the equipment id, name, class and hierarchy
+//  path, the property's unit and the quality of the value.  The configuration may contain:
+//
+//  MEASUREMENT: EquipmentProperty   (the measurement of the points)
+type tagChangeHandlerHistorian struct {
+	//inherit logging functions
+	libreLogger.LoggingEnabler
+
+	//inherit config functions
+	libreConfig.ConfigurationEnabler
+
+	mgdEq       *ports.ManagedEquipmentPort
+	measurement string
+}
+
+const defaultHistorianMeasurement = "EquipmentProperty"
+
+func NewTagChangeHandlerHistorian(mgdEq *ports.ManagedEquipmentPort, configHook string) *tagChangeHandlerHistorian {
+	s := tagChangeHandlerHistorian{
+		mgdEq: mgdEq,
+	}
+	s.SetConfigCategory(configHook)
+	s.SetLoggerConfigHook("HISTORIAN")
+	s.measurement = getConfigStringWithDefault(&s.ConfigurationEnabler, "MEASUREMENT", defaultHistorianMeasurement)
+	return &s
+}
+
+func (s *tagChangeHandlerHistorian) Initialize() {
+
+}
+
+func (s *tagChangeHandlerHistorian) HandleTagChange(tagData domain.StdMessageStruct, handlerContext *map[string]interface{}) error {
+	s.LogDebug("BEGIN: tagChangeHandlerHistorian.HandleTagChange")
+	prop, exists := (*s.mgdEq).GetPropertyMap()[tagData.ItemName]
+	if !exists || !prop.StoreHistory {
+		return nil
+	}
+	value, hasValue := domain.HistorianFieldValue(prop.DataType, tagData.ItemValue)
+	if !hasValue {
+		return nil
+	}
+	writer := services.GetHistorianWriterServiceInstance()
+	if writer == nil {
+		return fmt.Errorf("no historian writer to record %s of %s", tagData.ItemName, (*s.mgdEq).GetEquipmentName())
+	}
+	at := tagData.ChangedTimestamp
+	if at.IsZero() {
+		at = time.Now()
+	}
+	writer.WritePoint(domain.HistorianPoint{
+		Measurement: s.measurement,
+		Tags:        s.pointTags(prop, domain.TagQuality(tagData.TagQuality)),
+		Fields:      map[string]interface{}{prop.Name: value},
+		Timestamp:   at,
+	})
+	return nil
+}
+
+//pointTags are the tags of a point of the property; the path is only known with the equipment cache
+func (s *tagChangeHandlerHistorian) pointTags(prop domain.EquipmentPropertyDescriptor, quality domain.TagQuality) map[string]string {
+	eqId := (*s.mgdEq).GetEquipmentId()
+	tags := map[string]string{
+		"equipmentId":    eqId,
+		"equipmentName":  (*s.mgdEq).GetEquipmentName(),
+		"equipmentClass": (*s.mgdEq).GetEquipmentClassName(),
+		"quality":        quality.String(),
+	}
+	if cache := services.GetEquipmentCacheServiceInstance(); cache != nil {
+		if path := cache.GetEquipmentPath(eqId); path != "" {
+			tags["equipmentPath"] = path
+		}
+	}
+	if prop.UnitOfMeasure != "" {
+		tags["unitOfMeasure"] = prop.UnitOfMeasure
+	}
+	return tags
+}
+
+func (s *tagChangeHandlerHistorian) GetAckMessage(err error) string {
+	if err == nil {
+		return "\nTag change handled by writing property history."
+	} else {
+		return fmt.Sprintf("\nFailed to write property history while handling tag change with error [%s]", err)
+	}
+}
@@ -2,48 +2,239 @@ package utilities
 
 import (
 	"fmt"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/Spruik/libre-common/common/core/domain"
 	"github.com/Spruik/libre-common/common/core/ports"
+	"github.com/Spruik/libre-common/common/core/services"
+	libreConfig "github.com/Spruik/libre-configuration"
 	libreLogger "github.com/Spruik/libre-logging"
 )
 
+//tagChangeHandlerPackMLAdmin reads the PackML Admin product count arrays (ProdConsumedCount, ProdProcessedCount and
+//  ProdDefectiveCount).  The topic token of each array gives the array index; the tag name ends in the member, ID or
+//  the count.  Counts become running totals per product for the running job, which are stored in the equipment's
+//  ProdConsumedCount/ProdProcessedCount/ProdDefectiveCount properties (and "<property>.<product>" per product) when
+//  the equipment has them, and published as PackMLCountUpdate events.  The configuration may contain:
+//  CONSUMED_TOKEN, PROCESSED_TOKEN, DEFECTIVE_TOKEN - the topic tokens (PRODCONSCNT, PRODPROCCNT, PRODDFCTCNT)
+//  ID_MEMBER, COUNT_MEMBER - the members with the product id and the count (ID, AccCount)
+//  ROLLOVER - the value at which the PLC counters start again from zero, if they roll over
+//  PRODUCT_IDS - a stanza mapping PLC product ids to product names
 type tagChangeHandlerPackMLAdmin struct {
 	//inherit logging functions
 	libreLogger.LoggingEnabler
 
-	mgdEq *ports.ManagedEquipmentPort
+	//inherit config functions
+	libreConfig.ConfigurationEnabler
+
+	mgdEq       *ports.ManagedEquipmentPort
+	tokens      map[domain.PackMLCountKind]string
+	idMember    string
+	countMember string
+	rollover    float64
+	productIds  map[string]string
+
+	mutex  sync.Mutex
+	slots  map[string]*packMLCountSlot // by count kind and array index
+	totals *domain.PackMLJobCounts
 }
 
-func NewTagChangeHandlerPackMLAdmin(mgdEq *ports.ManagedEquipmentPort) *tagChangeHandlerPackMLAdmin {
+type packMLCountSlot struct {
+	product string
+	tracker *domain.CounterTracker
+}
+
+func NewTagChangeHandlerPackMLAdmin(mgdEq *ports.ManagedEquipmentPort, configHook string) *tagChangeHandlerPackMLAdmin {
 	s := tagChangeHandlerPackMLAdmin{
-		mgdEq: mgdEq,
+		mgdEq:      mgdEq,
+		productIds: map[string]string{},
+		slots:      map[string]*packMLCountSlot{},
+		totals:     domain.NewPackMLJobCounts(),
 	}
+	s.SetConfigCategory(configHook)
 	s.SetLoggerConfigHook("TAGCHNG")
+	s.tokens = map[domain.PackMLCountKind]string{
+		domain.PackMLCountConsumed:  getConfigStringWithDefault(&s.ConfigurationEnabler, "CONSUMED_TOKEN", "PRODCONSCNT"),
+		domain.PackMLCountProcessed: getConfigStringWithDefault(&s.ConfigurationEnabler, "PROCESSED_TOKEN", "PRODPROCCNT"),
+		domain.PackMLCountDefective: getConfigStringWithDefault(&s.ConfigurationEnabler, "DEFECTIVE_TOKEN", "PRODDFCTCNT"),
+	}
+	s.idMember = getConfigStringWithDefault(&s.ConfigurationEnabler, "ID_MEMBER", "ID")
+	s.countMember = getConfigStringWithDefault(&s.ConfigurationEnabler, "COUNT_MEMBER", "AccCount")
+	rollover, err := getConfigIntWithDefault(&s.ConfigurationEnabler, "ROLLOVER", 0)
+	if err != nil {
+		panic(fmt.Sprintf("FAILED IN CONFIGURATION SETUP FOR PACKML ADMIN HANDLER - %s", err))
+	}
+	s.rollover = float64(rollover)
+	if stanza := getConfigStanzaIfPresent(&s.ConfigurationEnabler, "PRODUCT_IDS"); stanza != nil {
+		for _, child := range stanza.Children {
+			s.productIds[child.Name] = child.Value
+		}
+	}
 	return &s
 }
 
+//Initialize starts the totals of the running job from the count properties, which after a restart hold the values
+//  restored from the equipment snapshot - so the totals carry on rather than overwrite them with smaller numbers
 func (s *tagChangeHandlerPackMLAdmin) Initialize() {
-	//TODO - implementation TBD
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.slots = map[string]*packMLCountSlot{}
+	s.totals = domain.NewPackMLJobCounts()
+	job := s.runningJob()
+	props := (*s.mgdEq).GetPropertyMap()
+	for kind := range s.tokens {
+		property := "Prod" + string(kind) + "Count"
+		kindTotal := 0.0
+		if prop, defined := props[property]; defined {
+			kindTotal, _ = domain.ValueAsFloat64(prop.Value)
+		}
+		productTotals := map[string]float64{}
+		for name, prop := range props {
+			if strings.HasPrefix(name, property+".") {
+				if quantity, isNumber := domain.ValueAsFloat64(prop.Value); isNumber {
+					productTotals[strings.TrimPrefix(name, property+".")] = quantity
+				}
+			}
+		}
+		s.totals.Seed(job, kind, kindTotal, productTotals)
+	}
+}
+
+//countKind returns the count array of the message and its index, if it is a PackML Admin count message
+func (s *tagChangeHandlerPackMLAdmin) countKind(tagData domain.StdMessageStruct) (domain.PackMLCountKind, string, bool) {
+	for kind, token := range s.tokens {
+		if index, exists := tagData.ItemNameExt[token]; exists {
+			return kind, index, true
+		}
+	}
+	return "", "", false
+}
+
+//tagMember is the last part of a tag name such as "Admin.ProdProcessedCount[2].AccCount"
+func tagMember(itemName string) string {
+	return itemName[strings.LastIndexAny(itemName, "./")+1:]
 }
 
 func (s *tagChangeHandlerPackMLAdmin) HandleTagChange(tagData domain.StdMessageStruct, handlerContext *map[string]interface{}) error {
 	s.LogDebug("BEGIN: tagChangeHandlerPackMLAdmin.HandleTagChange")
-	_, pcexists := tagData.ItemNameExt["PRODCONSCNT"] //TODO - get this token from configuration
-	_, ppexists := tagData.ItemNameExt["PRODPROCCNT"] //TODO - get this token from configuration
-	_, pdexists := tagData.ItemNameExt["PRODDFCTCNT"] //TODO - get this token from configuration
-	//if these tokens are present, then we are dealing with a PackML admin message
-	if pcexists || ppexists || pdexists {
-		//TODO - implementation TDB
-		s.LogInfof("Handling a PackML Admin message for %s with: %+v", (*s.mgdEq).GetEquipmentName(), tagData.ItemNameExt)
+	kind, index, isAdmin := s.countKind(tagData)
+	if !isAdmin {
+		return nil
+	}
+	member := tagMember(tagData.ItemName)
+	s.mutex.Lock()
+	slotKey := string(kind) + "/" + index
+	slot := s.slots[slotKey]
+	if slot == nil {
+		slot = &packMLCountSlot{product: index, tracker: domain.NewCounterTracker(s.rollover)}
+		s.slots[slotKey] = slot
+	}
+	switch member {
+	case s.idMember:
+		product := strings.TrimSpace(fmt.Sprintf("%v", tagData.ItemValue))
+		if name, mapped := s.productIds[product]; mapped {
+			product = name
+		}
+		if product != slot.product {
+			//the counts of the slot now belong to another product, so start counting it afresh
+			slot.product = product
+			slot.tracker = domain.NewCounterTracker(s.rollover)
+		}
+		s.mutex.Unlock()
+		return nil
+	case s.countMember:
+	default:
+		s.mutex.Unlock()
+		return nil
+	}
+	count, isNumber := domain.ValueAsFloat64(tagData.ItemValue)
+	if !isNumber {
+		s.mutex.Unlock()
+		return fmt.Errorf("PackML %s count of %s is not a number: %v", kind, (*s.mgdEq).GetEquipmentName(), tagData.ItemValue)
+	}
+	at := tagData.ChangedTimestamp
+	if at.IsZero() {
+		at = time.Now()
+	}
+	quantity, change := slot.tracker.Update(count, at)
+	if change == domain.CounterReset {
+		s.LogWarnf("PackML %s count %s of %s went down to %v, taking it as a reset", kind, index, (*s.mgdEq).GetEquipmentName(), count)
+	}
+	if quantity <= 0 {
+		s.mutex.Unlock()
+		return nil
+	}
+	job := s.runningJob()
+	productTotal := s.totals.Add(job, kind, slot.product, quantity)
+	kindTotal := s.totals.Total(kind)
+	product := slot.product
+	s.mutex.Unlock()
+
+	s.recordOEECount(kind, quantity, at)
+	property := "Prod" + string(kind) + "Count"
+	s.updatePropertyIfDefined(property, kindTotal)
+	s.updatePropertyIfDefined(property+"."+product, productTotal)
+	return s.publishCountUpdate(kind, product, quantity, productTotal, kindTotal, job)
+}
+
+//recordOEECount counts processed units, of which the defective count says how many were defective, for the OEE of
+//  the equipment
+func (s *tagChangeHandlerPackMLAdmin) recordOEECount(kind domain.PackMLCountKind, quantity float64, at time.Time) {
+	oee := services.GetOEECalculatorServiceInstance()
+	if oee == nil {
+		return
+	}
+	switch kind {
+	case domain.PackMLCountProcessed:
+		oee.RecordCount(s.mgdEq, quantity, 0, at)
+	case domain.PackMLCountDefective:
+		oee.RecordCount(s.mgdEq, 0, quantity, at)
+	}
+}
+
+//runningJob is the local id of the job running on the equipment, which stays the same once the job is written
+func (s *tagChangeHandlerPackMLAdmin) runningJob() string {
+	if tracker := services.GetJobTrackerServiceInstance(); tracker != nil {
+		if job, running := tracker.GetCurrentJob((*s.mgdEq).GetEquipmentId()); running {
+			return job.LocalId
+		}
+	}
+	return ""
+}
+
+func (s *tagChangeHandlerPackMLAdmin) updatePropertyIfDefined(property string, value float64) {
+	if _, defined := (*s.mgdEq).GetPropertyMap()[property]; defined {
+		if err := (*s.mgdEq).UpdatePropertyValue(property, value); err != nil {
+			s.LogErrorf("Failed to update %s of %s: %s", property, (*s.mgdEq).GetEquipmentName(), err)
+		}
+	}
+}
+
+func (s *tagChangeHandlerPackMLAdmin) publishCountUpdate(kind domain.PackMLCountKind, product string, quantity float64, productTotal float64, kindTotal float64, job string) error {
+	distributor := services.GetEventDefDistributorServiceInstance()
+	if distributor == nil {
+		return nil
+	}
+	payload := map[string]interface{}{
+		"kind":         string(kind),
+		"product":      product,
+		"quantity":     quantity,
+		"productTotal": productTotal,
+		"total":        kindTotal,
+	}
+	if job != "" {
+		payload["job"] = job
 	}
-	return nil
+	def := domain.EventDefinition{Name: "PackMLCountUpdate", MessageClass: "QuantityLog"}
+	return distributor.DistributeEventDef((*s.mgdEq).GetEquipmentId(), (*s.mgdEq).GetEquipmentName(), &def, payload)
 }
 
 func (s *tagChangeHandlerPackMLAdmin) GetAckMessage(err error) string {
 	if err == nil {
-		return "\nTag change handled by as a PackML status message."
+		return "\nTag change handled as a PackML admin message."
 	} else {
-		return fmt.Sprintf("\nFailed PackML status message processing while handling tag change with error [%s]", err)
+		return fmt.Sprintf("\nFailed PackML admin message processing while handling tag change with error [%s]", err)
 	}
 }
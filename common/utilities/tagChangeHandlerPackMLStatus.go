@@ -2,41 +2,178 @@ package utilities
 
 import (
 	"fmt"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/Spruik/libre-common/common/core/domain"
 	"github.com/Spruik/libre-common/common/core/ports"
+	"github.com/Spruik/libre-common/common/core/services"
+	libreConfig "github.com/Spruik/libre-configuration"
 	libreLogger "github.com/Spruik/libre-logging"
+	"github.com/google/uuid"
 )
 
+//tagChangeHandlerPackMLStatus follows the PackML state of the equipment from its StateCurrent tag.  Each state
+//  change is checked against the PackML state model, published as an event and stored in the STATE_PROPERTY of the
+//  equipment, and the time spent in the previous state is written as an EventLog.  The configuration may contain:
+//  STATE_TAG - the name of the state tag (default StateCurrent); tags ending in ".StateCurrent" also match
+//  STATE_PROPERTY - the equipment property that holds the state name (default MachineState)
+//  PARAMETER_TOKEN, PRODUCT_TOKEN - the topic tokens of Status.Parameter and Status.Product tags (PARAMNUM, PRODUCTNUM)
 type tagChangeHandlerPackMLStatus struct {
 	//inherit logging functions
 	libreLogger.LoggingEnabler
 
-	mgdEq *ports.ManagedEquipmentPort
+	//inherit config functions
+	libreConfig.ConfigurationEnabler
+
+	mgdEq          *ports.ManagedEquipmentPort
+	stateTag       string
+	stateProperty  string
+	parameterToken string
+	productToken   string
+
+	mutex   sync.Mutex
+	tracker *domain.PackMLStateTracker
 }
 
-func NewTagChangeHandlerPackMLStatus(mgdEq *ports.ManagedEquipmentPort) *tagChangeHandlerPackMLStatus {
+//handler context entries set for the handlers that run after this one
+const (
+	PACKML_STATE_CONTEXT       = "PACKML_STATE"
+	PACKML_STATE_SINCE_CONTEXT = "PACKML_STATE_SINCE"
+)
+
+func NewTagChangeHandlerPackMLStatus(mgdEq *ports.ManagedEquipmentPort, configHook string) *tagChangeHandlerPackMLStatus {
 	s := tagChangeHandlerPackMLStatus{
-		mgdEq: mgdEq,
+		mgdEq:   mgdEq,
+		tracker: domain.NewPackMLStateTracker(),
 	}
+	s.SetConfigCategory(configHook)
 	s.SetLoggerConfigHook("TAGCHNG")
+	s.stateTag = getConfigStringWithDefault(&s.ConfigurationEnabler, "STATE_TAG", "StateCurrent")
+	s.stateProperty = getConfigStringWithDefault(&s.ConfigurationEnabler, "STATE_PROPERTY", "MachineState")
+	s.parameterToken = getConfigStringWithDefault(&s.ConfigurationEnabler, "PARAMETER_TOKEN", "PARAMNUM")
+	s.productToken = getConfigStringWithDefault(&s.ConfigurationEnabler, "PRODUCT_TOKEN", "PRODUCTNUM")
 	return &s
 }
 
+//Initialize picks up the state from the state property, which after a restart holds the value restored from the
+//  equipment snapshot, so the first interval after the restart is not lost
 func (s *tagChangeHandlerPackMLStatus) Initialize() {
-	//TODO - implementation TBD
+	history := (*s.mgdEq).GetPropertyHistory(s.stateProperty, 1)
+	if len(history) == 0 {
+		return
+	}
+	if state, err := domain.ParseMachineState(history[0].Value); err == nil {
+		s.mutex.Lock()
+		s.tracker.Seed(state, history[0].Time)
+		s.mutex.Unlock()
+		if oee := services.GetOEECalculatorServiceInstance(); oee != nil {
+			oee.RecordMachineState(s.mgdEq, state, history[0].Time)
+		}
+	}
+}
+
+func (s *tagChangeHandlerPackMLStatus) isStateTag(itemName string) bool {
+	return itemName == s.stateTag || strings.HasSuffix(itemName, "."+s.stateTag) || strings.HasSuffix(itemName, "/"+s.stateTag)
 }
 
 func (s *tagChangeHandlerPackMLStatus) HandleTagChange(tagData domain.StdMessageStruct, handlerContext *map[string]interface{}) error {
 	s.LogDebug("BEGIN: tagChangeHandlerPackMLStatus.HandleTagChange")
-	_, paramexists := tagData.ItemNameExt["PARAMNUM"]  //TODO - get this token from configuration
-	_, prodexists := tagData.ItemNameExt["PRODUCTNUM"] //TODO - get this token from configuration
-	//if these tokens exist, then we are dealing with a PackML status message
+	_, paramexists := tagData.ItemNameExt[s.parameterToken]
+	_, prodexists := tagData.ItemNameExt[s.productToken]
 	if paramexists || prodexists {
-		//TODO - implementation TDB
-		s.LogInfof("Handling a PackML Status message for %s with: %+v", (*s.mgdEq).GetEquipmentName(), tagData.ItemNameExt)
+		s.LogDebugf("Handling a PackML Status parameter or product for %s with: %+v", (*s.mgdEq).GetEquipmentName(), tagData.ItemNameExt)
+		return nil
+	}
+	if !s.isStateTag(tagData.ItemName) {
+		return nil
+	}
+	state, err := domain.ParseMachineState(tagData.ItemValue)
+	if err != nil {
+		return fmt.Errorf("bad PackML state for %s: %s", (*s.mgdEq).GetEquipmentName(), err)
+	}
+	at := tagData.ChangedTimestamp
+	if at.IsZero() {
+		at = time.Now()
+	}
+	s.mutex.Lock()
+	change, changed := s.tracker.Update(state, at)
+	current, since := s.tracker.Current()
+	s.mutex.Unlock()
+	if handlerContext != nil {
+		(*handlerContext)[PACKML_STATE_CONTEXT] = string(current)
+		(*handlerContext)[PACKML_STATE_SINCE_CONTEXT] = since
+	}
+	if !changed {
+		return nil
+	}
+	if oee := services.GetOEECalculatorServiceInstance(); oee != nil {
+		oee.RecordMachineState(s.mgdEq, state, at)
+	}
+	if !change.Valid {
+		s.LogWarnf("Invalid PackML transition for %s from %s to %s", (*s.mgdEq).GetEquipmentName(), change.From, change.To)
+	}
+	if _, hasProperty := (*s.mgdEq).GetPropertyMap()[s.stateProperty]; hasProperty {
+		if err = (*s.mgdEq).UpdatePropertyValue(s.stateProperty, string(state)); err != nil {
+			s.LogErrorf("Failed to update %s of %s: %s", s.stateProperty, (*s.mgdEq).GetEquipmentName(), err)
+		}
+	}
+	if change.Interval != nil {
+		s.logStateInterval(*change.Interval, change)
+	}
+	return s.publishStateChange(change)
+}
+
+//logStateInterval writes the time spent in a state as a closed EventLog; a reason whose code is the state name
+//  classifies the interval, and may make the equipment ignore it
+func (s *tagChangeHandlerPackMLStatus) logStateInterval(interval domain.PackMLStateInterval, change domain.PackMLStateChange) {
+	writer := services.GetEventLogWriterServiceInstance()
+	if writer == nil {
+		return
+	}
+	log := domain.EventLog{
+		LocalId:       uuid.New().String(),
+		Name:          string(interval.State),
+		EquipmentId:   (*s.mgdEq).GetEquipmentId(),
+		StartDateTime: interval.Start,
+		ReasonCode:    string(interval.State),
+	}
+	log.JobResponseId, log.JobLocalId = currentJobRefs(log.EquipmentId)
+	if !canResolveJobResponseId(log.JobResponseId, log.JobLocalId) {
+		//the schema requires a job response, so the intervals outside a job are not logged
+		s.LogDebugf("Not logging the %s interval of %s, no job is running", interval.State, (*s.mgdEq).GetEquipmentName())
+		return
+	}
+	if reason, found := resolveEquipmentReason(s.mgdEq, string(interval.State), domain.ReasonClassTime); found {
+		if reason.Ignore {
+			return
+		}
+		log.ApplyReason(reason)
+	}
+	if !change.Valid {
+		log.Comments = fmt.Sprintf("invalid PackML transition from %s to %s", change.From, change.To)
+	}
+	log.Close(interval.End)
+	writer.EventEnded(log)
+}
+
+func (s *tagChangeHandlerPackMLStatus) publishStateChange(change domain.PackMLStateChange) error {
+	distributor := services.GetEventDefDistributorServiceInstance()
+	if distributor == nil {
+		return nil
+	}
+	payload := map[string]interface{}{
+		"from":        string(change.From),
+		"to":          string(change.To),
+		"stateNumber": change.To.Number(),
+		"valid":       change.Valid,
+	}
+	if change.Interval != nil {
+		payload["previousDuration"] = change.Interval.Duration
 	}
-	return nil
+	def := domain.EventDefinition{Name: "PackMLStateChange", MessageClass: "EventLog"}
+	return distributor.DistributeEventDef((*s.mgdEq).GetEquipmentId(), (*s.mgdEq).GetEquipmentName(), &def, payload)
 }
 
 func (s *tagChangeHandlerPackMLStatus) GetAckMessage(err error) string {
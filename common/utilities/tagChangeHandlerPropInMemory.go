@@ -34,7 +34,7 @@ func (s *tagChangeHandlerPropInMemory) HandleTagChange(tagData domain.StdMessage
 		if oldVal != nil {
 			tagData.ItemOldValue = fmt.Sprintf("%s", oldVal)
 		}
-		return (*s.mgdEq).UpdatePropertyValue(tagData.ItemName, tagData.ItemValue)
+		return (*s.mgdEq).UpdatePropertyValueAndQuality(tagData.ItemName, tagData.ItemValue, domain.TagQuality(tagData.TagQuality))
 	}
 	return nil
 }
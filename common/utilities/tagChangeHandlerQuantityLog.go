@@ -0,0 +1,204 @@
+package utilities
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Spruik/libre-common/common/core/domain"
+	"github.com/Spruik/libre-common/common/core/ports"
+	"github.com/Spruik/libre-common/common/core/services"
+	libreConfig "github.com/Spruik/libre-configuration"
+	"github.com/Spruik/libre-configuration/shared"
+	libreLogger "github.com/Spruik/libre-logging"
+)
+
+//tagChangeHandlerQuantityLog turns the cumulative counter properties of the equipment into QuantityLog records of
+//  what was counted since the last reading.  Which properties are counters is configured per equipment class:
+//
+//  COUNTERS:
+//    Filler:                              (equipment class name or id)
+//      GoodCount: Produced
+//      RejectCount: Scrap,REJECT          (material use, reason code)
+//      BottlesIn: Consumed,,65536         (material use, reason code, rollover value of the counter)
+//  JOB_RESPONSE_PROPERTY: JobResponseId   (without a job tracker, the equipment property holding the active job)
+type tagChangeHandlerQuantityLog struct {
+	//inherit logging functions
+	libreLogger.LoggingEnabler
+
+	//inherit config functions
+	libreConfig.ConfigurationEnabler
+
+	mgdEq               *ports.ManagedEquipmentPort
+	jobResponseProperty string
+	mutex               sync.Mutex
+	counters            map[string]*quantityCounter
+}
+
+type quantityCounter struct {
+	use        domain.MaterialUse
+	reasonCode string
+	tracker    *domain.CounterTracker
+}
+
+const defaultJobResponseProperty = "JobResponseId"
+
+func NewTagChangeHandlerQuantityLog(mgdEq *ports.ManagedEquipmentPort, configHook string) *tagChangeHandlerQuantityLog {
+	s := tagChangeHandlerQuantityLog{
+		mgdEq:    mgdEq,
+		counters: map[string]*quantityCounter{},
+	}
+	s.SetConfigCategory(configHook)
+	s.SetLoggerConfigHook("QTYLOG")
+	s.jobResponseProperty = getConfigStringWithDefault(&s.ConfigurationEnabler, "JOB_RESPONSE_PROPERTY", defaultJobResponseProperty)
+	return &s
+}
+
+//Initialize reads the counters of the equipment class and starts each from the current property value, which after
+//  a restart is the value restored from the equipment snapshot - so nothing counted before the restart is lost
+func (s *tagChangeHandlerQuantityLog) Initialize() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.counters = map[string]*quantityCounter{}
+	if stanza := getConfigStanzaIfPresent(&s.ConfigurationEnabler, "COUNTERS"); stanza != nil {
+		s.loadCounters(stanza)
+	}
+	s.LogDebugf("%s has %d quantity counters", (*s.mgdEq).GetEquipmentName(), len(s.counters))
+}
+
+//loadCounters reads the counters of the equipment's class from the COUNTERS stanza; the mutex must be held
+func (s *tagChangeHandlerQuantityLog) loadCounters(stanza *shared.ConfigItem) {
+	classId := (*s.mgdEq).GetEquipmentClassId()
+	className := (*s.mgdEq).GetEquipmentClassName()
+	for _, class := range stanza.Children {
+		if class.Name != classId && class.Name != className {
+			continue
+		}
+		for _, prop := range class.Children {
+			counter, err := parseQuantityCounter(prop.Value)
+			if err != nil {
+				s.LogErrorf("Bad counter %s for equipment class %s: %s", prop.Name, class.Name, err)
+				continue
+			}
+			if value, ok := domain.ValueAsFloat64((*s.mgdEq).GetPropertyValue(prop.Name)); ok {
+				counter.tracker.Seed(value, time.Time{})
+			}
+			s.counters[prop.Name] = counter
+		}
+	}
+}
+
+//parseQuantityCounter reads "use[,reasonCode[,rollover]]"
+func parseQuantityCounter(text string) (*quantityCounter, error) {
+	parts := strings.Split(text, ",")
+	use, err := domain.ParseMaterialUse(parts[0])
+	if err != nil {
+		return nil, err
+	}
+	counter := quantityCounter{use: use}
+	if len(parts) > 1 {
+		counter.reasonCode = strings.TrimSpace(parts[1])
+	}
+	var rollover float64
+	if len(parts) > 2 && strings.TrimSpace(parts[2]) != "" {
+		if rollover, err = strconv.ParseFloat(strings.TrimSpace(parts[2]), 64); err != nil || rollover < 0 {
+			return nil, fmt.Errorf("bad rollover value '%s'", parts[2])
+		}
+	}
+	counter.tracker = domain.NewCounterTracker(rollover)
+	return &counter, nil
+}
+
+func (s *tagChangeHandlerQuantityLog) HandleTagChange(tagData domain.StdMessageStruct, handlerContext *map[string]interface{}) error {
+	s.LogDebug("BEGIN: tagChangeHandlerQuantityLog.HandleTagChange")
+	s.mutex.Lock()
+	counter := s.counters[tagData.ItemName]
+	if counter == nil {
+		s.mutex.Unlock()
+		return nil
+	}
+	value, ok := domain.ValueAsFloat64(tagData.ItemValue)
+	if !ok {
+		s.mutex.Unlock()
+		return fmt.Errorf("counter %s of %s has a value that is not a number: %v", tagData.ItemName, (*s.mgdEq).GetEquipmentName(), tagData.ItemValue)
+	}
+	at := tagData.ChangedTimestamp
+	if at.IsZero() {
+		at = time.Now()
+	}
+	quantity, change := counter.tracker.Update(value, at)
+	s.mutex.Unlock()
+
+	switch change {
+	case domain.CounterRolledOver:
+		s.LogInfof("Counter %s of %s rolled over", tagData.ItemName, (*s.mgdEq).GetEquipmentName())
+	case domain.CounterReset:
+		s.LogWarnf("Counter %s of %s went down to %v, taking it as a reset", tagData.ItemName, (*s.mgdEq).GetEquipmentName(), value)
+	}
+	if quantity <= 0 {
+		return nil
+	}
+	s.recordOEECount(counter.use, quantity, at)
+	writer := services.GetQuantityLogWriterServiceInstance()
+	if writer == nil {
+		return fmt.Errorf("no quantity log writer to record %v %s of %s", quantity, counter.use, tagData.ItemName)
+	}
+	jobId, jobLocalId := s.activeJob()
+	quantityLog := domain.QuantityLog{
+		EquipmentId:   (*s.mgdEq).GetEquipmentId(),
+		JobResponseId: jobId,
+		JobLocalId:    jobLocalId,
+		Type:          counter.use,
+		Quantity:      quantity,
+		ReasonCode:    counter.reasonCode,
+		Timestamp:     at,
+		Property:      tagData.ItemName,
+	}
+	//counted quantities are logged even when the equipment ignores the reason, so the totals stay right; scrapped
+	//and reworked material take their code from the scrap reasons
+	var reasonClass domain.ReasonClass
+	if counter.use == domain.MaterialUseScrap || counter.use == domain.MaterialUseRework {
+		reasonClass = domain.ReasonClassScrap
+	}
+	if reason, found := resolveEquipmentReason(s.mgdEq, counter.reasonCode, reasonClass); found {
+		quantityLog.ApplyReason(reason)
+	}
+	writer.WriteQuantityLog(quantityLog)
+	return nil
+}
+
+//recordOEECount counts produced material as good units, and scrapped or reworked material as defective units, for
+//  the OEE of the equipment
+func (s *tagChangeHandlerQuantityLog) recordOEECount(use domain.MaterialUse, quantity float64, at time.Time) {
+	oee := services.GetOEECalculatorServiceInstance()
+	if oee == nil {
+		return
+	}
+	switch use {
+	case domain.MaterialUseProduced:
+		oee.RecordCount(s.mgdEq, quantity, 0, at)
+	case domain.MaterialUseScrap, domain.MaterialUseRework:
+		oee.RecordCount(s.mgdEq, quantity, quantity, at)
+	}
+}
+
+//activeJob returns the job from the job tracker, or else the job response id held by the equipment property
+func (s *tagChangeHandlerQuantityLog) activeJob() (string, string) {
+	if id, localId := currentJobRefs((*s.mgdEq).GetEquipmentId()); id != "" || localId != "" {
+		return id, localId
+	}
+	if val := (*s.mgdEq).GetPropertyValue(s.jobResponseProperty); val != nil {
+		return fmt.Sprintf("%v", val), ""
+	}
+	return "", ""
+}
+
+func (s *tagChangeHandlerQuantityLog) GetAckMessage(err error) string {
+	if err == nil {
+		return "\nTag change handled by logging counted quantities."
+	} else {
+		return fmt.Sprintf("\nFailed quantity logging while handling tag change with error [%s]", err)
+	}
+}
@@ -0,0 +1,112 @@
+package utilities
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Spruik/libre-common/common/core/domain"
+	"github.com/Spruik/libre-common/common/core/ports"
+	"github.com/Spruik/libre-common/common/core/services"
+	"github.com/Spruik/libre-configuration/shared"
+)
+
+//fakeQuantityLogWriter records the quantity logs written
+type fakeQuantityLogWriter struct {
+	logs []domain.QuantityLog
+}
+
+func (w *fakeQuantityLogWriter) WriteQuantityLog(log domain.QuantityLog) {
+	w.logs = append(w.logs, log)
+}
+
+func (w *fakeQuantityLogWriter) Close() error {
+	return nil
+}
+
+//fakeReasonResolver resolves every code to a reason labelled with the class it was asked for
+type fakeReasonResolver struct{}
+
+func (r *fakeReasonResolver) ResolveReason(equipmentId string, equipmentClassId string, code string, class domain.ReasonClass) (domain.ResolvedReason, bool) {
+	return domain.ResolvedReason{Reason: domain.Reason{Label: string(class) + ":" + code}}, true
+}
+
+func (r *fakeReasonResolver) Refresh() {}
+
+//newTestQuantityLogHandler loads the counters of a Filler, with the quantity log writer and reason resolver set as
+//  services until the test ends
+func newTestQuantityLogHandler(t *testing.T, restored map[string]float64) (*tagChangeHandlerQuantityLog, *fakeQuantityLogWriter) {
+	writer := &fakeQuantityLogWriter{}
+	previousWriter := services.GetQuantityLogWriterServiceInstance()
+	services.SetQuantityLogWriterServiceInstance(services.NewQuantityLogWriterService(writer))
+	previousResolver := services.GetReasonResolverServiceInstance()
+	services.SetReasonResolverServiceInstance(services.NewReasonResolverService(&fakeReasonResolver{}))
+	t.Cleanup(func() {
+		services.SetQuantityLogWriterServiceInstance(previousWriter)
+		services.SetReasonResolverServiceInstance(previousResolver)
+	})
+
+	var mgdEq ports.ManagedEquipmentPort = NewManagedEquipmentDefault("quantityLogTest", domain.Equipment{
+		Id:             "eq1",
+		Name:           "Filler1",
+		EquipmentClass: domain.IdNameTypenameRef{Id: "c-filler", Name: "Filler"},
+	}, newFakeDataStore())
+	mgdEq.SetPropertyDefinition(domain.EquipmentPropertyDescriptor{Name: defaultJobResponseProperty, DataType: "STRING", Value: "job1"})
+	for name, value := range restored {
+		mgdEq.SetPropertyDefinition(domain.EquipmentPropertyDescriptor{Name: name, DataType: "FLOAT64", Value: value})
+	}
+	s := NewTagChangeHandlerQuantityLog(&mgdEq, "quantityLogTest")
+	item := func(name string, value string, children ...*shared.ConfigItem) *shared.ConfigItem {
+		return &shared.ConfigItem{Name: name, Value: value, Children: children}
+	}
+	s.loadCounters(item("COUNTERS", "",
+		item("Capper", "", item("CapCount", "Produced")),
+		item("Filler", "", item("GoodCount", "Produced")),
+		item("c-filler", "", item("RejectCount", "Scrap,REJECT"), item("BadCount", "NotAUse")),
+	))
+	return s, writer
+}
+
+func TestQuantityLogHandlerLoadsTheCountersOfItsClass(t *testing.T) {
+	s, _ := newTestQuantityLogHandler(t, nil)
+	names := map[string]bool{}
+	for name := range s.counters {
+		names[name] = true
+	}
+	//matched by class name and by class id; another class's counter and a bad counter are left out
+	if expected := map[string]bool{"GoodCount": true, "RejectCount": true}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("Expect the counters %v; got %v", expected, names)
+	}
+}
+
+func TestQuantityLogHandlerCountsOnFromRestoredValues(t *testing.T) {
+	s, writer := newTestQuantityLogHandler(t, map[string]float64{"GoodCount": 100})
+	for _, value := range []float64{105, 105, 112} {
+		if err := s.HandleTagChange(domain.StdMessageStruct{ItemName: "GoodCount", ItemValue: value}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	//the first reading counts from the restored value, and an unchanged reading logs nothing
+	if len(writer.logs) != 2 || writer.logs[0].Quantity != 5 || writer.logs[1].Quantity != 7 {
+		t.Fatalf("Expect quantities of 5 and 7; got %+v", writer.logs)
+	}
+	log := writer.logs[0]
+	if log.Type != domain.MaterialUseProduced || log.JobResponseId != "job1" || log.EquipmentId != "eq1" || log.Property != "GoodCount" {
+		t.Errorf("Expect produced material of job1 from GoodCount; got %+v", log)
+	}
+	if err := s.HandleTagChange(domain.StdMessageStruct{ItemName: "GoodCount", ItemValue: "many"}, nil); err == nil {
+		t.Errorf("Expect an error for a counter value that is not a number")
+	}
+}
+
+func TestQuantityLogHandlerResolvesScrapWithScrapReasons(t *testing.T) {
+	s, writer := newTestQuantityLogHandler(t, map[string]float64{"RejectCount": 10})
+	if err := s.HandleTagChange(domain.StdMessageStruct{ItemName: "RejectCount", ItemValue: 13.0}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(writer.logs) != 1 || writer.logs[0].Quantity != 3 || writer.logs[0].Type != domain.MaterialUseScrap {
+		t.Fatalf("Expect 3 scrapped; got %+v", writer.logs)
+	}
+	if code := writer.logs[0].ReasonCode; code != string(domain.ReasonClassScrap)+":REJECT" {
+		t.Errorf("Expect the reason code to be resolved among the scrap reasons; got %s", code)
+	}
+}
@@ -0,0 +1,88 @@
+package utilities
+
+import (
+	"sync"
+
+	"github.com/Spruik/libre-common/common/core/domain"
+	"github.com/Spruik/libre-common/common/core/ports"
+	"github.com/Spruik/libre-common/common/core/queries"
+	libreConfig "github.com/Spruik/libre-configuration"
+	libreLogger "github.com/Spruik/libre-logging"
+)
+
+//unitOfMeasureConverterDefault uses the UnitOfMeasureConversion entries of the data store, falling back to the
+//  built in temperature and linear unit conversions
+type unitOfMeasureConverterDefault struct {
+	//inherit logging functions
+	libreLogger.LoggingEnabler
+
+	//inherit config functions
+	libreConfig.ConfigurationEnabler
+
+	dataStore   ports.LibreDataStorePort
+	mutex       sync.RWMutex
+	conversions []domain.UnitConversion
+}
+
+func NewUnitOfMeasureConverterDefault(configHook string, storeIF ports.LibreDataStorePort) *unitOfMeasureConverterDefault {
+	s := unitOfMeasureConverterDefault{
+		dataStore:   storeIF,
+		conversions: []domain.UnitConversion{},
+	}
+	s.SetConfigCategory(configHook)
+	loggerHook, cerr := s.GetConfigItemWithDefault(domain.LOGGER_CONFIG_HOOK_TOKEN, domain.DEFAULT_LOGGER_NAME)
+	if cerr != nil {
+		loggerHook = domain.DEFAULT_LOGGER_NAME
+	}
+	s.SetLoggerConfigHook(loggerHook)
+	return &s
+}
+
+//Refresh loads the conversions.  The data store conversions are defined per material - when materials disagree on
+//  the factor between two units, the first one found is used and the conflict is logged.
+func (s *unitOfMeasureConverterDefault) Refresh() error {
+	if s.dataStore == nil {
+		return nil
+	}
+	txn := s.dataStore.BeginTransaction(false, "uomconversions")
+	defer txn.Dispose()
+	storeConversions, err := queries.GetActiveUnitOfMeasureConversions(txn)
+	if err != nil {
+		s.LogErrorf("Failed to load unit of measure conversions: %s", err)
+		return err
+	}
+	conversions := make([]domain.UnitConversion, 0, len(storeConversions))
+	seen := map[string]domain.UnitConversion{}
+	for _, sc := range storeConversions {
+		conv := domain.UnitConversion{
+			From:        sc.FromUoM.Code,
+			To:          sc.ToUoM.Code,
+			Numerator:   sc.Numerator,
+			Denominator: sc.Denominator,
+		}
+		key := domain.NormaliseUnitCode(conv.From) + ">" + domain.NormaliseUnitCode(conv.To)
+		if prev, exists := seen[key]; exists {
+			if prev.Numerator*conv.Denominator != conv.Numerator*prev.Denominator {
+				s.LogWarnf("Conflicting conversions from %s to %s (material %s) - using %v/%v", conv.From, conv.To, sc.Material.Code, prev.Numerator, prev.Denominator)
+			}
+			continue
+		}
+		seen[key] = conv
+		conversions = append(conversions, conv)
+	}
+	s.mutex.Lock()
+	s.conversions = conversions
+	s.mutex.Unlock()
+	s.LogInfof("Loaded %d unit of measure conversions", len(conversions))
+	return nil
+}
+
+func (s *unitOfMeasureConverterDefault) Convert(value float64, fromUoM string, toUoM string) (float64, error) {
+	s.mutex.RLock()
+	conv, err := domain.FindUnitConversion(fromUoM, toUoM, s.conversions)
+	s.mutex.RUnlock()
+	if err != nil {
+		return value, err
+	}
+	return conv.Apply(value), nil
+}
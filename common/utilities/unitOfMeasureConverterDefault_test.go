@@ -0,0 +1,44 @@
+package utilities
+
+import (
+	"testing"
+)
+
+func TestUnitOfMeasureConverterRefreshQueriesTheSchema(t *testing.T) {
+	store := newFakeDataStore()
+	store.responses["queryUnitOfMeasureConversion"] = jsonData("queryUnitOfMeasureConversion", []map[string]interface{}{
+		{"id": "c1", "fromUoM": map[string]string{"code": "kg"}, "toUoM": map[string]string{"code": "g"}, "numerator": 1000, "denominator": 1, "material": map[string]string{"id": "m1", "code": "Flour"}},
+		{"id": "c2", "fromUoM": map[string]string{"code": "kg"}, "toUoM": map[string]string{"code": "g"}, "numerator": 999, "denominator": 1, "material": map[string]string{"id": "m2", "code": "Sugar"}},
+	})
+	converter := NewUnitOfMeasureConverterDefault("uomConverterTest", store)
+	if err := converter.Refresh(); err != nil {
+		t.Fatalf("Refresh failed: %s", err)
+	}
+	queries, _ := store.recorded()
+	if len(queries) != 1 {
+		t.Fatalf("Expect one query; got %v", queries)
+	}
+	if err := checkQueryShape(t, queries[0]); err != nil {
+		t.Errorf("Expect the conversion query to match the schema: %s", err)
+	}
+	//the first of the conflicting conversions is used
+	if val, err := converter.Convert(2, "kg", "g"); err != nil || val != 2000 {
+		t.Errorf("Convert(2 kg to g) = %v, %v; want 2000", val, err)
+	}
+}
+
+func TestCheckQueryShapeFindsBadSelections(t *testing.T) {
+	bad := []string{
+		`{queryUnitOfMeasureConversion(filter:{isActive:true}){id,material{id,name}}}`,
+		`{queryEquipment(filter:{isActive: true}){id,dataProvider{id,name,__typename}}}`,
+		`{queryEquipment{id,equipmentClass}}`,
+	}
+	for _, query := range bad {
+		if err := checkQueryShape(t, query); err == nil {
+			t.Errorf("Expect %s not to match the schema", query)
+		}
+	}
+	if err := checkQueryShape(t, `{queryEquipment(filter:{isActive: true}){id,name,dataProvider,equipmentClass{id,name,__typename}}}`); err != nil {
+		t.Errorf("Expect a query that matches the schema to pass: %s", err)
+	}
+}
@@ -0,0 +1,315 @@
+package utilities
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/Spruik/libre-common/common/core/domain"
+	"github.com/Spruik/libre-common/common/core/ports"
+	libreConfig "github.com/Spruik/libre-configuration"
+	libreLogger "github.com/Spruik/libre-logging"
+)
+
+//valueChangeFilterChain runs each property's changes through its own ordered list of filters.  A change stops at
+//  the first filter that drops it or fails, so the later filters never see it.  The list of a property is the first
+//  of these that is set (a list of filter keys such as "ValueChangeFilterDeadband,ValueChangeFilterRateLimit", or
+//  NONE for no filtering):
+//
+//  PROPERTIES:                   (in the configuration, by property name)
+//    Temperature: ValueChangeFilterDeadband,ValueChangeFilterHeartbeat
+//  valueFilters.Temperature      (in the model, the value of a property of the equipment or its class)
+//  valueFilters                  (in the model, the list of every property of the equipment)
+//  CLASSES:                      (in the configuration, by equipment class name or id)
+//    Filler: ValueChangeFilterDedupe
+//  FILTERS: ValueChangeFilterRateLimit        (the list of every other property; without it they all pass)
+//
+//  The model lists are ordinary string properties with a value, so the data store declares them without a schema
+//  change; MODEL_PROPERTY renames them.  Each filter is created once per equipment, and configured by the category
+//  of its key.
+type valueChangeFilterChain struct {
+	//inherit logging functions
+	libreLogger.LoggingEnabler
+
+	//inherit config functions
+	libreConfig.ConfigurationEnabler
+
+	mgdEq         *ports.ManagedEquipmentPort
+	key           string
+	factory       ports.ValueChangeFilterFactoryPort
+	defaultList   string
+	modelProperty string            // the name of the model property holding a list
+	classLists    map[string]string // by equipment class name or id
+	propertyLists map[string]string // by property name
+
+	mutex   sync.Mutex
+	members map[string]*valueChangeFilterChainMember   // by filter key
+	order   []string                                   // the filter keys in the order they were created
+	chains  map[string]*valueChangeFilterPropertyChain // by property name
+	emit    func(tagChange domain.StdMessageStruct)
+}
+
+type valueChangeFilterChainMember struct {
+	key      string
+	filter   ports.ValueChangeFilterPort
+	counters valueChangeFilterCounters
+}
+
+type valueChangeFilterPropertyChain struct {
+	list    string
+	members []*valueChangeFilterChainMember
+}
+
+//valueChangeFilterChainNone is the list of a property that is not filtered
+const valueChangeFilterChainNone = "NONE"
+
+//defaultValueChangeFilterModelProperty is used when MODEL_PROPERTY is not configured
+const defaultValueChangeFilterModelProperty = "valueFilters"
+
+//NewValueChangeFilterChain creates a chain whose filters are created by the factory; the chain's own key cannot be
+//  one of them
+func NewValueChangeFilterChain(mgdEq *ports.ManagedEquipmentPort, configHook string, factory ports.ValueChangeFilterFactoryPort) *valueChangeFilterChain {
+	s := valueChangeFilterChain{
+		mgdEq:         mgdEq,
+		key:           configHook,
+		factory:       factory,
+		classLists:    map[string]string{},
+		propertyLists: map[string]string{},
+		members:       map[string]*valueChangeFilterChainMember{},
+		chains:        map[string]*valueChangeFilterPropertyChain{},
+	}
+	s.SetConfigCategory(configHook)
+	s.SetLoggerConfigHook("valueChangeFilter")
+	return &s
+}
+
+//Initialize reads the configured lists and builds the chain of every property, so a bad list fails here rather
+//  than when the property changes
+func (s *valueChangeFilterChain) Initialize() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.defaultList = getConfigStringWithDefault(&s.ConfigurationEnabler, "FILTERS", "")
+	s.modelProperty = getConfigStringWithDefault(&s.ConfigurationEnabler, "MODEL_PROPERTY", defaultValueChangeFilterModelProperty)
+	s.classLists = map[string]string{}
+	if stanza := getConfigStanzaIfPresent(&s.ConfigurationEnabler, "CLASSES"); stanza != nil {
+		for _, child := range stanza.Children {
+			s.classLists[child.Name] = child.Value
+		}
+	}
+	s.propertyLists = map[string]string{}
+	if stanza := getConfigStanzaIfPresent(&s.ConfigurationEnabler, "PROPERTIES"); stanza != nil {
+		for _, child := range stanza.Children {
+			s.propertyLists[child.Name] = child.Value
+		}
+	}
+	s.chains = map[string]*valueChangeFilterPropertyChain{}
+	for propName := range (*s.mgdEq).GetPropertyMap() {
+		if _, err := s.chainFor(propName); err != nil {
+			return fmt.Errorf("property %s: %s", propName, err)
+		}
+	}
+	s.LogDebugf("%s has %d value change filters in its chains", (*s.mgdEq).GetEquipmentName(), len(s.members))
+	return nil
+}
+
+//listFor returns the filter list of the property
+func (s *valueChangeFilterChain) listFor(propName string) string {
+	if list, exists := s.propertyLists[propName]; exists {
+		return list
+	}
+	if list, exists := s.modelListOf(s.modelProperty + "." + propName); exists {
+		return list
+	}
+	if list, exists := s.modelListOf(s.modelProperty); exists {
+		return list
+	}
+	if list, exists := s.classLists[(*s.mgdEq).GetEquipmentClassName()]; exists {
+		return list
+	}
+	if list, exists := s.classLists[(*s.mgdEq).GetEquipmentClassId()]; exists {
+		return list
+	}
+	return s.defaultList
+}
+
+//modelListOf returns the list held by a model property of the equipment; it is read on each change, so a new
+//  value from the model rebuilds the chains that use it
+func (s *valueChangeFilterChain) modelListOf(propName string) (string, bool) {
+	if s.modelProperty == "" {
+		return "", false
+	}
+	list, isString := (*s.mgdEq).GetPropertyValue(propName).(string)
+	return list, isString && list != ""
+}
+
+//chainFor returns the chain of the property, rebuilding it when its list has changed; it must be called with the
+//  mutex held
+func (s *valueChangeFilterChain) chainFor(propName string) (*valueChangeFilterPropertyChain, error) {
+	list := s.listFor(propName)
+	if chain, exists := s.chains[propName]; exists && chain.list == list {
+		return chain, nil
+	}
+	chain := &valueChangeFilterPropertyChain{list: list}
+	for _, key := range strings.Split(list, ",") {
+		key = strings.TrimSpace(key)
+		if key == "" || strings.EqualFold(key, valueChangeFilterChainNone) {
+			continue
+		}
+		member, err := s.memberFor(key)
+		if err != nil {
+			return nil, err
+		}
+		chain.members = append(chain.members, member)
+	}
+	s.chains[propName] = chain
+	return chain, nil
+}
+
+//memberFor returns the filter of the key, creating it if needed; it must be called with the mutex held
+func (s *valueChangeFilterChain) memberFor(key string) (*valueChangeFilterChainMember, error) {
+	if member, exists := s.members[key]; exists {
+		return member, nil
+	}
+	if key == s.key {
+		return nil, fmt.Errorf("the filter chain %s cannot contain itself", key)
+	}
+	filter := s.factory.CreateFilterInstance(key, s.mgdEq)
+	if filter == nil {
+		return nil, fmt.Errorf("unknown value change filter '%s'", key)
+	}
+	if err := filter.Initialize(); err != nil {
+		return nil, fmt.Errorf("failed to initialize value change filter '%s': %s", key, err)
+	}
+	member := &valueChangeFilterChainMember{key: key, filter: filter}
+	if emitter, isEmitter := filter.(ports.ValueChangeEmitterPort); isEmitter && s.emit != nil {
+		emitter.StartEmitting(s.memberEmitFunc(member))
+	}
+	s.members[key] = member
+	s.order = append(s.order, key)
+	return member, nil
+}
+
+func (s *valueChangeFilterChain) PassValueThrough(tagChange domain.StdMessageStruct) (bool, error) {
+	s.mutex.Lock()
+	chain, err := s.chainFor(tagChange.ItemName)
+	s.mutex.Unlock()
+	if err != nil {
+		return false, fmt.Errorf("no filter chain for property %s: %s", tagChange.ItemName, err)
+	}
+	return passChainMembers(chain.members, tagChange)
+}
+
+//memberEmitFunc returns the function a filter of the chain uses to pass a change on by itself: the change goes
+//  through the filters after it in the property's chain, and then out of the chain
+func (s *valueChangeFilterChain) memberEmitFunc(member *valueChangeFilterChainMember) func(tagChange domain.StdMessageStruct) {
+	return func(tagChange domain.StdMessageStruct) {
+		member.counters.countEmitted()
+		s.mutex.Lock()
+		chain, err := s.chainFor(tagChange.ItemName)
+		emit := s.emit
+		s.mutex.Unlock()
+		if err != nil || emit == nil {
+			return
+		}
+		for ndx, chainMember := range chain.members {
+			if chainMember == member {
+				if pass, _ := passChainMembers(chain.members[ndx+1:], tagChange); pass {
+					emit(tagChange)
+				}
+				return
+			}
+		}
+	}
+}
+
+//passChainMembers runs the change through the filters in order, stopping at the first that drops it or fails
+func passChainMembers(members []*valueChangeFilterChainMember, tagChange domain.StdMessageStruct) (bool, error) {
+	for _, member := range members {
+		pass, err := member.filter.PassValueThrough(tagChange)
+		member.counters.count(pass, err)
+		if err != nil {
+			return false, fmt.Errorf("%s: %s", member.key, err)
+		}
+		if !pass {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (s *valueChangeFilterChain) StartEmitting(emit func(tagChange domain.StdMessageStruct)) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.emit = emit
+	for _, key := range s.order {
+		member := s.members[key]
+		if emitter, isEmitter := member.filter.(ports.ValueChangeEmitterPort); isEmitter {
+			emitter.StartEmitting(s.memberEmitFunc(member))
+		}
+	}
+}
+
+func (s *valueChangeFilterChain) StopEmitting() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.emit = nil
+	for _, key := range s.order {
+		if emitter, isEmitter := s.members[key].filter.(ports.ValueChangeEmitterPort); isEmitter {
+			emitter.StopEmitting()
+		}
+	}
+}
+
+func (s *valueChangeFilterChain) GetFilterStats() []ports.ValueChangeFilterStats {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	ret := make([]ports.ValueChangeFilterStats, 0, len(s.order))
+	for _, key := range s.order {
+		member := s.members[key]
+		ret = appendValueChangeFilterStats(ret, key, member.filter, &member.counters)
+	}
+	return ret
+}
+
+//valueChangeFilterCounters counts the results of one filter; the counts are read while the filter runs
+type valueChangeFilterCounters struct {
+	passed  int64
+	dropped int64
+	failed  int64
+	emitted int64
+}
+
+func (c *valueChangeFilterCounters) count(pass bool, err error) {
+	switch {
+	case err != nil:
+		atomic.AddInt64(&c.failed, 1)
+	case pass:
+		atomic.AddInt64(&c.passed, 1)
+	default:
+		atomic.AddInt64(&c.dropped, 1)
+	}
+}
+
+func (c *valueChangeFilterCounters) countEmitted() {
+	atomic.AddInt64(&c.emitted, 1)
+}
+
+//appendValueChangeFilterStats adds the counts of a filter, followed by those of the filters inside it named
+//  "<key>/<inner key>"
+func appendValueChangeFilterStats(stats []ports.ValueChangeFilterStats, key string, filter ports.ValueChangeFilterPort, counters *valueChangeFilterCounters) []ports.ValueChangeFilterStats {
+	stats = append(stats, ports.ValueChangeFilterStats{
+		Filter:  key,
+		Passed:  atomic.LoadInt64(&counters.passed),
+		Dropped: atomic.LoadInt64(&counters.dropped),
+		Failed:  atomic.LoadInt64(&counters.failed),
+		Emitted: atomic.LoadInt64(&counters.emitted),
+	})
+	if inner, hasStats := filter.(ports.ValueChangeFilterStatsPort); hasStats {
+		for _, innerStats := range inner.GetFilterStats() {
+			innerStats.Filter = key + "/" + innerStats.Filter
+			stats = append(stats, innerStats)
+		}
+	}
+	return stats
+}
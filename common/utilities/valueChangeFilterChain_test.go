@@ -0,0 +1,146 @@
+package utilities
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Spruik/libre-common/common/core/domain"
+	"github.com/Spruik/libre-common/common/core/ports"
+)
+
+//chainTestFilter drops the values it is told to and records the changes it sees; it can also emit changes
+type chainTestFilter struct {
+	key  string
+	drop map[interface{}]bool
+	seen *[]string
+	emit func(tagChange domain.StdMessageStruct)
+}
+
+func (f *chainTestFilter) Initialize() error {
+	return nil
+}
+
+func (f *chainTestFilter) PassValueThrough(tagChange domain.StdMessageStruct) (bool, error) {
+	*f.seen = append(*f.seen, f.key)
+	return !f.drop[tagChange.ItemValue], nil
+}
+
+func (f *chainTestFilter) StartEmitting(emit func(tagChange domain.StdMessageStruct)) {
+	f.emit = emit
+}
+
+func (f *chainTestFilter) StopEmitting() {
+	f.emit = nil
+}
+
+type chainTestFactory struct {
+	filters map[string]*chainTestFilter
+}
+
+func (f *chainTestFactory) CreateFilterInstance(key string, mgdEq *ports.ManagedEquipmentPort) ports.ValueChangeFilterPort {
+	if filter, exists := f.filters[key]; exists {
+		return filter
+	}
+	return nil
+}
+
+func newTestFilterChain() (*valueChangeFilterChain, map[string]*chainTestFilter, *[]string) {
+	seen := &[]string{}
+	filters := map[string]*chainTestFilter{
+		"first":  {key: "first", drop: map[interface{}]bool{"dropFirst": true}, seen: seen},
+		"second": {key: "second", drop: map[interface{}]bool{"dropSecond": true}, seen: seen},
+	}
+	chain := NewValueChangeFilterChain(newTestManagedEquipment(), "filterChainTest", &chainTestFactory{filters: filters})
+	chain.propertyLists["Speed"] = "first,second"
+	chain.propertyLists["Count"] = valueChangeFilterChainNone
+	chain.defaultList = "second"
+	return chain, filters, seen
+}
+
+func TestValueChangeFilterChainStopsAtTheFirstDrop(t *testing.T) {
+	chain, _, seen := newTestFilterChain()
+	var tests = []struct {
+		property string
+		value    interface{}
+		expected bool
+		seen     []string
+	}{
+		{"Speed", "ok", true, []string{"first", "second"}},
+		{"Speed", "dropFirst", false, []string{"first"}}, //the second filter never sees it
+		{"Speed", "dropSecond", false, []string{"first", "second"}},
+		{"Count", "dropFirst", true, []string{}},         //NONE passes everything
+		{"Level", "dropFirst", true, []string{"second"}}, //the default list
+		{"Level", "dropSecond", false, []string{"second"}},
+	}
+	for _, test := range tests {
+		*seen = []string{}
+		pass, err := chain.PassValueThrough(domain.StdMessageStruct{ItemName: test.property, ItemValue: test.value})
+		if err != nil || pass != test.expected || !reflect.DeepEqual(*seen, test.seen) {
+			t.Errorf("PassValueThrough(%s=%v) = %t, %v through %v; want %t through %v", test.property, test.value, pass, err, *seen, test.expected, test.seen)
+		}
+	}
+	for _, stats := range chain.GetFilterStats() {
+		if stats.Filter == "first" && (stats.Passed != 2 || stats.Dropped != 1) {
+			t.Errorf("Expect the first filter to pass 2 and drop 1; got %+v", stats)
+		}
+	}
+}
+
+func TestValueChangeFilterChainEmitsThroughTheLaterFilters(t *testing.T) {
+	chain, filters, seen := newTestFilterChain()
+	emitted := make([]interface{}, 0)
+	chain.StartEmitting(func(tagChange domain.StdMessageStruct) {
+		emitted = append(emitted, tagChange.ItemValue)
+	})
+	//the filters are created, and given their emit function, with the first change of the property
+	if _, err := chain.PassValueThrough(domain.StdMessageStruct{ItemName: "Speed", ItemValue: "ok"}); err != nil {
+		t.Fatal(err)
+	}
+	*seen = []string{}
+	filters["first"].emit(domain.StdMessageStruct{ItemName: "Speed", ItemValue: "held"})
+	filters["first"].emit(domain.StdMessageStruct{ItemName: "Speed", ItemValue: "dropSecond"})
+	if expected := []interface{}{"held"}; !reflect.DeepEqual(emitted, expected) {
+		t.Errorf("Expect the emitted changes %v; got %v", expected, emitted)
+	}
+	if expected := []string{"second", "second"}; !reflect.DeepEqual(*seen, expected) {
+		t.Errorf("Expect only the filters after the emitting one to see its changes; got %v", *seen)
+	}
+	filters["second"].emit(domain.StdMessageStruct{ItemName: "Speed", ItemValue: "last"})
+	chain.StopEmitting()
+	if filters["first"].emit != nil {
+		t.Errorf("Expect StopEmitting to stop the filters emitting")
+	}
+	if expected := []interface{}{"held", "last"}; !reflect.DeepEqual(emitted, expected) {
+		t.Errorf("Expect a change emitted by the last filter to leave the chain; got %v", emitted)
+	}
+}
+
+func TestValueChangeFilterChainReadsListsFromTheModel(t *testing.T) {
+	chain, _, seen := newTestFilterChain()
+	chain.modelProperty = defaultValueChangeFilterModelProperty
+	mgdEq := *chain.mgdEq
+	mgdEq.SetPropertyDefinition(domain.EquipmentPropertyDescriptor{Name: "valueFilters", DataType: "STRING", Value: "first"})
+	mgdEq.SetPropertyDefinition(domain.EquipmentPropertyDescriptor{Name: "valueFilters.Level", DataType: "STRING", Value: "second,first"})
+	var tests = []struct {
+		property string
+		seen     []string
+	}{
+		{"Speed", []string{"first", "second"}}, //the configured list comes first
+		{"Level", []string{"second", "first"}}, //the model list of the property
+		{"Flow", []string{"first"}},            //the model list of the equipment
+	}
+	for _, test := range tests {
+		*seen = []string{}
+		if _, err := chain.PassValueThrough(domain.StdMessageStruct{ItemName: test.property, ItemValue: "ok"}); err != nil || !reflect.DeepEqual(*seen, test.seen) {
+			t.Errorf("Expect %s to pass through %v; got %v, %v", test.property, test.seen, *seen, err)
+		}
+	}
+	//a new value in the model rebuilds the chain
+	if err := mgdEq.UpdatePropertyValue("valueFilters", "second"); err != nil {
+		t.Fatal(err)
+	}
+	*seen = []string{}
+	if _, err := chain.PassValueThrough(domain.StdMessageStruct{ItemName: "Flow", ItemValue: "ok"}); err != nil || !reflect.DeepEqual(*seen, []string{"second"}) {
+		t.Errorf("Expect Flow to pass through the new model list; got %v, %v", *seen, err)
+	}
+}
@@ -0,0 +1,90 @@
+package utilities
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/Spruik/libre-common/common/core/domain"
+	"github.com/Spruik/libre-common/common/core/ports"
+	libreConfig "github.com/Spruik/libre-configuration"
+	libreLogger "github.com/Spruik/libre-logging"
+)
+
+//valueChangeFilterDeadband drops numeric changes that stay within a deadband of the last value it passed on, so a
+//  slowly drifting value is still passed on once it has moved far enough.  Booleans and text pass only when they
+//  change.  The first change of each property always passes.  The configuration may contain:
+//
+//  DEADBAND: 0.5                 (the deadband of every property not listed; without it they all pass)
+//  PROPERTIES:
+//    Temperature: 0.2            (an absolute deadband)
+//    Pressure: 2%,0,160          (a percentage of the span from 0 to 160)
+type valueChangeFilterDeadband struct {
+	//inherit logging functions
+	libreLogger.LoggingEnabler
+
+	//inherit config functions
+	libreConfig.ConfigurationEnabler
+
+	mgdEq           *ports.ManagedEquipmentPort
+	defaultDeadband *domain.Deadband
+	deadbands       map[string]domain.Deadband // by property name
+
+	mutex      sync.Mutex
+	lastPassed map[string]interface{} // by property name
+}
+
+func NewValueChangeFilterDeadband(mgdEq *ports.ManagedEquipmentPort, configHook string) *valueChangeFilterDeadband {
+	s := valueChangeFilterDeadband{
+		mgdEq:      mgdEq,
+		deadbands:  map[string]domain.Deadband{},
+		lastPassed: map[string]interface{}{},
+	}
+	s.SetConfigCategory(configHook)
+	s.SetLoggerConfigHook("valueChangeFilter")
+	return &s
+}
+
+func (s *valueChangeFilterDeadband) Initialize() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.defaultDeadband = nil
+	s.deadbands = map[string]domain.Deadband{}
+	s.lastPassed = map[string]interface{}{}
+	if text := getConfigStringWithDefault(&s.ConfigurationEnabler, "DEADBAND", ""); text != "" {
+		deadband, err := domain.ParseDeadband(text)
+		if err != nil {
+			return fmt.Errorf("DEADBAND: %s", err)
+		}
+		s.defaultDeadband = &deadband
+	}
+	if stanza := getConfigStanzaIfPresent(&s.ConfigurationEnabler, "PROPERTIES"); stanza != nil {
+		for _, prop := range stanza.Children {
+			deadband, err := domain.ParseDeadband(prop.Value)
+			if err != nil {
+				return fmt.Errorf("property %s: %s", prop.Name, err)
+			}
+			s.deadbands[prop.Name] = deadband
+		}
+	}
+	s.LogDebugf("%s has deadbands for %d properties", (*s.mgdEq).GetEquipmentName(), len(s.deadbands))
+	return nil
+}
+
+func (s *valueChangeFilterDeadband) PassValueThrough(tagChange domain.StdMessageStruct) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	deadband, configured := s.deadbands[tagChange.ItemName]
+	if !configured {
+		if s.defaultDeadband == nil {
+			return true, nil
+		}
+		deadband = *s.defaultDeadband
+	}
+	//compare with the last value passed on, not the last one received, so small steps still add up
+	last, seen := s.lastPassed[tagChange.ItemName]
+	if seen && !deadband.Passes(last, tagChange.ItemValue) {
+		return false, nil
+	}
+	s.lastPassed[tagChange.ItemName] = tagChange.ItemValue
+	return true, nil
+}
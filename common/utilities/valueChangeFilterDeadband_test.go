@@ -0,0 +1,41 @@
+package utilities
+
+import (
+	"testing"
+
+	"github.com/Spruik/libre-common/common/core/domain"
+)
+
+func TestValueChangeFilterDeadband(t *testing.T) {
+	filter := NewValueChangeFilterDeadband(newTestManagedEquipment(), "deadbandFilterTest")
+	if err := filter.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+	absolute, _ := domain.ParseDeadband("0.5")
+	span, _ := domain.ParseDeadband("2%,0,160")
+	filter.defaultDeadband = &absolute
+	filter.deadbands["Pressure"] = span
+
+	var tests = []struct {
+		property string
+		value    interface{}
+		expected bool
+	}{
+		{"Temperature", 20.0, true},  //the first change always passes
+		{"Temperature", 20.3, false}, //within 0.5 of 20
+		{"Temperature", 20.6, true},  //small steps add up against the last value passed on
+		{"Temperature", 20.9, false},
+		{"Pressure", 100.0, true},
+		{"Pressure", 103.0, false}, //2% of 160 is 3.2
+		{"Pressure", 96.7, true},
+		{"Running", true, true},
+		{"Running", true, false}, //booleans pass only when they change
+		{"Running", false, true},
+	}
+	for _, test := range tests {
+		pass, err := filter.PassValueThrough(domain.StdMessageStruct{ItemName: test.property, ItemValue: test.value})
+		if err != nil || pass != test.expected {
+			t.Errorf("PassValueThrough(%s=%v) = %t, %v; want %t", test.property, test.value, pass, err, test.expected)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package utilities
+
+import (
+	"sync"
+
+	"github.com/Spruik/libre-common/common/core/domain"
+)
+
+//valueChangeFilterDedupe drops a change of a property whose value is the same as the last value it passed on
+type valueChangeFilterDedupe struct {
+	mutex      sync.Mutex
+	lastPassed map[string]interface{} // by property name
+}
+
+func NewValueChangeFilterDedupe() *valueChangeFilterDedupe {
+	return &valueChangeFilterDedupe{
+		lastPassed: map[string]interface{}{},
+	}
+}
+
+func (s *valueChangeFilterDedupe) Initialize() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.lastPassed = map[string]interface{}{}
+	return nil
+}
+
+func (s *valueChangeFilterDedupe) PassValueThrough(tagChange domain.StdMessageStruct) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if last, seen := s.lastPassed[tagChange.ItemName]; seen && domain.ValuesEqual(last, tagChange.ItemValue) {
+		return false, nil
+	}
+	s.lastPassed[tagChange.ItemName] = tagChange.ItemValue
+	return true, nil
+}
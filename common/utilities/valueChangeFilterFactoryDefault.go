@@ -19,6 +19,18 @@ func (s *valueChangeFilterFactoryDefault) CreateFilterInstance(key string, mgdEq
 	switch key {
 	case "ValueChangeFilterDefault":
 		return NewValueChangeFilterDefault()
+	case "ValueChangeFilterDeadband":
+		return NewValueChangeFilterDeadband(mgdEq, key)
+	case "ValueChangeFilterRateLimit":
+		return NewValueChangeFilterRateLimit(mgdEq, key)
+	case "ValueChangeFilterHeartbeat":
+		return NewValueChangeFilterHeartbeat(mgdEq, key)
+	case "ValueChangeFilterQuality":
+		return NewValueChangeFilterQuality(mgdEq, key)
+	case "ValueChangeFilterDedupe":
+		return NewValueChangeFilterDedupe()
+	case "ValueChangeFilterChain":
+		return NewValueChangeFilterChain(mgdEq, key, s)
 	}
 	return nil
 }
@@ -0,0 +1,144 @@
+package utilities
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Spruik/libre-common/common/core/domain"
+	"github.com/Spruik/libre-common/common/core/ports"
+	libreConfig "github.com/Spruik/libre-configuration"
+	libreLogger "github.com/Spruik/libre-logging"
+)
+
+//valueChangeFilterHeartbeat passes every change, and when a property has passed nothing for its maximum silence it
+//  passes the last change on again, stamped with the current time and with the HEARTBEAT sub category.  Consumers
+//  can then tell an unchanged value from an edge agent that stopped.  It repeats the last change it saw, so it goes
+//  after the filters that drop changes.  The heartbeats need the filter to be started as an emitter by the
+//  equipment service manager.  The configuration may contain:
+//
+//  MAX_SILENCE: 60s              (the silence of every property not listed; without it there are no heartbeats)
+//  PROPERTIES:
+//    Temperature: 10s
+//    Status: 0s                  (no heartbeat)
+type valueChangeFilterHeartbeat struct {
+	//inherit logging functions
+	libreLogger.LoggingEnabler
+
+	//inherit config functions
+	libreConfig.ConfigurationEnabler
+
+	mgdEq          *ports.ManagedEquipmentPort
+	defaultSilence time.Duration
+	silences       map[string]time.Duration // by property name
+
+	mutex      sync.Mutex
+	heartbeats map[string]*domain.Heartbeat // by property name
+	timers     map[string]*time.Timer       // by property name
+	emit       func(tagChange domain.StdMessageStruct)
+}
+
+func NewValueChangeFilterHeartbeat(mgdEq *ports.ManagedEquipmentPort, configHook string) *valueChangeFilterHeartbeat {
+	s := valueChangeFilterHeartbeat{
+		mgdEq:      mgdEq,
+		silences:   map[string]time.Duration{},
+		heartbeats: map[string]*domain.Heartbeat{},
+		timers:     map[string]*time.Timer{},
+	}
+	s.SetConfigCategory(configHook)
+	s.SetLoggerConfigHook("valueChangeFilter")
+	return &s
+}
+
+func (s *valueChangeFilterHeartbeat) Initialize() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.stopTimers()
+	s.heartbeats = map[string]*domain.Heartbeat{}
+	var err error
+	if s.defaultSilence, err = getConfigDurationWithDefault(&s.ConfigurationEnabler, "MAX_SILENCE", 0); err != nil {
+		return err
+	}
+	if s.silences, err = getConfigDurationStanza(&s.ConfigurationEnabler, "PROPERTIES"); err != nil {
+		return err
+	}
+	s.LogDebugf("%s has a maximum silence of %s and %d property silences", (*s.mgdEq).GetEquipmentName(), s.defaultSilence, len(s.silences))
+	return nil
+}
+
+func (s *valueChangeFilterHeartbeat) PassValueThrough(tagChange domain.StdMessageStruct) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	silence, configured := s.silences[tagChange.ItemName]
+	if !configured {
+		silence = s.defaultSilence
+	}
+	if silence <= 0 {
+		return true, nil
+	}
+	heartbeat, exists := s.heartbeats[tagChange.ItemName]
+	if !exists {
+		heartbeat = &domain.Heartbeat{MaxSilence: silence}
+		s.heartbeats[tagChange.ItemName] = heartbeat
+	}
+	heartbeat.Seen(tagChange, time.Now())
+	s.armTimer(tagChange.ItemName, silence)
+	return true, nil
+}
+
+//armTimer (re)starts the heartbeat timer of the property; it must be called with the mutex held
+func (s *valueChangeFilterHeartbeat) armTimer(propName string, after time.Duration) {
+	if s.emit == nil {
+		return
+	}
+	if timer, exists := s.timers[propName]; exists {
+		timer.Stop()
+		timer.Reset(after)
+		return
+	}
+	s.timers[propName] = time.AfterFunc(after, func() { s.beat(propName) })
+}
+
+//beat passes the heartbeat of the property on if it is due, and waits for the next one
+func (s *valueChangeFilterHeartbeat) beat(propName string) {
+	s.mutex.Lock()
+	heartbeat, exists := s.heartbeats[propName]
+	if !exists || s.emit == nil {
+		s.mutex.Unlock()
+		return
+	}
+	now := time.Now()
+	change, due := heartbeat.Due(now)
+	next, _ := heartbeat.NextDue()
+	s.armTimer(propName, next.Sub(now))
+	emit := s.emit
+	s.mutex.Unlock()
+	if due {
+		emit(change)
+	}
+}
+
+func (s *valueChangeFilterHeartbeat) StartEmitting(emit func(tagChange domain.StdMessageStruct)) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.emit = emit
+	for propName, heartbeat := range s.heartbeats {
+		if next, seen := heartbeat.NextDue(); seen {
+			s.armTimer(propName, time.Until(next))
+		}
+	}
+}
+
+func (s *valueChangeFilterHeartbeat) StopEmitting() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.emit = nil
+	s.stopTimers()
+}
+
+//stopTimers must be called with the mutex held
+func (s *valueChangeFilterHeartbeat) stopTimers() {
+	for _, timer := range s.timers {
+		timer.Stop()
+	}
+	s.timers = map[string]*time.Timer{}
+}
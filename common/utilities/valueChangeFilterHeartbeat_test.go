@@ -0,0 +1,34 @@
+package utilities
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Spruik/libre-common/common/core/domain"
+)
+
+func TestValueChangeFilterHeartbeatRepeatsTheLastChange(t *testing.T) {
+	filter := NewValueChangeFilterHeartbeat(newTestManagedEquipment(), "heartbeatFilterTest")
+	if err := filter.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+	filter.silences["Temperature"] = 50 * time.Millisecond
+	emitted := make(chan domain.StdMessageStruct, 10)
+	filter.StartEmitting(func(tagChange domain.StdMessageStruct) { emitted <- tagChange })
+	defer filter.StopEmitting()
+
+	sent := time.Now()
+	if pass, err := filter.PassValueThrough(domain.StdMessageStruct{ItemName: "Temperature", ItemValue: 21.5, ChangedTimestamp: sent}); !pass || err != nil {
+		t.Fatalf("Expect every change to pass; got %t, %v", pass, err)
+	}
+	for beat := 1; beat <= 2; beat++ {
+		select {
+		case change := <-emitted:
+			if change.ItemValue != 21.5 || change.SubCategory != domain.HEARTBEAT_SUBCATEGORY || !change.ChangedTimestamp.After(sent) {
+				t.Errorf("Expect heartbeat %d to repeat the last change with a new time; got %+v", beat, change)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Expect heartbeat %d after the silence", beat)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package utilities
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/Spruik/libre-common/common/core/domain"
+	"github.com/Spruik/libre-common/common/core/ports"
+	libreConfig "github.com/Spruik/libre-configuration"
+	libreLogger "github.com/Spruik/libre-logging"
+)
+
+//valueChangeFilterQuality decides what happens to changes whose tag quality is not Good.  A dropped change leaves
+//  the property at its last value; a flagged change is passed on with its quality, which the property then carries,
+//  and a warning is logged when the property's changes become Bad or Uncertain.  The configuration may contain:
+//
+//  BAD: DROP                     (DROP, FLAG or PASS; DROP by default)
+//  UNCERTAIN: FLAG               (DROP, FLAG or PASS; FLAG by default)
+type valueChangeFilterQuality struct {
+	//inherit logging functions
+	libreLogger.LoggingEnabler
+
+	//inherit config functions
+	libreConfig.ConfigurationEnabler
+
+	mgdEq           *ports.ManagedEquipmentPort
+	badAction       string
+	uncertainAction string
+
+	mutex       sync.Mutex
+	lastQuality map[string]domain.TagQuality // by property name
+}
+
+const (
+	qualityActionDrop = "DROP"
+	qualityActionFlag = "FLAG"
+	qualityActionPass = "PASS"
+)
+
+func NewValueChangeFilterQuality(mgdEq *ports.ManagedEquipmentPort, configHook string) *valueChangeFilterQuality {
+	s := valueChangeFilterQuality{
+		mgdEq:           mgdEq,
+		badAction:       qualityActionDrop,
+		uncertainAction: qualityActionFlag,
+		lastQuality:     map[string]domain.TagQuality{},
+	}
+	s.SetConfigCategory(configHook)
+	s.SetLoggerConfigHook("valueChangeFilter")
+	return &s
+}
+
+func (s *valueChangeFilterQuality) Initialize() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.lastQuality = map[string]domain.TagQuality{}
+	var err error
+	if s.badAction, err = s.qualityAction("BAD", qualityActionDrop); err != nil {
+		return err
+	}
+	if s.uncertainAction, err = s.qualityAction("UNCERTAIN", qualityActionFlag); err != nil {
+		return err
+	}
+	s.LogDebugf("%s changes with a bad quality: %s, with an uncertain quality: %s", (*s.mgdEq).GetEquipmentName(), s.badAction, s.uncertainAction)
+	return nil
+}
+
+//qualityAction reads the action configured for a quality
+func (s *valueChangeFilterQuality) qualityAction(key string, defaultAction string) (string, error) {
+	text, _ := s.GetConfigItemWithDefault(key, defaultAction)
+	action := strings.ToUpper(strings.TrimSpace(text))
+	switch action {
+	case qualityActionDrop, qualityActionFlag, qualityActionPass:
+		return action, nil
+	}
+	return "", fmt.Errorf("%s: bad quality action '%s', expected DROP, FLAG or PASS", key, text)
+}
+
+func (s *valueChangeFilterQuality) PassValueThrough(tagChange domain.StdMessageStruct) (bool, error) {
+	quality := domain.TagQuality(tagChange.TagQuality)
+	action := qualityActionPass
+	switch {
+	case quality.IsBad():
+		action = s.badAction
+	case quality.IsUncertain():
+		action = s.uncertainAction
+	}
+	s.mutex.Lock()
+	last, seen := s.lastQuality[tagChange.ItemName]
+	s.lastQuality[tagChange.ItemName] = quality
+	s.mutex.Unlock()
+	changed := !seen || last.String() != quality.String()
+	switch {
+	case action == qualityActionFlag && changed:
+		s.LogWarnf("%s of %s has a %s quality (%d), value %v", tagChange.ItemName, (*s.mgdEq).GetEquipmentName(), quality, int(quality), tagChange.ItemValue)
+	case action == qualityActionDrop && changed:
+		s.LogWarnf("%s of %s has a %s quality (%d), dropping its changes", tagChange.ItemName, (*s.mgdEq).GetEquipmentName(), quality, int(quality))
+	case quality.IsGood() && seen && !last.IsGood():
+		s.LogInfof("%s of %s has a good quality again", tagChange.ItemName, (*s.mgdEq).GetEquipmentName())
+	}
+	return action != qualityActionDrop, nil
+}
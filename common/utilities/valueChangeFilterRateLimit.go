@@ -0,0 +1,125 @@
+package utilities
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Spruik/libre-common/common/core/domain"
+	"github.com/Spruik/libre-common/common/core/ports"
+	libreConfig "github.com/Spruik/libre-configuration"
+	libreLogger "github.com/Spruik/libre-logging"
+)
+
+//valueChangeFilterRateLimit passes a change of a property on at most once in its minimum interval.  The changes
+//  arriving within the interval are held back, and the last of them is passed on when the interval closes, so the
+//  latest value is never lost.  Holding back needs the filter to be started as an emitter by the equipment service
+//  manager; otherwise the changes within the interval are dropped.  The configuration may contain:
+//
+//  MIN_INTERVAL: 1s              (the interval of every property not listed; without it they all pass)
+//  PROPERTIES:
+//    Speed: 500ms
+//    Status: 0s                  (no limit)
+type valueChangeFilterRateLimit struct {
+	//inherit logging functions
+	libreLogger.LoggingEnabler
+
+	//inherit config functions
+	libreConfig.ConfigurationEnabler
+
+	mgdEq           *ports.ManagedEquipmentPort
+	defaultInterval time.Duration
+	intervals       map[string]time.Duration // by property name
+
+	mutex  sync.Mutex
+	limits map[string]*domain.RateLimit // by property name
+	timers map[string]*time.Timer       // the properties with a change held back
+	emit   func(tagChange domain.StdMessageStruct)
+}
+
+func NewValueChangeFilterRateLimit(mgdEq *ports.ManagedEquipmentPort, configHook string) *valueChangeFilterRateLimit {
+	s := valueChangeFilterRateLimit{
+		mgdEq:     mgdEq,
+		intervals: map[string]time.Duration{},
+		limits:    map[string]*domain.RateLimit{},
+		timers:    map[string]*time.Timer{},
+	}
+	s.SetConfigCategory(configHook)
+	s.SetLoggerConfigHook("valueChangeFilter")
+	return &s
+}
+
+func (s *valueChangeFilterRateLimit) Initialize() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.stopTimers()
+	s.limits = map[string]*domain.RateLimit{}
+	var err error
+	if s.defaultInterval, err = getConfigDurationWithDefault(&s.ConfigurationEnabler, "MIN_INTERVAL", 0); err != nil {
+		return err
+	}
+	if s.intervals, err = getConfigDurationStanza(&s.ConfigurationEnabler, "PROPERTIES"); err != nil {
+		return err
+	}
+	s.LogDebugf("%s has a minimum interval of %s and %d property intervals", (*s.mgdEq).GetEquipmentName(), s.defaultInterval, len(s.intervals))
+	return nil
+}
+
+func (s *valueChangeFilterRateLimit) PassValueThrough(tagChange domain.StdMessageStruct) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	interval, configured := s.intervals[tagChange.ItemName]
+	if !configured {
+		interval = s.defaultInterval
+	}
+	if interval <= 0 {
+		return true, nil
+	}
+	limit, exists := s.limits[tagChange.ItemName]
+	if !exists {
+		limit = &domain.RateLimit{MinInterval: interval}
+		s.limits[tagChange.ItemName] = limit
+	}
+	pass, due := limit.Offer(tagChange, time.Now())
+	if !pass && s.emit != nil && s.timers[tagChange.ItemName] == nil {
+		propName := tagChange.ItemName
+		s.timers[propName] = time.AfterFunc(time.Until(due), func() { s.emitHeld(propName) })
+	}
+	return pass, nil
+}
+
+//emitHeld passes on the change held back for the property when its interval closes
+func (s *valueChangeFilterRateLimit) emitHeld(propName string) {
+	s.mutex.Lock()
+	delete(s.timers, propName)
+	var change domain.StdMessageStruct
+	var due bool
+	if limit, exists := s.limits[propName]; exists {
+		change, due = limit.TakeDue(time.Now())
+	}
+	emit := s.emit
+	s.mutex.Unlock()
+	if due && emit != nil {
+		emit(change)
+	}
+}
+
+func (s *valueChangeFilterRateLimit) StartEmitting(emit func(tagChange domain.StdMessageStruct)) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.emit = emit
+}
+
+func (s *valueChangeFilterRateLimit) StopEmitting() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.emit = nil
+	s.stopTimers()
+}
+
+//stopTimers must be called with the mutex held
+func (s *valueChangeFilterRateLimit) stopTimers() {
+	for _, timer := range s.timers {
+		timer.Stop()
+	}
+	s.timers = map[string]*time.Timer{}
+}
@@ -0,0 +1,50 @@
+package utilities
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Spruik/libre-common/common/core/domain"
+)
+
+func TestValueChangeFilterRateLimitCoalescesHeldChanges(t *testing.T) {
+	filter := NewValueChangeFilterRateLimit(newTestManagedEquipment(), "rateLimitFilterTest")
+	if err := filter.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+	filter.intervals["Speed"] = 100 * time.Millisecond
+	emitted := make(chan domain.StdMessageStruct, 10)
+	filter.StartEmitting(func(tagChange domain.StdMessageStruct) { emitted <- tagChange })
+	defer filter.StopEmitting()
+
+	offer := func(property string, value interface{}) bool {
+		pass, err := filter.PassValueThrough(domain.StdMessageStruct{ItemName: property, ItemValue: value})
+		if err != nil {
+			t.Fatalf("PassValueThrough failed: %s", err)
+		}
+		return pass
+	}
+	if !offer("Speed", 1) {
+		t.Errorf("Expect the first change to pass")
+	}
+	if offer("Speed", 2) || offer("Speed", 3) {
+		t.Errorf("Expect the changes within the interval to be held back")
+	}
+	if !offer("Status", "Running") || !offer("Status", "Held") {
+		t.Errorf("Expect a property without an interval to pass every change")
+	}
+	//only the latest of the held changes is passed on when the interval closes
+	select {
+	case change := <-emitted:
+		if change.ItemName != "Speed" || change.ItemValue != 3 {
+			t.Errorf("Expect the last held change to be emitted; got %+v", change)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Expect the held change to be emitted when the interval closes")
+	}
+	select {
+	case change := <-emitted:
+		t.Errorf("Expect one emitted change for the interval; also got %+v", change)
+	case <-time.After(200 * time.Millisecond):
+	}
+}